@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+var (
+	// pagerDutyURL is the PagerDuty Events API v2 endpoint.
+	pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+	// opsgenieURL is the Opsgenie alert API endpoint.
+	opsgenieURL = "https://api.opsgenie.com/v2/alerts"
+)
+
+type (
+	// alerter is used to page someone when a zone keeps failing to sync.
+	alerter interface {
+		Trigger(zoneName string, summary string) error
+		Resolve(zoneName string) error
+	}
+
+	// alertState tracks consecutive failures by zone.
+	alertState struct {
+		lock     sync.Mutex
+		failures map[string]int
+		alerted  map[string]bool
+	}
+)
+
+// alerts is the alert state of this process.
+var alerts = &alertState{
+	failures: map[string]int{},
+	alerted:  map[string]bool{},
+}
+
+// alerters returns the configured alerters.
+func alerters() ([]alerter, error) {
+	result := []alerter{}
+
+	if cfg.Alert.PagerDuty != nil {
+		key, err := readSecret(cfg.Alert.PagerDuty.RoutingKeyEnv, cfg.Alert.PagerDuty.RoutingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &pagerDuty{routingKey: key})
+	}
+
+	if cfg.Alert.Opsgenie != nil {
+		key, err := readSecret(cfg.Alert.Opsgenie.APIKeyEnv, cfg.Alert.Opsgenie.APIKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &opsgenie{apiKey: key})
+	}
+
+	return result, nil
+}
+
+// observe will count consecutive failures for the zone of r, and trigger an
+// alert when the configured threshold is reached. The alert is resolved on
+// the next successful sync.
+func (a *alertState) observe(r *result) {
+	if r.Err == errAborted {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	threshold := cfg.Alert.After
+	if threshold <= 0 {
+		threshold = 3
+	}
+
+	var action func(alerter) error
+
+	if r.Err != nil {
+		a.failures[r.ZoneName]++
+
+		if a.failures[r.ZoneName] < threshold || a.alerted[r.ZoneName] {
+			return
+		}
+
+		summary := fmt.Sprintf("cfzone failed to sync %s %d times: %s", r.ZoneName, a.failures[r.ZoneName], redact(r.Err.Error()))
+		action = func(al alerter) error { return al.Trigger(r.ZoneName, summary) }
+		a.alerted[r.ZoneName] = true
+	} else {
+		a.failures[r.ZoneName] = 0
+
+		if !a.alerted[r.ZoneName] {
+			return
+		}
+
+		action = func(al alerter) error { return al.Resolve(r.ZoneName) }
+		a.alerted[r.ZoneName] = false
+	}
+
+	list, err := alerters()
+	if err != nil {
+		errorf("Error in alert configuration: %s", err.Error())
+		return
+	}
+
+	for _, al := range list {
+		err = action(al)
+		if err != nil {
+			errorf("Alert for '%s' failed: %s", r.ZoneName, err.Error())
+		}
+	}
+}
+
+// alertKey returns the key used to deduplicate alerts for zoneName.
+func alertKey(zoneName string) string {
+	return "cfzone-" + zoneName
+}
+
+// pagerDuty sends events to PagerDuty using the Events API v2.
+type pagerDuty struct {
+	routingKey string
+}
+
+// pagerDutyEvent is an event for the Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// pagerDutyPayload describes a triggered PagerDuty event.
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+// Trigger implements alerter.
+func (p *pagerDuty) Trigger(zoneName string, summary string) error {
+	host, _ := os.Hostname()
+
+	return postJSON(pagerDutyURL, nil, pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    alertKey(zoneName),
+		Payload: &pagerDutyPayload{
+			Summary:  summary,
+			Source:   host,
+			Severity: "error",
+		},
+	})
+}
+
+// Resolve implements alerter.
+func (p *pagerDuty) Resolve(zoneName string) error {
+	return postJSON(pagerDutyURL, nil, pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "resolve",
+		DedupKey:    alertKey(zoneName),
+	})
+}
+
+// opsgenie creates and closes Opsgenie alerts.
+type opsgenie struct {
+	apiKey string
+}
+
+// opsgenieAlert is an alert for the Opsgenie alert API.
+type opsgenieAlert struct {
+	Message string `json:"message"`
+	Alias   string `json:"alias"`
+}
+
+// header returns the authentication header for the Opsgenie API.
+func (o *opsgenie) header() http.Header {
+	return http.Header{"Authorization": []string{"GenieKey " + o.apiKey}}
+}
+
+// Trigger implements alerter.
+func (o *opsgenie) Trigger(zoneName string, summary string) error {
+	return postJSON(opsgenieURL, o.header(), opsgenieAlert{
+		Message: summary,
+		Alias:   alertKey(zoneName),
+	})
+}
+
+// Resolve implements alerter.
+func (o *opsgenie) Resolve(zoneName string) error {
+	u := opsgenieURL + "/" + url.PathEscape(alertKey(zoneName)) + "/close?identifierType=alias"
+
+	return postJSON(u, o.header(), struct{}{})
+}
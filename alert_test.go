@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type alertRequest struct {
+	path  string
+	auth  string
+	event map[string]interface{}
+}
+
+func alertServer() (*httptest.Server, *[]alertRequest) {
+	requests := []alertRequest{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := alertRequest{path: r.URL.RequestURI(), auth: r.Header.Get("Authorization")}
+		json.NewDecoder(r.Body).Decode(&req.event)
+
+		requests = append(requests, req)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	return ts, &requests
+}
+
+func TestAlertPagerDuty(t *testing.T) {
+	ts, requests := alertServer()
+	defer ts.Close()
+
+	pagerDutyURL = ts.URL
+	defer func() { pagerDutyURL = "https://events.pagerduty.com/v2/enqueue" }()
+
+	os.Setenv("CFZONE_TEST_ROUTING_KEY", "routingkey")
+	defer os.Unsetenv("CFZONE_TEST_ROUTING_KEY")
+
+	cfg = config{Alert: alertConfig{After: 2, PagerDuty: &pagerDutyConfig{RoutingKeyEnv: "CFZONE_TEST_ROUTING_KEY"}}}
+	defer func() { cfg = config{} }()
+
+	a := &alertState{failures: map[string]int{}, alerted: map[string]bool{}}
+	failed := &result{ZoneName: "example.com", Err: errors.New("broken")}
+
+	cases := []struct {
+		r        *result
+		requests int
+		action   string
+	}{
+		{failed, 0, ""},
+		{&result{ZoneName: "example.com", Err: errAborted}, 0, ""},
+		{failed, 1, "trigger"},
+		{failed, 1, ""},
+		{&result{ZoneName: "example.com", Plan: &plan{}}, 2, "resolve"},
+		{&result{ZoneName: "example.com", Plan: &plan{}}, 2, ""},
+	}
+
+	for i, c := range cases {
+		a.observe(c.r)
+
+		if len(*requests) != c.requests {
+			t.Fatalf("%d: observe() sent %d requests, expected %d", i, len(*requests), c.requests)
+		}
+
+		if c.action == "" {
+			continue
+		}
+
+		event := (*requests)[c.requests-1].event
+		if event["event_action"] != c.action || event["routing_key"] != "routingkey" || event["dedup_key"] != "cfzone-example.com" {
+			t.Errorf("%d: observe() sent wrong event %+v", i, event)
+		}
+	}
+}
+
+func TestAlertOpsgenie(t *testing.T) {
+	ts, requests := alertServer()
+	defer ts.Close()
+
+	opsgenieURL = ts.URL + "/v2/alerts"
+	defer func() { opsgenieURL = "https://api.opsgenie.com/v2/alerts" }()
+
+	o := &opsgenie{apiKey: "apikey"}
+
+	err := o.Trigger("example.com", "broken")
+	if err != nil {
+		t.Fatalf("Trigger() returned error: %s", err.Error())
+	}
+
+	err = o.Resolve("example.com")
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %s", err.Error())
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+
+	trigger, resolve := (*requests)[0], (*requests)[1]
+
+	if trigger.path != "/v2/alerts" || trigger.auth != "GenieKey apikey" || trigger.event["alias"] != "cfzone-example.com" || trigger.event["message"] != "broken" {
+		t.Errorf("Trigger() sent wrong request %+v", trigger)
+	}
+
+	if resolve.path != "/v2/alerts/cfzone-example.com/close?identifierType=alias" || resolve.auth != "GenieKey apikey" {
+		t.Errorf("Resolve() sent wrong request %+v", resolve)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// apexCNAME decides what to do about a CNAME at the zone apex. "warn" will
+// leave it to Cloudflare to flatten it, "flatten" will replace it with the A
+// and AAAA records of the target and "fail" will fail the sync.
+var apexCNAME = "warn"
+
+// handleApexCNAME will handle CNAME records in c at the apex of zoneName
+// according to -apexcname. Cloudflare flattens an apex CNAME, but refuses it
+// next to other records at the apex, which would otherwise fail halfway
+// through applying.
+func handleApexCNAME(c recordCollection, zoneName string) (recordCollection, error) {
+	result := c.Clone()
+	others := []string{}
+
+	for _, r := range c {
+		if strings.EqualFold(r.Name, zoneName) && r.Type != "CNAME" {
+			others = append(others, r.Type)
+		}
+	}
+
+	for _, r := range c {
+		if !strings.EqualFold(r.Name, zoneName) || r.Type != "CNAME" {
+			continue
+		}
+
+		switch apexCNAME {
+		case "fail":
+			return nil, fmt.Errorf("CNAME at the apex of '%s' is not allowed", zoneName)
+
+		case "flatten":
+			flattened, err := flatten(r)
+			if err != nil {
+				return nil, err
+			}
+
+			n, _ := result.Find(r, cfzone.FullMatch)
+			result.Remove(n)
+			result = append(result, flattened...)
+
+		default:
+			if len(others) > 0 {
+				return nil, fmt.Errorf("CNAME at the apex of '%s' coexists with %s record, use -apexcname flatten to replace it with A and AAAA records", zoneName, others[0])
+			}
+
+			warnf("CNAME at the apex of '%s' will be flattened by Cloudflare", zoneName)
+		}
+	}
+
+	return result, nil
+}
+
+// flatten will resolve the target of the CNAME record r, and return A and
+// AAAA records for the same name in its place.
+func flatten(r cloudflare.DNSRecord) (recordCollection, error) {
+	addrs, err := lookupHost(strings.TrimSuffix(r.Content, "."))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to flatten CNAME at '%s': %s", r.Name, err.Error())
+	}
+
+	sort.Strings(addrs)
+
+	result := recordCollection{}
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+
+		flattened := r
+		flattened.Type = "AAAA"
+		flattened.Content = ip.String()
+		if ip.To4() != nil {
+			flattened.Type = "A"
+		}
+
+		result = append(result, flattened)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("Unable to flatten CNAME at '%s': %s has no addresses", r.Name, r.Content)
+	}
+
+	return result, nil
+}
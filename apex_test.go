@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApexCNAME(t *testing.T) {
+	defer func(orig func(string) ([]string, error)) { lookupHost = orig }(lookupHost)
+	defer func() { apexCNAME = "warn" }()
+
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "lb.example.net":
+			return []string{"2001:db8::1", "192.0.2.2", "192.0.2.1"}, nil
+
+		case "empty.example.net":
+			return []string{}, nil
+		}
+
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	cases := []struct {
+		mode     string
+		zone     recordCollection
+		expected []string
+		err      string
+		warning  string
+	}{
+		{
+			"warn",
+			recordCollection{{Name: "www.example.com", Type: "A", Content: "192.0.2.3"}},
+			[]string{"www.example.com. 0 IN A     192.0.2.3"},
+			"",
+			"",
+		},
+		{
+			"warn",
+			recordCollection{{Name: "example.com", Type: "CNAME", Content: "lb.example.net"}},
+			[]string{"example.com. 0 IN CNAME lb.example.net"},
+			"",
+			"CNAME at the apex of 'example.com' will be flattened by Cloudflare",
+		},
+		{
+			"warn",
+			recordCollection{{Name: "example.com", Type: "CNAME", Content: "lb.example.net"}, {Name: "example.com", Type: "MX", Content: "mail.example.com"}},
+			nil,
+			"CNAME at the apex of 'example.com' coexists with MX record, use -apexcname flatten to replace it with A and AAAA records",
+			"",
+		},
+		{
+			"fail",
+			recordCollection{{Name: "example.com", Type: "CNAME", Content: "lb.example.net"}},
+			nil,
+			"CNAME at the apex of 'example.com' is not allowed",
+			"",
+		},
+		{
+			"fail",
+			recordCollection{{Name: "www.example.com", Type: "CNAME", Content: "lb.example.net"}},
+			[]string{"www.example.com. 0 IN CNAME lb.example.net"},
+			"",
+			"",
+		},
+		{
+			"flatten",
+			recordCollection{{Name: "example.com", Type: "CNAME", Content: "lb.example.net", TTL: 300}, {Name: "example.com", Type: "MX", Content: "mail.example.com"}},
+			[]string{"example.com. 0 IN MX    0 mail.example.com", "example.com. 300 IN A     192.0.2.1", "example.com. 300 IN A     192.0.2.2", "example.com. 300 IN AAAA  2001:db8::1"},
+			"",
+			"",
+		},
+		{
+			"flatten",
+			recordCollection{{Name: "example.com", Type: "CNAME", Content: "gone.example.net"}},
+			nil,
+			"Unable to flatten CNAME at 'example.com': lookup gone.example.net: no such host",
+			"",
+		},
+		{
+			"flatten",
+			recordCollection{{Name: "example.com", Type: "CNAME", Content: "empty.example.net"}},
+			nil,
+			"Unable to flatten CNAME at 'example.com': empty.example.net has no addresses",
+			"",
+		},
+	}
+
+	for i, in := range cases {
+		buf, restore := captureStderr(0)
+
+		apexCNAME = in.mode
+		result, err := handleApexCNAME(in.zone, "example.com")
+		restore()
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+
+		if got != in.err {
+			t.Errorf("%d: handleApexCNAME() returned error [%s], expected [%s]", i, got, in.err)
+		}
+
+		var lines []string
+		for _, r := range result {
+			lines = append(lines, recordLine(r))
+		}
+
+		if !reflect.DeepEqual(lines, in.expected) {
+			t.Errorf("%d: handleApexCNAME() returned %#v, expected %#v", i, lines, in.expected)
+		}
+
+		if !strings.Contains(buf.String(), in.warning) || (in.warning == "" && buf.Len() > 0) {
+			t.Errorf("%d: handleApexCNAME() wrote wrong warning [%s], expected [%s]", i, buf.String(), in.warning)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+var (
+	// auditPath is the path of an append-only JSON lines file receiving
+	// every planned and applied operation. Empty disables the audit log.
+	auditPath = ""
+
+	// actor identifies who is running cfzone. Empty means user@host.
+	actor = ""
+)
+
+// auditEntry is a single line in the audit log.
+type auditEntry struct {
+	Time      string                `json:"time"`
+	Zone      string                `json:"zone"`
+	Operation string                `json:"operation"`
+	Before    *cloudflare.DNSRecord `json:"before,omitempty"`
+	After     *cloudflare.DNSRecord `json:"after,omitempty"`
+	Actor     string                `json:"actor"`
+	Result    string                `json:"result"`
+	Error     string                `json:"error,omitempty"`
+}
+
+// currentActor returns the actor given by -actor, or user@host.
+func currentActor() string {
+	if actor != "" {
+		return actor
+	}
+
+	name := "unknown"
+	u, err := user.Current()
+	if err == nil {
+		name = u.Username
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return name + "@" + host
+}
+
+// writeAudit will append entry to the audit log.
+func writeAudit(entry auditEntry) error {
+	if auditPath == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(auditPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Error writing audit log: %s", err.Error())
+	}
+	defer f.Close()
+
+	// A single write per line, to avoid interleaving lines from multiple
+	// processes.
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("Error writing audit log: %s", err.Error())
+	}
+
+	return f.Sync()
+}
+
+// audit will log operation on r with result to the audit log. The record
+// state before the operation is found in the records fetched from Cloudflare.
+func (p *plan) audit(operation string, r cloudflare.DNSRecord, result string, err error) error {
+	// Nothing is changed when simulating.
+	if simulated != nil {
+		return nil
+	}
+
+	entry := auditEntry{
+		Time:      now().UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Zone:      p.ZoneName,
+		Operation: operation,
+		Actor:     currentActor(),
+		Result:    result,
+	}
+
+	if err != nil {
+		entry.Error = redact(err.Error())
+	}
+
+	if operation != "add" {
+		_, before := p.existing.Find(r, sameID)
+		if before == nil {
+			before = &r
+		}
+
+		entry.Before = before
+	}
+
+	if operation != "delete" {
+		entry.After = &r
+	}
+
+	return writeAudit(entry)
+}
+
+// auditPlanned will log all operations in the plan as planned.
+func (p *plan) auditPlanned() error {
+	for _, op := range []struct {
+		operation string
+		records   recordCollection
+	}{
+		{"delete", p.Deletes},
+		{"add", p.Adds},
+		{"update", p.Updates},
+	} {
+		for _, r := range op.records {
+			err := p.audit(op.operation, r, "planned", nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	auditPath = filepath.Join(dir, "audit.jsonl")
+	actor = "tester"
+	now = func() time.Time { return time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() {
+		auditPath = ""
+		actor = ""
+		now = time.Now
+	}()
+
+	// Existing content must be kept.
+	ioutil.WriteFile(auditPath, []byte("{}\n"), 0600)
+
+	p := &plan{
+		ZoneName: "example.com",
+		Deletes:  recordCollection{{ID: "1", Type: "A", Name: "old.example.com", Content: "192.0.2.1"}},
+		Adds:     recordCollection{{Type: "A", Name: "new.example.com", Content: "192.0.2.2"}},
+		Updates:  recordCollection{{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.4"}},
+		existing: recordCollection{
+			{ID: "1", Type: "A", Name: "old.example.com", Content: "192.0.2.1"},
+			{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.3"},
+		},
+	}
+
+	err = p.auditPlanned()
+	if err != nil {
+		t.Fatalf("auditPlanned() returned error: %s", err.Error())
+	}
+
+	err = p.applied("update", p.Updates[0], errors.New("rejected"))
+	if err == nil || err.Error() != "rejected" {
+		t.Errorf("applied() returned wrong error: %v", err)
+	}
+
+	f, _ := os.Open(auditPath)
+	defer f.Close()
+
+	entries := []auditEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			t.Fatalf("Audit log contains invalid line '%s': %s", scanner.Text(), err.Error())
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != 5 {
+		t.Fatalf("Audit log has %d lines, expected 5", len(entries))
+	}
+
+	cases := []struct {
+		operation string
+		before    string
+		after     string
+		result    string
+	}{
+		{"delete", "192.0.2.1", "", "planned"},
+		{"add", "", "192.0.2.2", "planned"},
+		{"update", "192.0.2.3", "192.0.2.4", "planned"},
+		{"update", "192.0.2.3", "192.0.2.4", "failed"},
+	}
+
+	content := func(r *cloudflare.DNSRecord) string {
+		if r == nil {
+			return ""
+		}
+
+		return r.Content
+	}
+
+	for i, c := range cases {
+		e := entries[i+1]
+
+		if e.Zone != "example.com" || e.Actor != "tester" || e.Time != "2019-01-01T12:00:00.000Z" {
+			t.Errorf("%d: Wrong audit entry %+v", i, e)
+		}
+
+		if e.Operation != c.operation || content(e.Before) != c.before || content(e.After) != c.after || e.Result != c.result {
+			t.Errorf("%d: Wrong audit entry %+v, expected %+v", i, e, c)
+		}
+	}
+
+	if entries[4].Error != "rejected" {
+		t.Errorf("Audit entry for failed operation has error '%s'", entries[4].Error)
+	}
+}
+
+func TestAuditDisabled(t *testing.T) {
+	p := &plan{Adds: recordCollection{{Type: "A"}}}
+
+	err := p.applied("add", p.Adds[0], nil)
+	if err != nil {
+		t.Errorf("applied() returned error without audit log: %s", err.Error())
+	}
+}
+
+func TestAuditUnwritable(t *testing.T) {
+	auditPath = "/non/existing/audit.jsonl"
+	defer func() { auditPath = "" }()
+
+	p := &plan{Adds: recordCollection{{Type: "A"}}}
+
+	if p.auditPlanned() == nil {
+		t.Errorf("auditPlanned() did not return error for unwritable audit log")
+	}
+
+	if p.applied("add", p.Adds[0], nil) == nil {
+		t.Errorf("applied() did not return error for unwritable audit log")
+	}
+}
+
+func TestCurrentActor(t *testing.T) {
+	if currentActor() == "" {
+		t.Errorf("currentActor() returned empty actor")
+	}
+
+	actor = "ci"
+	defer func() { actor = "" }()
+
+	if currentActor() != "ci" {
+		t.Errorf("currentActor() ignored -actor")
+	}
+}
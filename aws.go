@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anderskvist/cfzone/internal/awsv4"
+)
+
+// awsTimeout is the maximum duration of each request to AWS.
+const awsTimeout = 30 * time.Second
+
+var (
+	// awsSecret is the name or ARN of a Secrets Manager secret holding
+	// the API token.
+	awsSecret = ""
+
+	// ssmParameter is the name or ARN of an SSM Parameter Store
+	// parameter holding the API token.
+	ssmParameter = ""
+
+	// awsEndpoint is the endpoint of an AWS service in a region.
+	awsEndpoint = func(service string, region string) string {
+		return "https://" + service + "." + region + ".amazonaws.com/"
+	}
+
+	// containerCredentialsHost serves the credentials of ECS tasks for
+	// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI.
+	containerCredentialsHost = "http://169.254.170.2"
+
+	// instanceMetadataHost serves the credentials of the instance role on
+	// EC2.
+	instanceMetadataHost = "http://169.254.169.254"
+)
+
+// instanceMetadataTimeout is the maximum duration of getting credentials
+// from the instance metadata service. It's only reachable on EC2, and
+// requests elsewhere would otherwise hang until awsTimeout.
+const instanceMetadataTimeout = 2 * time.Second
+
+// awsCredentials are credentials for signing AWS requests.
+type awsCredentials struct {
+	AccessKey    string `json:"AccessKeyId"`
+	SecretKey    string `json:"SecretAccessKey"`
+	SessionToken string `json:"Token"`
+}
+
+// awsToken will read the API token from Secrets Manager or SSM Parameter
+// Store according to -awssecret and -ssmparameter.
+func awsToken() (string, error) {
+	transport, err := baseTransport()
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Transport: transport, Timeout: awsTimeout}
+
+	creds, err := loadAWSCredentials(client)
+	if err != nil {
+		return "", err
+	}
+
+	if awsSecret != "" {
+		var resp struct {
+			SecretString string `json:"SecretString"`
+		}
+
+		err = awsCall(client, creds, "secretsmanager", awsSecret, "secretsmanager.GetSecretValue", map[string]interface{}{"SecretId": awsSecret}, &resp)
+		if err != nil {
+			return "", err
+		}
+
+		return secretToken(resp.SecretString)
+	}
+
+	var resp struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+
+	err = awsCall(client, creds, "ssm", ssmParameter, "AmazonSSM.GetParameter", map[string]interface{}{"Name": ssmParameter, "WithDecryption": true}, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(resp.Parameter.Value), nil
+}
+
+// secretToken returns the token in a Secrets Manager secret, either the
+// whole secret or the "token" field of a secret holding key/value pairs.
+func secretToken(secret string) (string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(secret), "{") {
+		return strings.TrimSpace(secret), nil
+	}
+
+	var values map[string]interface{}
+
+	err := json.Unmarshal([]byte(secret), &values)
+	if err != nil {
+		return "", err
+	}
+
+	token, _ := values["token"].(string)
+	if token == "" {
+		return "", errors.New("No 'token' key in the secret")
+	}
+
+	return token, nil
+}
+
+// loadAWSCredentials returns credentials from the first source found, in the
+// order used by the AWS SDKs: the standard environment variables, the shared
+// credentials file, a web identity token like on EKS with IAM roles for
+// service accounts, the ECS container credentials endpoint and the instance
+// role on EC2.
+func loadAWSCredentials(client *http.Client) (awsCredentials, error) {
+	creds, err := findAWSCredentials(client)
+	if err != nil {
+		return creds, err
+	}
+
+	registerSecret(creds.SecretKey)
+	registerSecret(creds.SessionToken)
+
+	return creds, nil
+}
+
+// findAWSCredentials is loadAWSCredentials without registering the secrets.
+func findAWSCredentials(client *http.Client) (awsCredentials, error) {
+	creds := awsCredentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if creds.AccessKey != "" && creds.SecretKey != "" {
+		return creds, nil
+	}
+
+	creds, found, err := sharedAWSCredentials()
+	if err != nil || found {
+		return creds, err
+	}
+
+	if os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != "" {
+		return webIdentityCredentials(client)
+	}
+
+	u := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		u = containerCredentialsHost + relative
+	}
+
+	if u != "" {
+		return containerCredentials(client, u)
+	}
+
+	creds, err = instanceCredentials(client)
+	if err != nil {
+		return creds, fmt.Errorf("No AWS credentials found, and no instance role: %s", err.Error())
+	}
+
+	return creds, nil
+}
+
+// sharedAWSCredentials returns the credentials of the profile given by
+// AWS_PROFILE, or the default profile, from the shared credentials file.
+// found is false if there's no such file or profile.
+func sharedAWSCredentials() (creds awsCredentials, found bool, err error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return creds, false, nil
+		}
+
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return creds, false, nil
+	}
+
+	if err != nil {
+		return creds, false, err
+	}
+
+	section := ""
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if section != profile || len(kv) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(kv[1])
+
+		switch strings.TrimSpace(kv[0]) {
+		case "aws_access_key_id":
+			creds.AccessKey = value
+
+		case "aws_secret_access_key":
+			creds.SecretKey = value
+
+		case "aws_session_token":
+			creds.SessionToken = value
+		}
+	}
+
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return creds, false, nil
+	}
+
+	return creds, true, nil
+}
+
+// webIdentityCredentials returns credentials for the role given by
+// AWS_ROLE_ARN, assumed using the token in AWS_WEB_IDENTITY_TOKEN_FILE.
+func webIdentityCredentials(client *http.Client) (awsCredentials, error) {
+	var creds awsCredentials
+
+	token, err := ioutil.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return creds, err
+	}
+
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "cfzone"
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+
+	resp, err := client.PostForm(awsEndpoint("sts", awsRegion("")), form)
+	if err != nil {
+		return creds, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return creds, fmt.Errorf("Assuming %s returned %s", os.Getenv("AWS_ROLE_ARN"), resp.Status)
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return creds, err
+	}
+
+	creds.AccessKey = result.Credentials.AccessKeyID
+	creds.SecretKey = result.Credentials.SecretAccessKey
+	creds.SessionToken = result.Credentials.SessionToken
+
+	return creds, nil
+}
+
+// containerCredentials returns the credentials of an ECS task from u.
+func containerCredentials(client *http.Client, u string) (awsCredentials, error) {
+	var creds awsCredentials
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return creds, err
+	}
+
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return creds, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return creds, fmt.Errorf("Container credentials endpoint returned %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&creds)
+
+	return creds, err
+}
+
+// instanceCredentials returns the credentials of the instance role from the
+// EC2 instance metadata service, using IMDSv2.
+func instanceCredentials(client *http.Client) (awsCredentials, error) {
+	var creds awsCredentials
+
+	ctx, cancel := context.WithTimeout(context.Background(), instanceMetadataTimeout)
+	defer cancel()
+
+	get := func(method string, path string, header string, value string) ([]byte, error) {
+		req, err := http.NewRequest(method, instanceMetadataHost+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(header, value)
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s %s returned %s", method, path, resp.Status)
+		}
+
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	token, err := get("PUT", "/latest/api/token", "X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	if err != nil {
+		return creds, err
+	}
+
+	roles, err := get("GET", "/latest/meta-data/iam/security-credentials/", "X-aws-ec2-metadata-token", string(token))
+	if err != nil {
+		return creds, err
+	}
+
+	role := strings.TrimSpace(strings.Split(string(roles), "\n")[0])
+	if role == "" {
+		return creds, errors.New("No instance role")
+	}
+
+	b, err := get("GET", "/latest/meta-data/iam/security-credentials/"+role, "X-aws-ec2-metadata-token", string(token))
+	if err != nil {
+		return creds, err
+	}
+
+	err = json.Unmarshal(b, &creds)
+
+	return creds, err
+}
+
+// awsRegion returns the region of the resource id, taken from the ARN or
+// from the environment.
+func awsRegion(id string) string {
+	parts := strings.Split(id, ":")
+	if len(parts) > 4 && parts[0] == "arn" && parts[3] != "" {
+		return parts[3]
+	}
+
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+
+	if region := os.Getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+
+	return "us-east-1"
+}
+
+// awsCall will call target of an AWS JSON API with input, and decode the
+// response into output.
+func awsCall(client *http.Client, creds awsCredentials, service string, id string, target string, input interface{}, output interface{}) error {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	region := awsRegion(id)
+
+	req, err := http.NewRequest("POST", awsEndpoint(service, region), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	awsv4.Sign(req, payload, creds.AccessKey, creds.SecretKey, region, service, time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+
+		json.Unmarshal(b, &failure)
+
+		return fmt.Errorf("%s returned %s for '%s': %s %s", service, resp.Status, id, failure.Type, failure.Message)
+	}
+
+	return json.Unmarshal(b, output)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAWSToken(t *testing.T) {
+	var regions []string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/creds" {
+			if r.Header.Get("Authorization") != "container-auth" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Write([]byte(`{"AccessKeyId":"AKIDCONTAINER","SecretAccessKey":"secret","Token":"session"}`))
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID") || !strings.Contains(auth, "x-amz-target") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		regions = append(regions, strings.Split(auth, "/")[2])
+
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			switch input["SecretId"] {
+			case "cfzone/plain", "arn:aws:secretsmanager:eu-west-1:123456789012:secret:cfzone/plain":
+				w.Write([]byte(`{"SecretString":"plain-token\n"}`))
+				return
+			case "cfzone/json":
+				w.Write([]byte(`{"SecretString":"{\"token\":\"json-token\",\"email\":\"x\"}"}`))
+				return
+			}
+
+		case "AmazonSSM.GetParameter":
+			if input["Name"] == "/cfzone/token" && input["WithDecryption"] == true {
+				w.Write([]byte(`{"Parameter":{"Value":"ssm-token"}}`))
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+	}))
+	defer s.Close()
+
+	defer func(f func(string, string) string, host string) {
+		awsEndpoint = f
+		containerCredentialsHost = host
+		awsSecret = ""
+		ssmParameter = ""
+	}(awsEndpoint, containerCredentialsHost)
+
+	awsEndpoint = func(service string, region string) string {
+		return s.URL + "/" + service
+	}
+	containerCredentialsHost = s.URL
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("AWS_REGION", "us-west-2")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	defer os.Unsetenv("AWS_REGION")
+
+	cases := []struct {
+		secret    string
+		parameter string
+		expected  string
+		region    string
+		err       bool
+	}{
+		{"cfzone/plain", "", "plain-token", "us-west-2", false},
+		{"arn:aws:secretsmanager:eu-west-1:123456789012:secret:cfzone/plain", "", "plain-token", "eu-west-1", false},
+		{"cfzone/json", "", "json-token", "us-west-2", false},
+		{"cfzone/missing", "", "", "", true},
+		{"", "/cfzone/token", "ssm-token", "us-west-2", false},
+		{"", "/cfzone/missing", "", "", true},
+	}
+
+	for i, c := range cases {
+		awsSecret, ssmParameter = c.secret, c.parameter
+		regions = nil
+
+		token, err := awsToken()
+		if c.err {
+			if err == nil {
+				t.Errorf("%d awsToken() didn't fail", i)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d awsToken() failed: %s", i, err.Error())
+			continue
+		}
+
+		if token != c.expected {
+			t.Errorf("%d awsToken() returned '%s', expected '%s'", i, token, c.expected)
+		}
+
+		if len(regions) != 1 || regions[0] != c.region {
+			t.Errorf("%d awsToken() used regions %v, expected %s", i, regions, c.region)
+		}
+	}
+
+	// Credentials of an ECS task.
+	os.Unsetenv("AWS_ACCESS_KEY_ID")
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent/credentials")
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+	os.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "/creds")
+	os.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "container-auth")
+	defer os.Unsetenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+	defer os.Unsetenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+
+	awsSecret, ssmParameter = "", "/cfzone/token"
+
+	token, err := awsToken()
+	if err != nil || token != "ssm-token" {
+		t.Errorf("awsToken() with container credentials returned '%s', %v", token, err)
+	}
+}
+
+func TestLoadAWSCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/sts":
+			r.ParseForm()
+			if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("WebIdentityToken") != "jwt" || r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/cfzone" || r.Form.Get("RoleSessionName") != "cfzone" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials><AccessKeyId>AKIDWEB</AccessKeyId><SecretAccessKey>web-secret</SecretAccessKey><SessionToken>web-session</SessionToken></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+
+		case r.URL.Path == "/latest/api/token" && r.Method == "PUT":
+			w.Write([]byte("imds-token"))
+
+		case r.Header.Get("X-aws-ec2-metadata-token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("cfzone-role"))
+
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/cfzone-role":
+			w.Write([]byte(`{"AccessKeyId":"AKIDINSTANCE","SecretAccessKey":"instance-secret","Token":"instance-session"}`))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	defer func(f func(string, string) string, host string) {
+		awsEndpoint = f
+		instanceMetadataHost = host
+	}(awsEndpoint, instanceMetadataHost)
+
+	awsEndpoint = func(service string, region string) string {
+		return s.URL + "/" + service
+	}
+	instanceMetadataHost = s.URL
+
+	credentials := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(credentials, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = default-secret\n\n[cfzone]\naws_access_key_id=AKIDPROFILE\naws_secret_access_key=profile-secret\naws_session_token=profile-session\n"), 0600)
+
+	token := filepath.Join(dir, "token")
+	ioutil.WriteFile(token, []byte("jwt\n"), 0600)
+
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_SHARED_CREDENTIALS_FILE", "AWS_PROFILE", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	cases := []struct {
+		env      map[string]string
+		expected awsCredentials
+	}{
+		{
+			map[string]string{"AWS_SHARED_CREDENTIALS_FILE": credentials},
+			awsCredentials{AccessKey: "AKIDDEFAULT", SecretKey: "default-secret"},
+		},
+		{
+			map[string]string{"AWS_SHARED_CREDENTIALS_FILE": credentials, "AWS_PROFILE": "cfzone"},
+			awsCredentials{AccessKey: "AKIDPROFILE", SecretKey: "profile-secret", SessionToken: "profile-session"},
+		},
+		{
+			map[string]string{"AWS_SHARED_CREDENTIALS_FILE": credentials, "AWS_PROFILE": "cfzone", "AWS_ACCESS_KEY_ID": "AKIDENV", "AWS_SECRET_ACCESS_KEY": "env-secret"},
+			awsCredentials{AccessKey: "AKIDENV", SecretKey: "env-secret"},
+		},
+		{
+			map[string]string{"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "missing"), "AWS_WEB_IDENTITY_TOKEN_FILE": token, "AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/cfzone"},
+			awsCredentials{AccessKey: "AKIDWEB", SecretKey: "web-secret", SessionToken: "web-session"},
+		},
+		{
+			map[string]string{"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "missing")},
+			awsCredentials{AccessKey: "AKIDINSTANCE", SecretKey: "instance-secret", SessionToken: "instance-session"},
+		},
+	}
+
+	for i, c := range cases {
+		for name, value := range c.env {
+			os.Setenv(name, value)
+		}
+
+		creds, err := loadAWSCredentials(http.DefaultClient)
+		if err != nil {
+			t.Errorf("%d loadAWSCredentials() failed: %s", i, err.Error())
+		} else if creds != c.expected {
+			t.Errorf("%d loadAWSCredentials() returned %+v, expected %+v", i, creds, c.expected)
+		}
+
+		for name := range c.env {
+			os.Unsetenv(name)
+		}
+	}
+
+	// No instance role.
+	instanceMetadataHost = s.URL + "/missing"
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+
+	_, err = loadAWSCredentials(http.DefaultClient)
+	if err == nil {
+		t.Errorf("loadAWSCredentials() without credentials didn't fail")
+	}
+}
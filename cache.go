@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// noCache disables caching of remote records between syncs.
+var noCache bool
+
+// cacheMaxAge is how long cached records are used. Editing records doesn't
+// change the modification time of a Cloudflare zone, so changes made outside
+// of cfzone are only seen when the cached records expire.
+const cacheMaxAge = 5 * time.Minute
+
+// zoneModifier is implemented by providers able to tell when a zone was last
+// modified, like Cloudflare. Only zones from such providers are cached.
+type zoneModifier interface {
+	ZoneModified(zoneName string) (time.Time, error)
+}
+
+// cachedZone is the remote state of a zone as fetched at modified.
+type cachedZone struct {
+	modified time.Time
+	fetched  time.Time
+	id       string
+	records  recordCollection
+}
+
+// remoteCache keeps the records fetched from providers between syncs, so
+// frequent syncs in watch or daemon mode don't have to download all records
+// every time.
+type remoteCache struct {
+	lock  sync.Mutex
+	zones map[string]cachedZone
+}
+
+// zoneCache is the cache used by fetchZone.
+var zoneCache = &remoteCache{zones: map[string]cachedZone{}}
+
+// get returns the cached state of zoneName if it was fetched at modified,
+// and not more than cacheMaxAge ago.
+func (c *remoteCache) get(zoneName string, modified time.Time) (string, recordCollection, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	z, found := c.zones[providerName+"/"+zoneName]
+	if !found || !z.modified.Equal(modified) || now().Sub(z.fetched) > cacheMaxAge {
+		return "", nil, false
+	}
+
+	return z.id, z.records.Clone(), true
+}
+
+// put stores the state of zoneName as fetched at modified.
+func (c *remoteCache) put(zoneName string, modified time.Time, id string, records recordCollection) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.zones[providerName+"/"+zoneName] = cachedZone{
+		modified: modified,
+		fetched:  now(),
+		id:       id,
+		records:  records.Clone(),
+	}
+}
+
+// invalidate removes zoneName from the cache. It must be called when the
+// zone is changed by us, the modification time can't be trusted to change
+// within the same second.
+func (c *remoteCache) invalidate(zoneName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.zones, providerName+"/"+zoneName)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// modifiedProvider is a fakeProvider with a modification time.
+type modifiedProvider struct {
+	fakeProvider
+	modified time.Time
+	lists    int
+}
+
+func (m *modifiedProvider) ZoneModified(zoneName string) (time.Time, error) {
+	return m.modified, nil
+}
+
+func (m *modifiedProvider) List(zoneName string) (recordCollection, error) {
+	m.lists++
+	return m.fakeProvider.List(zoneName)
+}
+
+func TestFetchZoneCache(t *testing.T) {
+	defer func() { zoneCache = &remoteCache{zones: map[string]cachedZone{}} }()
+
+	m := &modifiedProvider{
+		fakeProvider: fakeProvider{records: recordCollection{{ID: "1", Name: "a.example.com", Type: "A", Content: "192.0.2.1"}}},
+		modified:     time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	fetch := func() recordCollection {
+		_, records, err := fetchZone(m, "example.com")
+		if err != nil {
+			t.Fatalf("fetchZone() failed: %s", err.Error())
+		}
+
+		return records
+	}
+
+	fetch()
+	records := fetch()
+	if m.lists != 1 || len(records) != 1 {
+		t.Fatalf("fetchZone() listed records %d times, expected 1", m.lists)
+	}
+
+	// A modified zone must be fetched again.
+	m.modified = m.modified.Add(time.Second)
+	fetch()
+	if m.lists != 2 {
+		t.Fatalf("fetchZone() didn't fetch a modified zone")
+	}
+
+	// Applying changes invalidates the cache.
+	p := &plan{ZoneName: "example.com", Adds: recordCollection{{Name: "b.example.com", Type: "A", Content: "192.0.2.2"}}}
+	p.Apply(m, ioutil.Discard)
+	fetch()
+	if m.lists != 3 {
+		t.Fatalf("fetchZone() used cached records after applying changes")
+	}
+
+	noCache = true
+	defer func() { noCache = false }()
+
+	fetch()
+	if m.lists != 4 {
+		t.Fatalf("fetchZone() used cached records with -nocache")
+	}
+}
+
+func TestFetchZoneCacheExpires(t *testing.T) {
+	defer func() { zoneCache = &remoteCache{zones: map[string]cachedZone{}} }()
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	m := &modifiedProvider{modified: start}
+
+	fetchZone(m, "example.com")
+	fetchZone(m, "example.com")
+	if m.lists != 1 {
+		t.Fatalf("fetchZone() listed records %d times, expected 1", m.lists)
+	}
+
+	// Records edited at Cloudflare don't change the modification time, the
+	// cached records must expire.
+	now = func() time.Time { return start.Add(cacheMaxAge + time.Second) }
+	fetchZone(m, "example.com")
+	if m.lists != 2 {
+		t.Fatalf("fetchZone() used expired cached records")
+	}
+}
+
+func TestNewPlanRelistsCached(t *testing.T) {
+	defer func() { zoneCache = &remoteCache{zones: map[string]cachedZone{}} }()
+
+	m := &modifiedProvider{
+		fakeProvider: fakeProvider{records: recordCollection{{ID: "1", Name: "a.example.com", Type: "A", Content: "192.0.2.1", TTL: 300}}},
+		modified:     time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	file := recordCollection{{Name: "a.example.com", Type: "A", Content: "192.0.2.2", TTL: 300}}
+
+	fetchZone(m, "example.com")
+
+	// The record was recreated at Cloudflare without changing the
+	// modification time, and the cached records are stale.
+	m.records[0].ID = "2"
+
+	p, err := newPlan(m, "example.com", file)
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if m.lists != 2 {
+		t.Fatalf("newPlan() listed records %d times, expected 2", m.lists)
+	}
+
+	if len(p.Updates) != 1 || p.Updates[0].ID != "2" {
+		t.Errorf("newPlan() planned changes from stale records: %+v", p.Updates)
+	}
+}
+
+func TestNewPlanRelistsCachedWarnsOnce(t *testing.T) {
+	defer func() { zoneCache = &remoteCache{zones: map[string]cachedZone{}} }()
+
+	m := &modifiedProvider{
+		fakeProvider: fakeProvider{records: recordCollection{
+			{ID: "1", Name: "a.example.com", Type: "A", Content: "192.0.2.1", TTL: 300},
+			{ID: "2", Name: "a.example.com", Type: "A", Content: "192.0.2.1", TTL: 300},
+		}},
+		modified: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	fetchZone(m, "example.com")
+
+	buf, restore := captureStderr(0)
+	defer restore()
+
+	_, err := newPlan(m, "example.com", recordCollection{{Name: "a.example.com", Type: "A", Content: "192.0.2.1", TTL: 300}})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if m.lists != 2 {
+		t.Fatalf("newPlan() listed records %d times, expected 2", m.lists)
+	}
+
+	if n := strings.Count(buf.String(), "Found 1 duplicate record(s)"); n != 1 {
+		t.Errorf("newPlan() warned about duplicates %d times, expected once:\n%s", n, buf.String())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+var (
+	// changelogPath is where to write a description of applied changes.
+	// "-" means stdout, empty disables the changelog.
+	changelogPath = ""
+
+	// changelogStarted is true when the changelog has been written to
+	// during this run. The file is truncated on the first write, and
+	// appended to for the following zones.
+	changelogStarted = false
+)
+
+// describeTypes will describe the record types in c, like "3 A records and
+// 1 MX record".
+func describeTypes(c recordCollection) string {
+	counts := map[string]int{}
+	for _, r := range c {
+		counts[r.Type]++
+	}
+
+	types := []string{}
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	parts := []string{}
+	for _, t := range types {
+		noun := "records"
+		if counts[t] == 1 {
+			noun = "record"
+		}
+
+		parts = append(parts, fmt.Sprintf("%d %s %s", counts[t], t, noun))
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+// describeRecord will describe r in a single line, like "A record
+// www.example.com (192.0.2.1)".
+func describeRecord(r cloudflare.DNSRecord) string {
+	content := r.Content
+	if r.Type == "MX" {
+		content = fmt.Sprintf("%d %s", cloudflare.Uint16(r.Priority), r.Content)
+	}
+
+	return fmt.Sprintf("%s record %s (%s)", r.Type, r.Name, content)
+}
+
+// Changelog returns a human readable description of the changes in the plan,
+// suitable for a commit message or release note. The first line is a short
+// summary.
+func (p *plan) Changelog() string {
+	actions := []string{}
+	if len(p.Adds) > 0 {
+		actions = append(actions, "add "+describeTypes(p.Adds))
+	}
+
+	if len(p.Deletes) > 0 {
+		actions = append(actions, "remove "+describeTypes(p.Deletes))
+	}
+
+	if len(p.Updates) > 0 {
+		actions = append(actions, "update "+describeTypes(p.Updates))
+	}
+
+	if len(actions) == 0 {
+		return fmt.Sprintf("%s: No changes\n", p.ZoneName)
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: %s\n\n", p.ZoneName, strings.Join(actions, ", "))
+
+	for _, r := range p.Adds {
+		fmt.Fprintf(&b, "- Added %s\n", describeRecord(r))
+	}
+
+	for _, r := range p.Deletes {
+		fmt.Fprintf(&b, "- Removed %s\n", describeRecord(r))
+	}
+
+	for _, r := range p.Updates {
+		fmt.Fprintf(&b, "- Updated %s\n", describeRecord(r))
+	}
+
+	return b.String()
+}
+
+// writeChangelog will write the changelog for p to changelogPath.
+func writeChangelog(p *plan) error {
+	// Simulated changes must not end up in the changelog.
+	if simulated != nil {
+		return nil
+	}
+
+	var w io.Writer = stdout
+
+	if changelogPath != "-" {
+		mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if changelogStarted {
+			mode = os.O_WRONLY | os.O_APPEND
+		}
+
+		f, err := os.OpenFile(changelogPath, mode, 0644)
+		if err != nil {
+			return fmt.Errorf("Error writing changelog: %s", err.Error())
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	if changelogStarted {
+		fmt.Fprintf(w, "\n")
+	}
+	changelogStarted = true
+
+	_, err := io.WriteString(w, p.Changelog())
+
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestDescribeTypes(t *testing.T) {
+	cases := []struct {
+		records  recordCollection
+		expected string
+	}{
+		{recordCollection{{Type: "A"}}, "1 A record"},
+		{recordCollection{{Type: "A"}, {Type: "A"}}, "2 A records"},
+		{recordCollection{{Type: "MX"}, {Type: "A"}}, "1 A record and 1 MX record"},
+		{recordCollection{{Type: "TXT"}, {Type: "MX"}, {Type: "A"}, {Type: "A"}}, "2 A records, 1 MX record and 1 TXT record"},
+	}
+
+	for i, c := range cases {
+		result := describeTypes(c.records)
+		if result != c.expected {
+			t.Errorf("%d: describeTypes() returned '%s', expected '%s'", i, result, c.expected)
+		}
+	}
+}
+
+func TestChangelog(t *testing.T) {
+	p := &plan{
+		ZoneName: "example.com",
+		Adds: recordCollection{
+			{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{Type: "A", Name: "web.example.com", Content: "192.0.2.2"},
+		},
+		Deletes: recordCollection{
+			{Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10)},
+		},
+		Updates: recordCollection{
+			{Type: "CNAME", Name: "ftp.example.com", Content: "www.example.com"},
+		},
+	}
+
+	expected := `example.com: add 2 A records, remove 1 MX record, update 1 CNAME record
+
+- Added A record www.example.com (192.0.2.1)
+- Added A record web.example.com (192.0.2.2)
+- Removed MX record example.com (10 mail.example.com)
+- Updated CNAME record ftp.example.com (www.example.com)
+`
+
+	result := p.Changelog()
+	if result != expected {
+		t.Errorf("Changelog() returned [%s], expected [%s]", result, expected)
+	}
+
+	empty := (&plan{ZoneName: "example.com"}).Changelog()
+	if empty != "example.com: No changes\n" {
+		t.Errorf("Changelog() returned [%s] for empty plan", empty)
+	}
+}
+
+func TestWriteChangelog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	changelogPath = filepath.Join(dir, "changelog")
+	defer func() {
+		changelogPath = ""
+		changelogStarted = false
+	}()
+
+	// Old content should be replaced on the first write.
+	ioutil.WriteFile(changelogPath, []byte("stale\n"), 0644)
+
+	first := &plan{ZoneName: "example.com", Adds: recordCollection{{Type: "A", Name: "example.com", Content: "192.0.2.1"}}}
+	second := &plan{ZoneName: "example.org", Adds: recordCollection{{Type: "A", Name: "example.org", Content: "192.0.2.1"}}}
+
+	for _, p := range []*plan{first, second} {
+		err = writeChangelog(p)
+		if err != nil {
+			t.Fatalf("writeChangelog() returned error: %s", err.Error())
+		}
+	}
+
+	b, _ := ioutil.ReadFile(changelogPath)
+	expected := first.Changelog() + "\n" + second.Changelog()
+	if string(b) != expected {
+		t.Errorf("writeChangelog() wrote [%s], expected [%s]", string(b), expected)
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	changelogPath = "-"
+	changelogStarted = false
+	writeChangelog(first)
+
+	if out.String() != first.Changelog() {
+		t.Errorf("writeChangelog() wrote [%s] to stdout", out.String())
+	}
+}
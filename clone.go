@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// renameRecords returns copies of records in the zone from with names moved
+// to the zone to. Targets inside from are moved as well for every type with a
+// target, like CNAME, MX, NS, PTR and SRV, so the copy doesn't point back at
+// the original zone.
+func renameRecords(records recordCollection, from, to string) recordCollection {
+	rename := func(name string) string {
+		if !inZone(name, from) {
+			return name
+		}
+
+		trimmed := strings.TrimSuffix(name, ".")
+		prefix := trimmed[:len(trimmed)-len(strings.TrimSuffix(from, "."))]
+
+		return prefix + strings.TrimSuffix(to, ".") + name[len(trimmed):]
+	}
+
+	result := make(recordCollection, 0, len(records))
+	for _, r := range records {
+		r.ID = ""
+		r.ZoneID = ""
+		r.ZoneName = ""
+		r.Name = rename(r.Name)
+
+		// The target is the last field, SRV content is "weight port
+		// target".
+		fields := strings.Fields(cfzone.CanonicalContent(r))
+		if cfzone.HasTarget(r.Type) && len(fields) > 0 {
+			fields[len(fields)-1] = rename(fields[len(fields)-1])
+			r.Content = strings.Join(fields, " ")
+		}
+
+		result = append(result, r)
+	}
+
+	return result
+}
+
+// runClone will copy the records of one zone to another, moving names from
+// the source zone to the destination zone.
+func runClone(args []string) {
+	flagset := flag.NewFlagSet("clone", flag.ContinueOnError)
+	flagset.SetOutput(stderr)
+	config := flagset.String("config", "", "Path to configuration file (default "+defaultConfigPath()+")")
+	flagset.BoolVar(&leaveUnknown, "leaveunknown", false, "Keep records in the destination zone missing in the source zone")
+	flagset.BoolVar(&yes, "yes", false, "Don't ask before cloning")
+
+	err := flagset.Parse(args)
+	if err != nil || flagset.NArg() != 2 {
+		errorf("Usage: cfzone clone [-leaveunknown] [-yes] <source zone> <destination zone>")
+		exit(1)
+	}
+
+	src, dst := flagset.Arg(0), flagset.Arg(1)
+	if inZone(src, dst) || inZone(dst, src) {
+		errorf("%s and %s can't be inside each other", src, dst)
+		exit(1)
+	}
+
+	readCommandConfig(*config)
+	setupCommandFlags(flagset)
+	setupCredentials()
+
+	provider, err := newProvider()
+	if err != nil {
+		errorf("Can't connect to Cloudflare: %s", err.Error())
+		exit(1)
+	}
+
+	records, err := provider.List(src)
+	if err != nil {
+		errorf("%s", err.Error())
+		exit(1)
+	}
+
+	p, err := newPlan(provider, dst, renameRecords(records.Canonical(), src, dst))
+	if err != nil {
+		errorf("%s", err.Error())
+		exit(1)
+	}
+
+	err = confirmApply(p, provider, bufio.NewReader(stdin))
+	if err == errAborted {
+		return
+	}
+
+	if err != nil {
+		errorf("Can't clone %s to %s: %s", src, dst, err.Error())
+		exit(1)
+	}
+}
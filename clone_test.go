@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+func TestRenameRecords(t *testing.T) {
+	records := recordCollection{
+		{ID: "1", Type: "A", Name: "example.com", Content: "192.0.2.1", ZoneName: "example.com"},
+		{ID: "2", Type: "CNAME", Name: "www.example.com", Content: "example.com"},
+		{ID: "3", Type: "CNAME", Name: "cdn.example.com", Content: "cdn.example.net"},
+		{ID: "4", Type: "MX", Name: "example.com", Content: "mail.example.com"},
+		{ID: "5", Type: "TXT", Name: "_dmarc.example.com", Content: "mailto:dmarc@example.com"},
+		{ID: "6", Type: "A", Name: "notexample.com", Content: "192.0.2.2"},
+		{ID: "7", Type: "SRV", Name: "_sip._tcp.example.com", Content: "60 5060 SIP.Example.com."},
+		{ID: "8", Type: "PTR", Name: "1.2.0.192.in-addr.example.com", Content: "host.example.com."},
+		{ID: "9", Type: "SRV", Name: "_xmpp._tcp.example.com", Content: "0 5222 xmpp.example.net"},
+	}
+
+	expected := recordCollection{
+		{Type: "A", Name: "brand.example.org", Content: "192.0.2.1"},
+		{Type: "CNAME", Name: "www.brand.example.org", Content: "brand.example.org"},
+		{Type: "CNAME", Name: "cdn.brand.example.org", Content: "cdn.example.net"},
+		{Type: "MX", Name: "brand.example.org", Content: "mail.brand.example.org"},
+		{Type: "TXT", Name: "_dmarc.brand.example.org", Content: "mailto:dmarc@example.com"},
+		{Type: "A", Name: "notexample.com", Content: "192.0.2.2"},
+		{Type: "SRV", Name: "_sip._tcp.brand.example.org", Content: "60 5060 sip.brand.example.org"},
+		{Type: "PTR", Name: "1.2.0.192.in-addr.brand.example.org", Content: "host.brand.example.org"},
+		{Type: "SRV", Name: "_xmpp._tcp.brand.example.org", Content: "0 5222 xmpp.example.net"},
+	}
+
+	result := renameRecords(records, "example.com", "brand.example.org")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("renameRecords() returned %+v, expected %+v", result, expected)
+	}
+
+	if records[0].ID != "1" || records[1].Name != "www.example.com" {
+		t.Errorf("renameRecords() changed the original records")
+	}
+}
+
+func TestRunClone(t *testing.T) {
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 3600},
+	})
+	m.Seed("example.net", recordCollection{
+		{Type: "A", Name: "old.example.net", Content: "192.0.2.2", TTL: 3600},
+	})
+
+	simulated = m
+	defer func() { simulated = nil; yes = false; leaveUnknown = false }()
+
+	var b bytes.Buffer
+	realStdout := stdout
+	stdout = &b
+	defer func() { stdout = realStdout }()
+
+	runClone([]string{"-config", "/dev/null", "-yes", "example.com", "example.net"})
+
+	records, _ := m.List("example.net")
+	if len(records) != 1 || records[0].Name != "www.example.net" || records[0].Content != "192.0.2.1" {
+		t.Errorf("runClone() left wrong records %+v", records)
+	}
+}
+
+func TestRunCloneUsage(t *testing.T) {
+	for i, args := range [][]string{
+		{},
+		{"example.com"},
+		{"example.com", "www.example.com"},
+	} {
+		func() {
+			defer expectExit(t, 1)
+
+			runClone(args)
+			t.Errorf("%d: runClone() didn't exit", i)
+		}()
+	}
+}
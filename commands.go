@@ -0,0 +1,66 @@
+package main
+
+// Argument kinds used for shell completion of subcommand arguments.
+const (
+	argFile  = "file"
+	argZone  = "zone"
+	argShell = "shell"
+)
+
+// command is a cfzone subcommand. If the first argument to cfzone matches the
+// name of a command, the command will be run instead of syncing a zone.
+type command struct {
+	// description is a one-line description of the command.
+	description string
+
+	// args is the kind of positional arguments accepted by the command. This
+	// is used for shell completion.
+	args string
+
+	// run will be called with all arguments following the command name.
+	run func(args []string)
+}
+
+// commands holds all subcommands by name. It's populated in init() to avoid
+// an initialization loop with commands referring to the command list.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"audit": {
+			description: "Report drift between zone files and Cloudflare for all configured zones",
+			args:        argFile,
+			run:         runAudit,
+		},
+		"clone": {
+			description: "Copy the records of a zone to another zone, renaming them",
+			args:        argZone,
+			run:         runClone,
+		},
+		"completion": {
+			description: "Output shell completion script for bash, zsh or fish",
+			args:        argShell,
+			run:         runCompletion,
+		},
+		"diff": {
+			description: "Show changes needed to sync zone files, optionally against saved remote state",
+			args:        argFile,
+			run:         runDiff,
+		},
+		"migrate": {
+			description: "Copy zones from one Cloudflare account to another",
+			args:        argZone,
+			run:         runMigrate,
+		},
+		"roundtrip": {
+			description: "Check that zone files survive parsing and printing unchanged",
+			args:        argFile,
+			run:         runRoundtrip,
+		},
+		"validate": {
+			description: "Check zone files for problems without contacting Cloudflare",
+			args:        argFile,
+			run:         runValidate,
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// runCompletion implements "cfzone completion bash|zsh|fish". The special
+// argument "zones" will list all zones from Cloudflare, this is used by the
+// completion scripts to lazily complete zone names.
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		errorf("Usage: cfzone completion bash|zsh|fish")
+		exit(1)
+	}
+
+	switch args[0] {
+	case "bash":
+		bashCompletion(stdout)
+
+	case "zsh":
+		fmt.Fprintf(stdout, "#compdef cfzone\n\n")
+		fmt.Fprintf(stdout, "autoload -U +X bashcompinit && bashcompinit\n\n")
+		bashCompletion(stdout)
+
+	case "fish":
+		fishCompletion(stdout)
+
+	case "zones":
+		completeZones(stdout)
+
+	default:
+		errorf("Unsupported shell '%s'", args[0])
+		exit(1)
+	}
+}
+
+// completeZones will write the names of all zones available to w, one per
+// line. Credentials and the provider are set up like for any other command.
+// Errors are silently ignored, we don't want to clutter the terminal while
+// completing.
+func completeZones(w io.Writer) {
+	realStderr := stderr
+	stderr = ioutil.Discard
+	defer func() { stderr = realStderr }()
+
+	readCommandConfig("")
+	setupCommandFlags(flag.NewFlagSet("completion", flag.ContinueOnError))
+	setupCredentials()
+
+	provider, err := newProvider()
+	if err != nil {
+		exit(1)
+	}
+
+	lister, ok := provider.(cfzone.ZoneLister)
+	if !ok {
+		exit(1)
+	}
+
+	names, err := lister.ZoneNames()
+	if err != nil {
+		exit(1)
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\n", name)
+	}
+}
+
+// commandNames returns the names of all subcommands with positional arguments
+// of the kind args. If args is empty, all names are returned.
+func commandNames(args string) []string {
+	names := []string{}
+
+	for name, c := range commands {
+		if args == "" || c.args == args {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// flagNames returns all flags understood by cfzone prefixed by a dash.
+func flagNames() []string {
+	names := []string{}
+
+	newFlagSet("cfzone").VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+
+	return names
+}
+
+func bashCompletion(w io.Writer) {
+	fmt.Fprintf(w, "_cfzone() {\n")
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "\tlocal cmd=\"${COMP_WORDS[1]}\"\n\n")
+
+	fmt.Fprintf(w, "\tif [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(flagNames(), " "))
+	fmt.Fprintf(w, "\t\treturn\n")
+	fmt.Fprintf(w, "\tfi\n\n")
+
+	fmt.Fprintf(w, "\tif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") $(compgen -f -- \"$cur\") )\n", strings.Join(commandNames(""), " "))
+	fmt.Fprintf(w, "\t\treturn\n")
+	fmt.Fprintf(w, "\tfi\n\n")
+
+	fmt.Fprintf(w, "\tcase \"$cmd\" in\n")
+
+	if names := commandNames(argShell); len(names) > 0 {
+		fmt.Fprintf(w, "\t%s)\n", strings.Join(names, "|"))
+		fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"bash zsh fish\" -- \"$cur\") )\n")
+		fmt.Fprintf(w, "\t\t;;\n")
+	}
+
+	if names := commandNames(argZone); len(names) > 0 {
+		fmt.Fprintf(w, "\t%s)\n", strings.Join(names, "|"))
+		fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"$(cfzone completion zones 2>/dev/null)\" -- \"$cur\") )\n")
+		fmt.Fprintf(w, "\t\t;;\n")
+	}
+
+	fmt.Fprintf(w, "\t*)\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -f -- \"$cur\") )\n")
+	fmt.Fprintf(w, "\t\t;;\n")
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -o filenames -F _cfzone cfzone\n")
+}
+
+func fishCompletion(w io.Writer) {
+	newFlagSet("cfzone").VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(w, "complete -c cfzone -o %s -d %s\n", f.Name, fishQuote(f.Usage))
+	})
+
+	for _, name := range commandNames("") {
+		fmt.Fprintf(w, "complete -c cfzone -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(commands[name].description))
+	}
+
+	for _, name := range commandNames(argShell) {
+		fmt.Fprintf(w, "complete -c cfzone -n '__fish_seen_subcommand_from %s' -f -a 'bash zsh fish'\n", name)
+	}
+
+	for _, name := range commandNames(argZone) {
+		fmt.Fprintf(w, "complete -c cfzone -n '__fish_seen_subcommand_from %s' -f -a '(cfzone completion zones 2>/dev/null)'\n", name)
+	}
+}
+
+// fishQuote will quote s for use as a single argument in fish.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+
+	return "'" + s + "'"
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+func TestRunCompletionUsage(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"tcsh"},
+		{"bash", "zsh"},
+	}
+
+	for _, args := range cases {
+		func() {
+			defer expectExit(t, 1)
+
+			runCompletion(args)
+		}()
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	cases := []struct {
+		shell    string
+		expected []string
+	}{
+		{"bash", []string{"complete -o filenames -F _cfzone cfzone", "-yes", "-leaveunknown", "completion"}},
+		{"zsh", []string{"#compdef cfzone", "bashcompinit", "complete -o filenames -F _cfzone cfzone"}},
+		{"fish", []string{"complete -c cfzone -o yes -d 'Don\\'t ask before syncing'", "-a completion", "-a 'bash zsh fish'"}},
+	}
+
+	realStdout := stdout
+	defer func() { stdout = realStdout }()
+
+	for _, c := range cases {
+		var b bytes.Buffer
+		stdout = &b
+
+		runCompletion([]string{c.shell})
+
+		for _, e := range c.expected {
+			if !strings.Contains(b.String(), e) {
+				t.Errorf("%s completion did not contain '%s':\n%s", c.shell, e, b.String())
+			}
+		}
+	}
+}
+
+func TestFishQuote(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{"", "''"},
+		{"simple", "'simple'"},
+		{"Don't", `'Don\'t'`},
+		{`back\slash`, `'back\\slash'`},
+	}
+
+	for i, c := range cases {
+		result := fishQuote(c.in)
+		if result != c.expected {
+			t.Errorf("%d: fishQuote() returned wrong result for '%s', got %s, expected %s", i, c.in, result, c.expected)
+		}
+	}
+}
+
+func TestCompleteZones(t *testing.T) {
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 3600}})
+	m.Seed("example.net", recordCollection{{Type: "A", Name: "www.example.net", Content: "192.0.2.2", TTL: 3600}})
+
+	simulated = m
+	defer func() { simulated = nil }()
+
+	var b bytes.Buffer
+	completeZones(&b)
+
+	if b.String() != "example.com\nexample.net\n" {
+		t.Errorf("completeZones() wrote wrong zones [%s]", b.String())
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// config is the content of the cfzone configuration file.
+	config struct {
+		Credentials credentials `yaml:"credentials"`
+
+		// Flags holds default values for command line flags by flag name.
+		// Flags given on the command line will always take precedence.
+		Flags map[string]string `yaml:"flags"`
+
+		// Ignore is a list of glob patterns. Records with a name matching
+		// any of the patterns will be left untouched.
+		Ignore []string `yaml:"ignore"`
+
+		// Zones holds per-zone options by zone name.
+		Zones map[string]zoneConfig `yaml:"zones"`
+
+		Webhook webhookConfig `yaml:"webhook"`
+		Server  serverConfig  `yaml:"server"`
+		Notify  notifyConfig  `yaml:"notify"`
+		Alert   alertConfig   `yaml:"alert"`
+	}
+
+	// alertConfig holds options for alerting on repeated sync failures.
+	alertConfig struct {
+		// After is the number of consecutive failed syncs of a zone
+		// before an alert is triggered. Defaults to 3.
+		After int `yaml:"after"`
+
+		PagerDuty *pagerDutyConfig `yaml:"pagerduty"`
+		Opsgenie  *opsgenieConfig  `yaml:"opsgenie"`
+	}
+
+	// pagerDutyConfig holds options for PagerDuty alerts.
+	pagerDutyConfig struct {
+		// RoutingKeyEnv is the name of an environment variable holding
+		// the Events API v2 routing key.
+		RoutingKeyEnv string `yaml:"routing_key_env"`
+
+		// RoutingKeyFile is the path of a file holding the Events API
+		// v2 routing key.
+		RoutingKeyFile string `yaml:"routing_key_file"`
+	}
+
+	// opsgenieConfig holds options for Opsgenie alerts.
+	opsgenieConfig struct {
+		// APIKeyEnv is the name of an environment variable holding the
+		// API key.
+		APIKeyEnv string `yaml:"api_key_env"`
+
+		// APIKeyFile is the path of a file holding the API key.
+		APIKeyFile string `yaml:"api_key_file"`
+	}
+
+	// notifyConfig holds the notifiers to use after each sync.
+	notifyConfig struct {
+		Slack    *slackConfig          `yaml:"slack"`
+		Webhooks []webhookNotifyConfig `yaml:"webhooks"`
+		Email    *emailConfig          `yaml:"email"`
+	}
+
+	// emailConfig holds options for email reports.
+	emailConfig struct {
+		// Server is the SMTP server as host:port.
+		Server string   `yaml:"server"`
+		From   string   `yaml:"from"`
+		To     []string `yaml:"to"`
+
+		// Username enables authentication using PLAIN.
+		Username string `yaml:"username"`
+
+		// PasswordEnv is the name of an environment variable holding
+		// the SMTP password.
+		PasswordEnv string `yaml:"password_env"`
+
+		// PasswordFile is the path of a file holding the SMTP
+		// password.
+		PasswordFile string `yaml:"password_file"`
+	}
+
+	// webhookNotifyConfig holds options for a generic webhook notification.
+	webhookNotifyConfig struct {
+		URL string `yaml:"url"`
+
+		// URLEnv is the name of an environment variable holding the URL.
+		URLEnv string `yaml:"url_env"`
+
+		// URLFile is the path of a file holding the URL.
+		URLFile string `yaml:"url_file"`
+	}
+
+	// slackConfig holds options for Slack notifications.
+	slackConfig struct {
+		// URLEnv is the name of an environment variable holding the
+		// Slack incoming webhook URL.
+		URLEnv string `yaml:"url_env"`
+
+		// URLFile is the path of a file holding the Slack incoming
+		// webhook URL.
+		URLFile string `yaml:"url_file"`
+	}
+
+	// serverConfig holds options for the HTTP API server.
+	serverConfig struct {
+		// TokenEnv is the name of an environment variable holding the
+		// bearer token clients must present.
+		TokenEnv string `yaml:"token_env"`
+
+		// TokenFile is the path of a file holding the bearer token
+		// clients must present.
+		TokenFile string `yaml:"token_file"`
+	}
+
+	// webhookConfig holds options for the webhook receiver.
+	webhookConfig struct {
+		// SecretEnv is the name of an environment variable holding the
+		// secret used to verify signatures.
+		SecretEnv string `yaml:"secret_env"`
+
+		// SecretFile is the path of a file holding the secret used to
+		// verify signatures.
+		SecretFile string `yaml:"secret_file"`
+
+		// Pull is a shell command to run before syncing, for example to
+		// update a git checkout holding the zone files.
+		Pull string `yaml:"pull"`
+	}
+
+	// credentials references the Cloudflare credentials to use, this allows
+	// us to keep the secrets themselves outside the configuration file.
+	credentials struct {
+		Email string `yaml:"email"`
+
+		// KeyEnv is the name of an environment variable holding the API
+		// key.
+		KeyEnv string `yaml:"key_env"`
+
+		// KeyFile is the path of a file holding the API key.
+		KeyFile string `yaml:"key_file"`
+
+		// TokenEnv and TokenFile reference an API token used instead
+		// of the API key.
+		TokenEnv  string `yaml:"token_env"`
+		TokenFile string `yaml:"token_file"`
+
+		// SecondaryTokenEnv and SecondaryTokenFile reference an API
+		// token used if the primary token is rejected.
+		SecondaryTokenEnv  string `yaml:"secondary_token_env"`
+		SecondaryTokenFile string `yaml:"secondary_token_file"`
+
+		// Vault references an API token in HashiCorp Vault, read if no
+		// other token is given.
+		Vault *vaultConfig `yaml:"vault"`
+	}
+
+	// zoneConfig holds options for a single zone. These will override the
+	// global options.
+	zoneConfig struct {
+		Flags  map[string]string `yaml:"flags"`
+		Ignore []string          `yaml:"ignore"`
+
+		// Layers is a list of zone files merged into the zone file,
+		// like per-environment additions to a base zone. Layers don't
+		// need an SOA record.
+		Layers []string `yaml:"layers"`
+
+		// Notify will replace the global notifiers for the zone if
+		// present.
+		Notify *notifyConfig `yaml:"notify"`
+
+		// DefaultTTL is the TTL of records without one, where the zone
+		// file doesn't give a default using $TTL or an earlier record.
+		// 1 makes them proxied.
+		DefaultTTL int `yaml:"default_ttl"`
+
+		// Proxied makes proxiable records without a TTL proxied, like
+		// a default_ttl of 1 for those alone.
+		Proxied bool `yaml:"proxied"`
+
+		// ConfirmDeletes overrides -confirmdeletes from the
+		// configuration for the zone if present.
+		ConfirmDeletes *int `yaml:"confirm_deletes"`
+
+		// File is the zone file for the zone, used by cfzone audit -all.
+		File string `yaml:"file"`
+
+		// Targets are other zones receiving the same records.
+		Targets []targetConfig `yaml:"targets"`
+
+		// Delegations are child zones hosted elsewhere. NS and DS records
+		// are added for each.
+		Delegations []delegationConfig `yaml:"delegations"`
+	}
+)
+
+var (
+	// configPath is the path of the configuration file. If empty,
+	// defaultConfigPath() will be used.
+	configPath = ""
+
+	// cfg is the loaded configuration.
+	cfg = config{}
+)
+
+// defaultConfigPath returns the default location of the configuration file
+// following the XDG base directory specification.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+
+	return filepath.Join(dir, "cfzone", "config.yaml")
+}
+
+// loadConfig will read the configuration file at p. If mustExist is false, a
+// missing file will result in an empty configuration. Delegations and zone
+// options can change records, so the configuration must be signed like zone
+// files.
+func loadConfig(p string, mustExist bool) (config, error) {
+	c := config{}
+
+	b, err := readVerified(p)
+	if os.IsNotExist(err) && !mustExist {
+		return c, nil
+	}
+
+	if err != nil {
+		return c, err
+	}
+
+	err = yaml.UnmarshalStrict(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("%s: %s", p, err.Error())
+	}
+
+	return c, nil
+}
+
+// readCommandConfig will load the configuration for a subcommand from p, or
+// from defaultConfigPath() if p is empty. cfzone will exit if the
+// configuration can't be read.
+func readCommandConfig(p string) {
+	mustExist := p != ""
+	if p == "" {
+		p = defaultConfigPath()
+	}
+
+	var err error
+	cfg, err = loadConfig(p, mustExist)
+	if err != nil {
+		errorf("Can't read configuration: %s", err.Error())
+		exit(1)
+	}
+}
+
+// setupCommandFlags will set up the flags of cfzone from the environment and
+// the configuration for subcommands, and the API transport according to them.
+// Flags given on the command line of the subcommand, parsed by command, are
+// kept. It must be called after readCommandConfig.
+func setupCommandFlags(command *flag.FlagSet) {
+	given := map[string]string{}
+	command.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	flagset := newFlagSet("cfzone")
+
+	env := envFlags(flagset)
+	err := applyFlags(flagset, env, nil)
+	if err != nil {
+		errorf("Error in environment: %s", err.Error())
+		exit(1)
+	}
+
+	explicitFlags = map[string]bool{}
+	for name := range env {
+		explicitFlags[name] = true
+	}
+
+	for name := range given {
+		explicitFlags[name] = true
+	}
+
+	err = applyFlags(flagset, cfg.Flags, explicitFlags)
+	if err != nil {
+		errorf("Error in configuration: %s", err.Error())
+		exit(1)
+	}
+
+	// Subcommand flags like -yes share variables with the flags of cfzone,
+	// and have just been reset.
+	for name, value := range given {
+		command.Set(name, value)
+	}
+
+	parsedFlags = flagset
+	baseFlags = snapshotFlags(flagset)
+
+	err = setupAPITransport()
+	if err != nil {
+		errorf("Can't set up API connections: %s", err.Error())
+		exit(1)
+	}
+}
+
+// applyFlags will set the flags in values on flagset. Flags present in
+// explicit will not be touched, these have been given on the command line.
+func applyFlags(flagset *flag.FlagSet, values map[string]string, explicit map[string]bool) error {
+	for name, value := range values {
+		if explicit[name] {
+			continue
+		}
+
+		err := flagset.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for flag '%s': %s", value, name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// snapshotFlags returns the current values of all flags in flagset by name.
+func snapshotFlags(flagset *flag.FlagSet) map[string]string {
+	values := map[string]string{}
+
+	flagset.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+
+	return values
+}
+
+// envFlags returns flag values from CFZONE_* environment variables by flag
+// name. The variable name is the flag name in upper case prefixed by CFZONE_,
+// for example CFZONE_LEAVEUNKNOWN for -leaveunknown. Dashes become
+// underscores, like CFZONE_READ_ONLY for -read-only.
+func envFlags(flagset *flag.FlagSet) map[string]string {
+	values := map[string]string{}
+
+	flagset.VisitAll(func(f *flag.Flag) {
+		value, found := os.LookupEnv("CFZONE_" + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1)))
+		if found {
+			values[f.Name] = value
+		}
+	})
+
+	return values
+}
+
+// apiKey will resolve the API key referenced by the configuration.
+func (c config) apiKey() (string, error) {
+	return readSecret(c.Credentials.KeyEnv, c.Credentials.KeyFile)
+}
+
+// apiTokens will resolve the primary and secondary API tokens referenced by
+// the configuration.
+func (c config) apiTokens() (string, string, error) {
+	primary, err := readSecret(c.Credentials.TokenEnv, c.Credentials.TokenFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	secondary, err := readSecret(c.Credentials.SecondaryTokenEnv, c.Credentials.SecondaryTokenFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	return primary, secondary, nil
+}
+
+// readSecret will read a secret from the environment variable env, or if env
+// is empty, from the file at path. If both are empty, an empty string is
+// returned.
+func readSecret(env string, path string) (string, error) {
+	secret := ""
+
+	switch {
+	case env != "":
+		secret = os.Getenv(env)
+
+	case path != "":
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		secret = strings.TrimSpace(string(b))
+	}
+
+	registerSecret(secret)
+
+	return secret, nil
+}
+
+// ignorePatterns returns the combined ignore patterns for zoneName.
+func (c config) ignorePatterns(zoneName string) []string {
+	patterns := append([]string{}, c.Ignore...)
+
+	return append(patterns, c.Zones[zoneName].Ignore...)
+}
+
+// notifyConfig returns the notifiers configured for zoneName.
+func (c config) notifyConfig(zoneName string) notifyConfig {
+	if c.Zones[zoneName].Notify != nil {
+		return *c.Zones[zoneName].Notify
+	}
+
+	return c.Notify
+}
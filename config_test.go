@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %s", err.Error())
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	if err != nil {
+		t.Fatalf("Failed to write temporary file: %s", err.Error())
+	}
+
+	return f.Name()
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("XDG_CONFIG_HOME", xdg)
+
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if defaultConfigPath() != "/xdg/cfzone/config.yaml" {
+		t.Errorf("defaultConfigPath() ignored XDG_CONFIG_HOME, got %s", defaultConfigPath())
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", "")
+	expected := filepath.Join(os.Getenv("HOME"), ".config", "cfzone", "config.yaml")
+	if defaultConfigPath() != expected {
+		t.Errorf("defaultConfigPath() returned %s, expected %s", defaultConfigPath(), expected)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	p := writeTempFile(t, `
+credentials:
+  email: hostmaster@example.com
+  key_env: EXAMPLE_CF_KEY
+flags:
+  leaveunknown: "true"
+ignore:
+  - "*.k8s.example.com"
+zones:
+  example.com:
+    flags:
+      confirmdeletes: "100"
+    ignore:
+      - "_acme-challenge.*"
+`)
+	defer os.Remove(p)
+
+	c, err := loadConfig(p, true)
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %s", err.Error())
+	}
+
+	if c.Credentials.Email != "hostmaster@example.com" {
+		t.Errorf("loadConfig() did not read email, got %s", c.Credentials.Email)
+	}
+
+	if c.Flags["leaveunknown"] != "true" {
+		t.Errorf("loadConfig() did not read flags, got %+v", c.Flags)
+	}
+
+	patterns := c.ignorePatterns("example.com")
+	if !reflect.DeepEqual(patterns, []string{"*.k8s.example.com", "_acme-challenge.*"}) {
+		t.Errorf("ignorePatterns() returned wrong patterns: %+v", patterns)
+	}
+
+	patterns = c.ignorePatterns("example.net")
+	if !reflect.DeepEqual(patterns, []string{"*.k8s.example.com"}) {
+		t.Errorf("ignorePatterns() returned wrong patterns: %+v", patterns)
+	}
+
+	os.Setenv("EXAMPLE_CF_KEY", "secret")
+	defer os.Unsetenv("EXAMPLE_CF_KEY")
+
+	key, err := c.apiKey()
+	if err != nil || key != "secret" {
+		t.Errorf("apiKey() returned wrong key '%s' (%v)", key, err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	_, err := loadConfig("/non/existing/config.yaml", false)
+	if err != nil {
+		t.Errorf("loadConfig() failed on missing optional file: %s", err.Error())
+	}
+
+	_, err = loadConfig("/non/existing/config.yaml", true)
+	if err == nil {
+		t.Errorf("loadConfig() did not fail on missing file")
+	}
+
+	p := writeTempFile(t, "unknown: field\n")
+	defer os.Remove(p)
+
+	_, err = loadConfig(p, true)
+	if err == nil {
+		t.Errorf("loadConfig() did not fail on unknown field")
+	}
+}
+
+func TestAPIKeyFile(t *testing.T) {
+	p := writeTempFile(t, "secret\n")
+	defer os.Remove(p)
+
+	c := config{Credentials: credentials{KeyFile: p}}
+	key, err := c.apiKey()
+	if err != nil || key != "secret" {
+		t.Errorf("apiKey() returned wrong key '%s' (%v)", key, err)
+	}
+
+	c = config{Credentials: credentials{KeyFile: "/non/existing"}}
+	_, err = c.apiKey()
+	if err == nil {
+		t.Errorf("apiKey() did not fail on missing file")
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	flagset := newFlagSet("test")
+	flagset.Parse([]string{"-confirmdeletes", "5"})
+	defer newFlagSet("test")
+
+	explicit := map[string]bool{"confirmdeletes": true}
+
+	err := applyFlags(flagset, map[string]string{"confirmdeletes": "100", "leaveunknown": "true"}, explicit)
+	if err != nil {
+		t.Fatalf("applyFlags() returned error: %s", err.Error())
+	}
+
+	if confirmDeletes != 5 {
+		t.Errorf("applyFlags() overwrote explicit flag, got %d", confirmDeletes)
+	}
+
+	if !leaveUnknown {
+		t.Errorf("applyFlags() did not set flag")
+	}
+
+	err = applyFlags(flagset, map[string]string{"nonexisting": "1"}, explicit)
+	if err == nil {
+		t.Errorf("applyFlags() did not fail on unknown flag")
+	}
+
+	err = applyFlags(flagset, map[string]string{"leaveunknown": "maybe"}, explicit)
+	if err == nil {
+		t.Errorf("applyFlags() did not fail on invalid value")
+	}
+}
+
+func TestEnvFlags(t *testing.T) {
+	os.Setenv("CFZONE_LEAVEUNKNOWN", "true")
+	os.Setenv("CFZONE_CONFIRMDELETES", "42")
+	defer os.Unsetenv("CFZONE_LEAVEUNKNOWN")
+	defer os.Unsetenv("CFZONE_CONFIRMDELETES")
+
+	values := envFlags(newFlagSet("test"))
+
+	expected := map[string]string{
+		"leaveunknown":   "true",
+		"confirmdeletes": "42",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("envFlags() returned wrong values, got %+v, expected %+v", values, expected)
+	}
+}
+
+func TestEnvPrecedence(t *testing.T) {
+	os.Setenv("CFZONE_CONFIRMDELETES", "42")
+	defer os.Unsetenv("CFZONE_CONFIRMDELETES")
+
+	parseArguments([]string{"./test", "zone"})
+	if confirmDeletes != 42 {
+		t.Errorf("parseArguments() did not use environment, got %d", confirmDeletes)
+	}
+
+	parseArguments([]string{"./test", "-confirmdeletes", "7", "zone"})
+	if confirmDeletes != 7 {
+		t.Errorf("parseArguments() did not prefer command line, got %d", confirmDeletes)
+	}
+}
+
+func TestEnvInvalid(t *testing.T) {
+	defer expectExit(t, 1)
+
+	os.Setenv("CFZONE_CONFIRMDELETES", "many")
+	defer os.Unsetenv("CFZONE_CONFIRMDELETES")
+
+	parseArguments([]string{"./test", "zone"})
+}
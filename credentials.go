@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// apiToken is the Cloudflare API token used instead of the global API
+	// key if set.
+	apiToken = os.Getenv("CF_API_TOKEN")
+
+	// apiSecondaryToken is used if apiToken is rejected, to keep syncs
+	// running while tokens are rotated.
+	apiSecondaryToken = os.Getenv("CF_API_TOKEN_SECONDARY")
+
+	// primaryRejected is when apiToken was last rejected, in Unix
+	// nanoseconds. 0 means it's accepted.
+	primaryRejected int64
+)
+
+// primaryRetryInterval is how long the secondary token is used after the
+// primary token was rejected, before the primary token is tried again.
+const primaryRetryInterval = 5 * time.Minute
+
+// haveCredentials returns true if Cloudflare credentials are configured.
+func haveCredentials() bool {
+	return apiToken != "" || (apiKey != "" && apiEmail != "")
+}
+
+// loadSystemdCredentials will read credentials passed by systemd using
+// LoadCredential= or SetCredential=, named like the environment variables.
+// Credentials already set in the environment take precedence.
+func loadSystemdCredentials() error {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return nil
+	}
+
+	credentials := []struct {
+		name  string
+		value *string
+	}{
+		{"CF_API_TOKEN", &apiToken},
+		{"CF_API_TOKEN_SECONDARY", &apiSecondaryToken},
+		{"CF_API_KEY", &apiKey},
+		{"CF_API_EMAIL", &apiEmail},
+	}
+
+	for _, c := range credentials {
+		if *c.value != "" {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, c.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		*c.value = strings.TrimSpace(string(b))
+	}
+
+	return nil
+}
+
+// tokenTransport is a http.RoundTripper retrying requests rejected with the
+// primary API token using the secondary token. Only 401 Unauthorized means
+// the token was rejected, 403 Forbidden is a token lacking permissions. The
+// primary token is tried again after primaryRetryInterval.
+type tokenTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if apiSecondaryToken == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	rejected := atomic.LoadInt64(&primaryRejected)
+	if rejected != 0 && now().Sub(time.Unix(0, rejected)) < primaryRetryInterval {
+		return t.next.RoundTrip(withToken(req, apiSecondaryToken))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		if rejected != 0 && atomic.CompareAndSwapInt64(&primaryRejected, rejected, 0) {
+			warnf("The primary API token is accepted again")
+		}
+
+		return resp, nil
+	}
+
+	// The body is gone, and can only be sent again if it can be recreated.
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	retry := withToken(req, apiSecondaryToken)
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+	}
+
+	resp.Body.Close()
+
+	if atomic.SwapInt64(&primaryRejected, now().UnixNano()) == 0 {
+		warnf("The primary API token was rejected, using the secondary token. Please rotate CF_API_TOKEN")
+	}
+
+	return t.next.RoundTrip(retry)
+}
+
+// withToken returns a copy of req authenticated with token.
+func withToken(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return r
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenTransport(t *testing.T) {
+	bodies := []string{}
+	auths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		auths = append(auths, r.Header.Get("Authorization"))
+
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer server.Close()
+
+	start := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+
+	apiSecondaryToken = "new"
+	defer func() {
+		apiSecondaryToken = ""
+		primaryRejected = 0
+		now = time.Now
+	}()
+
+	b, restore := captureStderr(0)
+	defer restore()
+
+	client := &http.Client{Transport: &tokenTransport{next: http.DefaultTransport}}
+
+	for i := 0; i < 3; i++ {
+		// The primary token is tried again after a while.
+		if i == 2 {
+			now = func() time.Time { return start.Add(primaryRetryInterval) }
+		}
+
+		req, _ := http.NewRequest("POST", server.URL, bytes.NewBufferString("record"))
+		req.Header.Set("Authorization", "Bearer old")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %s", err.Error())
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%d: Request returned %d", i, resp.StatusCode)
+		}
+	}
+
+	expected := []string{"Bearer old", "Bearer new", "Bearer new", "Bearer old", "Bearer new"}
+	if strings.Join(auths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Requests used %v, expected %v", auths, expected)
+	}
+
+	if strings.Join(bodies, ",") != "record,record,record,record,record" {
+		t.Errorf("Request bodies were %q", bodies)
+	}
+
+	if strings.Count(b.String(), "primary API token was rejected") != 1 {
+		t.Errorf("Expected a single warning, got %q", b.String())
+	}
+}
+
+func TestTokenTransportForbidden(t *testing.T) {
+	auths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	apiSecondaryToken = "new"
+	defer func() {
+		apiSecondaryToken = ""
+		primaryRejected = 0
+	}()
+
+	client := &http.Client{Transport: &tokenTransport{next: http.DefaultTransport}}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Authorization", "Bearer old")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	// Missing permissions are not fixed by another token.
+	if resp.StatusCode != http.StatusForbidden || len(auths) != 1 || primaryRejected != 0 {
+		t.Errorf("Request returned %d using %v", resp.StatusCode, auths)
+	}
+}
+
+func TestTokenTransportWithoutSecondary(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &tokenTransport{next: http.DefaultTransport}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized || calls != 1 {
+		t.Errorf("Request returned %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	p := writeTempFile(t, "secondary\n")
+	defer os.Remove(p)
+
+	os.Setenv("EXAMPLE_CF_TOKEN", "primary")
+	defer os.Unsetenv("EXAMPLE_CF_TOKEN")
+
+	c := config{Credentials: credentials{TokenEnv: "EXAMPLE_CF_TOKEN", SecondaryTokenFile: p}}
+
+	primary, secondary, err := c.apiTokens()
+	if err != nil || primary != "primary" || secondary != "secondary" {
+		t.Errorf("apiTokens() returned '%s', '%s' (%v)", primary, secondary, err)
+	}
+}
+
+func TestLoadSystemdCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "CF_API_TOKEN"), []byte("from-systemd\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "CF_API_KEY"), []byte("key-from-systemd"), 0600)
+
+	defer func(token, secondary, key, email string) {
+		apiToken, apiSecondaryToken, apiKey, apiEmail = token, secondary, key, email
+	}(apiToken, apiSecondaryToken, apiKey, apiEmail)
+
+	apiToken, apiSecondaryToken, apiKey, apiEmail = "", "", "key-from-env", ""
+
+	err = loadSystemdCredentials()
+	if err != nil {
+		t.Fatalf("loadSystemdCredentials() without CREDENTIALS_DIRECTORY failed: %s", err.Error())
+	}
+
+	if apiToken != "" {
+		t.Errorf("loadSystemdCredentials() read credentials without CREDENTIALS_DIRECTORY")
+	}
+
+	os.Setenv("CREDENTIALS_DIRECTORY", dir)
+	defer os.Unsetenv("CREDENTIALS_DIRECTORY")
+
+	err = loadSystemdCredentials()
+	if err != nil {
+		t.Fatalf("loadSystemdCredentials() failed: %s", err.Error())
+	}
+
+	if apiToken != "from-systemd" {
+		t.Errorf("loadSystemdCredentials() set token '%s', expected 'from-systemd'", apiToken)
+	}
+
+	if apiKey != "key-from-env" {
+		t.Errorf("loadSystemdCredentials() replaced the key from the environment with '%s'", apiKey)
+	}
+
+	if apiSecondaryToken != "" || apiEmail != "" {
+		t.Errorf("loadSystemdCredentials() set credentials not passed by systemd")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+)
+
+// interval is the time between reconciliations in daemon mode. Zero disables
+// daemon mode.
+var interval time.Duration
+
+// reconcile will call sync for each of paths every interval until stop is
+// closed. This will correct any changes made to the zones outside of cfzone.
+// Errors from sync are logged, but will not stop reconciliation.
+func reconcile(paths []string, sync func(string) error, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, path := range paths {
+			syncLogged(path, sync)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
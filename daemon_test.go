@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	var lock sync.Mutex
+	synced := map[string]int{}
+	count := func(p string) error {
+		lock.Lock()
+		synced[p]++
+		lock.Unlock()
+
+		return nil
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		reconcile([]string{"a.zone", "b.zone"}, count, 20*time.Millisecond, stop)
+		close(done)
+	}()
+
+	time.Sleep(110 * time.Millisecond)
+	close(stop)
+	<-done
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if synced["a.zone"] < 3 || synced["a.zone"] != synced["b.zone"] {
+		t.Errorf("reconcile() did not sync all zones periodically: %+v", synced)
+	}
+}
+
+func TestDaemonRequiresYes(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-interval", "5m", "zone"})
+}
+
+func TestDaemonAndWatch(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-yes", "-watch", "-interval", "5m", "zone"})
+}
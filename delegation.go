@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// defaultDelegationTTL is the TTL of delegation records without one.
+const defaultDelegationTTL = 86400
+
+// delegationConfig is a child zone hosted elsewhere.
+type delegationConfig struct {
+	// Name is the name of the child zone.
+	Name string `yaml:"name"`
+
+	// NameServers are the name servers of the child zone.
+	NameServers []string `yaml:"nameservers"`
+
+	// DS holds DS records for the child zone, like
+	// "2371 13 2 1F987CC6583E92DF0890718C42...".
+	DS []string `yaml:"ds"`
+
+	// TTL of the delegation records. 0 means 86400.
+	TTL int `yaml:"ttl"`
+}
+
+// records returns the NS and DS records delegating d from zoneName.
+func (d delegationConfig) records(zoneName string) (recordCollection, error) {
+	name := cfzone.Name(d.Name)
+	if name == cfzone.Name(zoneName) || !inZone(name, zoneName) || !cfzone.ValidHostname(name) {
+		return nil, fmt.Errorf("Delegation '%s' is not a child zone of %s", d.Name, zoneName)
+	}
+
+	if len(d.NameServers) == 0 {
+		return nil, fmt.Errorf("Delegation of %s has no name servers", name)
+	}
+
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = defaultDelegationTTL
+	}
+
+	records := recordCollection{}
+	for _, ns := range d.NameServers {
+		if !cfzone.ValidHostname(ns) {
+			return nil, fmt.Errorf("Delegation of %s has invalid name server '%s'", name, ns)
+		}
+
+		records = append(records, cloudflare.DNSRecord{Type: "NS", Name: name, Content: cfzone.Target(ns), TTL: ttl})
+	}
+
+	for _, ds := range d.DS {
+		fields := strings.Fields(ds)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("Delegation of %s has invalid DS record '%s'", name, ds)
+		}
+
+		// The key tag is 16 bits, the algorithm and digest type 8 bits.
+		_, err := strconv.ParseUint(fields[0], 10, 16)
+		if err == nil {
+			_, err = strconv.ParseUint(fields[1], 10, 8)
+		}
+		if err == nil {
+			_, err = strconv.ParseUint(fields[2], 10, 8)
+		}
+		if err == nil {
+			_, err = hex.DecodeString(fields[3])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Delegation of %s has invalid DS record '%s'", name, ds)
+		}
+
+		records = append(records, cloudflare.DNSRecord{Type: "DS", Name: name, Content: strings.Join(fields, " "), TTL: ttl})
+	}
+
+	return records.Canonical(), nil
+}
+
+// delegationRecords returns the NS and DS records for all delegations of
+// zoneName in the configuration.
+func delegationRecords(zoneName string) (recordCollection, error) {
+	records := recordCollection{}
+
+	for _, d := range cfg.Zones[zoneName].Delegations {
+		r, err := d.records(zoneName)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, r...)
+	}
+
+	return records, nil
+}
+
+// withDelegations returns the records of zone with the delegation records
+// from the configuration added.
+func withDelegations(zone zoneSection) (recordCollection, error) {
+	delegations, err := delegationRecords(zone.name)
+	if err != nil || len(delegations) == 0 {
+		return zone.records, err
+	}
+
+	records, err := zone.records.Merge(delegations)
+	if err != nil {
+		return nil, fmt.Errorf("Error adding delegations to '%s': %s", zone.name, err.Error())
+	}
+
+	return records, nil
+}
+
+// warnExcludedDelegations will warn about delegations of zoneName inside a
+// subtree excluded by -excludesubtree. Their records are never synced.
+func warnExcludedDelegations(zoneName string) {
+	if excludeSubtrees == "" {
+		return
+	}
+
+	excluded := cfzone.BySubtree(splitList(excludeSubtrees)...)
+
+	for _, d := range cfg.Zones[zoneName].Delegations {
+		if excluded(cloudflare.DNSRecord{Name: cfzone.Name(d.Name)}) {
+			warnf("The delegation of %s is excluded by -excludesubtree, its NS and DS records are not synced", cfzone.Name(d.Name))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDelegationRecords(t *testing.T) {
+	d := delegationConfig{
+		Name:        "Sub.Example.com.",
+		NameServers: []string{"NS1.example.net.", "ns2.example.net"},
+		DS:          []string{"2371 13 2 1f987cc6583e"},
+	}
+
+	expected := recordCollection{
+		{Type: "NS", Name: "sub.example.com", Content: "ns1.example.net", TTL: 86400},
+		{Type: "NS", Name: "sub.example.com", Content: "ns2.example.net", TTL: 86400},
+		{Type: "DS", Name: "sub.example.com", Content: "2371 13 2 1F987CC6583E", TTL: 86400},
+	}
+
+	records, err := d.records("example.com")
+	if err != nil {
+		t.Fatalf("records() failed: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("records() returned %+v, expected %+v", records, expected)
+	}
+
+	cases := []delegationConfig{
+		{Name: "example.com", NameServers: []string{"ns1.example.net"}},
+		{Name: "sub.example.net", NameServers: []string{"ns1.example.net"}},
+		{Name: "sub.example.com"},
+		{Name: "sub.example.com", NameServers: []string{"-bad.example.net"}},
+		{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, DS: []string{"2371 13 2"}},
+		{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, DS: []string{"2371 x 2 1F98"}},
+		{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, DS: []string{"2371 300 2 1F98"}},
+		{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, DS: []string{"2371 13 256 1F98"}},
+		{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, DS: []string{"2371 13 2 1F9"}},
+		{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, DS: []string{"2371 13 2 xyz1"}},
+	}
+
+	for i, d := range cases {
+		_, err := d.records("example.com")
+		if err == nil {
+			t.Errorf("%d: records() didn't fail for %+v", i, d)
+		}
+	}
+}
+
+func TestReadZonesDelegations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func() { cfg = config{} }()
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte(`$TTL 300
+$ORIGIN example.com.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.1
+`), 0644)
+
+	cfg = config{Zones: map[string]zoneConfig{
+		"example.com": {Delegations: []delegationConfig{
+			{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}, TTL: 3600},
+		}},
+	}}
+
+	zones, err := readZones(path)
+	if err != nil {
+		t.Fatalf("readZones() failed: %s", err.Error())
+	}
+
+	records := zones[0].records
+	if len(records) != 2 || records[1].Type != "NS" || records[1].Name != "sub.example.com" || records[1].TTL != 3600 {
+		t.Errorf("readZones() returned wrong records %+v", records)
+	}
+}
+
+func TestWarnExcludedDelegations(t *testing.T) {
+	cfg.Zones = map[string]zoneConfig{"example.com": {Delegations: []delegationConfig{
+		{Name: "lab.dev.example.com", NameServers: []string{"ns1.example.net"}},
+		{Name: "prod.example.com", NameServers: []string{"ns1.example.net"}},
+	}}}
+	excludeSubtrees = "dev.example.com"
+	defer func() {
+		cfg = config{}
+		excludeSubtrees = ""
+	}()
+
+	buf, restore := captureStderr(0)
+	defer restore()
+
+	warnExcludedDelegations("example.com")
+
+	if !strings.Contains(buf.String(), "lab.dev.example.com is excluded") || strings.Contains(buf.String(), "prod.example.com") {
+		t.Errorf("warnExcludedDelegations() wrote wrong warnings: %s", buf.String())
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dialog implements a "git add -p"-like dialog where the user can
+// approve or skip each change individually.
+type dialog struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// all will be set to true when the user approves all remaining changes.
+	all bool
+
+	// quit will be set to true when the user skips all remaining changes.
+	quit bool
+}
+
+// newDialog will instantiate a new dialog reading answers from r and
+// writing questions to w.
+func newDialog(r io.Reader, w io.Writer) *dialog {
+	return &dialog{
+		in:  bufio.NewReader(r),
+		out: w,
+	}
+}
+
+// Pick will ask the user about each record in c and return a collection
+// containing only the records approved. action is used when prompting the
+// user, and should be a verb like "Delete".
+func (d *dialog) Pick(action string, c recordCollection) recordCollection {
+	result := recordCollection{}
+
+	for _, r := range c {
+		if d.quit {
+			break
+		}
+
+		if d.all {
+			result = append(result, r)
+			continue
+		}
+
+		recordCollection{r}.Fprint(d.out)
+		fmt.Fprintf(d.out, "%s this record (y,n,a,q,?)? ", action)
+
+		for answered := false; !answered; {
+			line, _, err := d.in.ReadLine()
+			if err != nil {
+				// If we can't read from the user, we play it safe and
+				// skip everything.
+				d.quit = true
+				break
+			}
+
+			answered = true
+
+			switch strings.ToLower(string(line)) {
+			case "y":
+				result = append(result, r)
+
+			case "n":
+
+			case "a":
+				d.all = true
+				result = append(result, r)
+
+			case "q":
+				d.quit = true
+
+			default:
+				fmt.Fprintf(d.out, "y - %s this record\n", strings.ToLower(action))
+				fmt.Fprintf(d.out, "n - skip this record\n")
+				fmt.Fprintf(d.out, "a - apply this and all remaining changes\n")
+				fmt.Fprintf(d.out, "q - skip this and all remaining changes\n")
+				fmt.Fprintf(d.out, "%s this record (y,n,a,q,?)? ", action)
+				answered = false
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestDialogPick(t *testing.T) {
+	a1 := cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}
+	a2 := cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"}
+	a3 := cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"}
+	in := recordCollection{a1, a2, a3}
+
+	cases := []struct {
+		answers  string
+		expected recordCollection
+	}{
+		{"", recordCollection{}},
+		{"y\ny\ny\n", recordCollection{a1, a2, a3}},
+		{"n\nn\nn\n", recordCollection{}},
+		{"y\nn\ny\n", recordCollection{a1, a3}},
+		{"n\na\n", recordCollection{a2, a3}},
+		{"y\nq\n", recordCollection{a1}},
+		{"?\nx\ny\nn\nY\n", recordCollection{a1, a3}},
+	}
+
+	for i, c := range cases {
+		d := newDialog(bytes.NewBufferString(c.answers), ioutil.Discard)
+		result := d.Pick("Delete", in)
+
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: Pick() returned wrong records for %q, got %+v, expected %+v", i, c.answers, result, c.expected)
+		}
+	}
+}
+
+func TestDialogPickRemembers(t *testing.T) {
+	a1 := cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}
+	a2 := cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"}
+
+	d := newDialog(bytes.NewBufferString("a\n"), ioutil.Discard)
+	d.Pick("Delete", recordCollection{a1})
+
+	result := d.Pick("Add", recordCollection{a2})
+	if !reflect.DeepEqual(result, recordCollection{a2}) {
+		t.Errorf("Pick() did not remember 'all', got %+v", result)
+	}
+
+	d = newDialog(bytes.NewBufferString("q\n"), ioutil.Discard)
+	d.Pick("Delete", recordCollection{a1})
+
+	result = d.Pick("Add", recordCollection{a2})
+	if len(result) != 0 {
+		t.Errorf("Pick() did not remember 'quit', got %+v", result)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// runDiff will print the changes needed to sync each zone file given in
+// args. The existing records are read from the API, or from a saved state
+// given by -remotestate.
+func runDiff(args []string) {
+	flagset := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flagset.SetOutput(stderr)
+	config := flagset.String("config", "", "Path to configuration file (default "+defaultConfigPath()+")")
+	remoteState := flagset.String("remotestate", "", "Read existing records from this JSON dump or zone file instead of the API")
+
+	err := flagset.Parse(args)
+	if err != nil || flagset.NArg() < 1 {
+		errorf("Usage: cfzone diff [-config <file>] [-remotestate <file>] <zone file>...")
+		exit(1)
+	}
+
+	readCommandConfig(*config)
+	setupCommandFlags(flagset)
+
+	var provider cfzone.Provider
+	if *remoteState != "" {
+		provider, err = loadSimulation(*remoteState)
+	} else {
+		provider, err = newProvider()
+	}
+
+	if err != nil {
+		errorf("Can't read remote state: %s", err.Error())
+		exit(1)
+	}
+
+	failed := false
+
+	for _, path := range flagset.Args() {
+		zones, err := readZones(path)
+		if err != nil {
+			errorf("%s", err.Error())
+			failed = true
+			continue
+		}
+
+		for _, zone := range zones {
+			records, err := prepareZone(zone.name, zone.records)
+			if err != nil {
+				errorf("%s", err.Error())
+				failed = true
+				continue
+			}
+
+			p, err := newPlan(provider, zone.name, records)
+			if err != nil {
+				errorf("%s", err.Error())
+				failed = true
+				continue
+			}
+
+			fmt.Fprintf(stdout, "%s:\n", zone.name)
+			p.Fprint(stdout)
+		}
+	}
+
+	if failed {
+		exit(1)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunDiffRemoteState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	state := filepath.Join(dir, "state.json")
+	ioutil.WriteFile(state, []byte(`[
+		{"id": "1", "type": "A", "name": "www.example.com", "content": "192.0.2.1", "ttl": 3600, "zone_name": "example.com"},
+		{"id": "2", "type": "A", "name": "old.example.com", "content": "192.0.2.2", "ttl": 3600, "zone_name": "example.com"}
+	]`), 0600)
+
+	zone := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(zone, []byte("$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 2 3 4 5\nwww 3600 IN A 192.0.2.1\n"), 0600)
+
+	var b bytes.Buffer
+	realStdout := stdout
+	stdout = &b
+	defer func() { stdout = realStdout }()
+
+	runDiff([]string{"-remotestate", state, zone})
+
+	expected := `example.com:
+Records to delete:
+old.example.com. 3600 IN A     192.0.2.2
+
+Summary:
+Records to delete: 1
+Records to add: 0
+Records to update: 0
+Unchanged records: 1
+Zone file: 1 records (A 1), 0 proxied, TTLs (3600: 1)
+`
+
+	if b.String() != expected {
+		t.Errorf("runDiff() printed wrong diff, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestRunDiffUsage(t *testing.T) {
+	defer expectExit(t, 1)
+
+	runDiff([]string{})
+}
+
+func TestRunDiffMissingState(t *testing.T) {
+	defer expectExit(t, 1)
+
+	runDiff([]string{"-remotestate", "/nonexistent/state.json", "example.com.zone"})
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// Zone states in the drift report.
+const (
+	driftUnchanged = "unchanged"
+	driftDrifted   = "drifted"
+	driftFailed    = "failed"
+)
+
+// driftZone is the drift of a single zone.
+type driftZone struct {
+	Name   string `json:"name"`
+	File   string `json:"file,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Plan   *plan  `json:"plan,omitempty"`
+}
+
+// driftTotal sums up the drift of all zones.
+type driftTotal struct {
+	Zones   int `json:"zones"`
+	Drifted int `json:"drifted"`
+	Failed  int `json:"failed"`
+	Deletes int `json:"deletes"`
+	Adds    int `json:"adds"`
+	Updates int `json:"updates"`
+}
+
+// String returns a one-line summary of the total drift.
+func (t driftTotal) String() string {
+	return fmt.Sprintf("%d zones, %d drifted, %d failed. Records to delete: %d, to add: %d, to update: %d",
+		t.Zones, t.Drifted, t.Failed, t.Deletes, t.Adds, t.Updates)
+}
+
+// driftReport is the consolidated drift of all audited zones.
+type driftReport struct {
+	Generated time.Time   `json:"generated"`
+	Actor     string      `json:"actor"`
+	Zones     []driftZone `json:"zones"`
+	Total     driftTotal  `json:"total"`
+}
+
+// auditPaths returns the zone files to audit, the files in args followed by
+// the files of all configured zones if all is true. Configured zones without
+// a file are returned as failed.
+func auditPaths(args []string, all bool) ([]string, []driftZone) {
+	paths := []string{}
+	seen := map[string]bool{}
+	var missing []driftZone
+
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, path := range args {
+		add(path)
+	}
+
+	if !all {
+		return paths, missing
+	}
+
+	names := make([]string, 0, len(cfg.Zones))
+	for name := range cfg.Zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if cfg.Zones[name].File == "" {
+			missing = append(missing, driftZone{
+				Name:   name,
+				Status: driftFailed,
+				Error:  "No zone file configured",
+			})
+			continue
+		}
+
+		add(cfg.Zones[name].File)
+	}
+
+	return paths, missing
+}
+
+// audit will diff the zone files in paths against provider.
+func audit(provider cfzone.Provider, paths []string, report *driftReport) {
+	for _, path := range paths {
+		zones, err := readZones(path)
+		if err != nil {
+			report.Zones = append(report.Zones, driftZone{
+				Name:   path,
+				File:   path,
+				Status: driftFailed,
+				Error:  redact(err.Error()),
+			})
+			continue
+		}
+
+		for _, zone := range zones {
+			z := driftZone{Name: zone.name, File: path, Status: driftUnchanged}
+
+			records, err := prepareZone(zone.name, zone.records)
+
+			var p *plan
+			if err == nil {
+				p, err = newPlan(provider, zone.name, records)
+			}
+
+			switch {
+			case err != nil:
+				z.Status = driftFailed
+				z.Error = redact(err.Error())
+
+			case p.NumChanges() > 0:
+				z.Status = driftDrifted
+				z.Plan = p
+
+			default:
+				z.Plan = p
+			}
+
+			report.Zones = append(report.Zones, z)
+		}
+	}
+
+	sort.SliceStable(report.Zones, func(i, j int) bool {
+		return report.Zones[i].Name < report.Zones[j].Name
+	})
+
+	report.Total = driftTotal{}
+	for _, z := range report.Zones {
+		report.Total.Zones++
+
+		switch z.Status {
+		case driftDrifted:
+			report.Total.Drifted++
+		case driftFailed:
+			report.Total.Failed++
+		}
+
+		if z.Plan != nil {
+			report.Total.Deletes += len(z.Plan.Deletes)
+			report.Total.Adds += len(z.Plan.Adds)
+			report.Total.Updates += len(z.Plan.Updates)
+		}
+	}
+}
+
+// Fprint will output a textual drift report.
+func (r *driftReport) Fprint(w io.Writer) {
+	for _, z := range r.Zones {
+		switch z.Status {
+		case driftFailed:
+			fmt.Fprintf(w, "%s: failed: %s\n", z.Name, z.Error)
+
+		case driftDrifted:
+			fmt.Fprintf(w, "%s: drifted\n", z.Name)
+			for _, c := range reportChanges(z.Plan) {
+				fmt.Fprintf(w, "  %s %s\n", c.Action, recordLine(c.Record))
+			}
+
+		default:
+			fmt.Fprintf(w, "%s: unchanged\n", z.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "\nTotal: %s\n", r.Total)
+}
+
+// write will output the report in format, one of text, json or html.
+func (r *driftReport) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+
+	case "html":
+		zones := make([]reportZone, 0, len(r.Zones))
+		for _, z := range r.Zones {
+			zones = append(zones, reportZone{
+				Name:    z.Name,
+				Status:  z.Status,
+				Error:   z.Error,
+				Changes: reportChanges(z.Plan),
+			})
+		}
+
+		return reportTemplate.Execute(w, struct {
+			Generated string
+			Actor     string
+			Summary   string
+			Zones     []reportZone
+		}{
+			Generated: r.Generated.Format(time.RFC1123),
+			Actor:     r.Actor,
+			Summary:   r.Total.String(),
+			Zones:     zones,
+		})
+	}
+
+	r.Fprint(w)
+
+	return nil
+}
+
+// runAudit will diff zone files against Cloudflare and output a
+// consolidated report of the drift. With -all, every zone in the
+// configuration with a file is audited.
+func runAudit(args []string) {
+	flagset := flag.NewFlagSet("audit", flag.ContinueOnError)
+	flagset.SetOutput(stderr)
+	all := flagset.Bool("all", false, "Audit every zone in the configuration")
+	config := flagset.String("config", "", "Path to configuration file (default "+defaultConfigPath()+")")
+	format := flagset.String("format", "text", "Report format, 'text', 'json' or 'html'")
+	output := flagset.String("output", "", "Write the report to this file instead of stdout")
+	remoteState := flagset.String("remotestate", "", "Read existing records from this JSON dump or zone file instead of the API")
+
+	err := flagset.Parse(args)
+	if err != nil || (flagset.NArg() < 1 && !*all) {
+		errorf("Usage: cfzone audit [-all] [-format text|json|html] [-output <file>] [-remotestate <file>] [<zone file>...]")
+		exit(1)
+	}
+
+	if *format != "text" && *format != "json" && *format != "html" {
+		errorf("-format must be 'text', 'json' or 'html'")
+		exit(1)
+	}
+
+	readCommandConfig(*config)
+	setupCommandFlags(flagset)
+
+	var provider cfzone.Provider
+	if *remoteState != "" {
+		provider, err = loadSimulation(*remoteState)
+	} else {
+		setupCredentials()
+		provider, err = newProvider()
+	}
+
+	if err != nil {
+		errorf("Can't read remote state: %s", err.Error())
+		exit(1)
+	}
+
+	paths, missing := auditPaths(flagset.Args(), *all)
+
+	report := &driftReport{
+		Generated: now().UTC(),
+		Actor:     currentActor(),
+		Zones:     missing,
+	}
+
+	audit(provider, paths, report)
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			errorf("Can't write report: %s", err.Error())
+			exit(1)
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	err = report.write(w, *format)
+	if err != nil {
+		errorf("Can't write report: %s", err.Error())
+		exit(1)
+	}
+
+	if report.Total.Failed > 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func() { cfg = config{} }()
+
+	state := filepath.Join(dir, "state.json")
+	ioutil.WriteFile(state, []byte(`[
+		{"id": "1", "type": "A", "name": "www.example.com", "content": "192.0.2.1", "ttl": 3600, "zone_name": "example.com"},
+		{"id": "2", "type": "A", "name": "old.example.com", "content": "192.0.2.2", "ttl": 3600, "zone_name": "example.com"},
+		{"id": "3", "type": "A", "name": "www.example.org", "content": "192.0.2.3", "ttl": 3600, "zone_name": "example.org"}
+	]`), 0600)
+
+	com := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(com, []byte("$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 2 3 4 5\nwww 3600 IN A 192.0.2.1\n"), 0600)
+
+	org := filepath.Join(dir, "example.org.zone")
+	ioutil.WriteFile(org, []byte("$ORIGIN example.org.\n@ 3600 IN SOA ns1 hostmaster 1 2 3 4 5\nwww 3600 IN A 192.0.2.3\n"), 0600)
+
+	config := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(config, []byte("zones:\n  example.com:\n    file: "+com+"\n  example.org:\n    file: "+org+"\n"), 0600)
+
+	realNow := now
+	now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() { now = realNow }()
+
+	var b bytes.Buffer
+	realStdout := stdout
+	stdout = &b
+	defer func() { stdout = realStdout }()
+
+	runAudit([]string{"-all", "-config", config, "-remotestate", state})
+
+	expected := `example.com: drifted
+  delete old.example.com. 3600 IN A     192.0.2.2
+example.org: unchanged
+
+Total: 2 zones, 1 drifted, 0 failed. Records to delete: 1, to add: 0, to update: 0
+`
+
+	if b.String() != expected {
+		t.Errorf("runAudit() printed wrong report, got [%s], expected [%s]", b.String(), expected)
+	}
+
+	b.Reset()
+	runAudit([]string{"-all", "-config", config, "-remotestate", state, "-format", "json"})
+
+	var report driftReport
+	err = json.Unmarshal(b.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("runAudit() printed invalid JSON: %s", err.Error())
+	}
+
+	if report.Total.Drifted != 1 || report.Total.Deletes != 1 || len(report.Zones) != 2 {
+		t.Errorf("runAudit() reported wrong totals: %+v", report.Total)
+	}
+
+	html := filepath.Join(dir, "report.html")
+	runAudit([]string{"-all", "-config", config, "-remotestate", state, "-format", "html", "-output", html})
+
+	content, _ := ioutil.ReadFile(html)
+	for _, s := range []string{"2 zones, 1 drifted", "old.example.com", "example.org: <span class=\"unchanged\">"} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("HTML report is missing '%s'", s)
+		}
+	}
+}
+
+func TestRunAuditZoneOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func() { cfg = config{} }()
+	defer parseArguments([]string{"./test", "zone"})
+
+	state := filepath.Join(dir, "state.json")
+	ioutil.WriteFile(state, []byte(`[
+		{"id": "1", "type": "A", "name": "www.example.com", "content": "192.0.2.1", "ttl": 3600, "zone_name": "example.com"},
+		{"id": "2", "type": "A", "name": "old.example.com", "content": "192.0.2.2", "ttl": 3600, "zone_name": "example.com"}
+	]`), 0600)
+
+	com := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(com, []byte("$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 2 3 4 5\nwww 3600 IN A 192.0.2.1\n"), 0600)
+
+	// Unknown records are left alone by syncs of example.com, and must not
+	// show up as drift.
+	config := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(config, []byte("zones:\n  example.com:\n    file: "+com+"\n    flags:\n      leaveunknown: \"true\"\n"), 0600)
+
+	var b bytes.Buffer
+	realStdout := stdout
+	stdout = &b
+	defer func() { stdout = realStdout }()
+
+	runAudit([]string{"-all", "-config", config, "-remotestate", state})
+
+	if !strings.HasPrefix(b.String(), "example.com: unchanged\n") {
+		t.Errorf("runAudit() didn't apply the zone options: %s", b.String())
+	}
+
+	b.Reset()
+	runDiff([]string{"-config", config, "-remotestate", state, com})
+
+	if !strings.Contains(b.String(), "Records to delete: 0") {
+		t.Errorf("runDiff() didn't apply the zone options: %s", b.String())
+	}
+}
+
+func TestAuditPathsMissingFile(t *testing.T) {
+	defer func() { cfg = config{} }()
+
+	cfg = config{Zones: map[string]zoneConfig{
+		"example.com": {File: "example.com.zone"},
+		"example.net": {},
+	}}
+
+	paths, missing := auditPaths([]string{"example.com.zone", "other.zone"}, true)
+
+	if strings.Join(paths, ",") != "example.com.zone,other.zone" {
+		t.Errorf("auditPaths() returned wrong paths %v", paths)
+	}
+
+	if len(missing) != 1 || missing[0].Name != "example.net" || missing[0].Status != driftFailed {
+		t.Errorf("auditPaths() returned wrong missing zones %+v", missing)
+	}
+}
+
+func TestRunAuditUsage(t *testing.T) {
+	defer expectExit(t, 1)
+
+	runAudit([]string{})
+}
+
+func TestRunAuditFormat(t *testing.T) {
+	defer expectExit(t, 1)
+
+	runAudit([]string{"-all", "-format", "pdf"})
+}
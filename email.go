@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendMail is used to send email, tests can replace it.
+var sendMail = smtp.SendMail
+
+// emailNotifier sends results by email using SMTP.
+type emailNotifier struct {
+	config   emailConfig
+	password string
+}
+
+// Notify implements notifier.
+func (e *emailNotifier) Notify(r *result) error {
+	if e.config.Server == "" || e.config.From == "" || len(e.config.To) == 0 {
+		return fmt.Errorf("email notifications require server, from and to")
+	}
+
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		host, _, err := net.SplitHostPort(e.config.Server)
+		if err != nil {
+			return err
+		}
+
+		auth = smtp.PlainAuth("", e.config.Username, e.password, host)
+	}
+
+	return sendMail(e.config.Server, auth, e.config.From, e.config.To, e.message(r))
+}
+
+// message will build the email for r.
+func (e *emailNotifier) message(r *result) []byte {
+	var b bytes.Buffer
+
+	subject := strings.SplitN(r.Summary(), "\n", 2)[0]
+
+	fmt.Fprintf(&b, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.config.To, ", "))
+	fmt.Fprintf(&b, "Subject: [cfzone] %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&b, "\r\n")
+
+	var body bytes.Buffer
+	if r.Plan != nil {
+		r.Plan.Fprint(&body)
+		fmt.Fprintf(&body, "\n")
+	}
+
+	if r.Err != nil {
+		fmt.Fprintf(&body, "Result: failed after %s: %s\n", r.Duration, redact(r.Err.Error()))
+	} else {
+		fmt.Fprintf(&body, "Result: applied in %s\n", r.Duration)
+	}
+
+	b.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	return b.Bytes()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailNotifier(t *testing.T) {
+	defer func() {
+		sendMail = smtp.SendMail
+		now = time.Now
+	}()
+
+	now = func() time.Time { return time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	var sentAddr, sentFrom string
+	var sentTo []string
+	var sentAuth smtp.Auth
+	var sent string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sentAddr, sentAuth, sentFrom, sentTo, sent = addr, a, from, to, string(msg)
+		return nil
+	}
+
+	e := &emailNotifier{
+		config: emailConfig{
+			Server:   "smtp.example.com:587",
+			From:     "cfzone@example.com",
+			To:       []string{"a@example.com", "b@example.com"},
+			Username: "cfzone",
+		},
+		password: "secret",
+	}
+
+	p := &plan{ZoneName: "example.com", Adds: recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300}}}
+	err := e.Notify(&result{ZoneName: "example.com", Plan: p, Duration: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("Notify() returned error: %s", err.Error())
+	}
+
+	if sentAddr != "smtp.example.com:587" || sentFrom != "cfzone@example.com" || len(sentTo) != 2 || sentAuth == nil {
+		t.Errorf("Notify() sent to wrong server or recipients: %s %s %v", sentAddr, sentFrom, sentTo)
+	}
+
+	expected := "From: cfzone@example.com\r\n" +
+		"To: a@example.com, b@example.com\r\n" +
+		"Subject: [cfzone] example.com: add 1 A record\r\n" +
+		"Date: Tue, 01 Jan 2019 12:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Records to add:\r\n" +
+		"www.example.com. 300 IN A     192.0.2.1\r\n" +
+		"\r\n" +
+		"Summary:\r\n" +
+		"Records to delete: 0\r\n" +
+		"Records to add: 1\r\n" +
+		"Records to update: 0\r\n" +
+		"Unchanged records: 0\r\n" +
+		"\r\n" +
+		"Result: applied in 2s\r\n"
+
+	if sent != expected {
+		t.Errorf("Notify() sent [%s], expected [%s]", sent, expected)
+	}
+
+	err = e.Notify(&result{ZoneName: "example.com", Err: errors.New("broken")})
+	if err != nil {
+		t.Fatalf("Notify() returned error: %s", err.Error())
+	}
+
+	if !strings.Contains(sent, "Subject: [cfzone] Sync of example.com failed: broken\r\n") || !strings.Contains(sent, "Result: failed after 0s: broken\r\n") {
+		t.Errorf("Notify() sent wrong failure report [%s]", sent)
+	}
+}
+
+func TestEmailNotifierConfig(t *testing.T) {
+	cases := []emailConfig{
+		{From: "cfzone@example.com", To: []string{"a@example.com"}},
+		{Server: "smtp.example.com:25", To: []string{"a@example.com"}},
+		{Server: "smtp.example.com:25", From: "cfzone@example.com"},
+		{Server: "smtp.example.com", From: "cfzone@example.com", To: []string{"a@example.com"}, Username: "cfzone"},
+	}
+
+	for i, c := range cases {
+		err := (&emailNotifier{config: c}).Notify(&result{ZoneName: "example.com", Err: errors.New("broken")})
+		if err == nil {
+			t.Errorf("%d: Notify() accepted invalid configuration %+v", i, c)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// externalDNSListen is the address to serve the ExternalDNS webhook provider
+// on. Empty disables ExternalDNS mode.
+var externalDNSListen = ""
+
+// externalDNSOwner is the owner ID of ExternalDNS, as given by its
+// --txt-owner-id. Only records claimed for this owner by the TXT registry can
+// be changed or deleted.
+var externalDNSOwner = "default"
+
+// externalDNSMediaType is the content type used by the ExternalDNS webhook
+// provider protocol.
+const externalDNSMediaType = "application/external.dns.webhook+json;version=1"
+
+// proxiedProperty is the provider specific property used by ExternalDNS to
+// enable the Cloudflare proxy.
+const proxiedProperty = "external-dns.alpha.kubernetes.io/cloudflare-proxied"
+
+type (
+	// endpoint is a record set as seen by ExternalDNS.
+	endpoint struct {
+		DNSName          string             `json:"dnsName"`
+		Targets          []string           `json:"targets"`
+		RecordType       string             `json:"recordType"`
+		SetIdentifier    string             `json:"setIdentifier,omitempty"`
+		RecordTTL        int64              `json:"recordTTL,omitempty"`
+		Labels           map[string]string  `json:"labels,omitempty"`
+		ProviderSpecific []providerProperty `json:"providerSpecific,omitempty"`
+	}
+
+	// providerProperty is a provider specific property of an endpoint.
+	providerProperty struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// changes is a set of changes requested by ExternalDNS.
+	changes struct {
+		Create    []*endpoint `json:"Create"`
+		UpdateOld []*endpoint `json:"UpdateOld"`
+		UpdateNew []*endpoint `json:"UpdateNew"`
+		Delete    []*endpoint `json:"Delete"`
+	}
+
+	// domainFilter tells ExternalDNS which domains we handle.
+	domainFilter struct {
+		Include []string `json:"include"`
+	}
+
+	// externalDNS implements the ExternalDNS webhook provider protocol for
+	// a fixed set of zones. Records matching the ignore patterns from the
+	// configuration are protected, and can't be changed by ExternalDNS.
+	externalDNS struct {
+		zones []string
+
+		// newProvider is used to get the DNS provider.
+		newProvider func() (cfzone.Provider, error)
+
+		// lock serializes all requests.
+		lock sync.Mutex
+	}
+)
+
+// newExternalDNS will instantiate a new ExternalDNS webhook provider for
+// zones.
+func newExternalDNS(zones []string) *externalDNS {
+	return &externalDNS{
+		zones:       zones,
+		newProvider: newProvider,
+	}
+}
+
+// Handler returns the http.Handler for the provider.
+func (e *externalDNS) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", e.negotiate)
+	mux.HandleFunc("/records", e.records)
+	mux.HandleFunc("/adjustendpoints", e.adjustEndpoints)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeExternalDNS will respond with v encoded as JSON.
+func writeExternalDNS(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", externalDNSMediaType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// negotiate responds with the domains we handle.
+func (e *externalDNS) negotiate(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeExternalDNS(w, http.StatusOK, domainFilter{Include: e.zones})
+}
+
+// records will list all records on GET, and apply changes on POST.
+func (e *externalDNS) records(w http.ResponseWriter, r *http.Request) {
+	provider, err := e.newProvider()
+	if err != nil {
+		http.Error(w, redact(err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		all := []*endpoint{}
+		for _, zoneName := range e.zones {
+			_, records, err := fetchZone(provider, zoneName)
+			if err != nil {
+				http.Error(w, redact(err.Error()), http.StatusInternalServerError)
+				return
+			}
+
+			all = append(all, endpoints(records.WithoutIgnored(cfg.ignorePatterns(zoneName)))...)
+		}
+
+		writeExternalDNS(w, http.StatusOK, all)
+
+	case "POST":
+		var c changes
+		err = json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = e.apply(provider, &c)
+		if err != nil {
+			errorf("ExternalDNS changes failed: %s", err.Error())
+			http.Error(w, redact(err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// adjustEndpoints will normalize the endpoints from ExternalDNS to match what
+// we return from records, to avoid endless updates.
+func (e *externalDNS) adjustEndpoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var in []*endpoint
+	err := json.NewDecoder(r.Body).Decode(&in)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, ep := range in {
+		ep.setProxied(ep.proxied())
+	}
+
+	writeExternalDNS(w, http.StatusOK, in)
+}
+
+// zoneFor returns the zone handling name, or an empty string if name is not
+// in any of our zones.
+func (e *externalDNS) zoneFor(name string) string {
+	found := ""
+	name = cfzone.Name(name)
+
+	for _, zoneName := range e.zones {
+		if name == zoneName || strings.HasSuffix(name, "."+zoneName) {
+			if len(zoneName) > len(found) {
+				found = zoneName
+			}
+		}
+	}
+
+	return found
+}
+
+// apply will apply the changes using provider. Changes to protected records
+// or records outside our zones are refused before anything is changed.
+func (e *externalDNS) apply(provider cfzone.Provider, c *changes) error {
+	oldRecords := map[string]recordCollection{}
+	newRecords := map[string]recordCollection{}
+
+	add := func(to map[string]recordCollection, eps []*endpoint) error {
+		for _, ep := range eps {
+			zoneName := e.zoneFor(ep.DNSName)
+			if zoneName == "" {
+				return fmt.Errorf("'%s' is not in a managed zone", ep.DNSName)
+			}
+
+			if cfzone.Ignored(cfzone.Name(ep.DNSName), cfg.ignorePatterns(zoneName)) {
+				return fmt.Errorf("'%s' is protected", ep.DNSName)
+			}
+
+			records, err := ep.records()
+			if err != nil {
+				return err
+			}
+
+			to[zoneName] = append(to[zoneName], records...)
+		}
+
+		return nil
+	}
+
+	for _, err := range []error{
+		add(oldRecords, c.Delete),
+		add(oldRecords, c.UpdateOld),
+		add(newRecords, c.Create),
+		add(newRecords, c.UpdateNew),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, zoneName := range e.zones {
+		if len(oldRecords[zoneName]) == 0 && len(newRecords[zoneName]) == 0 {
+			continue
+		}
+
+		err := e.applyZone(provider, zoneName, oldRecords[zoneName], newRecords[zoneName])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyZone will remove oldRecords and add newRecords to zoneName.
+func (e *externalDNS) applyZone(provider cfzone.Provider, zoneName string, oldRecords recordCollection, newRecords recordCollection) error {
+	unlockZone, err := lockZone(zoneName)
+	if err != nil {
+		return err
+	}
+	defer unlockZone()
+
+	id, existing, err := fetchZone(provider, zoneName)
+	if err != nil {
+		return err
+	}
+
+	// We only delete records actually present at Cloudflare, and this will
+	// give us their IDs.
+	deleteCandidates := existing.Intersect(oldRecords, sameContent)
+	updates := deleteCandidates.Intersect(newRecords, cfzone.Updatable)
+
+	err = checkOwnership(existing, deleteCandidates, newRecords)
+	if err != nil {
+		return err
+	}
+
+	p := &plan{
+		ZoneName: zoneName,
+		ZoneID:   id,
+		Deletes:  deleteCandidates.Difference(updates, cfzone.Updatable),
+		Adds:     newRecords.Difference(updates, cfzone.Updatable),
+		Updates:  updates,
+		existing: existing,
+	}
+
+	p.sort()
+
+	traceDecisions(existing, p.Adds, p.Deletes, p.Updates)
+
+	err = p.auditPlanned()
+	if err != nil {
+		return err
+	}
+
+	return p.Apply(provider, ioutil.Discard)
+}
+
+// registryOwner returns the owner of r if it's a TXT registry record written
+// by ExternalDNS, like "heritage=external-dns,external-dns/owner=default", or
+// an empty string if it isn't.
+func registryOwner(r cloudflare.DNSRecord) string {
+	if r.Type != "TXT" {
+		return ""
+	}
+
+	heritage, owner := false, ""
+	for _, pair := range strings.Split(strings.Trim(r.Content, `"`), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "heritage":
+			heritage = kv[1] == "external-dns"
+
+		case "external-dns/owner":
+			owner = kv[1]
+		}
+	}
+
+	if !heritage {
+		return ""
+	}
+
+	return owner
+}
+
+// owned returns true if a TXT registry record in records claims records of
+// type t named name for externalDNSOwner. The registry record is named like
+// the record itself, or prefixed with the lower case type like "a-www".
+func owned(records recordCollection, name string, t string) bool {
+	for _, r := range records {
+		if registryOwner(r) != externalDNSOwner {
+			continue
+		}
+
+		if r.Name == name || strings.HasSuffix(r.Name, strings.ToLower(t)+"-"+name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkOwnership returns an error if ExternalDNS tries to delete or change
+// records in existing it doesn't own, to create records where existing has
+// records it doesn't own, or to touch the registry records of other owners.
+func checkOwnership(existing recordCollection, oldRecords recordCollection, newRecords recordCollection) error {
+	for _, r := range oldRecords {
+		if owner := registryOwner(r); owner != "" {
+			if owner != externalDNSOwner {
+				return fmt.Errorf("'%s' is owned by '%s'", r.Name, owner)
+			}
+
+			continue
+		}
+
+		if !owned(existing, r.Name, r.Type) {
+			return fmt.Errorf("%s record '%s' is not owned by ExternalDNS", r.Type, r.Name)
+		}
+	}
+
+	for _, r := range newRecords {
+		if owner := registryOwner(r); owner != "" {
+			if owner != externalDNSOwner {
+				return fmt.Errorf("'%s' is owned by '%s'", r.Name, owner)
+			}
+
+			continue
+		}
+
+		for _, e := range existing {
+			if e.Name == r.Name && e.Type == r.Type && registryOwner(e) == "" && !owned(existing, r.Name, r.Type) {
+				return fmt.Errorf("%s record '%s' is not owned by ExternalDNS", r.Type, r.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameContent will match records with the same name, type and content.
+func sameContent(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+	return a.Type == b.Type && a.Name == b.Name && a.Content == b.Content && cloudflare.Uint16(a.Priority) == cloudflare.Uint16(b.Priority)
+}
+
+// endpoints will group records by name and type as expected by ExternalDNS.
+func endpoints(records recordCollection) []*endpoint {
+	result := []*endpoint{}
+	index := map[string]*endpoint{}
+
+	for _, r := range records {
+		target := r.Content
+		if r.Type == "MX" {
+			target = fmt.Sprintf("%d %s", cloudflare.Uint16(r.Priority), r.Content)
+		}
+
+		key := r.Name + " " + r.Type
+		ep, found := index[key]
+		if !found {
+			ep = &endpoint{
+				DNSName:    r.Name,
+				RecordType: r.Type,
+			}
+
+			// Cloudflare uses a TTL of 1 for automatic.
+			if r.TTL > 1 {
+				ep.RecordTTL = int64(r.TTL)
+			}
+
+			ep.setProxied(cloudflare.Bool(r.Proxied))
+
+			index[key] = ep
+			result = append(result, ep)
+		}
+
+		ep.Targets = append(ep.Targets, target)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DNSName != result[j].DNSName {
+			return result[i].DNSName < result[j].DNSName
+		}
+
+		return result[i].RecordType < result[j].RecordType
+	})
+
+	return result
+}
+
+// records will return a record for each target of the endpoint.
+func (ep *endpoint) records() (recordCollection, error) {
+	result := recordCollection{}
+
+	for _, target := range ep.Targets {
+		r := cloudflare.DNSRecord{
+			Type:    ep.RecordType,
+			Name:    cfzone.Name(ep.DNSName),
+			Content: target,
+			TTL:     int(ep.RecordTTL),
+		}
+
+		if ep.proxied() {
+			r.Proxied = cloudflare.BoolPtr(true)
+		}
+
+		if r.TTL == 0 {
+			r.TTL = 1
+		}
+
+		switch ep.RecordType {
+		case "A", "AAAA", "TXT":
+
+		case "CNAME":
+			r.Content = cfzone.Target(r.Content)
+
+		case "MX":
+			fields := strings.Fields(target)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("Invalid MX target '%s'", target)
+			}
+
+			priority, err := strconv.ParseUint(fields[0], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid MX target '%s'", target)
+			}
+
+			r.Priority = cloudflare.Uint16Ptr(uint16(priority))
+			r.Content = cfzone.Target(fields[1])
+
+		default:
+			return nil, fmt.Errorf("Record type %s is not supported", ep.RecordType)
+		}
+
+		result = append(result, r)
+	}
+
+	// Like records fetched from Cloudflare, or they would never match
+	// them. ExternalDNS quotes the content of TXT registry records.
+	return result.FromCloudflare(), nil
+}
+
+// proxied returns true if the endpoint should be proxied by Cloudflare.
+func (ep *endpoint) proxied() bool {
+	for _, p := range ep.ProviderSpecific {
+		if p.Name == proxiedProperty {
+			return p.Value == "true"
+		}
+	}
+
+	return false
+}
+
+// setProxied will set the proxied property of the endpoint.
+func (ep *endpoint) setProxied(proxied bool) {
+	value := strconv.FormatBool(proxied)
+
+	for i, p := range ep.ProviderSpecific {
+		if p.Name == proxiedProperty {
+			ep.ProviderSpecific[i].Value = value
+			return
+		}
+	}
+
+	ep.ProviderSpecific = append(ep.ProviderSpecific, providerProperty{proxiedProperty, value})
+}
+
+// runExternalDNS will serve the ExternalDNS webhook provider for zones on
+// addr. It only returns on error.
+func runExternalDNS(addr string, zones []string) error {
+	return http.ListenAndServe(addr, redactPanics(newExternalDNS(zones).Handler()))
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestEndpoints(t *testing.T) {
+	records := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.2", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+		{Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10), TTL: 300},
+	}
+
+	expected := []*endpoint{
+		{DNSName: "example.com", RecordType: "MX", Targets: []string{"10 mail.example.com"}, RecordTTL: 300, ProviderSpecific: []providerProperty{{proxiedProperty, "false"}}},
+		{DNSName: "www.example.com", RecordType: "A", Targets: []string{"192.0.2.2", "192.0.2.1"}, ProviderSpecific: []providerProperty{{proxiedProperty, "true"}}},
+	}
+
+	result := endpoints(records)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("endpoints() returned %+v, expected %+v", result, expected)
+	}
+}
+
+func TestEndpointRecords(t *testing.T) {
+	cases := []struct {
+		ep       endpoint
+		expected recordCollection
+		err      bool
+	}{
+		{
+			endpoint{DNSName: "www.example.com", RecordType: "A", Targets: []string{"192.0.2.1", "192.0.2.2"}},
+			recordCollection{
+				{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1},
+				{Type: "A", Name: "www.example.com", Content: "192.0.2.2", TTL: 1},
+			},
+			false,
+		},
+		{
+			endpoint{DNSName: "www.example.com.", RecordType: "CNAME", Targets: []string{"example.com."}, RecordTTL: 300, ProviderSpecific: []providerProperty{{proxiedProperty, "true"}}},
+			recordCollection{{Type: "CNAME", Name: "www.example.com", Content: "example.com", TTL: 300, Proxied: cloudflare.BoolPtr(true)}},
+			false,
+		},
+		{
+			endpoint{DNSName: "WWW.Example.com.", RecordType: "A", Targets: []string{"192.0.2.1"}},
+			recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1}},
+			false,
+		},
+		{
+			endpoint{DNSName: "example.com", RecordType: "MX", Targets: []string{"10 mail.example.com."}},
+			recordCollection{{Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10), TTL: 1}},
+			false,
+		},
+		{
+			endpoint{DNSName: "example.com", RecordType: "MX", Targets: []string{"0 ."}},
+			recordCollection{{Type: "MX", Name: "example.com", Content: ".", Priority: cloudflare.Uint16Ptr(0), TTL: 1}},
+			false,
+		},
+		{endpoint{DNSName: "example.com", RecordType: "MX", Targets: []string{"mail.example.com"}}, nil, true},
+		{endpoint{DNSName: "example.com", RecordType: "SRV", Targets: []string{"0 0 80 example.com"}}, nil, true},
+	}
+
+	for i, c := range cases {
+		result, err := c.ep.records()
+		if (err != nil) != c.err {
+			t.Errorf("%d: records() returned wrong error: %v", i, err)
+		}
+
+		if !c.err && !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: records() returned %+v, expected %+v", i, result, c.expected)
+		}
+	}
+}
+
+func TestZoneFor(t *testing.T) {
+	e := newExternalDNS([]string{"example.com", "sub.example.com"})
+
+	cases := map[string]string{
+		"example.com":         "example.com",
+		"www.example.com":     "example.com",
+		"www.sub.example.com": "sub.example.com",
+		"www.example.com.":    "example.com",
+		"WWW.Example.com.":    "example.com",
+		"notexample.com":      "",
+		"example.org":         "",
+	}
+
+	for name, expected := range cases {
+		result := e.zoneFor(name)
+		if result != expected {
+			t.Errorf("zoneFor(%s) returned '%s', expected '%s'", name, result, expected)
+		}
+	}
+}
+
+func TestExternalDNSRefusesChanges(t *testing.T) {
+	cfg = config{Ignore: []string{"protected.example.com"}}
+	defer func() { cfg = config{} }()
+
+	e := newExternalDNS([]string{"example.com"})
+
+	cases := []changes{
+		{Create: []*endpoint{{DNSName: "www.example.org", RecordType: "A", Targets: []string{"192.0.2.1"}}}},
+		{Delete: []*endpoint{{DNSName: "protected.example.com", RecordType: "A", Targets: []string{"192.0.2.1"}}}},
+		{UpdateNew: []*endpoint{{DNSName: "www.example.com", RecordType: "SRV", Targets: []string{"broken"}}}},
+	}
+
+	for i, c := range cases {
+		// A nil provider will panic if apply tries to touch the zone.
+		err := e.apply(nil, &c)
+		if err == nil {
+			t.Errorf("%d: apply() accepted %+v", i, c)
+		}
+	}
+}
+
+func TestCheckOwnership(t *testing.T) {
+	registry := func(name string, owner string) cloudflare.DNSRecord {
+		return cloudflare.DNSRecord{Type: "TXT", Name: name, Content: `"heritage=external-dns,external-dns/owner=` + owner + `,external-dns/resource=ingress/default/www"`}
+	}
+
+	existing := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+		registry("a-www.example.com", "default"),
+		{Type: "A", Name: "old.example.com", Content: "192.0.2.2"},
+		registry("old.example.com", "default"),
+		{Type: "A", Name: "static.example.com", Content: "192.0.2.3"},
+		{Type: "A", Name: "other.example.com", Content: "192.0.2.4"},
+		registry("a-other.example.com", "other"),
+	}
+
+	cases := []struct {
+		old      recordCollection
+		new      recordCollection
+		expected bool
+	}{
+		{recordCollection{existing[0]}, nil, true},
+		{recordCollection{existing[2]}, nil, true},
+		{recordCollection{existing[1]}, nil, true},
+		{recordCollection{existing[4]}, nil, false},
+		{recordCollection{existing[5]}, nil, false},
+		{recordCollection{existing[6]}, nil, false},
+		{nil, recordCollection{{Type: "A", Name: "new.example.com", Content: "192.0.2.5"}, registry("a-new.example.com", "default")}, true},
+		{nil, recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.5"}}, true},
+		{nil, recordCollection{{Type: "A", Name: "static.example.com", Content: "192.0.2.5"}}, false},
+		{nil, recordCollection{{Type: "AAAA", Name: "static.example.com", Content: "2001:db8::1"}}, true},
+		{nil, recordCollection{registry("a-other.example.com", "other")}, false},
+	}
+
+	for i, c := range cases {
+		err := checkOwnership(existing, c.old, c.new)
+		if (err == nil) != c.expected {
+			t.Errorf("%d: checkOwnership() returned %v", i, err)
+		}
+	}
+
+	externalDNSOwner = "other"
+	defer func() { externalDNSOwner = "default" }()
+
+	err := checkOwnership(existing, recordCollection{existing[5]}, nil)
+	if err != nil {
+		t.Errorf("checkOwnership() refused the record of -externaldnsowner: %s", err.Error())
+	}
+}
+
+func TestExternalDNSDeleteRegistry(t *testing.T) {
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{
+		{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1", TTL: 1},
+		{Type: "TXT", Name: "aaaa-www.example.com", Content: "heritage=external-dns,external-dns/owner=default", TTL: 1},
+		{Type: "A", Name: "static.example.com", Content: "192.0.2.1", TTL: 1},
+	})
+
+	e := newExternalDNS([]string{"example.com"})
+
+	c := changes{Delete: []*endpoint{
+		{DNSName: "www.example.com", RecordType: "AAAA", Targets: []string{"2001:DB8:0::1"}},
+		{DNSName: "aaaa-www.example.com", RecordType: "TXT", Targets: []string{`"heritage=external-dns,external-dns/owner=default"`}},
+	}}
+
+	err := e.apply(m, &c)
+	if err != nil {
+		t.Fatalf("apply() failed: %s", err.Error())
+	}
+
+	records, _ := m.List("example.com")
+	if len(records) != 1 || records[0].Name != "static.example.com" {
+		t.Errorf("apply() left %+v", records)
+	}
+}
+
+func TestExternalDNSHandler(t *testing.T) {
+	e := newExternalDNS([]string{"example.com"})
+	e.newProvider = func() (cfzone.Provider, error) {
+		return nil, errors.New("no network in tests")
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/", "", http.StatusOK},
+		{"GET", "/nonexisting", "", http.StatusNotFound},
+		{"GET", "/records", "", http.StatusInternalServerError},
+		{"GET", "/adjustendpoints", "", http.StatusMethodNotAllowed},
+		{"POST", "/adjustendpoints", "broken", http.StatusBadRequest},
+		{"POST", "/adjustendpoints", `[{"dnsName":"www.example.com","recordType":"A","targets":["192.0.2.1"]}]`, http.StatusOK},
+	}
+
+	for i, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, bytes.NewBufferString(c.body))
+		w := httptest.NewRecorder()
+
+		e.Handler().ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%d: %s %s returned %d, expected %d: %s", i, c.method, c.path, w.Code, c.status, w.Body.String())
+		}
+	}
+}
+
+func TestExternalDNSNegotiate(t *testing.T) {
+	e := newExternalDNS([]string{"example.com"})
+
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Header().Get("Content-Type") != externalDNSMediaType {
+		t.Errorf("negotiate returned wrong content type '%s'", w.Header().Get("Content-Type"))
+	}
+
+	var filter domainFilter
+	json.Unmarshal(w.Body.Bytes(), &filter)
+	if !reflect.DeepEqual(filter.Include, []string{"example.com"}) {
+		t.Errorf("negotiate returned wrong domain filter %+v", filter)
+	}
+}
+
+func TestExternalDNSRequiresYes(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-externaldns", ":8888", "example.com"})
+}
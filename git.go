@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitPrefix marks zone files read from git, like
+// "git+https://example.com/dns.git#main:zones/example.com.zone".
+const gitPrefix = "git+"
+
+// gitCacheDir is where fetched repositories are kept between syncs. Empty
+// means a directory in the user cache directory.
+var gitCacheDir = ""
+
+// gitSource is a zone file in a git repository.
+type gitSource struct {
+	url  string
+	ref  string
+	path string
+}
+
+// isGitSource returns true if path refers to a zone file in git.
+func isGitSource(path string) bool {
+	return strings.HasPrefix(path, gitPrefix)
+}
+
+// parseGitSource will parse a git source on the form
+// "git+<url>#<ref>:<path>".
+func parseGitSource(source string) (*gitSource, error) {
+	s := strings.TrimPrefix(source, gitPrefix)
+
+	i := strings.LastIndex(s, "#")
+	if i < 0 {
+		return nil, fmt.Errorf("Missing '#<ref>:<path>' in git source '%s'", source)
+	}
+
+	refPath := strings.SplitN(s[i+1:], ":", 2)
+	if len(refPath) != 2 || refPath[0] == "" || refPath[1] == "" || s[:i] == "" {
+		return nil, fmt.Errorf("Git source '%s' must be on the form 'git+<url>#<ref>:<path>'", source)
+	}
+
+	return &gitSource{
+		url:  s[:i],
+		ref:  refPath[0],
+		path: refPath[1],
+	}, nil
+}
+
+// dir returns the local directory used for the repository.
+func (g *gitSource) dir() (string, error) {
+	base := gitCacheDir
+	if base == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+
+		base = filepath.Join(cache, "cfzone", "git")
+	}
+
+	sum := sha256.Sum256([]byte(g.url))
+
+	return filepath.Join(base, hex.EncodeToString(sum[:8])), nil
+}
+
+// git will run git with args in dir, and return the output.
+func git(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %s: %s", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// Read will do a shallow fetch of the ref, and return the content of the
+// zone file. Nothing is checked out.
+func (g *gitSource) Read() ([]byte, error) {
+	dir, err := g.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "HEAD")); os.IsNotExist(err) {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = git(dir, "init", "--quiet", "--bare")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	debugf(1, "Fetching %s from %s", g.ref, g.url)
+
+	// The url and ref come from the command line or configuration, and "--"
+	// keeps them from being taken as options like --upload-pack.
+	_, err = git(dir, "fetch", "--quiet", "--depth", "1", "--", g.url, g.ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return git(dir, "show", "FETCH_HEAD:"+g.path)
+}
+
+// openZone will open the zone file at path, which can be a local file or a
+// git source.
+func openZone(path string) (io.ReadCloser, error) {
+	if !isGitSource(path) {
+		return os.Open(path)
+	}
+
+	g, err := parseGitSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := g.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGitSource(t *testing.T) {
+	cases := []struct {
+		source   string
+		expected *gitSource
+	}{
+		{"git+https://example.com/dns.git#main:zones/example.com.zone", &gitSource{"https://example.com/dns.git", "main", "zones/example.com.zone"}},
+		{"git+ssh://git@example.com/dns.git#v1.0:example.com.zone", &gitSource{"ssh://git@example.com/dns.git", "v1.0", "example.com.zone"}},
+		{"git+https://example.com/dns.git", nil},
+		{"git+https://example.com/dns.git#main", nil},
+		{"git+https://example.com/dns.git#:example.com.zone", nil},
+		{"git+https://example.com/dns.git#main:", nil},
+		{"git+#main:example.com.zone", nil},
+	}
+
+	for _, c := range cases {
+		result, err := parseGitSource(c.source)
+		if c.expected == nil {
+			if err == nil {
+				t.Errorf("parseGitSource(%s) did not return error", c.source)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseGitSource(%s) returned error: %s", c.source, err.Error())
+		}
+
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("parseGitSource(%s) returned %+v, expected %+v", c.source, result, c.expected)
+		}
+	}
+}
+
+func TestOpenZoneGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	gitCacheDir = filepath.Join(dir, "cache")
+	defer func() { gitCacheDir = "" }()
+
+	repo := filepath.Join(dir, "repo")
+	os.MkdirAll(filepath.Join(repo, "zones"), 0755)
+
+	commit := func(content string) {
+		ioutil.WriteFile(filepath.Join(repo, "zones", "example.com.zone"), []byte(content), 0644)
+
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", content},
+		} {
+			_, err := git(repo, args...)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+		}
+	}
+
+	_, err = git(repo, "init", "--quiet", "-b", "main")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	source := "git+file://" + repo + "#main:zones/example.com.zone"
+
+	for _, content := range []string{"first", "second"} {
+		commit(content)
+
+		f, err := openZone(source)
+		if err != nil {
+			t.Fatalf("openZone() returned error: %s", err.Error())
+		}
+
+		b, _ := ioutil.ReadAll(f)
+		f.Close()
+
+		if string(b) != content {
+			t.Errorf("openZone() returned '%s', expected '%s'", string(b), content)
+		}
+	}
+
+	_, err = openZone("git+file://" + repo + "#main:nonexisting")
+	if err == nil {
+		t.Errorf("openZone() did not return error for missing file")
+	}
+
+	marker := filepath.Join(dir, "injected")
+	_, err = openZone("git+--upload-pack=touch " + marker + "#main:zones/example.com.zone")
+	if err == nil {
+		t.Errorf("openZone() accepted an option as url")
+	}
+
+	if _, err = os.Stat(marker); err == nil {
+		t.Errorf("openZone() ran the --upload-pack given as url")
+	}
+}
+
+func TestWatchGitSource(t *testing.T) {
+	err := watchZones([]string{"git+https://example.com/dns.git#main:example.com.zone"}, nil, nil)
+	if err == nil {
+		t.Errorf("watchZones() accepted a git source")
+	}
+}
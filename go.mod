@@ -0,0 +1,16 @@
+module github.com/anderskvist/cfzone
+
+go 1.21
+
+require (
+	github.com/cloudflare/cloudflare-go v0.86.0
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/miekg/dns v1.0.15
+	golang.org/x/crypto v0.31.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+)
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// health tracks the state of a long-running cfzone for health and readiness
+// checks.
+type health struct {
+	lock sync.Mutex
+
+	// credentialsErr is set when Cloudflare rejects our credentials.
+	credentialsErr error
+
+	// paths holds the result of the last sync of each zone file, one
+	// failing zone must not be hidden by the others.
+	paths map[string]*pathHealth
+}
+
+// pathHealth is the result of syncing a single zone file.
+type pathHealth struct {
+	lastSuccess time.Time
+	lastErr     error
+}
+
+// status is the health of this process.
+var status = &health{}
+
+// apiResponse should be called with the status code of every response from
+// the Cloudflare API. Only 401 means our credentials are rejected. A 403 is a
+// token lacking permissions for some zone, which a restart won't fix, and it
+// fails the sync of that zone instead.
+func (h *health) apiResponse(code int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	switch {
+	case code == http.StatusUnauthorized:
+		h.credentialsErr = fmt.Errorf("credentials rejected by Cloudflare (%d)", code)
+
+	case code >= 200 && code < 300:
+		h.credentialsErr = nil
+	}
+}
+
+// synced should be called after each sync of the zone file at path with the
+// result.
+func (h *health) synced(path string, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.paths == nil {
+		h.paths = map[string]*pathHealth{}
+	}
+
+	p, found := h.paths[path]
+	if !found {
+		p = &pathHealth{}
+		h.paths[path] = p
+	}
+
+	p.lastErr = err
+	if err == nil {
+		p.lastSuccess = now()
+	}
+}
+
+// alive returns an error if cfzone can't do anything useful without outside
+// intervention.
+func (h *health) alive() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.credentialsErr
+}
+
+// ready returns an error if the zones can't be considered in sync.
+func (h *health) ready() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.credentialsErr != nil {
+		return h.credentialsErr
+	}
+
+	// In watch and daemon mode we sync at startup, and we're not ready
+	// until that has succeeded.
+	if (watch || interval > 0) && len(h.paths) == 0 {
+		return errors.New("no successful sync yet")
+	}
+
+	paths := []string{}
+	for path := range h.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		p := h.paths[path]
+
+		if p.lastErr != nil {
+			return fmt.Errorf("%s: %s", path, p.lastErr.Error())
+		}
+
+		if (watch || interval > 0) && p.lastSuccess.IsZero() {
+			return fmt.Errorf("%s: no successful sync yet", path)
+		}
+
+		if interval > 0 && now().Sub(p.lastSuccess) > 3*interval {
+			return fmt.Errorf("%s: last successful sync at %s", path, p.lastSuccess.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// healthHandler returns a handler responding with 200 if check returns nil,
+// and 503 otherwise.
+func healthHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := check()
+		if err != nil {
+			http.Error(w, redact(err.Error()), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintf(w, "ok\n")
+	}
+}
+
+// sdNotify will send state to systemd if running as a notify service. Errors
+// are ignored, systemd will notice if we fail to notify.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	// Abstract sockets are given with a leading @.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(state))
+}
+
+// runWatchdog will tell systemd that we're ready after the first successful
+// sync, and keep pinging the systemd watchdog while we're alive.
+func runWatchdog() {
+	usec, _ := strconv.Atoi(os.Getenv("WATCHDOG_USEC"))
+
+	go func() {
+		defer redactPanic()
+
+		ready := false
+
+		for {
+			if !ready && status.ready() == nil {
+				sdNotify("READY=1")
+				ready = true
+			}
+
+			if usec > 0 && status.alive() == nil {
+				sdNotify("WATCHDOG=1")
+			}
+
+			// We ping at twice the rate required by systemd, or
+			// check for readiness every second.
+			delay := time.Second
+			if usec > 0 && time.Duration(usec)*time.Microsecond/2 < delay {
+				delay = time.Duration(usec) * time.Microsecond / 2
+			}
+
+			time.Sleep(delay)
+		}
+	}()
+}
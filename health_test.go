@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHealthAlive(t *testing.T) {
+	h := &health{}
+
+	if h.alive() != nil {
+		t.Errorf("alive() returned error before any API response")
+	}
+
+	h.apiResponse(http.StatusForbidden)
+	if h.alive() != nil {
+		t.Errorf("alive() returned error after missing permissions")
+	}
+
+	h.apiResponse(http.StatusUnauthorized)
+	if h.alive() == nil {
+		t.Errorf("alive() did not return error after rejected credentials")
+	}
+
+	h.apiResponse(http.StatusInternalServerError)
+	if h.alive() == nil {
+		t.Errorf("alive() recovered after unrelated error")
+	}
+
+	h.apiResponse(http.StatusOK)
+	if h.alive() != nil {
+		t.Errorf("alive() returned error after successful API response")
+	}
+}
+
+func TestHealthReady(t *testing.T) {
+	defer func() {
+		now = time.Now
+		interval = 0
+		watch = false
+	}()
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := start
+	now = func() time.Time { return current }
+	interval = time.Minute
+	watch = false
+
+	h := &health{}
+	if h.ready() == nil {
+		t.Errorf("ready() returned nil before first sync")
+	}
+
+	h.synced("a.zone", nil)
+	if h.ready() != nil {
+		t.Errorf("ready() returned error after successful sync")
+	}
+
+	h.synced("a.zone", errors.New("failed"))
+	if h.ready() == nil {
+		t.Errorf("ready() returned nil after failed sync")
+	}
+
+	// One healthy zone file must not hide a failing one.
+	h.synced("b.zone", nil)
+	if h.ready() == nil {
+		t.Errorf("ready() returned nil with a failing zone file")
+	}
+
+	h.synced("a.zone", nil)
+	if h.ready() != nil {
+		t.Errorf("ready() returned error after all zone files synced")
+	}
+
+	current = start.Add(4 * time.Minute)
+	h.synced("a.zone", nil)
+	if h.ready() == nil {
+		t.Errorf("ready() returned nil for stale sync of b.zone")
+	}
+
+	h.synced("b.zone", nil)
+	h.apiResponse(http.StatusForbidden)
+	if h.ready() != nil {
+		t.Errorf("ready() returned error after missing permissions without failing sync")
+	}
+
+	h.apiResponse(http.StatusUnauthorized)
+	if h.ready() == nil {
+		t.Errorf("ready() returned nil with rejected credentials")
+	}
+
+	interval = 0
+	if (&health{}).ready() != nil {
+		t.Errorf("ready() returned error outside watch and daemon mode")
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{nil, http.StatusOK},
+		{errors.New("broken"), http.StatusServiceUnavailable},
+	}
+
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		healthHandler(func() error { return c.err })(w, httptest.NewRequest("GET", "/healthz", nil))
+
+		if w.Code != c.status {
+			t.Errorf("%d: healthHandler() returned status %d, expected %d", i, w.Code, c.status)
+		}
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	sdNotify("READY=1")
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read notification: %s", err.Error())
+	}
+
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("sdNotify() sent '%s', expected 'READY=1'", string(buf[:n]))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// preHook is a command run before applying changes. It receives the plan
+	// as JSON on stdin, and changes are not applied if it fails.
+	preHook = ""
+
+	// postHook is a command run after applying changes. It receives the
+	// result as JSON on stdin.
+	postHook = ""
+)
+
+// runCommand will run command with in encoded as JSON on stdin, and return
+// what it writes to stdout. The command is split on spaces and run without a
+// shell. The zone name is available to the command in CFZONE_ZONE.
+func runCommand(command string, zoneName string, in interface{}) ([]byte, error) {
+	args := strings.Fields(command)
+
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var out, errOut bytes.Buffer
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "CFZONE_ZONE="+zoneName)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed: %s: %s", command, err.Error(), strings.TrimSpace(errOut.String()))
+	}
+
+	return out.Bytes(), nil
+}
+
+// runPreHook will run the pre-apply hook for p, if any.
+func runPreHook(p *plan) error {
+	// Hooks could act on simulated changes as if they were real.
+	if preHook == "" || simulated != nil {
+		return nil
+	}
+
+	_, err := runCommand(preHook, p.ZoneName, p)
+	if err != nil {
+		return fmt.Errorf("Pre-apply hook %s", err.Error())
+	}
+
+	return nil
+}
+
+// runPostHook will run the post-apply hook for p, if any. applyErr is the
+// error returned when applying p. Failures are only logged, the changes are
+// already applied.
+func runPostHook(p *plan, applyErr error) {
+	if postHook == "" || simulated != nil {
+		return
+	}
+
+	result := applyResult{Plan: p, Applied: applyErr == nil}
+	if applyErr != nil {
+		result.Error = redact(applyErr.Error())
+	}
+
+	out, err := runCommand(postHook, p.ZoneName, result)
+	if err != nil {
+		errorf("Post-apply hook %s", err.Error())
+		return
+	}
+
+	if len(out) > 0 {
+		debugf(1, "Post-apply hook: %s", strings.TrimSpace(string(out)))
+	}
+}
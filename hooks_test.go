@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHookHelper is run as a hook by the tests below. It copies stdin to the
+// file given by CFZONE_TEST_HOOK, and fails if CFZONE_TEST_HOOK_FAIL is set.
+func TestHookHelper(t *testing.T) {
+	path := os.Getenv("CFZONE_TEST_HOOK")
+	if path == "" {
+		return
+	}
+
+	b, _ := ioutil.ReadAll(os.Stdin)
+	ioutil.WriteFile(path, b, 0600)
+
+	if os.Getenv("CFZONE_TEST_HOOK_FAIL") != "" {
+		os.Stderr.WriteString("denied")
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func TestHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Skipf("Can't find test binary: %s", err.Error())
+	}
+
+	path := filepath.Join(dir, "hook.json")
+	os.Setenv("CFZONE_TEST_HOOK", path)
+	defer os.Unsetenv("CFZONE_TEST_HOOK")
+
+	p := &plan{
+		ZoneName: "example.com",
+		Adds:     recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1"}},
+	}
+
+	preHook = ""
+	postHook = ""
+	if runPreHook(p) != nil {
+		t.Errorf("runPreHook() failed without a hook")
+	}
+
+	preHook = self + " -test.run=TestHookHelper"
+	postHook = preHook
+	defer func() {
+		preHook = ""
+		postHook = ""
+	}()
+
+	err = runPreHook(p)
+	if err != nil {
+		t.Fatalf("runPreHook() failed: %s", err.Error())
+	}
+
+	var got plan
+	b, _ := ioutil.ReadFile(path)
+	json.Unmarshal(b, &got)
+	if got.ZoneName != "example.com" || len(got.Adds) != 1 {
+		t.Errorf("Pre-apply hook got wrong plan: %s", b)
+	}
+
+	runPostHook(p, nil)
+
+	var result applyResult
+	b, _ = ioutil.ReadFile(path)
+	json.Unmarshal(b, &result)
+	if !result.Applied || result.Plan == nil || result.Plan.ZoneName != "example.com" {
+		t.Errorf("Post-apply hook got wrong result: %s", b)
+	}
+
+	os.Setenv("CFZONE_TEST_HOOK_FAIL", "1")
+	defer os.Unsetenv("CFZONE_TEST_HOOK_FAIL")
+
+	err = runPreHook(p)
+	if err == nil {
+		t.Errorf("runPreHook() didn't fail when the hook failed")
+	}
+}
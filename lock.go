@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// lockDir is where zone lock files are kept. Empty means the system
+	// temporary directory.
+	lockDir = ""
+
+	// lockWait is how long to wait for another cfzone to release a zone.
+	lockWait = time.Duration(0)
+
+	// lockRetry is the interval between attempts to take a lock.
+	lockRetry = 100 * time.Millisecond
+)
+
+// lockZone will take an exclusive lock on zoneName, preventing other cfzone
+// processes from applying to the same zone concurrently. The returned
+// function must be called to release the lock.
+func lockZone(zoneName string) (func(), error) {
+	dir := lockDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	path := filepath.Join(dir, "cfzone-"+zoneName+".lock")
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open lock file: %s", err.Error())
+	}
+
+	deadline := now().Add(lockWait)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Can't lock '%s': %s", path, err.Error())
+		}
+
+		if locked {
+			break
+		}
+
+		if !now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s is locked by another cfzone (%s)", zoneName, path)
+		}
+
+		debugf(1, "Waiting for lock on %s", zoneName)
+		time.Sleep(lockRetry)
+	}
+
+	return func() {
+		unlock(f)
+		f.Close()
+	}, nil
+}
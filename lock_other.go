@@ -0,0 +1,17 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import (
+	"os"
+)
+
+// tryLock is not supported on this platform, and will always succeed.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock does nothing on this platform.
+func unlock(f *os.File) {
+}
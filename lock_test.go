@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLockZone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	lockDir = dir
+	lockRetry = 10 * time.Millisecond
+	defer func() {
+		lockDir = ""
+		lockWait = 0
+		lockRetry = 100 * time.Millisecond
+	}()
+
+	unlockZone, err := lockZone("example.com")
+	if err != nil {
+		t.Fatalf("lockZone() returned error: %s", err.Error())
+	}
+
+	_, err = lockZone("example.com")
+	if err == nil {
+		t.Fatalf("lockZone() locked a zone already locked")
+	}
+
+	// Other zones should not be affected.
+	unlockOther, err := lockZone("example.org")
+	if err != nil {
+		t.Fatalf("lockZone() returned error for other zone: %s", err.Error())
+	}
+	unlockOther()
+
+	// Waiting should succeed when the lock is released.
+	lockWait = time.Second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlockZone()
+	}()
+
+	unlockZone, err = lockZone("example.com")
+	if err != nil {
+		t.Fatalf("lockZone() did not wait for lock: %s", err.Error())
+	}
+	unlockZone()
+}
+
+func TestLockZoneUnwritable(t *testing.T) {
+	lockDir = "/non/existing"
+	defer func() { lockDir = "" }()
+
+	_, err := lockZone("example.com")
+	if err == nil {
+		t.Errorf("lockZone() did not return error for missing directory")
+	}
+}
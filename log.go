@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+var (
+	// verbosity controls how much debug output is written to stderr. 0
+	// means no debug output, 1 will log API calls and 2 will add the
+	// reasoning behind each change.
+	verbosity = 0
+
+	// logFormat is the format of log lines written to stderr. Can be "text"
+	// for human readable output or "json" for JSON lines.
+	logFormat = "text"
+
+	// syslogTarget is where to send syslog messages. "local" means the
+	// local syslog daemon, "udp://host:port" or "tcp://host:port" a remote
+	// one. Empty means logging to stderr.
+	syslogTarget = ""
+
+	// logSyslog receives all log lines instead of stderr when set.
+	logSyslog syslogger
+)
+
+// syslogger is implemented by *syslog.Writer.
+type syslogger interface {
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+}
+
+// logLine is a single log line as written in JSON format.
+type logLine struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+}
+
+// registeredSecrets are secrets loaded at runtime, like tokens read from
+// files or fetched from Vault and metadata endpoints.
+var registeredSecrets struct {
+	sync.Mutex
+	secrets []string
+}
+
+// registerSecret will make sure secret is never output. Secrets must be
+// registered where they're loaded.
+func registerSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	registeredSecrets.Lock()
+	defer registeredSecrets.Unlock()
+
+	for _, s := range registeredSecrets.secrets {
+		if s == secret {
+			return
+		}
+	}
+
+	registeredSecrets.secrets = append(registeredSecrets.secrets, secret)
+}
+
+// secrets returns all secrets that must never be output.
+func secrets() []string {
+	registeredSecrets.Lock()
+	defer registeredSecrets.Unlock()
+
+	return append([]string{
+		apiKey,
+		apiToken,
+		apiSecondaryToken,
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		os.Getenv("AWS_SESSION_TOKEN"),
+		os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"),
+		os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		os.Getenv("VAULT_TOKEN"),
+	}, registeredSecrets.secrets...)
+}
+
+// redact will replace all known secrets in s.
+func redact(s string) string {
+	for _, secret := range secrets() {
+		if secret != "" {
+			s = strings.Replace(s, secret, "[REDACTED]", -1)
+		}
+	}
+
+	return s
+}
+
+// redactPanic must be deferred. It will make sure that secrets are not
+// present in the message printed by the runtime if we crash.
+func redactPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	switch v := r.(type) {
+	case error:
+		// Used by handlers to abort a response, and must reach the
+		// HTTP server as is.
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+
+		panic(redact(v.Error()))
+
+	case string:
+		panic(redact(v))
+	}
+
+	panic(r)
+}
+
+// redactPanics returns h with secrets redacted from panics in handlers, which
+// the HTTP server would otherwise log as is.
+func redactPanics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer redactPanic()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// logf will write a log line to stderr in the configured format. Secrets will
+// be redacted.
+func logf(level string, format string, a ...interface{}) {
+	msg := redact(fmt.Sprintf(format, a...))
+
+	if logSyslog != nil {
+		switch level {
+		case "error":
+			logSyslog.Err(msg)
+
+		case "warning":
+			logSyslog.Warning(msg)
+
+		case "debug":
+			logSyslog.Debug(msg)
+
+		default:
+			logSyslog.Info(msg)
+		}
+
+		return
+	}
+
+	if logFormat == "json" {
+		b, _ := json.Marshal(logLine{
+			Time:    now().UTC().Format(time.RFC3339),
+			Level:   level,
+			Message: msg,
+		})
+
+		fmt.Fprintf(stderr, "%s\n", b)
+		return
+	}
+
+	fmt.Fprintf(stderr, "%s\n", msg)
+}
+
+// errorf will log an error.
+func errorf(format string, a ...interface{}) {
+	logf("error", format, a...)
+}
+
+// warnf will log a warning.
+func warnf(format string, a ...interface{}) {
+	logf("warning", format, a...)
+}
+
+// debugf will log a debug message if the verbosity is at least level.
+func debugf(level int, format string, a ...interface{}) {
+	if verbosity < level {
+		return
+	}
+
+	logf("debug", format, a...)
+}
+
+// recordLine will return a one-line textual representation of r as output by
+// recordCollection.Fprint().
+func recordLine(r cloudflare.DNSRecord) string {
+	var b bytes.Buffer
+
+	w := bufio.NewWriter(&b)
+	recordCollection{r}.Fprint(w)
+	w.Flush()
+
+	return strings.TrimSpace(b.String())
+}
+
+// tracingTransport is a http.RoundTripper logging all requests to the DNS
+// provider API.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := now()
+	timings.call(req.Method)
+
+	s := tracing.newSpan(req.Method+" "+req.URL.Path, spanKindClient)
+	s.SetAttribute("http.method", req.Method)
+	s.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+
+	resp, err := t.next.RoundTrip(req)
+	apiDuration.Observe(req.Method, now().Sub(start).Seconds())
+	if err != nil {
+		s.End(err)
+		debugf(1, "%s %s failed after %s: %s", req.Method, req.URL.Path, now().Sub(start), err.Error())
+		return resp, err
+	}
+
+	s.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	s.End(nil)
+
+	debugf(1, "%s %s %d (%s)", req.Method, req.URL.Path, resp.StatusCode, now().Sub(start))
+	status.apiResponse(resp.StatusCode)
+
+	return resp, err
+}
+
+// traceDecisions will log why each record is about to be added, deleted or
+// updated.
+func traceDecisions(existing recordCollection, adds recordCollection, deletes recordCollection, updates recordCollection) {
+	if verbosity < 2 {
+		return
+	}
+
+	for _, r := range adds {
+		debugf(2, "add %s: not found at Cloudflare", recordLine(r))
+	}
+
+	for _, r := range deletes {
+		debugf(2, "delete %s: not found in zone file", recordLine(r))
+	}
+
+	for _, r := range updates {
+		_, old := existing.Find(r, sameID)
+		if old == nil {
+			debugf(2, "update %s: same name and type as existing record %s", recordLine(r), r.ID)
+			continue
+		}
+
+		debugf(2, "update %s: same name and type as %s", recordLine(r), recordLine(*old))
+	}
+}
+
+// sameID is a cfzone.FilterFunc matching records with the same Cloudflare ID.
+func sameID(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+	return a.ID == b.ID
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func captureStderr(verbose int) (*bytes.Buffer, func()) {
+	var b bytes.Buffer
+
+	stderr = &b
+	verbosity = verbose
+
+	return &b, func() {
+		stderr = ioutil.Discard
+		verbosity = 0
+	}
+}
+
+func TestDebugf(t *testing.T) {
+	cases := []struct {
+		verbosity int
+		level     int
+		expected  string
+	}{
+		{0, 1, ""},
+		{0, 2, ""},
+		{1, 1, "hello 1\n"},
+		{1, 2, ""},
+		{2, 1, "hello 1\n"},
+		{2, 2, "hello 2\n"},
+	}
+
+	for i, c := range cases {
+		b, restore := captureStderr(c.verbosity)
+		debugf(c.level, "hello %d", c.level)
+		restore()
+
+		if b.String() != c.expected {
+			t.Errorf("%d: debugf() wrote wrong output, got [%s], expected [%s]", i, b.String(), c.expected)
+		}
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	b, restore := captureStderr(1)
+	defer restore()
+
+	tr := &tracingTransport{next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "DELETE" {
+			return nil, errors.New("connection reset")
+		}
+
+		return &http.Response{StatusCode: 200}, nil
+	})}
+
+	req, _ := http.NewRequest("GET", "https://api.cloudflare.com/client/v4/zones", nil)
+	_, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() returned unexpected error: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(b.String(), "GET /client/v4/zones 200 (") {
+		t.Errorf("RoundTrip() logged wrong line: [%s]", b.String())
+	}
+
+	b.Reset()
+	req, _ = http.NewRequest("DELETE", "https://api.cloudflare.com/client/v4/zones", nil)
+	_, err = tr.RoundTrip(req)
+	if err == nil {
+		t.Fatalf("RoundTrip() did not forward error")
+	}
+
+	if !strings.Contains(b.String(), "connection reset") {
+		t.Errorf("RoundTrip() did not log error: [%s]", b.String())
+	}
+}
+
+func TestTraceDecisions(t *testing.T) {
+	old := cloudflare.DNSRecord{ID: "1", Type: "A", Name: "a1", Content: "127.0.0.1"}
+	updated := cloudflare.DNSRecord{ID: "1", Type: "A", Name: "a1", Content: "127.0.0.2"}
+	added := cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.3"}
+	deleted := cloudflare.DNSRecord{ID: "2", Type: "A", Name: "a3", Content: "127.0.0.4"}
+
+	b, restore := captureStderr(1)
+	traceDecisions(recordCollection{old, deleted}, recordCollection{added}, recordCollection{deleted}, recordCollection{updated})
+	restore()
+
+	if b.Len() != 0 {
+		t.Fatalf("traceDecisions() logged at verbosity 1: [%s]", b.String())
+	}
+
+	b, restore = captureStderr(2)
+	traceDecisions(recordCollection{old, deleted}, recordCollection{added}, recordCollection{deleted}, recordCollection{updated})
+	restore()
+
+	expected := `add a2. 0 IN A     127.0.0.3: not found at Cloudflare
+delete a3. 0 IN A     127.0.0.4: not found in zone file
+update a1. 0 IN A     127.0.0.2: same name and type as a1. 0 IN A     127.0.0.1
+`
+	if b.String() != expected {
+		t.Errorf("traceDecisions() logged wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestLogfJSON(t *testing.T) {
+	clock := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	b, restore := captureStderr(0)
+	defer restore()
+
+	logFormat = "json"
+	defer func() { logFormat = "text" }()
+
+	errorf("Can't get zone ID for '%s'", "example.com")
+
+	expected := `{"time":"2017-01-02T03:04:05Z","level":"error","msg":"Can't get zone ID for 'example.com'"}` + "\n"
+	if b.String() != expected {
+		t.Errorf("errorf() wrote wrong JSON, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestLogfText(t *testing.T) {
+	b, restore := captureStderr(0)
+	defer restore()
+
+	errorf("Too few arguments")
+
+	if b.String() != "Too few arguments\n" {
+		t.Errorf("errorf() wrote wrong text, got [%s]", b.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	apiKey = "0123456789abcdef"
+	defer func() { apiKey = "" }()
+
+	b, restore := captureStderr(2)
+	defer restore()
+
+	err := errors.New("error from Cloudflare: invalid key 0123456789abcdef")
+	errorf("Can't get zone ID for '%s': %s", "example.com", err.Error())
+	debugf(1, "GET /client/v4/zones?key=%s 403", apiKey)
+
+	logFormat = "json"
+	errorf("wrapped: %s", err)
+	logFormat = "text"
+
+	if strings.Contains(b.String(), apiKey) {
+		t.Errorf("Secret found in log output: [%s]", b.String())
+	}
+
+	if strings.Count(b.String(), "[REDACTED]") != 3 {
+		t.Errorf("Secret was not replaced in all lines: [%s]", b.String())
+	}
+}
+
+func TestRedactPanic(t *testing.T) {
+	apiKey = "0123456789abcdef"
+	defer func() { apiKey = "" }()
+
+	cases := []struct {
+		in       interface{}
+		expected interface{}
+	}{
+		{"key 0123456789abcdef", "key [REDACTED]"},
+		{errors.New("key 0123456789abcdef"), "key [REDACTED]"},
+		{1, 1},
+	}
+
+	for i, c := range cases {
+		func() {
+			defer func() {
+				got := recover()
+				if got != c.expected {
+					t.Errorf("%d: redactPanic() re-panicked with %v, expected %v", i, got, c.expected)
+				}
+			}()
+
+			defer redactPanic()
+			panic(c.in)
+		}()
+	}
+}
+
+func TestRegisterSecret(t *testing.T) {
+	registeredSecrets.secrets = nil
+	defer func() { registeredSecrets.secrets = nil }()
+
+	os.Setenv("CFZONE_TEST_SECRET", "s3cr3t-webhook")
+	defer os.Unsetenv("CFZONE_TEST_SECRET")
+
+	_, err := readSecret("CFZONE_TEST_SECRET", "")
+	if err != nil {
+		t.Fatalf("readSecret() failed: %s", err.Error())
+	}
+
+	registerSecret("vault-client-token")
+	registerSecret("vault-client-token")
+	registerSecret("")
+
+	got := redact("webhook s3cr3t-webhook and vault vault-client-token")
+	if got != "webhook [REDACTED] and vault [REDACTED]" {
+		t.Errorf("redact() returned '%s'", got)
+	}
+
+	if len(registeredSecrets.secrets) != 2 {
+		t.Errorf("registerSecret() registered %v", registeredSecrets.secrets)
+	}
+}
+
+func TestRedactPanics(t *testing.T) {
+	apiKey = "0123456789abcdef"
+	defer func() { apiKey = "" }()
+
+	cases := []struct {
+		in       interface{}
+		expected interface{}
+	}{
+		{errors.New("key 0123456789abcdef"), "key [REDACTED]"},
+		{http.ErrAbortHandler, http.ErrAbortHandler},
+	}
+
+	for i, c := range cases {
+		h := redactPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(c.in)
+		}))
+
+		func() {
+			defer func() {
+				got := recover()
+				if got != c.expected {
+					t.Errorf("%d: handler panicked with %v, expected %v", i, got, c.expected)
+				}
+			}()
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+}
+
+type fakeSyslog struct {
+	lines []string
+}
+
+func (f *fakeSyslog) Err(m string) error {
+	f.lines = append(f.lines, "err "+m)
+	return nil
+}
+
+func (f *fakeSyslog) Warning(m string) error {
+	f.lines = append(f.lines, "warning "+m)
+	return nil
+}
+
+func (f *fakeSyslog) Info(m string) error {
+	f.lines = append(f.lines, "info "+m)
+	return nil
+}
+
+func (f *fakeSyslog) Debug(m string) error {
+	f.lines = append(f.lines, "debug "+m)
+	return nil
+}
+
+func TestLogSyslog(t *testing.T) {
+	b, restore := captureStderr(1)
+	defer restore()
+
+	f := &fakeSyslog{}
+	logSyslog = f
+	defer func() { logSyslog = nil }()
+
+	apiKey = "0123456789abcdef"
+	defer func() { apiKey = "" }()
+
+	errorf("failed with %s", apiKey)
+	warnf("warning")
+	debugf(1, "debug")
+	logf("info", "info")
+
+	expected := []string{"err failed with [REDACTED]", "warning warning", "debug debug", "info info"}
+	if !reflect.DeepEqual(f.lines, expected) {
+		t.Errorf("logf() sent %+v to syslog, expected %+v", f.lines, expected)
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("logf() wrote to stderr while logging to syslog: %s", b.String())
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/anderskvist/cfzone/pkg/cfzone"
 	"github.com/cloudflare/cloudflare-go"
 )
 
@@ -22,6 +25,81 @@ var (
 	// without asking the user. Will be set to true by the "-yes" flag.
 	yes          = false
 	leaveUnknown = false
+
+	// interactive will make cfzone ask the user to approve each change
+	// individually.
+	interactive = false
+
+	// confirmDeletes is the number of deletions allowed before the user must
+	// type the zone name to continue.
+	confirmDeletes = 10
+
+	// duplicates decides what to do about duplicate records in zone files.
+	// "warn" will ignore them with a warning, "fail" will fail the sync.
+	duplicates = "warn"
+
+	// providerName is the DNS provider zones are synced to, "cloudflare",
+	// "route53" or "clouddns".
+	providerName = "cloudflare"
+
+	// concurrency is the number of changes applied concurrently.
+	concurrency = 1
+
+	// apiTimeout is the maximum duration of each Cloudflare API call. 0
+	// means no timeout.
+	apiTimeout = time.Duration(0)
+
+	// syncTypes is a comma separated list of record types to sync. Empty
+	// means all types.
+	syncTypes = ""
+
+	// syncMatch is a comma separated list of name patterns to sync. Empty
+	// means all names.
+	syncMatch = ""
+
+	// excludeSubtrees is a comma separated list of names. Records at or
+	// below these names are left alone.
+	excludeSubtrees = ""
+
+	// ignoreFields is a comma separated list of fields left out when
+	// comparing records.
+	ignoreFields = ""
+
+	// autoTTL is the TTL in zone files meaning automatic TTL, in addition
+	// to 0.
+	autoTTL = 0
+
+	// pageSize is the number of records fetched by each Cloudflare API
+	// call, 0 for the default.
+	pageSize = 0
+
+	// prefetch is the number of record pages fetched concurrently.
+	prefetch = 1
+
+	// skipUnsupported will make cfzone skip records of unsupported types
+	// with a warning instead of failing.
+	skipUnsupported = false
+
+	// skippedTypes holds the types of the records skipped because of
+	// -skipunsupported for each zone, as of the last time it was read.
+	// Records of these types are left alone at Cloudflare.
+	skippedTypes = map[string]map[string]bool{}
+
+	// quiet will suppress all output unless changes were applied or an error
+	// occurred.
+	quiet = false
+
+	verbose     = false
+	veryVerbose = false
+
+	showVersion = false
+
+	// parsedFlags is the flagset used when parsing the command line, and
+	// explicitFlags holds the names of all flags given on the command line.
+	// These are used when applying per-zone options from the configuration.
+	parsedFlags   *flag.FlagSet
+	explicitFlags map[string]bool
+	baseFlags     map[string]string
 )
 
 var (
@@ -29,143 +107,526 @@ var (
 	apiEmail = os.Getenv("CF_API_EMAIL")
 )
 
-// parseArguments tries to pass the arguments in args. For most uses it would
-// make sense to simple pass os.Args. The function will call exit(1) on any
-// error. It will return the first ńon-flag argument.
-func parseArguments(args []string) string {
+// newFlagSet will return a flagset with all flags understood by cfzone.
+func newFlagSet(name string) *flag.FlagSet {
 	// We do our own flagset to be able to test arguments.
-	flagset := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flagset := flag.NewFlagSet(name, flag.ContinueOnError)
 	flagset.SetOutput(stderr)
+	flagset.StringVar(&providerName, "provider", "cloudflare", "DNS provider to sync to, 'cloudflare', 'route53' or 'clouddns'")
+	flagset.IntVar(&concurrency, "concurrency", 1, "Number of changes to apply concurrently. Changes to records with the same name are always applied in order")
+	flagset.DurationVar(&apiTimeout, "apitimeout", 0, "Maximum duration of each Cloudflare API call, like '30s'")
+	flagset.IntVar(&cfzone.DeleteBatchSize, "deletebatch", 200, "Number of records deleted by each batch call to Cloudflare, 0 to delete one record at a time")
+	flagset.StringVar(&awsSecret, "awssecret", "", "Read the API token from this AWS Secrets Manager secret, by name or ARN")
+	flagset.StringVar(&ssmParameter, "ssmparameter", "", "Read the API token from this AWS SSM Parameter Store parameter, by name or ARN")
+	flagset.StringVar(&minisignKeys, "minisignkeys", "", "Only sync zone files signed by one of the minisign public keys in this file. Signatures are read from <zone file>.minisig")
+	flagset.StringVar(&gpgKeyring, "gpgkeyring", "", "Only sync zone files signed by a key in this GPG keyring. Signatures are read from <zone file>.sig and verified using gpgv")
+	flagset.StringVar(&caBundle, "cabundle", "", "PEM file with CA certificates to trust for API connections instead of the system roots")
+	flagset.StringVar(&clientCert, "clientcert", "", "PEM file with a client certificate for API connections")
+	flagset.StringVar(&clientKey, "clientkey", "", "PEM file with the key for -clientcert")
+	flagset.IntVar(&pageSize, "pagesize", 0, "Number of records fetched by each Cloudflare API call (default 100)")
+	flagset.IntVar(&prefetch, "prefetch", 1, "Number of pages of records fetched concurrently from Cloudflare")
+	flagset.BoolVar(&showTimings, "timings", false, "Print how long parsing, fetching, diffing and applying took, and the number of API calls")
+	flagset.StringVar(&transformCommand, "transform", "", "Command transforming records between parsing and diffing, receiving and returning JSON")
+	flagset.BoolVar(&transformAllowEmpty, "transformallowempty", false, "Allow -transform to return no records, deleting every record in the zone")
+	flagset.StringVar(&preHook, "prehook", "", "Command run before applying changes, receiving the plan as JSON. Changes are not applied if it fails")
+	flagset.StringVar(&postHook, "posthook", "", "Command run after applying changes, receiving the result as JSON")
+	flagset.StringVar(&recordAPIPath, "recordapi", "", "Record all API requests and responses to this file")
+	flagset.StringVar(&replayAPIPath, "replayapi", "", "Replay API responses recorded with -recordapi instead of contacting the API")
+	flagset.StringVar(&simulatePath, "simulate", "", "Sync against an in-memory provider seeded from this JSON dump or zone file instead of a real provider")
+	flagset.BoolVar(&readOnly, "readonly", false, "Refuse all API calls changing records, even if asked to sync")
+	flagset.BoolVar(&readOnly, "read-only", false, "Same as -readonly")
+	flagset.StringVar(&savePlanPath, "saveplan", "", "Save the plan to this file instead of applying it")
+	flagset.StringVar(&applyPlanPath, "applyplan", "", "Apply a plan saved with -saveplan instead of syncing zone files")
+	flagset.StringVar(&approvers, "approvers", "", "Minisign public keys allowed to approve saved plans. Saved plans must be signed by enough of them, signatures are read from <plan file>*.minisig")
+	flagset.IntVar(&approvals, "approvals", 2, "Number of different approvers that must sign a saved plan")
+	flagset.StringVar(&planAuthor, "author", "", "Minisign key ID of the author saving a plan with -saveplan. The author's signature doesn't count as an approval")
 	flagset.BoolVar(&yes, "yes", false, "Don't ask before syncing")
+	flagset.BoolVar(&skipUnchanged, "skipunchanged", false, "Skip zone files not changed since they were last synced successfully")
+	flagset.BoolVar(&noCache, "nocache", false, "Always fetch all records instead of using records cached from an earlier sync of an unmodified zone")
+	flagset.BoolVar(&renderTemplates, "template", false, "Render zone files as Go templates before parsing them")
+	flagset.StringVar(&valuesPath, "values", "", "YAML file with values for rendering zone files as templates, implies -template")
+	flagset.StringVar(&zonePatterns, "zones", "", "Use zone files as templates for all zones matching these patterns, like '*.example-customers.com'")
+	flagset.StringVar(&syncTypes, "types", "", "Only sync records of these types, like 'A,AAAA,CNAME'. Other records are left alone")
+	flagset.StringVar(&syncMatch, "match", "", "Only sync records with names matching these patterns, like '*.k8s.example.com'. Other records are left alone")
+	flagset.StringVar(&excludeSubtrees, "excludesubtree", "", "Leave records at or below these names alone, like 'k8s.example.com'")
+	flagset.IntVar(&autoTTL, "autottl", 0, "TTL in zone files meaning automatic TTL at Cloudflare, in addition to 0")
+	flagset.StringVar(&ignoreFields, "ignorefields", "", "Fields left out when comparing records, like 'ttl,proxied,comment'. Updates keep the existing values")
+	flagset.BoolVar(&threeWay, "threeway", false, "Compare changes to the records applied by the last sync, to tell changes in the zone file from changes made outside of cfzone")
+	flagset.StringVar(&driftPolicy, "drift", "apply", "Records changed outside of cfzone with -threeway, 'apply' to assert the zone file, 'keep' to leave them alone or 'fail'")
+	flagset.StringVar(&conflictPolicy, "conflicts", "fail", "Records changed both in the zone file and outside of cfzone with -threeway, 'apply', 'keep' or 'fail'")
+	flagset.StringVar(&policyPath, "policy", "", "Refuse changes breaking the rules in this YAML file")
 	flagset.BoolVar(&leaveUnknown, "leaveunknown", false, "Don't delete unknown records")
-	err := flagset.Parse(args[1:])
-	if err != nil {
-		flagset.PrintDefaults()
-		exit(1)
+	flagset.BoolVar(&interactive, "interactive", false, "Ask before applying each change")
+	flagset.IntVar(&confirmDeletes, "confirmdeletes", 10, "Require typing the zone name when deleting more than this number of records")
+	flagset.StringVar(&duplicates, "duplicates", "warn", "Duplicate records in zone files, 'warn' to ignore them with a warning or 'fail'")
+	flagset.BoolVar(&skipUnsupported, "skipunsupported", false, "Skip records of unsupported types with a warning instead of failing")
+	flagset.StringVar(&outOfZone, "outofzone", "warn", "Records outside the zone, 'warn' to keep them with a warning, 'drop' or 'fail'")
+	flagset.StringVar(&apexCNAME, "apexcname", "warn", "CNAME at the zone apex, 'warn' to let Cloudflare flatten it, 'flatten' to replace it with A and AAAA records or 'fail'")
+	flagset.BoolVar(&verifyTargets, "verifytargets", false, "Warn about CNAME and MX records pointing to names that don't exist")
+	flagset.BoolVar(&quiet, "q", false, "Only output something if changes were applied or an error occurred")
+	flagset.StringVar(&auditPath, "audit", "", "Append every planned and applied operation to this JSON lines file")
+	flagset.BoolVar(&stampComments, "stampcomments", false, "Append who ran cfzone and when to the comments of records created or updated")
+	flagset.StringVar(&actor, "actor", "", "Name of the person or system running cfzone for the audit log (default user@host)")
+	flagset.StringVar(&changelogPath, "changelog", "", "Write a description of applied changes to this file, '-' for stdout")
+	flagset.StringVar(&reportPath, "report", "", "Write a HTML report of pending and applied changes to this file")
+	flagset.StringVar(&lockDir, "lockdir", "", "Directory for zone lock files (default "+os.TempDir()+")")
+	flagset.DurationVar(&lockWait, "lockwait", 0, "How long to wait for another cfzone to finish with a zone")
+	flagset.StringVar(&logFormat, "logformat", "text", "Log format, 'text' or 'json'")
+	flagset.StringVar(&syslogTarget, "syslog", "", "Log to syslog instead of stderr, 'local', 'udp://host:port' or 'tcp://host:port'")
+	flagset.BoolVar(&verbose, "v", false, "Log Cloudflare API calls")
+	flagset.BoolVar(&veryVerbose, "vv", false, "Log Cloudflare API calls and the reason for each change")
+	flagset.BoolVar(&showVersion, "version", false, "Print version information and exit")
+	flagset.BoolVar(&watch, "watch", false, "Keep running and sync every time the zone file changes")
+	flagset.DurationVar(&interval, "interval", 0, "Keep running and sync at this interval")
+	flagset.StringVar(&webhookListen, "webhook", "", "Keep running and sync when receiving a webhook on this address, like ':8080'")
+	flagset.StringVar(&serveListen, "serve", "", "Serve the HTTP API on this address, like ':8081'")
+	flagset.StringVar(&externalDNSListen, "externaldns", "", "Serve an ExternalDNS webhook provider for the given zones on this address, like ':8888'")
+	flagset.StringVar(&externalDNSOwner, "externaldnsowner", "default", "Owner ID of ExternalDNS, as given by its --txt-owner-id. Only records owned by it according to the TXT registry can be changed")
+	flagset.StringVar(&statsdAddr, "statsd", "", "Send metrics to this StatsD server after each sync, like 'localhost:8125'")
+	flagset.StringVar(&monitorListen, "monitor", "", "Serve Prometheus metrics on this address, like ':9100'")
+	flagset.StringVar(&configPath, "config", "", "Path to configuration file (default "+defaultConfigPath()+")")
+
+	return flagset
+}
+
+// parseArguments tries to pass the arguments in args. For most uses it would
+// make sense to simple pass os.Args. The function will call exit(1) on any
+// error. It will return all ńon-flag arguments. Flags and non-flag arguments
+// can be mixed.
+func parseArguments(args []string) []string {
+	flagset := newFlagSet(args[0])
+	paths := []string{}
+
+	rest := args[1:]
+	for {
+		err := flagset.Parse(rest)
+		if err != nil {
+			flagset.PrintDefaults()
+			exit(1)
+		}
+
+		if flagset.NArg() == 0 {
+			break
+		}
+
+		paths = append(paths, flagset.Arg(0))
+		rest = flagset.Args()[1:]
 	}
 
-	if flagset.NArg() < 1 {
-		fmt.Fprintf(stderr, "Too few arguments\n")
-		exit(1)
+	if showVersion {
+		printVersion(stdout)
+		exit(0)
 	}
 
-	return flagset.Arg(0)
-}
+	var err error
 
-func main() {
-	path := parseArguments(os.Args)
+	explicitFlags = map[string]bool{}
+	flagset.Visit(func(f *flag.Flag) {
+		explicitFlags[f.Name] = true
+	})
+	parsedFlags = flagset
 
-	if apiKey == "" || apiEmail == "" {
-		fmt.Fprintf(stderr, "Please set CF_API_KEY and CF_API_EMAIL environment variables\n")
+	// Environment variables take precedence over the configuration file,
+	// so we treat them as if they were given on the command line.
+	env := envFlags(flagset)
+	err = applyFlags(flagset, env, explicitFlags)
+	if err != nil {
+		errorf("Error in environment: %s", err.Error())
 		exit(1)
 	}
 
-	f, err := os.Open(path)
+	for name := range env {
+		explicitFlags[name] = true
+	}
+
+	p := configPath
+	if p == "" {
+		p = defaultConfigPath()
+	}
+
+	cfg, err = loadConfig(p, configPath != "")
 	if err != nil {
-		fmt.Fprintf(stderr, "Error opening '%s': %s\n", path, err.Error())
+		errorf("Can't read configuration: %s", err.Error())
 		exit(1)
 	}
 
-	zoneName, fileRecords, err := parseZone(f)
+	err = applyFlags(flagset, cfg.Flags, explicitFlags)
 	if err != nil {
-		fmt.Fprintf(stderr, "Error reading '%s': %s\n", path, err.Error())
+		errorf("Error in configuration: %s", err.Error())
 		exit(1)
 	}
 
-	api, err := cloudflare.New(apiKey, apiEmail)
-	if err != nil {
-		fmt.Fprintf(stderr, "Error contacting Cloudflare: %s\n", err.Error())
+	checkFlags()
+
+	// We save the resulting flag values, to be able to reset flags between
+	// zones with different options.
+	baseFlags = snapshotFlags(flagset)
+
+	// The HTTP API receives zones from clients.
+	if len(paths) < 1 && serveListen == "" && applyPlanPath == "" {
+		errorf("Too few arguments")
 		exit(1)
 	}
 
-	id, err := api.ZoneIDByName(zoneName)
-	if err != nil {
-		fmt.Fprintf(stderr, "Can't get zone ID for '%s': %s\n", zoneName, err.Error())
+	if len(paths) > 0 && applyPlanPath != "" {
+		errorf("-applyplan can't be used with zone files")
 		exit(1)
 	}
 
-	records, err := api.DNSRecords(id, cloudflare.DNSRecord{})
+	if len(paths) > 1 && savePlanPath != "" {
+		errorf("-saveplan can only be used with one zone file")
+		exit(1)
+	}
+
+	return paths
+}
+
+// checkFlags will validate flag values and derive settings from them. It
+// will call exit(1) on any error.
+func checkFlags() {
+	err := validateFlags()
 	if err != nil {
-		fmt.Fprintf(stderr, "Can't get zone records for '%s': %s\n", id, err.Error())
+		errorf("%s", err.Error())
 		exit(1)
 	}
-	existingRecords := recordCollection(records)
+}
+
+// validateFlags will validate flag values and derive settings from them.
+// Invalid values of flags used while logging are reset to their defaults.
+func validateFlags() error {
+	verbosity = 0
+	if verbose {
+		verbosity = 1
+	}
+
+	if veryVerbose {
+		verbosity = 2
+	}
+
+	if logFormat != "text" && logFormat != "json" {
+		format := logFormat
+		logFormat = "text"
+		return fmt.Errorf("Unknown log format '%s'", format)
+	}
+
+	if providerName != "cloudflare" && providerName != "route53" && providerName != "clouddns" {
+		value := providerName
+		providerName = "cloudflare"
+		return fmt.Errorf("Unknown value '%s' for -provider", value)
+	}
+
+	if duplicates != "warn" && duplicates != "fail" {
+		value := duplicates
+		duplicates = "warn"
+		return fmt.Errorf("Unknown value '%s' for -duplicates", value)
+	}
+
+	if outOfZone != "warn" && outOfZone != "drop" && outOfZone != "fail" {
+		value := outOfZone
+		outOfZone = "warn"
+		return fmt.Errorf("Unknown value '%s' for -outofzone", value)
+	}
+
+	if apexCNAME != "warn" && apexCNAME != "flatten" && apexCNAME != "fail" {
+		value := apexCNAME
+		apexCNAME = "warn"
+		return fmt.Errorf("Unknown value '%s' for -apexcname", value)
+	}
+
+	if driftPolicy != "apply" && driftPolicy != "keep" && driftPolicy != "fail" {
+		value := driftPolicy
+		driftPolicy = "apply"
+		return fmt.Errorf("Unknown value '%s' for -drift", value)
+	}
+
+	if conflictPolicy != "apply" && conflictPolicy != "keep" && conflictPolicy != "fail" {
+		value := conflictPolicy
+		conflictPolicy = "fail"
+		return fmt.Errorf("Unknown value '%s' for -conflicts", value)
+	}
+
+	if _, err := cfzone.ParseFields(ignoreFields); err != nil {
+		return fmt.Errorf("Invalid -ignorefields: %s", err.Error())
+	}
+
+	if autoTTL < 0 || autoTTL == 1 {
+		return fmt.Errorf("-autottl must be 0 or above 1, a TTL of 1 means proxied")
+	}
+
+	if concurrency < 1 {
+		return fmt.Errorf("-concurrency must be at least 1")
+	}
+
+	if pageSize < 0 {
+		return fmt.Errorf("-pagesize can't be negative")
+	}
+
+	if prefetch < 1 {
+		return fmt.Errorf("-prefetch must be at least 1")
+	}
+
+	if recordAPIPath != "" && replayAPIPath != "" {
+		return fmt.Errorf("-recordapi and -replayapi can't be used together")
+	}
+
+	if approvals < 1 {
+		return fmt.Errorf("-approvals must be at least 1")
+	}
+
+	if awsSecret != "" && ssmParameter != "" {
+		return fmt.Errorf("-awssecret and -ssmparameter can't be used together")
+	}
+
+	if quiet && !yes {
+		return fmt.Errorf("Quiet mode requires -yes")
+	}
+
+	if watch && !yes {
+		return fmt.Errorf("Watch mode requires -yes")
+	}
+
+	if interval > 0 && !yes {
+		return fmt.Errorf("Daemon mode requires -yes")
+	}
+
+	if webhookListen != "" && !yes {
+		return fmt.Errorf("Webhook mode requires -yes")
+	}
+
+	if externalDNSListen != "" && !yes {
+		return fmt.Errorf("ExternalDNS mode requires -yes")
+	}
+
+	// Zone files posted to the HTTP API have no detached signature.
+	if serveListen != "" && (minisignKeys != "" || gpgKeyring != "") {
+		return fmt.Errorf("-serve can't be used with -minisignkeys or -gpgkeyring")
+	}
+
+	modes := 0
+	for _, enabled := range []bool{watch, interval > 0, webhookListen != "", serveListen != "", externalDNSListen != ""} {
+		if enabled {
+			modes++
+		}
+	}
+
+	if modes > 1 {
+		return fmt.Errorf("Only one of -watch, -interval, -webhook, -serve and -externaldns can be used")
+	}
+
+	if monitorListen != "" && modes == 0 {
+		return fmt.Errorf("-monitor requires one of -watch, -interval, -webhook, -serve or -externaldns")
+	}
+
+	return nil
+}
+
+// newAPI will instantiate a new Cloudflare API client using the credentials
+// from the environment.
+func newAPI() (*cloudflare.API, error) {
+	client := &http.Client{
+		Transport: &tokenTransport{next: &tracingTransport{next: apiTransport}},
+	}
 
-	// Find records only present at cloudflare - and records only present in
-	// the file zone. This will be the basis for the add/delete collections.
-	addCandidates := fileRecords.Difference(existingRecords, FullMatch)
-	deleteCandidates := existingRecords.Difference(fileRecords, FullMatch)
+	if apiToken != "" && replayAPIPath == "" {
+		return cloudflare.NewWithAPIToken(apiToken, cloudflare.HTTPClient(client))
+	}
+
+	key, email := apiKey, apiEmail
+
+	// Credentials are never sent anywhere when replaying, but the client
+	// insists on having some.
+	if replayAPIPath != "" && (key == "" || email == "") {
+		key, email = "replay", "replay@example.com"
+	}
 
-	// If we find the intersection between file and existing, we should have
-	// a list of records to update. We use only Updatable here, because that
-	// will give us a collection of records that makes sense to update.
-	updates := deleteCandidates.Intersect(addCandidates, Updatable)
+	return cloudflare.New(key, email, cloudflare.HTTPClient(client))
+}
 
-	// The records to be updated can be removed from the add and delete
-	// collections.
-	adds := addCandidates.Difference(updates, Updatable)
-	deletes := deleteCandidates.Difference(updates, Updatable)
+// newProvider returns the DNS provider zones are synced to.
+func newProvider() (cfzone.Provider, error) {
+	if simulated != nil {
+		return simulated, nil
+	}
 
-	if len(deletes) > 0 && leaveUnknown {
-		fmt.Fprintf(stdout, "%d unknown records left untouched\n", len(deletes))
-		deletes = deletes[:0]
+	client := &http.Client{
+		Transport: &tracingTransport{next: apiTransport},
 	}
 
-	numChanges := len(updates) + len(adds) + len(deletes)
+	switch providerName {
+	case "route53":
+		r, err := cfzone.NewRoute53()
+		if err != nil {
+			return nil, err
+		}
+
+		r.Client = client
+
+		return r, nil
+
+	case "clouddns":
+		c, err := cfzone.NewCloudDNS()
+		if err != nil {
+			return nil, err
+		}
+
+		c.Client = client
+
+		// Tokens from the metadata server are only known once fetched.
+		token := c.Token
+		c.Token = func() (string, error) {
+			t, err := token()
+			registerSecret(t)
 
-	if numChanges > 0 && !yes {
-		if len(deletes) > 0 {
-			fmt.Fprintf(stdout, "Records to delete:\n")
-			deletes.Fprint(stdout)
-			fmt.Printf("\n")
+			return t, err
 		}
 
-		if len(adds) > 0 {
-			fmt.Fprintf(stdout, "Records to add:\n")
-			adds.Fprint(stdout)
-			fmt.Printf("\n")
+		return c, nil
+	}
+
+	api, err := newAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	c := cfzone.NewCloudflare(api)
+	c.Timeout = apiTimeout
+	c.PageSize = pageSize
+	c.Prefetch = prefetch
+	c.KeepComments = ignoresField(cfzone.FieldComment)
+
+	return c, nil
+}
+
+// ignoresField returns true if f is left out when comparing records
+// according to -ignorefields.
+func ignoresField(f cfzone.Field) bool {
+	fields, _ := cfzone.ParseFields(ignoreFields)
+	for _, ignored := range fields {
+		if ignored == f {
+			return true
 		}
+	}
+
+	return false
+}
+
+func main() {
+	defer redactPanic()
 
-		if len(updates) > 0 {
-			fmt.Fprintf(stdout, "Records to update:\n")
-			updates.Fprint(stdout)
-			fmt.Printf("\n")
+	cfzone.PanicHandler = redactPanic
+
+	if len(os.Args) > 1 {
+		c, found := commands[os.Args[1]]
+		if found {
+			c.run(os.Args[2:])
+			return
 		}
+	}
 
-		fmt.Fprintf(stdout, "Summary:\n")
-		fmt.Fprintf(stdout, "Records to delete: %d\n", len(deletes))
-		fmt.Fprintf(stdout, "Records to add: %d\n", len(adds))
-		fmt.Fprintf(stdout, "Records to update: %d\n", len(updates))
-		fmt.Fprintf(stdout, "Unchanged records: %d\n", len(records)-len(deleteCandidates))
+	paths := parseArguments(os.Args)
 
-		fmt.Fprintf(stdout, "%d change(s). Continue (y/N)? ", numChanges)
+	initTracing()
 
-		if !yesNo(stdin) {
-			fmt.Fprintf(stdout, "Aborting...\n")
-			exit(0)
+	if syslogTarget != "" {
+		w, err := openSyslog(syslogTarget)
+		if err != nil {
+			errorf("Can't connect to syslog: %s", err.Error())
+			exit(1)
 		}
+
+		logSyslog = w
+	}
+
+	err := setupAPITransport()
+	if err != nil {
+		errorf("Can't set up API connections: %s", err.Error())
+		exit(1)
 	}
 
-	for _, r := range deletes {
-		err = api.DeleteDNSRecord(id, r.ID)
+	if simulatePath != "" {
+		m, err := loadSimulation(simulatePath)
 		if err != nil {
-			fmt.Fprintf(stderr, "Failed to delete record %+v: %s\n", r, err.Error())
+			errorf("Can't load simulation: %s", err.Error())
 			exit(1)
 		}
+
+		simulated = m
+		warnf("Simulating with %d zone(s) from '%s', nothing will be changed", m.Zones(), simulatePath)
+	}
+
+	setupCredentials()
+
+	if monitorListen != "" {
+		runMonitor(monitorListen)
 	}
 
-	for _, r := range adds {
-		_, err = api.CreateDNSRecord(id, r)
+	if watch || interval > 0 || webhookListen != "" || serveListen != "" || externalDNSListen != "" {
+		runWatchdog()
+	}
+
+	if applyPlanPath != "" {
+		err := applyPlan(applyPlanPath)
+		if err == errAborted {
+			exit(0)
+		}
+
 		if err != nil {
-			fmt.Fprintf(stderr, "Failed to add record %+v: %s\n", r, err.Error())
+			errorf("%s", err.Error())
 			exit(1)
 		}
+
+		exit(0)
+	}
+
+	if serveListen != "" {
+		err := runServer(serveListen)
+		if err != nil {
+			errorf("HTTP API server failed: %s", err.Error())
+		}
+
+		exit(1)
+	}
+
+	// In ExternalDNS mode the arguments are zone names, not zone files.
+	if externalDNSListen != "" {
+		err := runExternalDNS(externalDNSListen, paths)
+		if err != nil {
+			errorf("ExternalDNS provider failed: %s", err.Error())
+		}
+
+		exit(1)
+	}
+
+	if watch {
+		err := watchZones(paths, syncZone, nil)
+		if err != nil {
+			errorf("Error watching zone files: %s", err.Error())
+		}
+
+		exit(1)
+	}
+
+	if interval > 0 {
+		reconcile(paths, syncZone, interval, nil)
+	}
+
+	if webhookListen != "" {
+		err := runWebhook(webhookListen, paths, syncZone)
+		if err != nil {
+			errorf("Webhook receiver failed: %s", err.Error())
+		}
+
+		exit(1)
 	}
 
-	for _, r := range updates {
-		err = api.UpdateDNSRecord(id, r.ID, r)
+	for _, path := range paths {
+		err := syncZone(path)
+		if err == errAborted {
+			exit(0)
+		}
+
 		if err != nil {
-			fmt.Fprintf(stderr, "Failed to update record %+v: %s\n", r, err.Error())
+			errorf("%s", err.Error())
 			exit(1)
 		}
 	}
@@ -182,3 +643,71 @@ func yesNo(r io.Reader) bool {
 
 	return false
 }
+
+// confirmZone will return true if the user entered zoneName + enter. False in
+// all other cases.
+func confirmZone(r io.Reader, zoneName string) bool {
+	line, _, _ := bufio.NewReader(r).ReadLine()
+
+	return strings.TrimSpace(string(line)) == zoneName
+}
+
+// setupCredentials will read credentials not given on the command line or in
+// the environment from the configuration, systemd, AWS or Vault. cfzone will
+// exit if no credentials are found.
+func setupCredentials() {
+	err := loadSystemdCredentials()
+	if err != nil {
+		errorf("Can't read systemd credentials: %s", err.Error())
+		exit(1)
+	}
+
+	if apiKey == "" {
+		key, err := cfg.apiKey()
+		if err != nil {
+			errorf("Can't read API key: %s", err.Error())
+			exit(1)
+		}
+
+		apiKey = key
+	}
+
+	if apiEmail == "" {
+		apiEmail = cfg.Credentials.Email
+	}
+
+	primary, secondary, err := cfg.apiTokens()
+	if err != nil {
+		errorf("Can't read API token: %s", err.Error())
+		exit(1)
+	}
+
+	if apiToken == "" {
+		apiToken = primary
+	}
+
+	if apiSecondaryToken == "" {
+		apiSecondaryToken = secondary
+	}
+
+	if apiToken == "" && (awsSecret != "" || ssmParameter != "") && simulated == nil && replayAPIPath == "" {
+		apiToken, err = awsToken()
+		if err != nil {
+			errorf("Can't read API token from AWS: %s", err.Error())
+			exit(1)
+		}
+	}
+
+	if apiToken == "" && cfg.Credentials.Vault != nil && simulated == nil && replayAPIPath == "" {
+		apiToken, err = cfg.Credentials.Vault.token()
+		if err != nil {
+			errorf("Can't read API token from Vault: %s", err.Error())
+			exit(1)
+		}
+	}
+
+	if simulated == nil && replayAPIPath == "" && providerName == "cloudflare" && !haveCredentials() {
+		errorf("Please set CF_API_TOKEN, or CF_API_KEY and CF_API_EMAIL environment variables")
+		exit(1)
+	}
+}
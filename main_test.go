@@ -2,14 +2,20 @@ package main
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
 func init() {
 	exit = panicExit
 	stderr = ioutil.Discard
+
+	// Make sure we don't pick up the configuration of the user running the
+	// tests.
+	os.Setenv("XDG_CONFIG_HOME", "/non/existing")
 }
 
 func panicExit(code int) {
@@ -41,18 +47,19 @@ func TestBrokenFlag(t *testing.T) {
 func TestParseArguments(t *testing.T) {
 	cases := []struct {
 		in       []string
-		expected string
+		expected []string
 	}{
-		{[]string{"./test", "-yes", "path1"}, "path1"},
-		{[]string{"./test", "path2"}, "path2"},
-		{[]string{"./test", "path3", "-yes"}, "path3"},
+		{[]string{"./test", "-yes", "path1"}, []string{"path1"}},
+		{[]string{"./test", "path2"}, []string{"path2"}},
+		{[]string{"./test", "path3", "-yes"}, []string{"path3"}},
+		{[]string{"./test", "-yes", "path4", "path5"}, []string{"path4", "path5"}},
 	}
 
 	for i, c := range cases {
 		result := parseArguments(c.in)
 
-		if result != c.expected {
-			t.Errorf("%d: parseArguments() did not return expected path for %+v. Got %s, expected %s", i, c.in, result, c.expected)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: parseArguments() did not return expected paths for %+v. Got %v, expected %v", i, c.in, result, c.expected)
 		}
 	}
 }
@@ -117,3 +124,57 @@ func TestYesNo(t *testing.T) {
 		}
 	}
 }
+
+func TestConfirmZone(t *testing.T) {
+	cases := []struct {
+		line     string
+		expected bool
+	}{
+		{"example.com\n", true},
+		{" example.com \n", true},
+		{"example.com", true},
+		{"y\n", false},
+		{"Y\n", false},
+		{"\n", false},
+		{"", false},
+		{"example.net\n", false},
+		{"example.com.\n", false},
+		{"\nexample.com\n", false},
+	}
+
+	for i, in := range cases {
+		b := bytes.NewBufferString(in.line)
+		result := confirmZone(b, "example.com")
+		if result != in.expected {
+			t.Errorf("%d: confirmZone() returned wrong result for '%s', got %v, expected %v", i, in.line, result, in.expected)
+		}
+	}
+}
+
+func TestQuietRequiresYes(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-q", "zone"})
+}
+
+func TestQuietRestoresOutput(t *testing.T) {
+	apiKey = "nonempty"
+	apiEmail = "nonempty"
+
+	os.Args = []string{"./test", "-q", "-yes", "/non/existing/zone"}
+
+	func() {
+		defer expectExit(t, 1)
+		main()
+	}()
+
+	if stdout != io.Writer(os.Stdout) {
+		t.Errorf("main() did not restore stdout after quiet run")
+	}
+}
+
+func TestUnknownLogFormat(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-logformat", "xml", "zone"})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// monitorListen is the address to serve /metrics, /healthz and /readyz on.
+// Empty disables the monitoring endpoint.
+var monitorListen = ""
+
+// metric is a family of Prometheus metrics sharing the same name, and
+// distinguished by a label. A metric with more labels has them comma
+// separated in label, and values joined using labelValues.
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	label string
+
+	lock   sync.Mutex
+	values map[string]float64
+
+	// These are only used for histograms.
+	buckets []float64
+	counts  map[string][]uint64
+	totals  map[string]uint64
+}
+
+var (
+	// metrics holds all metrics in the order they were created.
+	metrics []*metric
+
+	zoneRecords  = newMetric("cfzone_zone_records", "Number of records managed by the zone file.", "gauge", "zone")
+	zoneTypes    = newMetric("cfzone_zone_records_by_type", "Number of records managed by the zone file by type.", "gauge", "zone,type")
+	zoneProxied  = newMetric("cfzone_zone_proxied_records", "Number of proxied records managed by the zone file.", "gauge", "zone")
+	zoneDrift    = newMetric("cfzone_zone_drift", "Number of changes needed at the last sync.", "gauge", "zone")
+	zoneLastSync = newMetric("cfzone_zone_last_sync_timestamp_seconds", "Time of the last successful sync.", "gauge", "zone")
+	applyErrors  = newMetric("cfzone_apply_errors_total", "Number of failed syncs.", "counter", "zone")
+	apiDuration  = newHistogram("cfzone_api_request_duration_seconds", "Latency of Cloudflare API requests.", "method", []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+)
+
+// newMetric will create and register a new gauge or counter.
+func newMetric(name string, help string, kind string, label string) *metric {
+	m := &metric{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		label:  label,
+		values: map[string]float64{},
+	}
+
+	metrics = append(metrics, m)
+
+	return m
+}
+
+// newHistogram will create and register a new histogram with the given
+// upper bounds.
+func newHistogram(name string, help string, label string, buckets []float64) *metric {
+	m := newMetric(name, help, "histogram", label)
+	m.buckets = buckets
+	m.counts = map[string][]uint64{}
+	m.totals = map[string]uint64{}
+
+	return m
+}
+
+// Set will set the value for labelValue.
+func (m *metric) Set(labelValue string, v float64) {
+	// Simulated syncs must not look like real ones in the metrics.
+	if simulated != nil {
+		return
+	}
+
+	m.lock.Lock()
+	m.values[labelValue] = v
+	m.lock.Unlock()
+}
+
+// Add will add v to the value for labelValue.
+func (m *metric) Add(labelValue string, v float64) {
+	if simulated != nil {
+		return
+	}
+
+	m.lock.Lock()
+	m.values[labelValue] += v
+	m.lock.Unlock()
+}
+
+// Observe will add an observation of v to the histogram for labelValue.
+func (m *metric) Observe(labelValue string, v float64) {
+	if simulated != nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	counts, found := m.counts[labelValue]
+	if !found {
+		counts = make([]uint64, len(m.buckets))
+		m.counts[labelValue] = counts
+	}
+
+	for i, bound := range m.buckets {
+		if v <= bound {
+			counts[i]++
+		}
+	}
+
+	m.totals[labelValue]++
+	m.values[labelValue] += v
+}
+
+// write will output the metric family in the Prometheus text format.
+func (m *metric) write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	labelValues := []string{}
+	for labelValue := range m.values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+
+	for _, labelValue := range labelValues {
+		names := strings.Split(m.label, ",")
+		values := strings.SplitN(labelValue, "\x00", len(names))
+
+		pairs := []string{}
+		for i, name := range names {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabel(value)))
+		}
+
+		labels := strings.Join(pairs, ",")
+
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s{%s} %g\n", m.name, labels, m.values[labelValue])
+			continue
+		}
+
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", m.name, labels, bound, m.counts[labelValue][i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", m.name, labels, m.totals[labelValue])
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", m.name, labels, m.values[labelValue])
+		fmt.Fprintf(w, "%s_count{%s} %d\n", m.name, labels, m.totals[labelValue])
+	}
+}
+
+// labelValues joins the values of a metric with more than one label.
+func labelValues(values ...string) string {
+	return strings.Join(values, "\x00")
+}
+
+// escapeLabel will escape a label value for the Prometheus text format.
+func escapeLabel(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+// writeMetrics will output all metrics in the Prometheus text format.
+func writeMetrics(w io.Writer) {
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// monitorHandler returns the handler for the monitoring endpoint.
+func monitorHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+	})
+	mux.HandleFunc("/healthz", healthHandler(status.alive))
+	mux.HandleFunc("/readyz", healthHandler(status.ready))
+
+	return mux
+}
+
+// runMonitor will serve the monitoring endpoint on addr in the background.
+func runMonitor(addr string) {
+	go func() {
+		defer redactPanic()
+
+		err := http.ListenAndServe(addr, redactPanics(monitorHandler()))
+		if err != nil {
+			errorf("Monitoring endpoint failed: %s", err.Error())
+		}
+	}()
+}
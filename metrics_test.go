@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricWrite(t *testing.T) {
+	g := &metric{name: "test_gauge", help: "A gauge.", kind: "gauge", label: "zone", values: map[string]float64{}}
+	g.Set("example.net", 2)
+	g.Set("example.com", 1)
+	g.Add("example.com", 2)
+	g.Set(`quo"te`, 0)
+
+	expected := `# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge{zone="example.com"} 3
+test_gauge{zone="example.net"} 2
+test_gauge{zone="quo\"te"} 0
+`
+
+	var b bytes.Buffer
+	g.write(&b)
+
+	if b.String() != expected {
+		t.Errorf("write() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestMetricWriteLabels(t *testing.T) {
+	g := &metric{name: "test_gauge", help: "A gauge.", kind: "gauge", label: "zone,type", values: map[string]float64{}}
+	g.Set(labelValues("example.com", "MX"), 2)
+	g.Set(labelValues("example.com", "A"), 5)
+
+	expected := `# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge{zone="example.com",type="A"} 5
+test_gauge{zone="example.com",type="MX"} 2
+`
+
+	var b bytes.Buffer
+	g.write(&b)
+
+	if b.String() != expected {
+		t.Errorf("write() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	realMetrics := metrics
+	defer func() { metrics = realMetrics }()
+
+	h := newHistogram("test_seconds", "A histogram.", "method", []float64{0.1, 1})
+	h.Observe("GET", 0.05)
+	h.Observe("GET", 0.5)
+	h.Observe("GET", 5)
+
+	expected := `# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="GET",le="0.1"} 1
+test_seconds_bucket{method="GET",le="1"} 2
+test_seconds_bucket{method="GET",le="+Inf"} 3
+test_seconds_sum{method="GET"} 5.55
+test_seconds_count{method="GET"} 3
+`
+
+	var b bytes.Buffer
+	h.write(&b)
+
+	if b.String() != expected {
+		t.Errorf("write() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestMonitorHandler(t *testing.T) {
+	zoneDrift.Set("example.com", 4)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	monitorHandler().ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), `cfzone_zone_drift{zone="example.com"} 4`) {
+		t.Errorf("/metrics did not contain drift: %s", w.Body.String())
+	}
+}
+
+func TestMonitorRequiresDaemon(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-monitor", ":9100", "zone"})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// accountProvider returns a provider for the Cloudflare account the API token
+// gives access to.
+var accountProvider = func(token string) (cfzone.Provider, error) {
+	client := &http.Client{
+		Transport: &tracingTransport{next: apiTransport},
+	}
+
+	api, err := cloudflare.NewWithAPIToken(token, cloudflare.HTTPClient(client))
+	if err != nil {
+		return nil, err
+	}
+
+	c := cfzone.NewCloudflare(api)
+	c.Timeout = apiTimeout
+	c.PageSize = pageSize
+	c.Prefetch = prefetch
+
+	return c, nil
+}
+
+// migrateZone will copy all records of zoneName from src to dst, creating the
+// zone in the account with the ID accountID first if create is true and the
+// zone is missing. Records at dst not present at src are deleted. Answers to
+// the confirmation are read from answers unless -yes is given.
+func migrateZone(src, dst cfzone.Provider, zoneName string, create bool, accountID string, answers io.Reader) error {
+	records, err := src.List(zoneName)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.List(zoneName)
+	if err != nil {
+		creator, ok := dst.(cfzone.ZoneCreator)
+		if !create || !ok {
+			return err
+		}
+
+		err = creator.CreateZone(zoneName, accountID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "Created %s\n", zoneName)
+	}
+
+	p, err := newPlan(dst, zoneName, records.Canonical())
+	if err != nil {
+		return err
+	}
+
+	return confirmApply(p, dst, answers)
+}
+
+// confirmApply will print p, and apply it to provider if confirmed with an
+// answer from answers or -yes is given.
+func confirmApply(p *plan, provider cfzone.Provider, answers io.Reader) error {
+	fmt.Fprintf(stdout, "%s:\n", p.ZoneName)
+	p.Fprint(stdout)
+
+	if p.NumChanges() == 0 {
+		return nil
+	}
+
+	if !yes {
+		fmt.Fprintf(stdout, "%d change(s). Continue (y/N)? ", p.NumChanges())
+
+		if !yesNo(answers) {
+			fmt.Fprintf(stdout, "Aborting...\n")
+			return errAborted
+		}
+	}
+
+	return p.Apply(provider, stdout)
+}
+
+// runMigrate will copy zones from one Cloudflare account to another. The API
+// tokens of the accounts are read from environment variables.
+func runMigrate(args []string) {
+	flagset := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flagset.SetOutput(stderr)
+	config := flagset.String("config", "", "Path to configuration file (default "+defaultConfigPath()+")")
+	from := flagset.String("from", "CF_API_TOKEN", "Environment variable holding the API token of the source account")
+	to := flagset.String("to", "", "Environment variable holding the API token of the destination account")
+	create := flagset.Bool("create", false, "Create zones missing in the destination account")
+	account := flagset.String("account", "", "ID of the destination account, needed by -create")
+	flagset.BoolVar(&yes, "yes", false, "Don't ask before migrating")
+
+	err := flagset.Parse(args)
+	if err != nil || flagset.NArg() < 1 || *to == "" {
+		errorf("Usage: cfzone migrate [-config <file>] [-from <env>] -to <env> [-create -account <id>] [-yes] <zone>...")
+		exit(1)
+	}
+
+	if *create && *account == "" {
+		errorf("-create requires -account")
+		exit(1)
+	}
+
+	if *from == *to {
+		errorf("-from and -to must be different")
+		exit(1)
+	}
+
+	readCommandConfig(*config)
+	setupCommandFlags(flagset)
+
+	providers := []cfzone.Provider{}
+	for _, env := range []string{*from, *to} {
+		token := os.Getenv(env)
+		registerSecret(token)
+		if token == "" {
+			errorf("%s is not set", env)
+			exit(1)
+		}
+
+		provider, err := accountProvider(token)
+		if err != nil {
+			errorf("Can't connect to Cloudflare: %s", err.Error())
+			exit(1)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	// The zones have the same names in both accounts, cached records
+	// could come from the wrong one.
+	noCache = true
+
+	answers := bufio.NewReader(stdin)
+
+	failed := false
+	for _, zoneName := range flagset.Args() {
+		err := migrateZone(providers[0], providers[1], zoneName, *create, *account, answers)
+		if err == errAborted {
+			continue
+		}
+
+		if err != nil {
+			errorf("Can't migrate %s: %s", zoneName, err.Error())
+			failed = true
+		}
+	}
+
+	if failed {
+		exit(1)
+	}
+}
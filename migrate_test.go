@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestRunMigrate(t *testing.T) {
+	src := cfzone.NewMemory()
+	src.Seed("example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true), Comment: "web", Tags: []string{"team:web"}},
+		{Type: "TXT", Name: "example.com", Content: "hello", TTL: 3600, Proxied: cloudflare.BoolPtr(false)},
+	})
+
+	dst := cfzone.NewMemory()
+
+	realAccountProvider := accountProvider
+	accountProvider = func(token string) (cfzone.Provider, error) {
+		switch token {
+		case "old":
+			return src, nil
+		case "new":
+			return dst, nil
+		}
+
+		return nil, fmt.Errorf("unknown token %s", token)
+	}
+	defer func() { accountProvider = realAccountProvider }()
+
+	os.Setenv("OLD_TOKEN", "old")
+	os.Setenv("NEW_TOKEN", "new")
+	defer os.Unsetenv("OLD_TOKEN")
+	defer os.Unsetenv("NEW_TOKEN")
+
+	realNoCache := noCache
+	defer func() { noCache = realNoCache; yes = false }()
+
+	var b bytes.Buffer
+	realStdout := stdout
+	stdout = &b
+	defer func() { stdout = realStdout }()
+
+	runMigrate([]string{"-from", "OLD_TOKEN", "-to", "NEW_TOKEN", "-create", "-account", "abc", "-yes", "example.com"})
+
+	if !strings.HasPrefix(b.String(), "Created example.com\n") {
+		t.Errorf("runMigrate() didn't create the zone, output [%s]", b.String())
+	}
+
+	expected, _ := src.List("example.com")
+	records, err := dst.List("example.com")
+	if err != nil {
+		t.Fatalf("runMigrate() left no zone: %s", err.Error())
+	}
+
+	for _, c := range []recordCollection{records, expected} {
+		for i := range c {
+			c[i].ID = ""
+		}
+
+		sort.Slice(c, func(i, j int) bool { return c[i].Name < c[j].Name })
+	}
+
+	if !reflect.DeepEqual(records.Canonical(), expected.Canonical()) {
+		t.Errorf("runMigrate() copied wrong records, got %+v, expected %+v", records, expected)
+	}
+}
+
+func TestMigrateZoneMissing(t *testing.T) {
+	src := cfzone.NewMemory()
+	src.Seed("example.com", recordCollection{{Type: "A", Name: "example.com", Content: "192.0.2.1"}})
+
+	err := migrateZone(src, cfzone.NewMemory(), "example.com", false, "", nil)
+	if err == nil {
+		t.Errorf("migrateZone() didn't fail for missing zone without -create")
+	}
+}
+
+func TestMigrateZoneAborted(t *testing.T) {
+	src := cfzone.NewMemory()
+	src.Seed("example.com", recordCollection{{Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: 3600}})
+
+	dst := cfzone.NewMemory()
+	dst.CreateZone("example.com", "")
+
+	var b bytes.Buffer
+	realStdout := stdout
+	stdout = &b
+	defer func() { stdout = realStdout }()
+
+	err := migrateZone(src, dst, "example.com", false, "", strings.NewReader("n\n"))
+	if err != errAborted {
+		t.Errorf("migrateZone() returned %v, expected errAborted", err)
+	}
+
+	records, _ := dst.List("example.com")
+	if len(records) != 0 {
+		t.Errorf("migrateZone() changed the zone after aborting: %+v", records)
+	}
+}
+
+func TestRunMigrateUsage(t *testing.T) {
+	for i, args := range [][]string{
+		{},
+		{"example.com"},
+		{"-to", "NEW_TOKEN", "-create", "example.com"},
+		{"-to", "CF_API_TOKEN", "example.com"},
+	} {
+		func() {
+			defer expectExit(t, 1)
+
+			runMigrate(args)
+			t.Errorf("%d: runMigrate() didn't exit", i)
+		}()
+	}
+}
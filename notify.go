@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type (
+	// result describes the outcome of syncing a zone.
+	result struct {
+		ZoneName string
+		Plan     *plan
+		Err      error
+		Duration time.Duration
+	}
+
+	// notifier is used to tell the world about the result of a sync.
+	notifier interface {
+		Notify(r *result) error
+	}
+)
+
+// notifyTimeout is the maximum time a notifier may spend posting a
+// notification.
+var notifyTimeout = 10 * time.Second
+
+// Summary returns a short human readable description of the result.
+func (r *result) Summary() string {
+	if r.Err != nil {
+		return fmt.Sprintf("Sync of %s failed: %s", r.ZoneName, redact(r.Err.Error()))
+	}
+
+	return r.Plan.Changelog()
+}
+
+// notifiers returns the configured notifiers for zoneName.
+func notifiers(zoneName string) ([]notifier, error) {
+	c := cfg.notifyConfig(zoneName)
+	result := []notifier{}
+
+	if c.Slack != nil {
+		url, err := readSecret(c.Slack.URLEnv, c.Slack.URLFile)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &slackNotifier{url: url})
+	}
+
+	for _, w := range c.Webhooks {
+		url := w.URL
+		if url == "" {
+			var err error
+			url, err = readSecret(w.URLEnv, w.URLFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result = append(result, &webhookNotifier{url: url})
+	}
+
+	if c.Email != nil {
+		password, err := readSecret(c.Email.PasswordEnv, c.Email.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &emailNotifier{config: *c.Email, password: password})
+	}
+
+	return result, nil
+}
+
+// notify will pass r to all configured notifiers if changes were applied or
+// the sync failed. Errors are logged, a failing notifier should not fail the
+// sync.
+func notify(r *result) {
+	if r.Err == errAborted || (r.Err == nil && (r.Plan == nil || r.Plan.NumChanges() == 0)) {
+		return
+	}
+
+	list, err := notifiers(r.ZoneName)
+	if err != nil {
+		errorf("Error in notification configuration for '%s': %s", r.ZoneName, err.Error())
+		return
+	}
+
+	for _, n := range list {
+		err = n.Notify(r)
+		if err != nil {
+			errorf("Notification for '%s' failed: %s", r.ZoneName, err.Error())
+		}
+	}
+}
+
+// webhookNotifier posts results as JSON to a URL.
+type webhookNotifier struct {
+	url string
+}
+
+// webhookPayload is posted by webhookNotifier.
+type webhookPayload struct {
+	Zone     string           `json:"zone"`
+	Adds     recordCollection `json:"adds"`
+	Deletes  recordCollection `json:"deletes"`
+	Updates  recordCollection `json:"updates"`
+	Error    string           `json:"error,omitempty"`
+	Duration float64          `json:"duration_seconds"`
+}
+
+// Notify implements notifier.
+func (n *webhookNotifier) Notify(r *result) error {
+	if n.url == "" {
+		return fmt.Errorf("no webhook URL configured")
+	}
+
+	payload := webhookPayload{
+		Zone:     r.ZoneName,
+		Adds:     recordCollection{},
+		Deletes:  recordCollection{},
+		Updates:  recordCollection{},
+		Duration: r.Duration.Seconds(),
+	}
+
+	if r.Plan != nil {
+		payload.Adds = r.Plan.Adds
+		payload.Deletes = r.Plan.Deletes
+		payload.Updates = r.Plan.Updates
+	}
+
+	if r.Err != nil {
+		payload.Error = redact(r.Err.Error())
+	}
+
+	return postJSON(n.url, nil, payload)
+}
+
+// postJSON will POST v encoded as JSON to url with the extra headers given.
+func postJSON(url string, header http.Header, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: notifyTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifiers(t *testing.T) {
+	os.Setenv("CFZONE_TEST_SLACK", "https://hooks.slack.com/services/test")
+	defer os.Unsetenv("CFZONE_TEST_SLACK")
+
+	cfg = config{
+		Notify: notifyConfig{Slack: &slackConfig{URLEnv: "CFZONE_TEST_SLACK"}},
+		Zones: map[string]zoneConfig{
+			"quiet.example.com": {Notify: &notifyConfig{}},
+		},
+	}
+	defer func() { cfg = config{} }()
+
+	list, err := notifiers("example.com")
+	if err != nil {
+		t.Fatalf("notifiers() returned error: %s", err.Error())
+	}
+
+	if len(list) != 1 || list[0].(*slackNotifier).url != "https://hooks.slack.com/services/test" {
+		t.Errorf("notifiers() returned wrong notifiers: %+v", list)
+	}
+
+	list, _ = notifiers("quiet.example.com")
+	if len(list) != 0 {
+		t.Errorf("notifiers() did not use per-zone configuration: %+v", list)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var received slackMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer ts.Close()
+
+	s := &slackNotifier{url: ts.URL}
+
+	p := &plan{ZoneName: "example.com", Adds: recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1"}}}
+	err := s.Notify(&result{ZoneName: "example.com", Plan: p})
+	if err != nil {
+		t.Fatalf("Notify() returned error: %s", err.Error())
+	}
+
+	expected := ":white_check_mark: example.com: add 1 A record\n```\n- Added A record www.example.com (192.0.2.1)\n```"
+	if received.Text != expected {
+		t.Errorf("Notify() posted [%s], expected [%s]", received.Text, expected)
+	}
+
+	err = s.Notify(&result{ZoneName: "example.com", Err: errors.New("broken")})
+	if err != nil {
+		t.Fatalf("Notify() returned error: %s", err.Error())
+	}
+
+	if received.Text != ":x: Sync of example.com failed: broken" {
+		t.Errorf("Notify() posted [%s] for failure", received.Text)
+	}
+}
+
+func TestSlackNotifierError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	err := (&slackNotifier{url: ts.URL}).Notify(&result{ZoneName: "example.com", Err: errors.New("broken")})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Notify() did not return error for failed post: %v", err)
+	}
+
+	err = (&slackNotifier{}).Notify(&result{ZoneName: "example.com", Err: errors.New("broken")})
+	if err == nil {
+		t.Errorf("Notify() did not return error without URL")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received webhookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Notify() posted wrong content type '%s'", r.Header.Get("Content-Type"))
+		}
+
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer ts.Close()
+
+	apiKey = "0123456789abcdef"
+	defer func() { apiKey = "" }()
+
+	n := &webhookNotifier{url: ts.URL}
+
+	p := &plan{ZoneName: "example.com", Deletes: recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1"}}}
+	err := n.Notify(&result{ZoneName: "example.com", Plan: p, Err: errors.New("key 0123456789abcdef rejected"), Duration: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Notify() returned error: %s", err.Error())
+	}
+
+	if received.Zone != "example.com" || len(received.Deletes) != 1 || len(received.Adds) != 0 || received.Duration != 1.5 {
+		t.Errorf("Notify() posted wrong payload: %+v", received)
+	}
+
+	if received.Error != "key [REDACTED] rejected" {
+		t.Errorf("Notify() posted unredacted error '%s'", received.Error)
+	}
+}
+
+func TestWebhookNotifiers(t *testing.T) {
+	os.Setenv("CFZONE_TEST_HOOK", "https://example.com/secret")
+	defer os.Unsetenv("CFZONE_TEST_HOOK")
+
+	cfg = config{
+		Notify: notifyConfig{Webhooks: []webhookNotifyConfig{
+			{URL: "https://example.com/hook"},
+			{URLEnv: "CFZONE_TEST_HOOK"},
+		}},
+	}
+	defer func() { cfg = config{} }()
+
+	list, err := notifiers("example.com")
+	if err != nil {
+		t.Fatalf("notifiers() returned error: %s", err.Error())
+	}
+
+	if len(list) != 2 || list[0].(*webhookNotifier).url != "https://example.com/hook" || list[1].(*webhookNotifier).url != "https://example.com/secret" {
+		t.Errorf("notifiers() returned wrong notifiers: %+v", list)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// outOfZone decides what to do about records outside the zone. "warn" will
+// keep them with a warning, "drop" will remove them with a warning and
+// "fail" will fail the sync.
+var outOfZone = "warn"
+
+// inZone returns true if name is zoneName or a name below it.
+func inZone(name string, zoneName string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zoneName = strings.ToLower(strings.TrimSuffix(zoneName, "."))
+
+	return name == zoneName || strings.HasSuffix(name, "."+zoneName)
+}
+
+// handleOutOfZone will handle records in c with names outside zoneName
+// according to -outofzone. These are usually left over from copying records
+// between zone files, and Cloudflare would either reject them or file them
+// wrongly.
+func handleOutOfZone(c recordCollection, zoneName string) (recordCollection, error) {
+	result := recordCollection{}
+	outside := []string{}
+
+	for _, r := range c {
+		if inZone(r.Name, zoneName) {
+			result = append(result, r)
+			continue
+		}
+
+		outside = append(outside, recordLine(r))
+		if outOfZone != "drop" {
+			result = append(result, r)
+		}
+	}
+
+	if len(outside) == 0 {
+		return c, nil
+	}
+
+	switch outOfZone {
+	case "fail":
+		return nil, fmt.Errorf("Records outside %s:\n  %s", zoneName, strings.Join(outside, "\n  "))
+
+	case "drop":
+		warnf("Dropping records outside %s:\n  %s", zoneName, strings.Join(outside, "\n  "))
+
+	default:
+		warnf("Records outside %s:\n  %s", zoneName, strings.Join(outside, "\n  "))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInZone(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected bool
+	}{
+		{"example.com", true},
+		{"example.com.", true},
+		{"www.example.com", true},
+		{"WWW.Example.COM", true},
+		{"a.b.example.com", true},
+		{"example.net", false},
+		{"notexample.com", false},
+		{"com", false},
+	}
+
+	for _, in := range cases {
+		if inZone(in.name, "example.com") != in.expected {
+			t.Errorf("inZone() returned %v for '%s'", !in.expected, in.name)
+		}
+	}
+}
+
+func TestOutOfZone(t *testing.T) {
+	defer func() { outOfZone = "warn" }()
+
+	zone := recordCollection{
+		{Name: "www.example.com", Type: "A", Content: "192.0.2.1"},
+		{Name: "www.example.net", Type: "A", Content: "192.0.2.2"},
+	}
+
+	cases := []struct {
+		mode     string
+		expected recordCollection
+		err      string
+		warning  string
+	}{
+		{"warn", zone, "", "Records outside example.com:\n  www.example.net. 0 IN A     192.0.2.2\n"},
+		{"drop", zone[:1], "", "Dropping records outside example.com:\n  www.example.net. 0 IN A     192.0.2.2\n"},
+		{"fail", nil, "Records outside example.com:\n  www.example.net. 0 IN A     192.0.2.2", ""},
+	}
+
+	for i, in := range cases {
+		buf, restore := captureStderr(0)
+
+		outOfZone = in.mode
+		result, err := handleOutOfZone(zone, "example.com")
+		restore()
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+
+		if got != in.err {
+			t.Errorf("%d: handleOutOfZone() returned error [%s], expected [%s]", i, got, in.err)
+		}
+
+		if !reflect.DeepEqual(result, in.expected) {
+			t.Errorf("%d: handleOutOfZone() returned %v, expected %v", i, result, in.expected)
+		}
+
+		if buf.String() != in.warning {
+			t.Errorf("%d: handleOutOfZone() wrote [%s], expected [%s]", i, buf.String(), in.warning)
+		}
+	}
+
+	outOfZone = "fail"
+	result, err := handleOutOfZone(zone[:1], "example.com")
+	if err != nil || !reflect.DeepEqual(result, zone[:1]) {
+		t.Errorf("handleOutOfZone() failed a zone without outside records: %v", err)
+	}
+}
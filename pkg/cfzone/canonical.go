@@ -0,0 +1,99 @@
+package cfzone
+
+import (
+	"net"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Name returns a record name in canonical form, in lower case and without
+// the trailing dot. DNS names are case insensitive, and names differing only
+// in case are the same.
+func Name(name string) string {
+	return strings.ToLower(strings.Trim(name, "."))
+}
+
+// Target returns a target host name in canonical form, in lower case and
+// without the trailing dot. The null MX target "." from RFC 7505 is kept, as
+// an empty target is not the same thing. This is the only place targets are
+// made canonical, for all record types with a target.
+func Target(target string) string {
+	if target == "." {
+		return target
+	}
+
+	return strings.ToLower(strings.Trim(target, "."))
+}
+
+// HasTarget returns true if the content of records of type t is a host name,
+// or ends with one like for SRV.
+func HasTarget(t string) bool {
+	switch t {
+	case "CNAME", "MX", "NS", "PTR", "SRV":
+		return true
+	}
+
+	return false
+}
+
+// CanonicalContent returns the content of r with its target in canonical
+// form, if it has one. Targets differing only in case or trailing dot are
+// the same.
+func CanonicalContent(r cloudflare.DNSRecord) string {
+	if !HasTarget(r.Type) {
+		return r.Content
+	}
+
+	// SRV content is "weight port target".
+	fields := strings.Fields(r.Content)
+	if r.Type != "SRV" || len(fields) == 0 {
+		return Target(r.Content)
+	}
+
+	fields[len(fields)-1] = Target(fields[len(fields)-1])
+
+	return strings.Join(fields, " ")
+}
+
+// canonical returns r in canonical form, with content normalized the way
+// Cloudflare does it.
+func canonical(r cloudflare.DNSRecord) cloudflare.DNSRecord {
+	r.Name = Name(r.Name)
+
+	switch r.Type {
+	case "A", "AAAA":
+		// Cloudflare compresses IPv6 addresses and uses lower case hex.
+		ip := net.ParseIP(r.Content)
+		if ip != nil {
+			r.Content = ip.String()
+		}
+
+	case "DS":
+		// The digest is hex, which Cloudflare may return in any case.
+		fields := strings.Fields(r.Content)
+		if len(fields) == 4 {
+			fields[3] = strings.ToUpper(fields[3])
+			r.Content = strings.Join(fields, " ")
+		}
+
+	default:
+		r.Content = CanonicalContent(r)
+	}
+
+	return r
+}
+
+// Canonical returns a copy of c with all records in canonical form. Records
+// fetched from a provider can have names in mixed case, like when entered in
+// the Cloudflare dashboard, and records from other sources than a zone file
+// can have content Cloudflare would change when applied. Both sides should be
+// canonical before matching.
+func (c RecordCollection) Canonical() RecordCollection {
+	result := make(RecordCollection, 0, len(c))
+	for _, r := range c {
+		result = append(result, canonical(r))
+	}
+
+	return result
+}
@@ -0,0 +1,138 @@
+package cfzone
+
+import (
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestCanonicalNames(t *testing.T) {
+	zone := `Example.COM. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+$ORIGIN Example.COM.
+WWW 300 IN A 192.0.2.1
+Mail.Example.com. 300 IN A 192.0.2.2
+`
+
+	zoneName, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	if zoneName != "example.com" || records[0].Name != "www.example.com" || records[1].Name != "mail.example.com" {
+		t.Fatalf("ParseZone() returned %s and %+v", zoneName, records)
+	}
+
+	// Records entered in the dashboard can have names in mixed case.
+	fetched := RecordCollection{
+		{ID: "1", Type: "A", Name: "Www.Example.com", Content: "192.0.2.1", TTL: 300},
+		{ID: "2", Type: "A", Name: "MAIL.example.com", Content: "192.0.2.2", TTL: 300},
+	}
+
+	if changes := Diff(records, fetched.Canonical()); changes.Unchanged != 2 {
+		t.Errorf("Diff() returned %+v for names differing only in case", changes)
+	}
+
+	if fetched[0].Name != "Www.Example.com" {
+		t.Errorf("Canonical() changed the original collection")
+	}
+
+	if name := Name("Example.COM."); name != "example.com" {
+		t.Errorf("Name() returned %s", name)
+	}
+}
+
+func TestCanonicalContent(t *testing.T) {
+	cases := []struct {
+		in       cloudflare.DNSRecord
+		expected string
+	}{
+		{cloudflare.DNSRecord{Type: "AAAA", Content: "2001:0DB8:0000:0000:0000:0000:0000:0001"}, "2001:db8::1"},
+		{cloudflare.DNSRecord{Type: "AAAA", Content: "2001:db8::1"}, "2001:db8::1"},
+		{cloudflare.DNSRecord{Type: "A", Content: "192.0.2.1"}, "192.0.2.1"},
+		{cloudflare.DNSRecord{Type: "A", Content: "not an ip"}, "not an ip"},
+		{cloudflare.DNSRecord{Type: "TXT", Content: `"v=spf1 -all"`}, `"v=spf1 -all"`},
+		{cloudflare.DNSRecord{Type: "TXT", Content: `"a" "b"`}, `"a" "b"`},
+		{cloudflare.DNSRecord{Type: "TXT", Content: `"`}, `"`},
+		{cloudflare.DNSRecord{Type: "TXT", Content: `say "hi"`}, `say "hi"`},
+		{cloudflare.DNSRecord{Type: "CNAME", Content: `"quoted"`}, `"quoted"`},
+		{cloudflare.DNSRecord{Type: "DS", Content: "2371  13 2 c4ed0a"}, "2371 13 2 C4ED0A"},
+		{cloudflare.DNSRecord{Type: "DS", Content: "2371 13"}, "2371 13"},
+	}
+
+	for i, c := range cases {
+		got := RecordCollection{c.in}.Canonical()[0].Content
+		if got != c.expected {
+			t.Errorf("%d: Canonical() returned %q, expected %q", i, got, c.expected)
+		}
+	}
+
+	// A freshly applied record must not diff as changed.
+	local := RecordCollection{{Type: "AAAA", Name: "www.example.com", Content: "2001:DB8:0:0::1"}}
+	fetched := RecordCollection{{ID: "1", Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1"}}
+
+	if changes := Diff(local.Canonical(), fetched.Canonical()); changes.Unchanged != 1 {
+		t.Errorf("Diff() returned %+v for the same IPv6 address", changes)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	cases := map[string]string{
+		"Mail.Example.COM.": "mail.example.com",
+		"mail.example.com":  "mail.example.com",
+		".":                 ".",
+		"":                  "",
+	}
+
+	for in, expected := range cases {
+		if got := Target(in); got != expected {
+			t.Errorf("Target(%q) returned %q, expected %q", in, got, expected)
+		}
+	}
+
+	// Targets from a zone file and Cloudflare must match, whatever their
+	// case.
+	_, records, _, err := ParseZone(strings.NewReader(`example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www.example.com. 300 IN CNAME Web.Example.COM.
+example.com. 300 IN MX 10 Mail.Example.COM.
+`), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	if records[0].Content != "web.example.com" || records[1].Content != "mail.example.com" {
+		t.Errorf("ParseZone() didn't canonicalize targets: %+v", records)
+	}
+
+	existing := RecordCollection{
+		{ID: "1", Type: "CNAME", Name: "www.example.com", Content: "WEB.example.com", TTL: 300},
+		{ID: "2", Type: "MX", Name: "example.com", Content: "Mail.example.com", TTL: 300, Priority: cloudflare.Uint16Ptr(10)},
+	}
+
+	changes := Diff(records, existing)
+	if changes.Unchanged != 2 {
+		t.Errorf("Diff() didn't match targets differing in case: %+v", changes)
+	}
+}
+
+func TestCanonicalTargets(t *testing.T) {
+	cases := []struct {
+		in       cloudflare.DNSRecord
+		expected string
+	}{
+		{cloudflare.DNSRecord{Type: "CNAME", Content: "Web.Example.COM."}, "web.example.com"},
+		{cloudflare.DNSRecord{Type: "MX", Content: "Mail.Example.COM."}, "mail.example.com"},
+		{cloudflare.DNSRecord{Type: "MX", Content: "."}, "."},
+		{cloudflare.DNSRecord{Type: "NS", Content: "NS1.example.com."}, "ns1.example.com"},
+		{cloudflare.DNSRecord{Type: "PTR", Content: "Host.example.com."}, "host.example.com"},
+		{cloudflare.DNSRecord{Type: "SRV", Content: "5 5060 SIP.Example.com."}, "5 5060 sip.example.com"},
+		{cloudflare.DNSRecord{Type: "TXT", Content: "Mixed.Case."}, "Mixed.Case."},
+		{cloudflare.DNSRecord{Type: "A", Content: "192.0.2.1"}, "192.0.2.1"},
+	}
+
+	for i, c := range cases {
+		if got := CanonicalContent(c.in); got != c.expected {
+			t.Errorf("%d: CanonicalContent() returned %q, expected %q", i, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package cfzone
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// metadataTokenURL is where the access token of the default service account
+// is found on Google Cloud.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// CloudDNS is a Provider using the Google Cloud DNS API.
+type CloudDNS struct {
+	// Endpoint is the Cloud DNS API endpoint.
+	Endpoint string
+
+	// Project is the Google Cloud project holding the managed zones.
+	Project string
+
+	// Token returns the OAuth2 access token to use for requests.
+	Token func() (string, error)
+
+	// Client is used for all requests.
+	Client *http.Client
+
+	lock  sync.Mutex
+	zones map[string]string
+}
+
+type (
+	// cloudDNSZone is a managed zone.
+	cloudDNSZone struct {
+		Name    string `json:"name"`
+		DNSName string `json:"dnsName"`
+	}
+
+	// cloudDNSSet is a resource record set.
+	cloudDNSSet struct {
+		Name          string           `json:"name"`
+		Type          string           `json:"type"`
+		TTL           int              `json:"ttl,omitempty"`
+		Rrdatas       []string         `json:"rrdatas,omitempty"`
+		RoutingPolicy *json.RawMessage `json:"routingPolicy,omitempty"`
+	}
+
+	// cloudDNSChange is a change to the record sets of a managed zone.
+	cloudDNSChange struct {
+		Additions []cloudDNSSet `json:"additions,omitempty"`
+		Deletions []cloudDNSSet `json:"deletions,omitempty"`
+	}
+)
+
+// NewCloudDNS returns a Cloud DNS provider for the project given by the
+// GOOGLE_CLOUD_PROJECT environment variable. The access token is read from
+// GOOGLE_OAUTH_ACCESS_TOKEN if set, otherwise it's fetched from the metadata
+// server.
+func NewCloudDNS() (*CloudDNS, error) {
+	c := &CloudDNS{
+		Endpoint: "https://dns.googleapis.com/dns/v1",
+		Project:  os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		Client:   http.DefaultClient,
+	}
+
+	if c.Project == "" {
+		return nil, errors.New("GOOGLE_CLOUD_PROJECT must be set")
+	}
+
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token != "" {
+		c.Token = func() (string, error) { return token, nil }
+	} else {
+		c.Token = metadataToken(c)
+	}
+
+	return c, nil
+}
+
+// metadataToken returns a token function fetching access tokens from the
+// metadata server. Tokens are cached until shortly before they expire.
+func metadataToken(c *CloudDNS) func() (string, error) {
+	var lock sync.Mutex
+	var token string
+	var expires time.Time
+
+	return func() (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if token != "" && time.Now().Before(expires) {
+			return token, nil
+		}
+
+		req, err := http.NewRequest("GET", metadataTokenURL, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("Can't get access token from metadata server: %s", err.Error())
+		}
+		defer resp.Body.Close()
+
+		var t struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&t)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("Can't get access token from metadata server: %d", resp.StatusCode)
+		}
+
+		token = t.AccessToken
+		expires = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+
+		return token, nil
+	}
+}
+
+// do will send an authorized request to the Cloud DNS API, and decode the
+// JSON response into v.
+func (c *CloudDNS) do(method string, path string, query url.Values, body interface{}, v interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	u := strings.TrimSuffix(c.Endpoint, "/") + "/projects/" + url.PathEscape(c.Project) + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	token, err := c.Token()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(b, &e)
+
+		return fmt.Errorf("Cloud DNS returned %d: %s", resp.StatusCode, e.Error.Message)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// ZoneID returns the name of the managed zone for zoneName. Names are cached.
+func (c *CloudDNS) ZoneID(zoneName string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	name, found := c.zones[zoneName]
+	if found {
+		return name, nil
+	}
+
+	var resp struct {
+		Zones []cloudDNSZone `json:"managedZones"`
+	}
+
+	err := c.do("GET", "/managedZones", url.Values{"dnsName": {fqdn(zoneName)}}, nil, &resp)
+	if err != nil {
+		return "", fmt.Errorf("Can't get managed zone for '%s': %s", zoneName, err.Error())
+	}
+
+	if len(resp.Zones) == 0 {
+		return "", fmt.Errorf("Can't get managed zone for '%s': not found", zoneName)
+	}
+
+	if c.zones == nil {
+		c.zones = map[string]string{}
+	}
+	c.zones[zoneName] = resp.Zones[0].Name
+
+	return resp.Zones[0].Name, nil
+}
+
+// sets will return all record sets of the managed zone, or only the set with
+// name and type if name is not empty.
+func (c *CloudDNS) sets(zone string, name string, typ string) ([]cloudDNSSet, error) {
+	result := []cloudDNSSet{}
+	query := url.Values{}
+
+	if name != "" {
+		query.Set("name", name)
+		query.Set("type", typ)
+	}
+
+	for {
+		var resp struct {
+			Sets          []cloudDNSSet `json:"rrsets"`
+			NextPageToken string        `json:"nextPageToken"`
+		}
+
+		err := c.do("GET", "/managedZones/"+url.PathEscape(zone)+"/rrsets", query, nil, &resp)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, resp.Sets...)
+
+		if resp.NextPageToken == "" {
+			return result, nil
+		}
+
+		query.Set("pageToken", resp.NextPageToken)
+	}
+}
+
+// List implements Provider. Records with routing policies and the NS and SOA
+// records at the apex are left out.
+func (c *CloudDNS) List(zoneName string) (RecordCollection, error) {
+	zone, err := c.ZoneID(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	sets, err := c.sets(zone, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("Can't get zone records for '%s': %s", zoneName, err.Error())
+	}
+
+	records := RecordCollection{}
+	for _, set := range sets {
+		apex := fromFQDN(set.Name) == Name(zoneName)
+		if set.RoutingPolicy != nil || set.Type == "SOA" || (set.Type == "NS" && apex) {
+			continue
+		}
+
+		for _, value := range set.Rrdatas {
+			record := cloudflare.DNSRecord{
+				ID:   setRecordID(fromFQDN(set.Name), set.Type, value),
+				Name: fromFQDN(set.Name),
+				Type: set.Type,
+				TTL:  set.TTL,
+			}
+			setRdata(&record, value)
+
+			records = append(records, setRecord(record))
+		}
+	}
+
+	return records, nil
+}
+
+// change will fetch the record set with the name and type of rec, let
+// modify change the values, and replace the set.
+func (c *CloudDNS) change(zoneName string, rec cloudflare.DNSRecord, modify func(values []string) []string) error {
+	zone, err := c.ZoneID(zoneName)
+	if err != nil {
+		return err
+	}
+
+	sets, err := c.sets(zone, fqdn(rec.Name), rec.Type)
+	if err != nil {
+		return err
+	}
+
+	change := cloudDNSChange{}
+	values := []string{}
+
+	if len(sets) > 0 {
+		// Deletions must match the existing set exactly.
+		change.Deletions = sets[:1]
+		values = sets[0].Rrdatas
+	}
+
+	values = modify(values)
+	sort.Strings(values)
+
+	if len(values) > 0 {
+		ttl := setRecord(rec).TTL
+
+		change.Additions = []cloudDNSSet{{Name: fqdn(rec.Name), Type: rec.Type, TTL: ttl, Rrdatas: values}}
+	}
+
+	if len(change.Additions) == 0 && len(change.Deletions) == 0 {
+		return nil
+	}
+
+	return c.do("POST", "/managedZones/"+url.PathEscape(zone)+"/changes", nil, change, nil)
+}
+
+// Normalize implements Normalizer. Cloud DNS has no automatic TTL, no proxy
+// and no comments.
+func (c *CloudDNS) Normalize(rec cloudflare.DNSRecord) cloudflare.DNSRecord {
+	return setRecord(rec)
+}
+
+// Create implements Provider.
+func (c *CloudDNS) Create(zoneName string, rec cloudflare.DNSRecord) error {
+	return c.change(zoneName, rec, addValue(rec))
+}
+
+// Update implements Provider. The value replaced is found from the ID of
+// rec.
+func (c *CloudDNS) Update(zoneName string, rec cloudflare.DNSRecord) error {
+	return c.change(zoneName, rec, replaceValue(rec))
+}
+
+// Delete implements Provider.
+func (c *CloudDNS) Delete(zoneName string, rec cloudflare.DNSRecord) error {
+	return c.change(zoneName, rec, removeValue(rec))
+}
@@ -0,0 +1,186 @@
+package cfzone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// fakeCloudDNS is a minimal Cloud DNS API serving a single managed zone.
+type fakeCloudDNS struct {
+	sets    []cloudDNSSet
+	changes []cloudDNSChange
+}
+
+func (f *fakeCloudDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/projects/p/managedZones":
+		zones := []cloudDNSZone{}
+		if r.URL.Query().Get("dnsName") == "example.com." {
+			zones = append(zones, cloudDNSZone{Name: "example-com", DNSName: "example.com."})
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"managedZones": zones})
+
+	case "/projects/p/managedZones/example-com/rrsets":
+		sets := []cloudDNSSet{}
+		for _, set := range f.sets {
+			name := r.URL.Query().Get("name")
+			if name == "" || (set.Name == name && set.Type == r.URL.Query().Get("type")) {
+				sets = append(sets, set)
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"rrsets": sets})
+
+	case "/projects/p/managedZones/example-com/changes":
+		var c cloudDNSChange
+		json.NewDecoder(r.Body).Decode(&c)
+		f.changes = append(f.changes, c)
+
+		for _, deletion := range c.Deletions {
+			sets := []cloudDNSSet{}
+			for _, set := range f.sets {
+				if set.Name != deletion.Name || set.Type != deletion.Type {
+					sets = append(sets, set)
+				}
+			}
+
+			f.sets = sets
+		}
+
+		f.sets = append(f.sets, c.Additions...)
+		w.Write([]byte(`{}`))
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestCloudDNS(f *fakeCloudDNS) (*CloudDNS, func()) {
+	s := httptest.NewServer(f)
+
+	return &CloudDNS{
+		Endpoint: s.URL,
+		Project:  "p",
+		Token:    func() (string, error) { return "token", nil },
+		Client:   s.Client(),
+	}, s.Close
+}
+
+func TestCloudDNSList(t *testing.T) {
+	routing := json.RawMessage(`{"wrr":{}}`)
+
+	f := &fakeCloudDNS{sets: []cloudDNSSet{
+		{Name: "example.com.", Type: "SOA", TTL: 21600, Rrdatas: []string{"ns-cloud-a1.googledomains.com. cloud-dns-hostmaster.google.com. 1 21600 3600 259200 300"}},
+		{Name: "example.com.", Type: "MX", TTL: 300, Rrdatas: []string{"10 mx1.example.com.", "20 mx2.example.com."}},
+		{Name: "*.example.com.", Type: "TXT", TTL: 60, Rrdatas: []string{`"hello"`}},
+		{Name: "lb.example.com.", Type: "A", TTL: 60, RoutingPolicy: &routing},
+		{Name: "example.com.", Type: "NS", TTL: 21600, Rrdatas: []string{"ns-cloud-a1.googledomains.com."}},
+		{Name: "sub.example.com.", Type: "NS", TTL: 86400, Rrdatas: []string{"ns1.example.net."}},
+	}}
+
+	c, done := newTestCloudDNS(f)
+	defer done()
+
+	records, err := c.List("example.com")
+	if err != nil {
+		t.Fatalf("List() failed: %s", err.Error())
+	}
+
+	expected := RecordCollection{
+		{ID: "example.com/MX/10 mx1.example.com.", Name: "example.com", Type: "MX", TTL: 300, Priority: cloudflare.Uint16Ptr(10), Content: "mx1.example.com", Proxied: cloudflare.BoolPtr(false)},
+		{ID: "example.com/MX/20 mx2.example.com.", Name: "example.com", Type: "MX", TTL: 300, Priority: cloudflare.Uint16Ptr(20), Content: "mx2.example.com", Proxied: cloudflare.BoolPtr(false)},
+		{ID: `*.example.com/TXT/"hello"`, Name: "*.example.com", Type: "TXT", TTL: 60, Content: "hello", Proxied: cloudflare.BoolPtr(false)},
+		{ID: "sub.example.com/NS/ns1.example.net.", Name: "sub.example.com", Type: "NS", TTL: 86400, Content: "ns1.example.net", Proxied: cloudflare.BoolPtr(false)},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("List() returned wrong records: %+v", records)
+	}
+
+	_, err = c.List("example.org")
+	if err == nil {
+		t.Errorf("List() didn't fail for unknown zone")
+	}
+}
+
+func TestCloudDNSChanges(t *testing.T) {
+	mx := cloudDNSSet{Name: "example.com.", Type: "MX", TTL: 300, Rrdatas: []string{"10 mx1.example.com.", "20 mx2.example.com."}}
+
+	cases := []struct {
+		name      string
+		apply     func(c *CloudDNS) error
+		additions []cloudDNSSet
+		deletions []cloudDNSSet
+	}{
+		{
+			"create new set",
+			func(c *CloudDNS) error {
+				return c.Create("example.com", cloudflare.DNSRecord{Name: "www.example.com", Type: "CNAME", TTL: 1, Content: "example.net"})
+			},
+			[]cloudDNSSet{{Name: "www.example.com.", Type: "CNAME", TTL: 300, Rrdatas: []string{"example.net."}}},
+			nil,
+		},
+		{
+			"update",
+			func(c *CloudDNS) error {
+				return c.Update("example.com", cloudflare.DNSRecord{ID: "example.com/MX/10 mx1.example.com.", Name: "example.com", Type: "MX", TTL: 600, Priority: cloudflare.Uint16Ptr(10), Content: "mx3.example.com"})
+			},
+			[]cloudDNSSet{{Name: "example.com.", Type: "MX", TTL: 600, Rrdatas: []string{"10 mx3.example.com.", "20 mx2.example.com."}}},
+			[]cloudDNSSet{mx},
+		},
+		{
+			"delete set",
+			func(c *CloudDNS) error {
+				return c.Delete("example.com", cloudflare.DNSRecord{Name: "txt.example.com", Type: "TXT", Content: "hello"})
+			},
+			nil,
+			[]cloudDNSSet{{Name: "txt.example.com.", Type: "TXT", TTL: 300, Rrdatas: []string{`"hello"`}}},
+		},
+	}
+
+	for _, tc := range cases {
+		f := &fakeCloudDNS{sets: []cloudDNSSet{
+			mx,
+			{Name: "txt.example.com.", Type: "TXT", TTL: 300, Rrdatas: []string{`"hello"`}},
+		}}
+
+		c, done := newTestCloudDNS(f)
+
+		err := tc.apply(c)
+		done()
+		if err != nil {
+			t.Errorf("%s: failed: %s", tc.name, err.Error())
+			continue
+		}
+
+		if len(f.changes) != 1 {
+			t.Errorf("%s: sent %d changes, expected 1", tc.name, len(f.changes))
+			continue
+		}
+
+		if !reflect.DeepEqual(f.changes[0].Additions, tc.additions) || !reflect.DeepEqual(f.changes[0].Deletions, tc.deletions) {
+			t.Errorf("%s: sent wrong change %+v", tc.name, f.changes[0])
+		}
+	}
+}
+
+func TestCloudDNSSyncTwice(t *testing.T) {
+	c, done := newTestCloudDNS(&fakeCloudDNS{})
+	defer done()
+
+	changes := syncTwice(t, c)
+	if len(changes.Adds)+len(changes.Deletes)+len(changes.Updates) != 0 {
+		t.Errorf("Second sync found changes: %+v", changes)
+	}
+}
@@ -0,0 +1,285 @@
+package cfzone
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Changes holds the changes needed to make a set of existing records match
+// the wanted records.
+type Changes struct {
+	Deletes RecordCollection
+	Adds    RecordCollection
+	Updates RecordCollection
+
+	// Unchanged is the number of existing records already matching.
+	Unchanged int
+}
+
+// Diff will find the changes needed to make existing match wanted. Records
+// with the same name and type are updated in place when possible, updates
+// carry the ID of the existing record. Records differing only in TTL are
+// paired first, so they get their TTL updated instead of being replaced by
+// another record with the same name. Diff runs in linear time, and gives the
+// same result as matching using FullMatch, SameExceptTTL and Updatable.
+func Diff(wanted RecordCollection, existing RecordCollection) Changes {
+	return DiffIgnoring(wanted, existing)
+}
+
+// DiffIgnoring is like Diff, but leaves fields out when comparing records.
+// This is useful when fields are managed by someone else. Updates keep the
+// existing values of fields.
+func DiffIgnoring(wanted RecordCollection, existing RecordCollection, fields ...Field) Changes {
+	fullMatchKey := maskedKey(commentedKey(fullMatchKey), fields)
+	sameExceptTTLKey := maskedKey(commentedKey(sameExceptTTLKey), fields)
+
+	// Find records only present at cloudflare - and records only present in
+	// the file zone. This will be the basis for the add/delete collections.
+	addCandidates := wanted.differenceByKey(existing, fullMatchKey)
+	deleteCandidates := existing.differenceByKey(wanted, fullMatchKey)
+
+	// Records where only the TTL differs are the cheapest to update, and
+	// must not end up being deleted while another record is updated to take
+	// their place.
+	ttlUpdates := deleteCandidates.intersectByKey(addCandidates, sameExceptTTLKey)
+	addRest := addCandidates.differenceByKey(ttlUpdates, sameExceptTTLKey)
+	deleteRest := deleteCandidates.differenceByKey(ttlUpdates, sameExceptTTLKey)
+
+	// If we find the intersection between file and existing, we should have
+	// a list of records to update. We use only Updatable here, because that
+	// will give us a collection of records that makes sense to update.
+	updates := deleteRest.intersectByKey(addRest, updatableKey)
+
+	// The records to be updated can be removed from the add and delete
+	// collections.
+	deletes := deleteRest.differenceByKey(updates, updatableKey)
+	adds := addRest.differenceByKey(updates, updatableKey)
+	updates = append(ttlUpdates, updates...)
+
+	// Fields left out are not ours to change.
+	if len(fields) > 0 {
+		byID := map[string]cloudflare.DNSRecord{}
+		for _, r := range deleteCandidates {
+			byID[r.ID] = r
+		}
+
+		for i, r := range updates {
+			updates[i] = unmask(r, byID[r.ID], fields)
+		}
+	}
+
+	return Changes{
+		Deletes:   deletes,
+		Adds:      adds,
+		Updates:   updates,
+		Unchanged: len(existing) - len(deleteCandidates),
+	}
+}
+
+// PanicHandler is deferred in goroutines started by the package, like the
+// workers applying changes. It can recover a panic to keep secrets out of
+// the crash message, like a deferred function in main would for the main
+// goroutine.
+var PanicHandler = func() {}
+
+// DeleteBatchSize is the maximum number of records deleted in a single call
+// to a BatchDeleter. Less than 1 disables batching.
+var DeleteBatchSize = 200
+
+// deleteBatches will delete records with names not otherwise changed in
+// batches, if provider is a BatchDeleter. Such records can be deleted in any
+// order. The changes left to apply are returned.
+func (c Changes) deleteBatches(provider Provider, zoneName string, done func(operation string, r cloudflare.DNSRecord, err error) error) (Changes, error) {
+	batcher, ok := provider.(BatchDeleter)
+	if !ok || DeleteBatchSize < 1 || len(c.Deletes) == 0 {
+		return c, nil
+	}
+
+	changed := map[string]bool{}
+	for _, r := range append(c.Adds.Clone(), c.Updates...) {
+		changed[strings.ToLower(r.Name)] = true
+	}
+
+	rest := c
+	rest.Deletes = RecordCollection{}
+
+	batch := RecordCollection{}
+	for _, r := range c.Deletes {
+		if changed[strings.ToLower(r.Name)] {
+			rest.Deletes = append(rest.Deletes, r)
+		} else {
+			batch = append(batch, r)
+		}
+	}
+
+	for len(batch) > 0 {
+		n := DeleteBatchSize
+		if n > len(batch) {
+			n = len(batch)
+		}
+
+		err := batcher.DeleteBatch(zoneName, batch[:n])
+
+		for _, r := range batch[:n] {
+			doneErr := done("delete", r, err)
+			if doneErr != nil {
+				return rest, fmt.Errorf("Failed to delete record %+v: %s", r, doneErr.Error())
+			}
+		}
+
+		batch = batch[n:]
+	}
+
+	return rest, nil
+}
+
+// Apply will apply the changes to zoneName using provider. Records are
+// deleted first, then added and updated. done is called after each change
+// with its result, and can be nil. If done returns an error, Apply stops
+// and returns it. If provider is a BatchDeleter, records are deleted in
+// batches where possible.
+func (c Changes) Apply(provider Provider, zoneName string, done func(operation string, r cloudflare.DNSRecord, err error) error) error {
+	if done == nil {
+		done = func(operation string, r cloudflare.DNSRecord, err error) error { return err }
+	}
+
+	c, err := c.deleteBatches(provider, zoneName, done)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range c.Deletes {
+		err := done("delete", r, provider.Delete(zoneName, r))
+		if err != nil {
+			return fmt.Errorf("Failed to delete record %+v: %s", r, err.Error())
+		}
+	}
+
+	for _, r := range c.Adds {
+		err := done("add", r, provider.Create(zoneName, r))
+		if err != nil {
+			return fmt.Errorf("Failed to add record %+v: %s", r, err.Error())
+		}
+	}
+
+	for _, r := range c.Updates {
+		err := done("update", r, provider.Update(zoneName, r))
+		if err != nil {
+			return fmt.Errorf("Failed to update record %+v: %s", r, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// operation is a single change to apply.
+type operation struct {
+	name  string
+	apply func(zoneName string, r cloudflare.DNSRecord) error
+	r     cloudflare.DNSRecord
+}
+
+// groupByName returns the changes as groups of operations on records with
+// the same name. Groups are in order of first appearance, and operations in
+// each group are in the order Apply would use.
+func (c Changes) groupByName(provider Provider) [][]operation {
+	groups := [][]operation{}
+	byName := map[string]int{}
+
+	add := func(name string, apply func(string, cloudflare.DNSRecord) error, records RecordCollection) {
+		for _, r := range records {
+			key := strings.ToLower(r.Name)
+
+			n, found := byName[key]
+			if !found {
+				n = len(groups)
+				byName[key] = n
+				groups = append(groups, nil)
+			}
+
+			groups[n] = append(groups[n], operation{name, apply, r})
+		}
+	}
+
+	add("delete", provider.Delete, c.Deletes)
+	add("add", provider.Create, c.Adds)
+	add("update", provider.Update, c.Updates)
+
+	return groups
+}
+
+// ApplyConcurrently is like Apply, but makes up to workers calls to provider
+// concurrently. Changes to records with the same name are applied in order,
+// as a CNAME must be deleted before other records with its name are added.
+// done is never called concurrently. If a change fails, no more changes are
+// started, and the first error is returned once the changes in progress are
+// done.
+func (c Changes) ApplyConcurrently(provider Provider, zoneName string, workers int, done func(operation string, r cloudflare.DNSRecord, err error) error) error {
+	if workers <= 1 {
+		return c.Apply(provider, zoneName, done)
+	}
+
+	if done == nil {
+		done = func(operation string, r cloudflare.DNSRecord, err error) error { return err }
+	}
+
+	c, err := c.deleteBatches(provider, zoneName, done)
+	if err != nil {
+		return err
+	}
+
+	var lock sync.Mutex
+	var firstErr error
+
+	failed := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return firstErr != nil
+	}
+
+	jobs := make(chan []operation)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer PanicHandler()
+
+			for group := range jobs {
+				for _, op := range group {
+					if failed() {
+						break
+					}
+
+					err := op.apply(zoneName, op.r)
+
+					lock.Lock()
+					err = done(op.name, op.r, err)
+					if err != nil && firstErr == nil {
+						firstErr = fmt.Errorf("Failed to %s record %+v: %s", op.name, op.r, err.Error())
+					}
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, group := range c.groupByName(provider) {
+		if failed() {
+			break
+		}
+
+		jobs <- group
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
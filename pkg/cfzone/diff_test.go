@@ -0,0 +1,125 @@
+package cfzone
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestDiff(t *testing.T) {
+	a1 := cloudflare.DNSRecord{Type: "A", Name: "a1.example.com", Content: "192.0.2.1"}
+	a2 := cloudflare.DNSRecord{Type: "A", Name: "a2.example.com", Content: "192.0.2.2"}
+	a2changed := cloudflare.DNSRecord{Type: "A", Name: "a2.example.com", Content: "192.0.2.3"}
+	txt := cloudflare.DNSRecord{Type: "TXT", Name: "example.com", Content: "hello"}
+
+	existingA2 := a2
+	existingA2.ID = "2"
+	existingTXT := txt
+	existingTXT.ID = "3"
+
+	updated := a2changed
+	updated.ID = "2"
+
+	existingA2changed := a2changed
+	existingA2changed.ID = "4"
+	a2ttl := a2
+	a2ttl.TTL = 600
+	ttlUpdated := a2ttl
+	ttlUpdated.ID = "2"
+
+	cases := []struct {
+		wanted   RecordCollection
+		existing RecordCollection
+		expected Changes
+	}{
+		{
+			RecordCollection{},
+			RecordCollection{},
+			Changes{Deletes: RecordCollection{}, Adds: RecordCollection{}, Updates: RecordCollection{}},
+		},
+		{
+			RecordCollection{a1, a2},
+			RecordCollection{existingA2},
+			Changes{Deletes: RecordCollection{}, Adds: RecordCollection{a1}, Updates: RecordCollection{}, Unchanged: 1},
+		},
+		{
+			RecordCollection{a2changed},
+			RecordCollection{existingA2, existingTXT},
+			Changes{Deletes: RecordCollection{existingTXT}, Adds: RecordCollection{}, Updates: RecordCollection{updated}},
+		},
+		{
+			// Only the TTL of a2 should change, it must not be deleted.
+			RecordCollection{a2ttl},
+			RecordCollection{existingA2changed, existingA2},
+			Changes{Deletes: RecordCollection{existingA2changed}, Adds: RecordCollection{}, Updates: RecordCollection{ttlUpdated}},
+		},
+	}
+
+	for i, in := range cases {
+		changes := Diff(in.wanted, in.existing)
+		if !reflect.DeepEqual(changes, in.expected) {
+			t.Errorf("%d: Diff() returned %+v, expected %+v", i, changes, in.expected)
+		}
+	}
+}
+
+func TestDiffIgnoring(t *testing.T) {
+	existing := cloudflare.DNSRecord{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)}
+	wanted := cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300}
+
+	changes := DiffIgnoring(RecordCollection{wanted}, RecordCollection{existing}, FieldTTL, FieldProxied)
+	if changes.Unchanged != 1 || len(changes.Adds)+len(changes.Deletes)+len(changes.Updates) != 0 {
+		t.Errorf("DiffIgnoring() didn't ignore TTL and proxied: %+v", changes)
+	}
+
+	// Updates must keep what's ignored.
+	wanted.Content = "192.0.2.2"
+	expected := wanted
+	expected.ID = "1"
+	expected.Proxied = cloudflare.BoolPtr(true)
+
+	changes = DiffIgnoring(RecordCollection{wanted}, RecordCollection{existing}, FieldProxied)
+	if !reflect.DeepEqual(changes.Updates, RecordCollection{expected}) {
+		t.Errorf("DiffIgnoring() returned updates %+v, expected %+v", changes.Updates, expected)
+	}
+
+	if !IgnoreFields(FullMatch, FieldTTL, FieldProxied)(wanted, expected) || IgnoreFields(FullMatch, FieldTTL)(wanted, expected) {
+		t.Errorf("IgnoreFields() didn't ignore the right fields")
+	}
+}
+
+func TestDiffComments(t *testing.T) {
+	existing := cloudflare.DNSRecord{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300, Comment: "old"}
+	wanted := cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300, Comment: "new"}
+
+	expected := wanted
+	expected.ID = "1"
+
+	changes := Diff(RecordCollection{wanted}, RecordCollection{existing})
+	if !reflect.DeepEqual(changes.Updates, RecordCollection{expected}) || len(changes.Adds)+len(changes.Deletes) != 0 {
+		t.Errorf("Diff() didn't update the comment: %+v", changes)
+	}
+
+	changes = DiffIgnoring(RecordCollection{wanted}, RecordCollection{existing}, FieldComment)
+	if changes.Unchanged != 1 || len(changes.Adds)+len(changes.Deletes)+len(changes.Updates) != 0 {
+		t.Errorf("DiffIgnoring() didn't ignore the comment: %+v", changes)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(" TTL, proxied,comment")
+	if err != nil || !reflect.DeepEqual(fields, []Field{FieldTTL, FieldProxied, FieldComment}) {
+		t.Errorf("ParseFields() returned %v, %v", fields, err)
+	}
+
+	fields, err = ParseFields("")
+	if err != nil || len(fields) != 0 {
+		t.Errorf("ParseFields() returned %v, %v for an empty list", fields, err)
+	}
+
+	_, err = ParseFields("ttl,content")
+	if err == nil {
+		t.Errorf("ParseFields() accepted an unknown field")
+	}
+}
@@ -0,0 +1,17 @@
+// Package cfzone parses BIND style zone files into Cloudflare DNS records, and
+// finds the changes needed to make a Cloudflare zone match a zone file.
+//
+// A zone file is synced by parsing it, fetching the existing records of the
+// zone and applying the changes found by Diff:
+//
+//	zoneName, records, _, err := cfzone.ParseZone(f, cfzone.ParseOptions{})
+//	...
+//	existing, _, err := api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{})
+//	...
+//	changes := cfzone.Diff(records.Canonical(), cfzone.RecordCollection(existing).Canonical())
+//
+// Records are made canonical first, as Cloudflare normalizes names and some
+// content. Records fetched with the Cloudflare provider already are.
+//
+// This package is used by the cfzone command.
+package cfzone
@@ -0,0 +1,92 @@
+package cfzone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Field is a record attribute that can be left out when comparing records.
+type Field string
+
+const (
+	// FieldTTL is the TTL of a record.
+	FieldTTL Field = "ttl"
+
+	// FieldProxied is whether a record is proxied by Cloudflare.
+	FieldProxied Field = "proxied"
+
+	// FieldComment is the comment of a record.
+	FieldComment Field = "comment"
+)
+
+// ParseFields parses a comma separated list of fields, like "ttl,proxied".
+func ParseFields(s string) ([]Field, error) {
+	fields := []Field{}
+
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+
+		switch Field(f) {
+		case "":
+		case FieldTTL, FieldProxied, FieldComment:
+			fields = append(fields, Field(f))
+		default:
+			return nil, fmt.Errorf("Unknown field '%s'", f)
+		}
+	}
+
+	return fields, nil
+}
+
+// mask returns r with fields cleared.
+func mask(r cloudflare.DNSRecord, fields []Field) cloudflare.DNSRecord {
+	for _, f := range fields {
+		switch f {
+		case FieldTTL:
+			r.TTL = 0
+		case FieldProxied:
+			r.Proxied = nil
+		case FieldComment:
+			r.Comment = ""
+		}
+	}
+
+	return r
+}
+
+// unmask returns r with fields copied from from.
+func unmask(r cloudflare.DNSRecord, from cloudflare.DNSRecord, fields []Field) cloudflare.DNSRecord {
+	for _, f := range fields {
+		switch f {
+		case FieldTTL:
+			r.TTL = from.TTL
+		case FieldProxied:
+			r.Proxied = from.Proxied
+		case FieldComment:
+			r.Comment = from.Comment
+		}
+	}
+
+	return r
+}
+
+// maskedKey returns key ignoring fields.
+func maskedKey(key keyFunc, fields []Field) keyFunc {
+	if len(fields) == 0 {
+		return key
+	}
+
+	return func(r cloudflare.DNSRecord) string {
+		return key(mask(r, fields))
+	}
+}
+
+// IgnoreFields returns a FilterFunc matching like match, but ignoring
+// fields.
+func IgnoreFields(match FilterFunc, fields ...Field) FilterFunc {
+	return func(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+		return match(mask(a, fields), mask(b, fields))
+	}
+}
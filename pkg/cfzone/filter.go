@@ -0,0 +1,94 @@
+package cfzone
+
+import (
+	"path"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Predicate returns true for records to keep when filtering.
+type Predicate func(r cloudflare.DNSRecord) bool
+
+// Filter returns a new collection with the records matching all predicates.
+func (c RecordCollection) Filter(predicates ...Predicate) RecordCollection {
+	result := RecordCollection{}
+
+	for _, r := range c {
+		keep := true
+		for _, p := range predicates {
+			if !p(r) {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// ByType matches records of any of types. Types are case insensitive.
+func ByType(types ...string) Predicate {
+	set := map[string]bool{}
+	for _, t := range types {
+		set[strings.ToUpper(t)] = true
+	}
+
+	return func(r cloudflare.DNSRecord) bool {
+		return set[strings.ToUpper(r.Type)]
+	}
+}
+
+// ByName matches records with names matching any of the glob patterns, like
+// "*.k8s.example.com". Names are case insensitive.
+func ByName(patterns ...string) Predicate {
+	return func(r cloudflare.DNSRecord) bool {
+		name := strings.ToLower(r.Name)
+
+		for _, pattern := range patterns {
+			match, _ := path.Match(strings.ToLower(pattern), name)
+			if match {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// BySubtree matches records named any of names, or with names below any of
+// them, like "app.k8s.example.com" below "k8s.example.com". Names are case
+// insensitive.
+func BySubtree(names ...string) Predicate {
+	return func(r cloudflare.DNSRecord) bool {
+		name := strings.ToLower(strings.TrimSuffix(r.Name, "."))
+
+		for _, n := range names {
+			n = strings.ToLower(strings.TrimSuffix(n, "."))
+			if name == n || strings.HasSuffix(name, "."+n) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// ByProxied matches records proxied by Cloudflare if proxied is true, and
+// records not proxied if it's false.
+func ByProxied(proxied bool) Predicate {
+	return func(r cloudflare.DNSRecord) bool {
+		return cloudflare.Bool(r.Proxied) == proxied
+	}
+}
+
+// Not matches records not matching p.
+func Not(p Predicate) Predicate {
+	return func(r cloudflare.DNSRecord) bool {
+		return !p(r)
+	}
+}
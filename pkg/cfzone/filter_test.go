@@ -0,0 +1,40 @@
+package cfzone
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestFilter(t *testing.T) {
+	a := cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: "192.0.2.1", Proxied: cloudflare.BoolPtr(true)}
+	aaaa := cloudflare.DNSRecord{Type: "AAAA", Name: "WWW.example.com", Content: "2001:db8::1"}
+	txt := cloudflare.DNSRecord{Type: "TXT", Name: "app.k8s.example.com", Content: "owner"}
+	in := RecordCollection{a, aaaa, txt}
+
+	cases := []struct {
+		predicates []Predicate
+		expected   RecordCollection
+	}{
+		{nil, RecordCollection{a, aaaa, txt}},
+		{[]Predicate{ByType("a", "AAAA")}, RecordCollection{a, aaaa}},
+		{[]Predicate{ByName("www.example.com")}, RecordCollection{a, aaaa}},
+		{[]Predicate{ByName("*.k8s.example.com", "nothing")}, RecordCollection{txt}},
+		{[]Predicate{BySubtree("k8s.example.com.")}, RecordCollection{txt}},
+		{[]Predicate{Not(BySubtree("WWW.example.com", "example.net"))}, RecordCollection{txt}},
+		{[]Predicate{BySubtree("example.com")}, RecordCollection{a, aaaa, txt}},
+		{[]Predicate{BySubtree("s.example.com")}, RecordCollection{}},
+		{[]Predicate{ByProxied(true)}, RecordCollection{a}},
+		{[]Predicate{ByProxied(false), ByType("AAAA", "TXT")}, RecordCollection{aaaa, txt}},
+		{[]Predicate{Not(ByType("TXT")), ByProxied(false)}, RecordCollection{aaaa}},
+		{[]Predicate{ByType()}, RecordCollection{}},
+	}
+
+	for i, c := range cases {
+		result := in.Filter(c.predicates...)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: Filter() returned %+v, expected %+v", i, result, c.expected)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cfzone
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// keyFunc returns a key for a record. Records with the same key match.
+// Records returning an empty key never match anything. A keyFunc makes it
+// possible to match collections using maps instead of comparing all pairs of
+// records like a FilterFunc.
+type keyFunc func(r cloudflare.DNSRecord) string
+
+// fullMatchKey is the keyFunc equivalent of FullMatch.
+func fullMatchKey(r cloudflare.DNSRecord) string {
+	content := CanonicalContent(r)
+
+	switch r.Type {
+	case "A", "AAAA", "CNAME", "TXT", "NS", "DS":
+
+	case "MX":
+		content = strconv.Itoa(int(cloudflare.Uint16(r.Priority))) + " " + content
+
+	default:
+		return ""
+	}
+
+	return strings.Join([]string{
+		r.Type,
+		r.Name,
+		strconv.FormatBool(cloudflare.Bool(r.Proxied)),
+		strconv.Itoa(r.TTL),
+		content,
+	}, "\x00")
+}
+
+// sameExceptTTLKey is the keyFunc equivalent of SameExceptTTL.
+func sameExceptTTLKey(r cloudflare.DNSRecord) string {
+	r.TTL = 0
+
+	return fullMatchKey(r)
+}
+
+// commentedKey returns key also comparing the comment of records.
+func commentedKey(key keyFunc) keyFunc {
+	return func(r cloudflare.DNSRecord) string {
+		k := key(r)
+		if k == "" {
+			return ""
+		}
+
+		return k + "\x00" + r.Comment
+	}
+}
+
+// updatableKey is the keyFunc equivalent of Updatable.
+func updatableKey(r cloudflare.DNSRecord) string {
+	return r.Type + "\x00" + r.Name
+}
+
+// index maps keys to the positions of records with that key, in order.
+type index map[string][]int
+
+// newIndex returns an index of c using key.
+func newIndex(c RecordCollection, key keyFunc) index {
+	idx := index{}
+
+	for i, r := range c {
+		k := key(r)
+		if k != "" {
+			idx[k] = append(idx[k], i)
+		}
+	}
+
+	return idx
+}
+
+// take will return the position of the first record with key k, and remove
+// it from the index. -1 is returned if no record is left.
+func (idx index) take(k string) int {
+	if k == "" || len(idx[k]) == 0 {
+		return -1
+	}
+
+	n := idx[k][0]
+	idx[k] = idx[k][1:]
+
+	return n
+}
+
+// differenceByKey is like Difference, but runs in linear time.
+func (c RecordCollection) differenceByKey(remote RecordCollection, key keyFunc) RecordCollection {
+	result := RecordCollection{}
+	idx := newIndex(remote, key)
+
+	for _, r := range c {
+		if idx.take(key(r)) < 0 {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// intersectByKey is like Intersect, but runs in linear time.
+func (c RecordCollection) intersectByKey(remote RecordCollection, key keyFunc) RecordCollection {
+	intersect := RecordCollection{}
+	idx := newIndex(remote, key)
+
+	for _, r := range c {
+		n := idx.take(key(r))
+		if n < 0 {
+			continue
+		}
+
+		record := remote[n]
+		record.ID = r.ID
+
+		intersect = append(intersect, record)
+	}
+
+	return intersect
+}
@@ -0,0 +1,125 @@
+package cfzone
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// diffFiltered is Diff using FilterFuncs, as it was before indexing.
+func diffFiltered(wanted RecordCollection, existing RecordCollection) Changes {
+	addCandidates := wanted.Difference(existing, FullMatch)
+	deleteCandidates := existing.Difference(wanted, FullMatch)
+	ttlUpdates := deleteCandidates.Intersect(addCandidates, SameExceptTTL)
+	addRest := addCandidates.Difference(ttlUpdates, SameExceptTTL)
+	deleteRest := deleteCandidates.Difference(ttlUpdates, SameExceptTTL)
+	updates := deleteRest.Intersect(addRest, Updatable)
+
+	return Changes{
+		Deletes:   deleteRest.Difference(updates, Updatable),
+		Adds:      addRest.Difference(updates, Updatable),
+		Updates:   append(ttlUpdates, updates...),
+		Unchanged: len(existing) - len(deleteCandidates),
+	}
+}
+
+// randomZone returns n random records. Names and contents are picked from
+// small sets to get plenty of matches, duplicates and updates.
+func randomZone(rnd *rand.Rand, n int, names int) RecordCollection {
+	types := []string{"A", "AAAA", "CNAME", "MX", "TXT", "SRV"}
+	c := RecordCollection{}
+
+	for i := 0; i < n; i++ {
+		r := cloudflare.DNSRecord{
+			ID:      strconv.Itoa(i),
+			Type:    types[rnd.Intn(len(types))],
+			Name:    fmt.Sprintf("host%d.example.com", rnd.Intn(names)),
+			Content: strconv.Itoa(rnd.Intn(3)),
+			TTL:     []int{1, 300}[rnd.Intn(2)],
+		}
+
+		if rnd.Intn(2) == 0 {
+			r.Proxied = cloudflare.BoolPtr(rnd.Intn(2) == 0)
+		}
+
+		if r.Type == "MX" {
+			r.Priority = cloudflare.Uint16Ptr(uint16(rnd.Intn(2) * 10))
+		}
+
+		c = append(c, r)
+	}
+
+	return c
+}
+
+func TestKeysMatchFilters(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	c := randomZone(rnd, 300, 5)
+
+	for _, a := range c {
+		for _, b := range c {
+			full := fullMatchKey(a) != "" && fullMatchKey(a) == fullMatchKey(b)
+			if full != FullMatch(a, b) {
+				t.Fatalf("fullMatchKey() and FullMatch() disagree about %+v and %+v", a, b)
+			}
+
+			ttl := sameExceptTTLKey(a) != "" && sameExceptTTLKey(a) == sameExceptTTLKey(b)
+			if ttl != SameExceptTTL(a, b) {
+				t.Fatalf("sameExceptTTLKey() and SameExceptTTL() disagree about %+v and %+v", a, b)
+			}
+
+			if (updatableKey(a) == updatableKey(b)) != Updatable(a, b) {
+				t.Fatalf("updatableKey() and Updatable() disagree about %+v and %+v", a, b)
+			}
+		}
+	}
+}
+
+func TestDiffMatchesFiltered(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		wanted := randomZone(rnd, rnd.Intn(50), 10)
+		existing := randomZone(rnd, rnd.Intn(50), 10)
+
+		for j := range wanted {
+			wanted[j].ID = ""
+		}
+
+		changes := Diff(wanted, existing)
+		expected := diffFiltered(wanted, existing)
+		if !reflect.DeepEqual(changes, expected) {
+			t.Fatalf("%d: Diff() returned %+v, expected %+v", i, changes, expected)
+		}
+	}
+}
+
+func benchmarkDiff(b *testing.B, diff func(RecordCollection, RecordCollection) Changes, n int) {
+	rnd := rand.New(rand.NewSource(1))
+	wanted := randomZone(rnd, n, n)
+	existing := randomZone(rnd, n, n)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		diff(wanted, existing)
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	for _, n := range []int{100, 1000, 20000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) { benchmarkDiff(b, Diff, n) })
+	}
+}
+
+// BenchmarkDiffFiltered shows the quadratic time of matching using
+// FilterFuncs for comparison with BenchmarkDiff.
+func BenchmarkDiffFiltered(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) { benchmarkDiff(b, diffFiltered, n) })
+	}
+}
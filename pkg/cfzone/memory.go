@@ -0,0 +1,141 @@
+package cfzone
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Memory is a Provider keeping all zones in memory. It's useful for testing
+// and rehearsing changes without touching a real DNS provider.
+type Memory struct {
+	lock   sync.Mutex
+	zones  map[string]RecordCollection
+	nextID int
+}
+
+// NewMemory returns an empty Memory provider.
+func NewMemory() *Memory {
+	return &Memory{
+		zones: map[string]RecordCollection{},
+	}
+}
+
+// Seed will add records to zoneName. Records without an ID are given one.
+func (m *Memory) Seed(zoneName string, records RecordCollection) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, r := range records {
+		if r.ID == "" {
+			r.ID = m.newID()
+		}
+
+		m.zones[zoneName] = append(m.zones[zoneName], r)
+	}
+}
+
+// Zones returns the number of zones known.
+func (m *Memory) Zones() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.zones)
+}
+
+// ZoneNames implements ZoneLister. Names are sorted.
+func (m *Memory) ZoneNames() ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := []string{}
+	for name := range m.zones {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// CreateZone implements ZoneCreator.
+func (m *Memory) CreateZone(zoneName, accountID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, found := m.zones[zoneName]
+	if found {
+		return fmt.Errorf("Zone '%s' already exists", zoneName)
+	}
+
+	m.zones[zoneName] = RecordCollection{}
+
+	return nil
+}
+
+// newID returns a new record ID. The lock must be held.
+func (m *Memory) newID() string {
+	m.nextID++
+
+	return "memory-" + strconv.Itoa(m.nextID)
+}
+
+// List implements Provider.
+func (m *Memory) List(zoneName string) (RecordCollection, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	records, found := m.zones[zoneName]
+	if !found {
+		return nil, fmt.Errorf("Can't get zone records for '%s': zone not found", zoneName)
+	}
+
+	return records.Clone(), nil
+}
+
+// Create implements Provider.
+func (m *Memory) Create(zoneName string, r cloudflare.DNSRecord) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	r.ID = m.newID()
+	m.zones[zoneName] = append(m.zones[zoneName], WithPriority(r))
+
+	return nil
+}
+
+// Update implements Provider.
+func (m *Memory) Update(zoneName string, r cloudflare.DNSRecord) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	records := m.zones[zoneName]
+	for i := range records {
+		if records[i].ID == r.ID {
+			records[i] = WithPriority(r)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Record %s not found", r.ID)
+}
+
+// Delete implements Provider.
+func (m *Memory) Delete(zoneName string, r cloudflare.DNSRecord) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	records := m.zones[zoneName]
+	for i := range records {
+		if records[i].ID == r.ID {
+			records.Remove(i)
+			m.zones[zoneName] = records
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Record %s not found", r.ID)
+}
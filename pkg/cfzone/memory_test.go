@@ -0,0 +1,74 @@
+package cfzone
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+
+	_, err := m.List("example.com")
+	if err == nil {
+		t.Errorf("List() didn't fail for unknown zone")
+	}
+
+	m.Seed("example.com", RecordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+		{ID: "old", Type: "TXT", Name: "example.com", Content: "old"},
+	})
+
+	existing, _ := m.List("example.com")
+	if existing[0].ID != "memory-1" || existing[1].ID != "old" {
+		t.Errorf("Seed() assigned wrong IDs: %+v", existing)
+	}
+
+	changes := Diff(RecordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.2"},
+		{Type: "A", Name: "web.example.com", Content: "192.0.2.3"},
+	}, existing)
+
+	err = changes.Apply(m, "example.com", nil)
+	if err != nil {
+		t.Fatalf("Apply() failed: %s", err.Error())
+	}
+
+	records, _ := m.List("example.com")
+	expected := RecordCollection{
+		{ID: "memory-1", Type: "A", Name: "www.example.com", Content: "192.0.2.2"},
+		{ID: "memory-2", Type: "A", Name: "web.example.com", Content: "192.0.2.3"},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Apply() left wrong records: %+v", records)
+	}
+
+	m.Seed("example.net", RecordCollection{{Type: "A", Name: "example.net", Content: "192.0.2.4"}})
+
+	names, err := m.ZoneNames()
+	if err != nil || !reflect.DeepEqual(names, []string{"example.com", "example.net"}) {
+		t.Errorf("ZoneNames() returned %v, %v", names, err)
+	}
+
+	err = m.Delete("example.com", cloudflare.DNSRecord{ID: "missing"})
+	if err == nil {
+		t.Errorf("Delete() didn't fail for unknown record")
+	}
+
+	err = m.CreateZone("example.org", "")
+	if err != nil {
+		t.Fatalf("CreateZone() failed: %s", err.Error())
+	}
+
+	records, err = m.List("example.org")
+	if err != nil || len(records) != 0 {
+		t.Errorf("CreateZone() created wrong zone: %v, %v", records, err)
+	}
+
+	err = m.CreateZone("example.com", "")
+	if err == nil {
+		t.Errorf("CreateZone() didn't fail for existing zone")
+	}
+}
@@ -0,0 +1,57 @@
+package cfzone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// mergeKey identifies a record when merging collections.
+func mergeKey(r cloudflare.DNSRecord) string {
+	return strings.ToLower(r.Name) + "\x00" + r.Type + "\x00" + CanonicalContent(r)
+}
+
+// Merge will combine c with others, like a base zone with per-environment
+// additions. Records with the same name, type and content in more than one
+// collection are only included once, and must otherwise be identical. An
+// error listing all conflicts is returned if they're not.
+func (c RecordCollection) Merge(others ...RecordCollection) (RecordCollection, error) {
+	result := c.Clone()
+	seen := map[string]cloudflare.DNSRecord{}
+	for _, r := range c {
+		seen[mergeKey(r)] = r
+	}
+
+	conflicts := []string{}
+
+	for _, other := range others {
+		added := map[string]cloudflare.DNSRecord{}
+
+		for _, r := range other {
+			key := mergeKey(r)
+
+			existing, found := seen[key]
+			if !found {
+				result = append(result, r)
+				added[key] = r
+				continue
+			}
+
+			if existing.TTL != r.TTL || cloudflare.Bool(existing.Proxied) != cloudflare.Bool(r.Proxied) || cloudflare.Uint16(existing.Priority) != cloudflare.Uint16(r.Priority) {
+				conflicts = append(conflicts, fmt.Sprintf("%s %s %s", r.Name, r.Type, r.Content))
+			}
+		}
+
+		// Duplicates within a collection are left for Dedupe.
+		for key, r := range added {
+			seen[key] = r
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("Conflicting records:\n  %s", strings.Join(conflicts, "\n  "))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package cfzone
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestMerge(t *testing.T) {
+	www := cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300}
+	mx := cloudflare.DNSRecord{Type: "MX", Name: "example.com", Content: "mx.example.com", Priority: cloudflare.Uint16Ptr(10)}
+	staging := cloudflare.DNSRecord{Type: "A", Name: "staging.example.com", Content: "192.0.2.2"}
+
+	same := www
+	same.Name = "WWW.example.com"
+
+	merged, err := RecordCollection{www, mx}.Merge(RecordCollection{same, staging}, RecordCollection{})
+	if err != nil {
+		t.Fatalf("Merge() failed: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(merged, RecordCollection{www, mx, staging}) {
+		t.Errorf("Merge() returned %+v", merged)
+	}
+
+	ttl := www
+	ttl.TTL = 600
+
+	priority := mx
+	priority.Priority = cloudflare.Uint16Ptr(20)
+
+	_, err = RecordCollection{www, mx}.Merge(RecordCollection{staging}, RecordCollection{ttl, priority})
+	if err == nil {
+		t.Fatalf("Merge() didn't detect conflicts")
+	}
+
+	if !strings.Contains(err.Error(), "www.example.com A 192.0.2.1") || !strings.Contains(err.Error(), "example.com MX mx.example.com") {
+		t.Errorf("Merge() returned wrong error: %s", err.Error())
+	}
+}
@@ -0,0 +1,363 @@
+package cfzone
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
+)
+
+// ParseOptions controls how zone files are parsed by ParseZone.
+type ParseOptions struct {
+	// SkipUnsupported will skip records of types not supported instead of
+	// failing. Skipped records are returned by ParseZone.
+	SkipUnsupported bool
+
+	// Skipped is called for every skipped record if set. ParseZone and
+	// ParseZoneFunc will not collect skipped records when it's used.
+	Skipped func(rr dns.RR)
+
+	// Origin is used for relative names, and as the zone name if the zone
+	// file has no SOA record. This makes it possible to parse partial zone
+	// files, like layers merged into a zone.
+	Origin string
+
+	// DefaultTTL returns the TTL of records of type recordType in zoneName
+	// without one, until the zone file gives a default using $TTL or a
+	// record with a TTL. 0 or a nil DefaultTTL means such records are
+	// errors. A TTL of 1 makes proxiable records proxied.
+	DefaultTTL func(zoneName string, recordType string) int
+
+	// ZoneStart is called for every SOA record if set, before the records
+	// following it. This makes it possible to split files holding several
+	// zones.
+	ZoneStart func(zoneName string)
+}
+
+// placeholderTTL is given to records without a TTL by the parser, as it
+// needs a default to accept them. TTLs with the most significant bit set are
+// treated as 0 by RFC 2181, so it will never be a useful TTL in a zone file.
+const placeholderTTL = math.MaxUint32
+
+// ParseZone will parse a BIND style zone file and return the zone name and
+// all records. Names and content are validated, and records with a type not
+// supported by Cloudflare will fail unless skipped using opts.
+func ParseZone(r io.Reader, opts ParseOptions) (zoneName string, records RecordCollection, skipped []dns.RR, err error) {
+	records = RecordCollection{}
+
+	zoneName, skipped, err = ParseZoneFunc(r, opts, func(record cloudflare.DNSRecord) error {
+		records = append(records, record)
+
+		return nil
+	})
+	if err != nil {
+		return "", RecordCollection{}, nil, err
+	}
+
+	return zoneName, records, skipped, nil
+}
+
+// ParseZoneFunc is like ParseZone, but will call fn for every record as the
+// zone file is read instead of returning a collection. Only a small window
+// of the zone file is held in memory, making it possible to handle huge
+// zones. If fn returns an error, parsing stops and the error is returned.
+func ParseZoneFunc(r io.Reader, opts ParseOptions, fn func(record cloudflare.DNSRecord) error) (zoneName string, skipped []dns.RR, err error) {
+	origin := ""
+	if opts.Origin != "" {
+		origin = dns.Fqdn(opts.Origin)
+	}
+
+	zp := dns.NewZoneParser(r, origin, "")
+	zp.SetIncludeAllowed(true)
+
+	// ttlKnown is set once the zone file has given a TTL, after which
+	// records without one use it instead of DefaultTTL.
+	ttlKnown := opts.DefaultTTL == nil
+	if !ttlKnown {
+		zp.SetDefaultTTL(placeholderTTL)
+	}
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		missingTTL := false
+		if !ttlKnown {
+			missingTTL = rr.Header().Ttl == placeholderTTL
+			ttlKnown = !missingTTL
+		}
+
+		// Search for zonename while we're at it.
+		soa, found := rr.(*dns.SOA)
+		if found {
+			zoneName = Name(soa.Header().Name)
+
+			if opts.ZoneStart != nil {
+				opts.ZoneStart(zoneName)
+			}
+		}
+
+		err = WildcardError(rr.Header().Name)
+		if err != nil {
+			return "", nil, err
+		}
+
+		err = ContentError(rr)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %s", strings.Trim(rr.Header().Name, "."), err.Error())
+		}
+
+		record, err := NewRecord(rr)
+		if err != nil && opts.SkipUnsupported {
+			if opts.Skipped != nil {
+				opts.Skipped(rr)
+			} else {
+				skipped = append(skipped, rr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		if record != nil && missingTTL {
+			name := zoneName
+			if name == "" {
+				name = Name(opts.Origin)
+			}
+
+			record.TTL = opts.DefaultTTL(name, record.Type)
+			if record.TTL == 0 {
+				return "", nil, fmt.Errorf("%s has no TTL, and there's no default TTL for %s", record.Name, name)
+			}
+
+			if record.TTL == 1 && Proxiable(record.Type) {
+				record.Proxied = cloudflare.BoolPtr(true)
+			}
+		}
+
+		if record != nil {
+			record.Comment = zoneComment(zp.Comment())
+
+			err = fn(*record)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	err = zp.Err()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if zoneName == "" {
+		zoneName = Name(opts.Origin)
+	}
+
+	if zoneName == "" {
+		return "", nil, errors.New("Zone name not found")
+	}
+
+	return zoneName, skipped, nil
+}
+
+// zoneComment returns the comment for a record from the comments following
+// it in a zone file, like "; legacy mail host". The PROXIED marker written by
+// Fprint is left out.
+func zoneComment(comment string) string {
+	parts := []string{}
+
+	for _, part := range strings.Split(comment, ";") {
+		part = strings.TrimSpace(part)
+		if part != "" && part != "PROXIED" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// NewRecord will instantiate a new cloudflare-compatible DNS record based on
+// a record from miekg/dns. NS and SOA records are ignored, and nil is
+// returned.
+// If the TTL has a value of 1 Proxied will be set to true in the resulting
+// DNSRecord mimicking Cloudflare internal TTL's, unless the type can't be
+// proxied.
+// A TTL of 0 will result in "automatic" TTL.
+func NewRecord(in dns.RR) (*cloudflare.DNSRecord, error) {
+	record := &cloudflare.DNSRecord{
+		Name: Name(in.Header().Name),
+		TTL:  int(in.Header().Ttl),
+	}
+
+	if record.TTL == 1 && Proxiable(dns.TypeToString[in.Header().Rrtype]) {
+		record.Proxied = cloudflare.BoolPtr(true)
+	}
+
+	switch in.(type) {
+	case *dns.A:
+		a := in.(*dns.A)
+		record.Content = a.A.String()
+		record.Type = "A"
+		return record, nil
+
+	case *dns.AAAA:
+		a := in.(*dns.AAAA)
+		record.Content = a.AAAA.String()
+		record.Type = "AAAA"
+		return record, nil
+
+	case *dns.CNAME:
+		cname := in.(*dns.CNAME)
+		// CloudFlare does not use the "FQDN-dot". We remove it.
+		record.Content = Target(cname.Target)
+		record.Type = "CNAME"
+		return record, nil
+
+	case *dns.MX:
+		mx := in.(*dns.MX)
+		record.Content = Target(mx.Mx)
+		record.Priority = cloudflare.Uint16Ptr(mx.Preference)
+		record.Type = "MX"
+		return record, nil
+
+	case *dns.TXT:
+		// Empty TXT records are used as placeholders, and get empty
+		// content. Whitespace is kept as is. Records with several
+		// strings, like long DKIM keys, are joined.
+		txt := in.(*dns.TXT)
+		for _, s := range txt.Txt {
+			record.Content += unescapeTXT(s)
+		}
+		record.Type = "TXT"
+		return record, nil
+
+	case *dns.NS, *dns.SOA:
+		// We silently ignore NS and SOA because Cloudflare does not allow
+		// the user to change nameservers and SOA doesn't make sense.
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("Record type %T is not supported", in)
+}
+
+// ValidLabel returns true if label only holds letters, digits, hyphens and
+// underscores, and doesn't start or end with a hyphen.
+func ValidLabel(label string) bool {
+	if label == "" || len(label) > 63 {
+		return false
+	}
+
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	for _, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidHostname returns true if name is a valid host name with or without a
+// trailing dot.
+func ValidHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if !ValidLabel(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// knownCAATags holds the CAA property tags supported by Cloudflare.
+var knownCAATags = map[string]bool{
+	"issue":     true,
+	"issuewild": true,
+	"iodef":     true,
+}
+
+// ContentError returns an error if the content of rr is invalid for its type.
+// miekg/dns will happily parse an IPv6 address in an A record and any
+// label in a target.
+func ContentError(rr dns.RR) error {
+	switch rr := rr.(type) {
+	case *dns.A:
+		if rr.A.To4() == nil {
+			return fmt.Errorf("A record content '%s' is not an IPv4 address", ipString(rr.A))
+		}
+
+	case *dns.AAAA:
+		if rr.AAAA == nil || rr.AAAA.To4() != nil {
+			return fmt.Errorf("AAAA record content '%s' is not an IPv6 address", ipString(rr.AAAA))
+		}
+
+	case *dns.CNAME:
+		if !ValidHostname(rr.Target) {
+			return fmt.Errorf("Illegal CNAME target '%s'", rr.Target)
+		}
+
+	case *dns.MX:
+		// A single dot is a null MX as defined by RFC 7505.
+		if rr.Mx != "." && !ValidHostname(rr.Mx) {
+			return fmt.Errorf("Illegal MX target '%s'", rr.Mx)
+		}
+
+	case *dns.CAA:
+		if !knownCAATags[strings.ToLower(rr.Tag)] {
+			return fmt.Errorf("Unknown CAA tag '%s'", rr.Tag)
+		}
+	}
+
+	return nil
+}
+
+// ipString returns ip as a string, or an empty string if ip is missing.
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
+
+// Proxiable returns true if Cloudflare can proxy records of type t.
+func Proxiable(t string) bool {
+	return t == "A" || t == "AAAA" || t == "CNAME"
+}
+
+// WildcardError returns an error if name uses a wildcard anywhere but as the
+// complete leftmost label.
+func WildcardError(name string) error {
+	for i, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if !strings.Contains(label, "*") {
+			continue
+		}
+
+		if i > 0 {
+			return fmt.Errorf("Wildcard in '%s' must be the leftmost label", name)
+		}
+
+		if label != "*" {
+			return fmt.Errorf("Wildcard in '%s' must be a complete label", name)
+		}
+	}
+
+	return nil
+}
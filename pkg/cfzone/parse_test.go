@@ -0,0 +1,586 @@
+package cfzone
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
+)
+
+func TestParseZone(t *testing.T) {
+	zone := `
+$ORIGIN example.com.
+$TTL 3600
+
+@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
+          2015071700 ; serial
+          86400 ; refresh
+          7200 ; retry
+          604800 ; expire
+          86400 ; minimum
+          )
+
+@     1800     IN NS    ns1.example.com.
+@     1800     IN NS    ns2.example.com.
+@     1800     IN NS    ns3.example.com.
+@     1800     IN MX    10 mail10.example.com.
+test1 1800 IN A 127.0.0.1
+test2 1800 IN CNAME test1
+test3 1800 IN AAAA ::1
+test4 1 IN A 127.0.0.4
+@     1800 IN TXT "v=spf1 include:spf.example.com -all"
+`
+
+	parsed := RecordCollection{
+		cloudflare.DNSRecord{
+			Type:     "MX",
+			Priority: cloudflare.Uint16Ptr(10),
+			Name:     "example.com",
+			Content:  "mail10.example.com",
+			TTL:      1800,
+		},
+		cloudflare.DNSRecord{
+			Type:    "A",
+			Name:    "test1.example.com",
+			Content: "127.0.0.1",
+			TTL:     1800,
+		},
+		cloudflare.DNSRecord{
+			Type:    "CNAME",
+			Name:    "test2.example.com",
+			Content: "test1.example.com",
+			TTL:     1800,
+		},
+		cloudflare.DNSRecord{
+			Type:    "AAAA",
+			Name:    "test3.example.com",
+			Content: "::1",
+			TTL:     1800,
+		},
+		cloudflare.DNSRecord{
+			Type:    "A",
+			Name:    "test4.example.com",
+			Content: "127.0.0.4",
+			TTL:     1,
+			Proxied: cloudflare.BoolPtr(true),
+		},
+		cloudflare.DNSRecord{
+			Type:    "TXT",
+			Name:    "example.com",
+			Content: "v=spf1 include:spf.example.com -all",
+			TTL:     1800,
+		},
+	}
+
+	cases := []struct {
+		zone         string
+		expectedName string
+		expected     RecordCollection
+		err          bool
+	}{
+		{"", "", RecordCollection{}, true},
+		{"broken zone", "", RecordCollection{}, true},
+		{zone, "example.com", parsed, false},
+	}
+
+	for i, in := range cases {
+		r := strings.NewReader(in.zone)
+		zoneName, records, _, err := ParseZone(r, ParseOptions{})
+		if in.err && err == nil {
+			t.Fatalf("%d: ParseZone() failed to error on [%s]", i, in.zone)
+		}
+
+		if !in.err && err != nil {
+			t.Fatalf("%d: ParseZone() returned error on [%s]: %s", i, in.zone, err.Error())
+		}
+
+		if zoneName != in.expectedName {
+			t.Errorf("%d: ParseZone() rturned wrong zone name for [%s], got %s, expected %s", i, in.zone, zoneName, in.expectedName)
+		}
+
+		if !reflect.DeepEqual(in.expected, records) {
+			t.Errorf("%d: ParseZone() returned wrong zone for [%s], got:\n%s, expected:\n%s", i, in.zone, zoneString(records), zoneString(in.expected))
+		}
+	}
+}
+
+func TestParseZoneFail(t *testing.T) {
+	cases := []string{`$ORIGIN example.com.
+
+@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
+          2015071700
+          86400
+          7200
+          604800
+          86400
+)
+test1 1800 IN A 127.0.0.1
+loc1 IN LOC 57 2 59.173 N 9 56 42.07 E 0m 10m 100m 10m
+`, `@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
+	  2015071700
+	  86400
+	  7200
+	  604800
+	  86400
+)
+test2 1800 IN A 127.0.0.2
+`, `$ORIGIN example.com.
+
+@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
+          2015071700
+          86400
+          7200
+          604800
+          86400
+)
+test3 1800 IN A ::1
+`,
+	}
+
+	for i, in := range cases {
+		r := strings.NewReader(in)
+		zoneName, records, _, err := ParseZone(r, ParseOptions{})
+
+		if zoneName != "" {
+			t.Errorf("%d ParseZone() returned a zonename for a broken zone: %s", i, zoneName)
+		}
+
+		if len(records) > 0 {
+			t.Errorf("%d: ParseZone() returned record for a broken zone", i)
+		}
+
+		if err == nil {
+			t.Errorf("%d: ParseZone() failed to err on broken zone", i)
+		}
+
+		ParseZone(r, ParseOptions{})
+	}
+}
+
+func zoneString(c RecordCollection) string {
+	var b bytes.Buffer
+
+	w := bufio.NewWriter(&b)
+	c.Fprint(w)
+	w.Flush()
+
+	return b.String()
+}
+
+func TestContentError(t *testing.T) {
+	cases := []struct {
+		rr       string
+		expected string
+	}{
+		{"www.example.com. IN A 192.0.2.1", ""},
+		{"www.example.com. IN A 2001:db8::1", "A record content '2001:db8::1' is not an IPv4 address"},
+		{"www.example.com. IN AAAA 2001:db8::1", ""},
+		{"www.example.com. IN AAAA 192.0.2.1", "AAAA record content '192.0.2.1' is not an IPv6 address"},
+		{"www.example.com. IN CNAME web.example.com.", ""},
+		{"www.example.com. IN CNAME _acme.example.com.", ""},
+		{"s1._domainkey.example.com. IN CNAME s1._domainkey.mail.example.net.", ""},
+		{"_sip._tcp.example.com. IN CNAME _sip._tcp.voip.example.net.", ""},
+		{"www.example.com. IN CNAME web!.example.com.", "Illegal CNAME target 'web!.example.com.'"},
+		{"www.example.com. IN CNAME -web.example.com.", "Illegal CNAME target '-web.example.com.'"},
+		{"example.com. IN MX 10 mail.example.com.", ""},
+		{"example.com. IN MX 0 .", ""},
+		{"example.com. IN MX 10 mail\\.example.com.", "Illegal MX target 'mail\\.example.com.'"},
+		{"example.com. IN CAA 0 issue \"letsencrypt.org\"", ""},
+		{"example.com. IN CAA 0 IODEF \"mailto:hostmaster@example.com\"", ""},
+		{"example.com. IN CAA 0 isue \"letsencrypt.org\"", "Unknown CAA tag 'isue'"},
+		{"example.com. IN TXT \"anything goes\"", ""},
+	}
+
+	for i, in := range cases {
+		rr, err := dns.NewRR(in.rr)
+		if err != nil {
+			t.Fatalf("%d: dns.NewRR() failed: %s", i, err.Error())
+		}
+
+		err = ContentError(rr)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+
+		if got != in.expected {
+			t.Errorf("%d: ContentError() returned [%s] for [%s], expected [%s]", i, got, in.rr, in.expected)
+		}
+	}
+}
+
+func TestWildcardError(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"www.example.com.", ""},
+		{"*.example.com.", ""},
+		{"*.www.example.com", ""},
+		{"www.*.example.com.", "Wildcard in 'www.*.example.com.' must be the leftmost label"},
+		{"*.*.example.com.", "Wildcard in '*.*.example.com.' must be the leftmost label"},
+		{"*www.example.com.", "Wildcard in '*www.example.com.' must be a complete label"},
+		{"w*.example.com.", "Wildcard in 'w*.example.com.' must be a complete label"},
+	}
+
+	for i, in := range cases {
+		err := WildcardError(in.name)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+
+		if got != in.expected {
+			t.Errorf("%d: WildcardError() returned [%s] for '%s', expected [%s]", i, got, in.name, in.expected)
+		}
+	}
+}
+
+func TestParseZoneSkipUnsupported(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@ 86400 IN SOA ns1.example.com. hostmaster.example.com. 2015071700 86400 7200 604800 86400
+test1 1800 IN A 127.0.0.1
+_sip._tcp 3600 IN SRV 10 60 5060 sip.example.com.
+`
+
+	_, _, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+	if err == nil {
+		t.Errorf("ParseZone() accepted an unsupported record")
+	}
+
+	_, records, skipped, err := ParseZone(strings.NewReader(zone), ParseOptions{SkipUnsupported: true})
+	if err != nil {
+		t.Fatalf("ParseZone() failed with SkipUnsupported: %s", err.Error())
+	}
+
+	if len(records) != 1 || len(skipped) != 1 || skipped[0].Header().Rrtype != dns.TypeSRV {
+		t.Errorf("ParseZone() returned wrong records %v and skipped %v", records, skipped)
+	}
+}
+
+func TestParseZoneFunc(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+$GENERATE 1-254 host-$ 3600 IN A 192.0.2.$
+loc 3600 IN LOC 57 2 59.173 N 9 56 42.07 E 0m 10m 100m 10m
+`
+
+	n := 0
+	skipped := 0
+	opts := ParseOptions{
+		SkipUnsupported: true,
+		Skipped:         func(rr dns.RR) { skipped++ },
+	}
+
+	zoneName, collected, err := ParseZoneFunc(strings.NewReader(zone), opts, func(r cloudflare.DNSRecord) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseZoneFunc() failed: %s", err.Error())
+	}
+
+	if zoneName != "example.com" {
+		t.Errorf("ParseZoneFunc() returned wrong zone name '%s'", zoneName)
+	}
+
+	if n != 254 {
+		t.Errorf("ParseZoneFunc() called fn %d times, expected 254", n)
+	}
+
+	if skipped != 1 || len(collected) != 0 {
+		t.Errorf("ParseZoneFunc() skipped %d and collected %d records", skipped, len(collected))
+	}
+
+	// Errors from fn must stop parsing.
+	stop := errors.New("stop")
+	n = 0
+	_, _, err = ParseZoneFunc(strings.NewReader(zone), opts, func(r cloudflare.DNSRecord) error {
+		n++
+		if n == 10 {
+			return stop
+		}
+
+		return nil
+	})
+	if err != stop || n != 10 {
+		t.Errorf("ParseZoneFunc() didn't stop on error, got %v after %d records", err, n)
+	}
+}
+
+func TestParseZoneDefaultTTL(t *testing.T) {
+	opts := ParseOptions{
+		Origin: "example.com",
+		DefaultTTL: func(zoneName string, recordType string) int {
+			if zoneName != "example.com" || recordType != "A" {
+				return 0
+			}
+
+			return 300
+		},
+	}
+
+	cases := []struct {
+		zone     string
+		expected []int
+		err      bool
+	}{
+		{"www IN A 192.0.2.1\napi 600 IN A 192.0.2.2\nmail IN A 192.0.2.3\n", []int{300, 600, 600}, false},
+		{"$TTL 3600\nwww IN A 192.0.2.1\n", []int{3600}, false},
+		{"www IN AAAA 2001:db8::1\n", nil, true},
+
+		{"www 2147483647 IN A 192.0.2.1\nmail IN A 192.0.2.3\n", []int{2147483647, 2147483647}, false},
+	}
+
+	for i, c := range cases {
+		_, records, _, err := ParseZone(strings.NewReader(c.zone), opts)
+		if (err != nil) != c.err {
+			t.Errorf("%d ParseZone() returned wrong error: %v", i, err)
+			continue
+		}
+
+		ttls := []int{}
+		for _, r := range records {
+			ttls = append(ttls, r.TTL)
+		}
+
+		if !c.err && !reflect.DeepEqual(ttls, c.expected) {
+			t.Errorf("%d ParseZone() returned TTLs %v, expected %v", i, ttls, c.expected)
+		}
+	}
+}
+
+func TestParseZoneOrigin(t *testing.T) {
+	zoneName, records, _, err := ParseZone(strings.NewReader("staging 300 IN A 192.0.2.2\n"), ParseOptions{Origin: "example.com"})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	if zoneName != "example.com" || len(records) != 1 || records[0].Name != "staging.example.com" {
+		t.Errorf("ParseZone() returned %s and %+v", zoneName, records)
+	}
+}
+
+func TestParseZoneComments(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400 ; serial
+mail 300 IN A 192.0.2.1 ; legacy mail host, remove after Q3
+www 1 IN A 192.0.2.2 ; PROXIED ; frontend
+mx 300 IN MX 10 ( ; first
+  mail ) ; second
+; not attached to anything
+api 300 IN A 192.0.2.3
+`
+
+	_, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	comments := []string{}
+	for _, r := range records {
+		comments = append(comments, r.Comment)
+	}
+
+	expected := []string{"legacy mail host, remove after Q3", "frontend", "first; second", ""}
+	if !reflect.DeepEqual(comments, expected) {
+		t.Errorf("ParseZone() returned wrong comments %q", comments)
+	}
+
+	// Comments must survive printing and parsing again.
+	var b bytes.Buffer
+	records.FprintZone(&b, "example.com", LayoutSorted)
+
+	_, reparsed, _, err := ParseZone(strings.NewReader("example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400\n"+b.String()), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed on FprintZone() output: %s", err.Error())
+	}
+
+	for _, r := range reparsed {
+		found := false
+		for _, o := range records {
+			if o.Name == r.Name && o.Comment == r.Comment && cloudflare.Bool(o.Proxied) == cloudflare.Bool(r.Proxied) {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("%s lost its comment, got %q", r.Name, r.Comment)
+		}
+	}
+}
+
+func TestParseZoneNullMX(t *testing.T) {
+	zone := `example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+example.com. 300 IN MX 0 .
+`
+
+	_, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	if len(records) != 1 || records[0].Content != "." || records[0].Priority == nil || *records[0].Priority != 0 {
+		t.Fatalf("ParseZone() returned %+v for a null MX", records)
+	}
+
+	existing := records[0]
+	existing.ID = "1"
+
+	changes := Diff(records, RecordCollection{existing})
+	if changes.Unchanged != 1 {
+		t.Errorf("Diff() didn't match a null MX: %+v", changes)
+	}
+
+	existing.Priority = cloudflare.Uint16Ptr(10)
+
+	changes = Diff(records, RecordCollection{existing})
+	if len(changes.Updates) != 1 || *changes.Updates[0].Priority != 0 {
+		t.Errorf("Diff() didn't update the priority to 0: %+v", changes)
+	}
+
+	// Printing and parsing again must give the same record.
+	var b bytes.Buffer
+	records.FprintZone(&b, "example.com", LayoutSorted)
+
+	_, reparsed, _, err := ParseZone(strings.NewReader(zone[:strings.Index(zone, "\n")+1]+b.String()), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed on FprintZone() output: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(reparsed, records) {
+		t.Errorf("FprintZone() changed a null MX to %+v", reparsed)
+	}
+
+	// Record sets used by Route53 and Cloud DNS.
+	r := cloudflare.DNSRecord{Type: "MX"}
+	setRdata(&r, rdata(records[0]))
+	if r.Content != "." || cloudflare.Uint16(r.Priority) != 0 || r.Priority == nil {
+		t.Errorf("Record set value of a null MX became %+v", r)
+	}
+}
+
+func TestParseZoneEmptyTXT(t *testing.T) {
+	zone := `example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+empty.example.com. 300 IN TXT ""
+blank.example.com. 300 IN TXT " "
+`
+
+	_, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	if len(records) != 2 || records[0].Content != "" || records[1].Content != " " {
+		t.Fatalf("ParseZone() returned %+v", records)
+	}
+
+	// Empty and blank are different, and must only match themselves.
+	existing := RecordCollection{
+		{ID: "1", Type: "TXT", Name: "empty.example.com", Content: " ", TTL: 300},
+		{ID: "2", Type: "TXT", Name: "blank.example.com", Content: " ", TTL: 300},
+	}
+
+	changes := Diff(records, existing)
+	if changes.Unchanged != 1 || len(changes.Updates) != 1 || changes.Updates[0].Content != "" {
+		t.Errorf("Diff() returned %+v", changes)
+	}
+
+	// Cloudflare requires content, an empty TXT record is sent as "".
+	if content := cloudflareContent(records[0]); content != `""` {
+		t.Errorf("cloudflareContent() returned %q for an empty TXT record", content)
+	}
+
+	fetched := RecordCollection{{Type: "TXT", Content: `""`}, {Type: "TXT", Content: " "}}.FromCloudflare()
+	if fetched[0].Content != "" || fetched[1].Content != " " {
+		t.Errorf("FromCloudflare() returned %+v", fetched)
+	}
+
+	var b bytes.Buffer
+	records.FprintZone(&b, "example.com", LayoutSorted)
+
+	_, reparsed, _, err := ParseZone(strings.NewReader(zone[:strings.Index(zone, "\n")+1]+b.String()), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed on FprintZone() output: %s", err.Error())
+	}
+
+	reparsed.Sort()
+	records.Sort()
+	if !reflect.DeepEqual(reparsed, records) {
+		t.Errorf("FprintZone() changed empty TXT records to %+v", reparsed)
+	}
+
+	r := cloudflare.DNSRecord{Type: "TXT"}
+	setRdata(&r, rdata(records[1]))
+	if r.Content != "" {
+		t.Errorf("Record set value of an empty TXT record became %q", r.Content)
+	}
+}
+
+func TestValidLabel(t *testing.T) {
+	cases := map[string]bool{
+		"www":             true,
+		"_dmarc":          true,
+		"_acme-challenge": true,
+		"_sip":            true,
+		"under_score":     true,
+		"_":               true,
+		"":                false,
+		"-www":            false,
+		"www-":            false,
+		"w!w":             false,
+	}
+
+	for label, expected := range cases {
+		if ValidLabel(label) != expected {
+			t.Errorf("ValidLabel(%q) returned %v, expected %v", label, !expected, expected)
+		}
+	}
+
+	if !ValidHostname("_sip._tcp.example.com.") {
+		t.Errorf("ValidHostname() rejected a name with underscore labels")
+	}
+}
+
+func TestParseZoneUnderscores(t *testing.T) {
+	zone := `example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+_dmarc 300 IN TXT "v=DMARC1; p=reject"
+_acme-challenge.www 300 IN TXT "token"
+_sip._tcp 300 IN CNAME _sip._tcp.voip.example.net.
+`
+
+	_, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{Origin: "example.com"})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	names := []string{}
+	for _, r := range records {
+		names = append(names, r.Name)
+	}
+
+	expected := []string{"_dmarc.example.com", "_acme-challenge.www.example.com", "_sip._tcp.example.com"}
+	if !reflect.DeepEqual(names, expected) || records[2].Content != "_sip._tcp.voip.example.net" {
+		t.Fatalf("ParseZone() returned %+v", records)
+	}
+
+	existing := records.Clone()
+	for i := range existing {
+		existing[i].ID = strconv.Itoa(i)
+	}
+
+	if changes := Diff(records, existing); changes.Unchanged != len(records) {
+		t.Errorf("Diff() didn't match records with underscore labels: %+v", changes)
+	}
+
+	if matched := records.Filter(ByName("_acme-challenge.*")); len(matched) != 1 {
+		t.Errorf("ByName() matched %+v", matched)
+	}
+}
@@ -0,0 +1,26 @@
+package cfzone
+
+import (
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// HasPriority returns true if records of type t carry a priority. Cloudflare
+// tells a missing priority from priority 0 for these types, and rejects a
+// priority for all others.
+func HasPriority(t string) bool {
+	return t == "MX" || t == "SRV" || t == "URI"
+}
+
+// WithPriority returns r with its priority mapped for its type. Types with a
+// priority always get one, 0 if missing, and other types never have one.
+func WithPriority(r cloudflare.DNSRecord) cloudflare.DNSRecord {
+	switch {
+	case !HasPriority(r.Type):
+		r.Priority = nil
+
+	case r.Priority == nil:
+		r.Priority = cloudflare.Uint16Ptr(0)
+	}
+
+	return r
+}
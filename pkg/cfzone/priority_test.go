@@ -0,0 +1,29 @@
+package cfzone
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestWithPriority(t *testing.T) {
+	cases := []struct {
+		in       cloudflare.DNSRecord
+		expected *uint16
+	}{
+		{cloudflare.DNSRecord{Type: "MX", Priority: cloudflare.Uint16Ptr(10)}, cloudflare.Uint16Ptr(10)},
+		{cloudflare.DNSRecord{Type: "MX", Priority: cloudflare.Uint16Ptr(0)}, cloudflare.Uint16Ptr(0)},
+		{cloudflare.DNSRecord{Type: "MX"}, cloudflare.Uint16Ptr(0)},
+		{cloudflare.DNSRecord{Type: "SRV"}, cloudflare.Uint16Ptr(0)},
+		{cloudflare.DNSRecord{Type: "A", Priority: cloudflare.Uint16Ptr(0)}, nil},
+		{cloudflare.DNSRecord{Type: "TXT"}, nil},
+	}
+
+	for i, c := range cases {
+		r := WithPriority(c.in)
+		if !reflect.DeepEqual(r.Priority, c.expected) {
+			t.Errorf("%d: WithPriority() returned priority %v for %s", i, r.Priority, c.in.Type)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package cfzone
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Provider is a DNS provider hosting zones. Records are identified by their
+// ID as returned by List.
+type Provider interface {
+	// List returns all records in zoneName.
+	List(zoneName string) (RecordCollection, error)
+
+	// Create will add r to zoneName.
+	Create(zoneName string, r cloudflare.DNSRecord) error
+
+	// Update will replace the record with the ID of r in zoneName with r.
+	Update(zoneName string, r cloudflare.DNSRecord) error
+
+	// Delete will remove the record with the ID of r from zoneName.
+	Delete(zoneName string, r cloudflare.DNSRecord) error
+}
+
+// BatchDeleter is implemented by providers able to delete many records in a
+// single call.
+type BatchDeleter interface {
+	// DeleteBatch will remove all records in c from zoneName. Either all
+	// or none of the records are deleted.
+	DeleteBatch(zoneName string, c RecordCollection) error
+}
+
+// ZoneLister is implemented by providers able to list the zones available.
+type ZoneLister interface {
+	// ZoneNames returns the names of all zones available.
+	ZoneNames() ([]string, error)
+}
+
+// Normalizer is implemented by providers unable to store all attributes of a
+// record, like the automatic TTL and proxy of Cloudflare.
+type Normalizer interface {
+	// Normalize returns r the way the provider would store it.
+	Normalize(r cloudflare.DNSRecord) cloudflare.DNSRecord
+}
+
+// Normalize returns c the way provider would store the records, if provider
+// is a Normalizer. Records to sync should be normalized before diffing, or
+// records the provider can't store as given would differ on every sync.
+func Normalize(provider Provider, c RecordCollection) RecordCollection {
+	n, ok := provider.(Normalizer)
+	if !ok {
+		return c
+	}
+
+	result := make(RecordCollection, 0, len(c))
+	for _, r := range c {
+		result = append(result, n.Normalize(r))
+	}
+
+	return result
+}
+
+// ZoneCreator is implemented by providers able to create zones.
+type ZoneCreator interface {
+	// CreateZone will create the empty zone zoneName owned by the
+	// account with the ID accountID. Providers without accounts ignore
+	// accountID.
+	CreateZone(zoneName, accountID string) error
+}
+
+// Cloudflare is a Provider using the Cloudflare API.
+type Cloudflare struct {
+	// Timeout is the maximum duration of each API call. 0 means no
+	// timeout.
+	Timeout time.Duration
+
+	// PageSize is the number of records fetched by each API call when
+	// listing records. 0 uses the default of cloudflare-go.
+	PageSize int
+
+	// Prefetch is the number of pages fetched concurrently when listing
+	// records. 0 or 1 fetches one page at a time.
+	Prefetch int
+
+	// KeepComments leaves comments alone when updating records, for
+	// comments managed by someone else.
+	KeepComments bool
+
+	api *cloudflare.API
+
+	lock sync.Mutex
+	ids  map[string]string
+}
+
+// NewCloudflare returns a Provider using api.
+func NewCloudflare(api *cloudflare.API) *Cloudflare {
+	return &Cloudflare{
+		api: api,
+		ids: map[string]string{},
+	}
+}
+
+// context returns a context for a single API call.
+func (c *Cloudflare) context() (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), c.Timeout)
+}
+
+// ZoneID returns the Cloudflare ID of zoneName. IDs are cached.
+func (c *Cloudflare) ZoneID(zoneName string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id, found := c.ids[zoneName]
+	if found {
+		return id, nil
+	}
+
+	id, err := c.api.ZoneIDByName(zoneName)
+	if err != nil {
+		return "", fmt.Errorf("Can't get zone ID for '%s': %s", zoneName, err.Error())
+	}
+
+	c.ids[zoneName] = id
+
+	return id, nil
+}
+
+// ZoneNames implements ZoneLister. The IDs of the zones are cached.
+func (c *Cloudflare) ZoneNames() ([]string, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	zones, err := c.api.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	names := []string{}
+	for _, z := range zones {
+		c.ids[z.Name] = z.ID
+		names = append(names, z.Name)
+	}
+
+	return names, nil
+}
+
+// CreateZone implements ZoneCreator. The zone is created without importing
+// any records, and the ID is cached.
+func (c *Cloudflare) CreateZone(zoneName, accountID string) error {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	zone, err := c.api.CreateZone(ctx, zoneName, false, cloudflare.Account{ID: accountID}, "full")
+	if err != nil {
+		return fmt.Errorf("Can't create zone '%s': %s", zoneName, err.Error())
+	}
+
+	c.lock.Lock()
+	c.ids[zoneName] = zone.ID
+	c.lock.Unlock()
+
+	return nil
+}
+
+// ZoneModified returns the time zoneName was last modified according to
+// Cloudflare.
+func (c *Cloudflare) ZoneModified(zoneName string) (time.Time, error) {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	zone, err := c.api.ZoneDetails(ctx, id)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Can't get zone details for '%s': %s", id, err.Error())
+	}
+
+	return zone.ModifiedOn, nil
+}
+
+// NameServers returns the name servers Cloudflare assigned to zoneName.
+func (c *Cloudflare) NameServers(zoneName string) ([]string, error) {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	zone, err := c.api.ZoneDetails(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get zone details for '%s': %s", id, err.Error())
+	}
+
+	return zone.NameServers, nil
+}
+
+// List implements Provider.
+func (c *Cloudflare) List(zoneName string) (RecordCollection, error) {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.PageSize <= 0 && c.Prefetch <= 1 {
+		ctx, cancel := c.context()
+		defer cancel()
+
+		records, _, err := c.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(id), cloudflare.ListDNSRecordsParams{})
+		if err != nil {
+			return nil, fmt.Errorf("Can't get zone records for '%s': %s", id, err.Error())
+		}
+
+		return RecordCollection(records).FromCloudflare(), nil
+	}
+
+	records, err := listPages(func(page int) (RecordCollection, int, error) {
+		ctx, cancel := c.context()
+		defer cancel()
+
+		params := cloudflare.ListDNSRecordsParams{}
+		params.Page = page
+		params.PerPage = c.PageSize
+
+		records, info, err := c.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(id), params)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		pages := 1
+		if info != nil {
+			pages = info.TotalPages
+		}
+
+		return RecordCollection(records).FromCloudflare(), pages, nil
+	}, c.Prefetch)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get zone records for '%s': %s", id, err.Error())
+	}
+
+	return records, nil
+}
+
+// emptyTXT is the content of an empty TXT record at Cloudflare, which
+// requires all records to have content.
+const emptyTXT = `""`
+
+// cloudflareContent returns the content of r as given to Cloudflare.
+func cloudflareContent(r cloudflare.DNSRecord) string {
+	if r.Type == "TXT" && r.Content == "" {
+		return emptyTXT
+	}
+
+	return r.Content
+}
+
+// cloudflareData returns the data of r as given to Cloudflare, for record
+// types Cloudflare only accepts as structured data. DS records are given as
+// key tag, algorithm, digest type and digest, like "2371 13 2 1F98...".
+func cloudflareData(r cloudflare.DNSRecord) (interface{}, error) {
+	if r.Type != "DS" {
+		return nil, nil
+	}
+
+	fields := strings.Fields(r.Content)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("Invalid DS record '%s' for %s", r.Content, r.Name)
+	}
+
+	numbers := make([]uint64, 3)
+	for i, bits := range []int{16, 8, 8} {
+		n, err := strconv.ParseUint(fields[i], 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid DS record '%s' for %s", r.Content, r.Name)
+		}
+
+		numbers[i] = n
+	}
+
+	return map[string]interface{}{
+		"key_tag":     numbers[0],
+		"algorithm":   numbers[1],
+		"digest_type": numbers[2],
+		"digest":      fields[3],
+	}, nil
+}
+
+// FromCloudflare returns c with records fetched from Cloudflare, like a JSON
+// dump from the API, mapped to records as parsed from a zone file. Records
+// are made canonical, priorities are mapped by WithPriority, and TXT content
+// made of quoted strings is unquoted, joined and unescaped like the parser
+// does.
+func (c RecordCollection) FromCloudflare() RecordCollection {
+	result := make(RecordCollection, 0, len(c))
+	for _, r := range c {
+		if r.Type == "TXT" && quotedStrings(r.Content) {
+			r.Content = unquoteTXT(r.Content)
+		}
+
+		result = append(result, WithPriority(canonical(r)))
+	}
+
+	return result
+}
+
+// quotedStrings returns true if s is one or more quoted strings separated by
+// whitespace, like Cloudflare may return TXT content, which includes
+// emptyTXT. Content like `say "hi"` is text with quotes, and is not.
+func quotedStrings(s string) bool {
+	found := false
+
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+
+		if s[i] != '"' {
+			return false
+		}
+
+		// Find the closing quote, skipping escaped characters.
+		i++
+		for i < len(s) && s[i] != '"' {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+
+		if i >= len(s) {
+			return false
+		}
+
+		i++
+		if i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			return false
+		}
+
+		found = true
+	}
+
+	return found
+}
+
+// listPages will fetch the first page using list to learn the number of
+// pages, and then fetch the rest using up to workers concurrent calls.
+// Records are returned in page order.
+func listPages(list func(page int) (RecordCollection, int, error), workers int) (RecordCollection, error) {
+	first, total, err := list(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// An empty zone has no pages at all.
+	if total < 1 {
+		total = 1
+	}
+
+	pages := make([]RecordCollection, total+1)
+	pages[1] = first
+
+	var lock sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+
+	queue := make(chan int)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer PanicHandler()
+
+			for page := range queue {
+				records, _, err := list(page)
+
+				lock.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				pages[page] = records
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for page := 2; page <= total; page++ {
+		lock.Lock()
+		failed := firstErr != nil
+		lock.Unlock()
+
+		if failed {
+			break
+		}
+
+		queue <- page
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	result := RecordCollection{}
+	for _, records := range pages {
+		result = append(result, records...)
+	}
+
+	return result, nil
+}
+
+// Create implements Provider. The priority is mapped by WithPriority, as
+// Cloudflare rejects records with a priority they can't have.
+func (c *Cloudflare) Create(zoneName string, r cloudflare.DNSRecord) error {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return err
+	}
+
+	data, err := cloudflareData(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	_, err = c.api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(id), cloudflare.CreateDNSRecordParams{
+		Type:     r.Type,
+		Name:     r.Name,
+		Content:  cloudflareContent(r),
+		Data:     data,
+		TTL:      r.TTL,
+		Priority: WithPriority(r).Priority,
+		Proxied:  r.Proxied,
+		Comment:  r.Comment,
+		Tags:     r.Tags,
+	})
+
+	return err
+}
+
+// Update implements Provider. Priorities are mapped by WithPriority, like
+// for Create.
+func (c *Cloudflare) Update(zoneName string, r cloudflare.DNSRecord) error {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return err
+	}
+
+	params, err := c.updateParams(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	_, err = c.api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(id), params)
+
+	return err
+}
+
+// updateParams returns the parameters updating a record to r. Proxied is
+// always given, as a missing value leaves a proxied record proxied. The
+// comment is always given too, clearing removed comments, unless
+// KeepComments is set.
+func (c *Cloudflare) updateParams(r cloudflare.DNSRecord) (cloudflare.UpdateDNSRecordParams, error) {
+	data, err := cloudflareData(r)
+	if err != nil {
+		return cloudflare.UpdateDNSRecordParams{}, err
+	}
+
+	params := cloudflare.UpdateDNSRecordParams{
+		ID:       r.ID,
+		Type:     r.Type,
+		Name:     r.Name,
+		Content:  cloudflareContent(r),
+		Data:     data,
+		TTL:      r.TTL,
+		Priority: WithPriority(r).Priority,
+		Proxied:  cloudflare.BoolPtr(cloudflare.Bool(r.Proxied)),
+		Tags:     r.Tags,
+	}
+
+	if !c.KeepComments {
+		comment := r.Comment
+		params.Comment = &comment
+	}
+
+	return params, nil
+}
+
+// Delete implements Provider.
+func (c *Cloudflare) Delete(zoneName string, r cloudflare.DNSRecord) error {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	return c.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(id), r.ID)
+}
+
+// batchID identifies a record in a batch request.
+type batchID struct {
+	ID string `json:"id"`
+}
+
+// DeleteBatch implements BatchDeleter using the batch endpoint of the
+// Cloudflare API.
+func (c *Cloudflare) DeleteBatch(zoneName string, records RecordCollection) error {
+	id, err := c.ZoneID(zoneName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	deletes := []batchID{}
+	for _, r := range records {
+		deletes = append(deletes, batchID{r.ID})
+	}
+
+	_, err = c.api.Raw(ctx, "POST", "/zones/"+id+"/dns_records/batch", map[string]interface{}{"deletes": deletes}, nil)
+
+	return err
+}
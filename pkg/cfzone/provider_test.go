@@ -0,0 +1,412 @@
+package cfzone
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// memoryProvider is a Provider keeping records in memory.
+type memoryProvider struct {
+	records RecordCollection
+	nextID  int
+	failOn  string
+	log     []string
+}
+
+func (m *memoryProvider) List(zoneName string) (RecordCollection, error) {
+	return m.records.Clone(), nil
+}
+
+func (m *memoryProvider) Create(zoneName string, r cloudflare.DNSRecord) error {
+	m.log = append(m.log, "create "+r.Name)
+	if m.failOn == r.Name {
+		return errors.New("failed")
+	}
+
+	m.nextID++
+	r.ID = strconv.Itoa(m.nextID)
+	m.records = append(m.records, r)
+
+	return nil
+}
+
+func (m *memoryProvider) Update(zoneName string, r cloudflare.DNSRecord) error {
+	m.log = append(m.log, "update "+r.Name)
+
+	for i := range m.records {
+		if m.records[i].ID == r.ID {
+			m.records[i] = r
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
+func (m *memoryProvider) Delete(zoneName string, r cloudflare.DNSRecord) error {
+	m.log = append(m.log, "delete "+r.Name)
+
+	for i := range m.records {
+		if m.records[i].ID == r.ID {
+			m.records.Remove(i)
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
+func TestApply(t *testing.T) {
+	m := &memoryProvider{
+		records: RecordCollection{
+			{ID: "a", Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{ID: "b", Type: "TXT", Name: "example.com", Content: "old"},
+		},
+		nextID: 100,
+	}
+
+	wanted := RecordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.2"},
+		{Type: "A", Name: "web.example.com", Content: "192.0.2.3"},
+	}
+
+	existing, _ := m.List("example.com")
+	changes := Diff(wanted, existing)
+
+	done := []string{}
+	err := changes.Apply(m, "example.com", func(operation string, r cloudflare.DNSRecord, err error) error {
+		done = append(done, operation+" "+r.Name)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Apply() failed: %s", err.Error())
+	}
+
+	expected := []string{"delete example.com", "create web.example.com", "update www.example.com"}
+	if !reflect.DeepEqual(m.log, expected) {
+		t.Errorf("Apply() made wrong calls: %v, expected %v", m.log, expected)
+	}
+
+	if !reflect.DeepEqual(done, []string{"delete example.com", "add web.example.com", "update www.example.com"}) {
+		t.Errorf("Apply() reported wrong changes: %v", done)
+	}
+
+	existing, _ = m.List("example.com")
+	if changes := Diff(wanted, existing); len(changes.Adds)+len(changes.Deletes)+len(changes.Updates) != 0 {
+		t.Errorf("Apply() didn't make the zone match: %+v", changes)
+	}
+}
+
+func TestApplyFailure(t *testing.T) {
+	m := &memoryProvider{failOn: "a.example.com"}
+
+	changes := Changes{Adds: RecordCollection{{Name: "a.example.com"}, {Name: "b.example.com"}}}
+
+	err := changes.Apply(m, "example.com", nil)
+	if err == nil {
+		t.Fatalf("Apply() didn't fail")
+	}
+
+	if !reflect.DeepEqual(m.log, []string{"create a.example.com"}) {
+		t.Errorf("Apply() continued after failure: %v", m.log)
+	}
+}
+
+// concurrentProvider is a Provider tracking concurrent calls and the order of
+// calls for each name.
+type concurrentProvider struct {
+	lock     sync.Mutex
+	running  int
+	max      int
+	byName   map[string][]string
+	failOn   string
+	attempts int
+}
+
+func (c *concurrentProvider) call(operation string, r cloudflare.DNSRecord) error {
+	c.lock.Lock()
+	c.running++
+	c.attempts++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.byName[r.Name] = append(c.byName[r.Name], operation)
+	c.lock.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.lock.Lock()
+	c.running--
+	c.lock.Unlock()
+
+	if r.Name == c.failOn {
+		return errors.New("failed")
+	}
+
+	return nil
+}
+
+func (c *concurrentProvider) List(zoneName string) (RecordCollection, error) {
+	return nil, nil
+}
+
+func (c *concurrentProvider) Create(zoneName string, r cloudflare.DNSRecord) error {
+	return c.call("create", r)
+}
+
+func (c *concurrentProvider) Update(zoneName string, r cloudflare.DNSRecord) error {
+	return c.call("update", r)
+}
+
+func (c *concurrentProvider) Delete(zoneName string, r cloudflare.DNSRecord) error {
+	return c.call("delete", r)
+}
+
+func TestApplyConcurrently(t *testing.T) {
+	changes := Changes{}
+	for i := 0; i < 20; i++ {
+		name := "host" + strconv.Itoa(i) + ".example.com"
+		changes.Deletes = append(changes.Deletes, cloudflare.DNSRecord{Type: "CNAME", Name: name})
+		changes.Adds = append(changes.Adds, cloudflare.DNSRecord{Type: "A", Name: name})
+		changes.Updates = append(changes.Updates, cloudflare.DNSRecord{Type: "TXT", Name: name})
+	}
+
+	c := &concurrentProvider{byName: map[string][]string{}}
+
+	calls := 0
+	err := changes.ApplyConcurrently(c, "example.com", 4, func(operation string, r cloudflare.DNSRecord, err error) error {
+		// done must never be called concurrently, the race detector
+		// will catch it.
+		calls++
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ApplyConcurrently() failed: %s", err.Error())
+	}
+
+	if calls != 60 {
+		t.Errorf("ApplyConcurrently() reported %d changes, expected 60", calls)
+	}
+
+	if c.max < 2 || c.max > 4 {
+		t.Errorf("ApplyConcurrently() made %d concurrent calls, expected 2 to 4", c.max)
+	}
+
+	for name, operations := range c.byName {
+		if !reflect.DeepEqual(operations, []string{"delete", "create", "update"}) {
+			t.Errorf("ApplyConcurrently() applied changes to %s in wrong order: %v", name, operations)
+		}
+	}
+}
+
+func TestApplyConcurrentlyFailure(t *testing.T) {
+	changes := Changes{}
+	for i := 0; i < 100; i++ {
+		changes.Adds = append(changes.Adds, cloudflare.DNSRecord{Type: "A", Name: "host" + strconv.Itoa(i) + ".example.com"})
+	}
+
+	c := &concurrentProvider{byName: map[string][]string{}, failOn: "host0.example.com"}
+
+	err := changes.ApplyConcurrently(c, "example.com", 4, nil)
+	if err == nil {
+		t.Fatalf("ApplyConcurrently() didn't fail")
+	}
+
+	if c.attempts >= 100 {
+		t.Errorf("ApplyConcurrently() continued after failure, made %d calls", c.attempts)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	list := func(total int, fail int) func(page int) (RecordCollection, int, error) {
+		return func(page int) (RecordCollection, int, error) {
+			if page == fail {
+				return nil, total, errors.New("failed")
+			}
+
+			// Later pages are faster, to make sure order is kept.
+			time.Sleep(time.Duration(total-page) * time.Millisecond)
+
+			return RecordCollection{{ID: strconv.Itoa(page)}}, total, nil
+		}
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		records, err := listPages(list(10, -1), workers)
+		if err != nil {
+			t.Fatalf("listPages() failed: %s", err.Error())
+		}
+
+		ids := []string{}
+		for _, r := range records {
+			ids = append(ids, r.ID)
+		}
+
+		expected := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("listPages() with %d workers returned %v", workers, ids)
+		}
+
+		_, err = listPages(list(10, 5), workers)
+		if err == nil {
+			t.Errorf("listPages() with %d workers didn't fail", workers)
+		}
+	}
+
+	records, err := listPages(func(page int) (RecordCollection, int, error) { return nil, 0, nil }, 4)
+	if err != nil || len(records) != 0 {
+		t.Errorf("listPages() returned %v, %v for an empty zone", records, err)
+	}
+}
+
+// batchProvider is a memoryProvider able to delete in batches.
+type batchProvider struct {
+	memoryProvider
+	batches [][]string
+}
+
+func (b *batchProvider) DeleteBatch(zoneName string, c RecordCollection) error {
+	ids := []string{}
+	for _, r := range c {
+		ids = append(ids, r.ID)
+	}
+
+	b.batches = append(b.batches, ids)
+
+	return nil
+}
+
+func TestApplyDeleteBatches(t *testing.T) {
+	DeleteBatchSize = 2
+	defer func() { DeleteBatchSize = 200 }()
+
+	changes := Changes{
+		Deletes: RecordCollection{
+			{ID: "1", Type: "A", Name: "a.example.com"},
+			{ID: "2", Type: "CNAME", Name: "www.example.com"},
+			{ID: "3", Type: "A", Name: "b.example.com"},
+			{ID: "4", Type: "A", Name: "c.example.com"},
+		},
+		Adds: RecordCollection{{Type: "A", Name: "WWW.example.com"}},
+	}
+
+	for _, workers := range []int{1, 4} {
+		b := &batchProvider{memoryProvider: memoryProvider{records: changes.Deletes.Clone()}}
+
+		deleted := 0
+		err := changes.ApplyConcurrently(b, "example.com", workers, func(operation string, r cloudflare.DNSRecord, err error) error {
+			if operation == "delete" {
+				deleted++
+			}
+
+			return err
+		})
+		if err != nil {
+			t.Fatalf("ApplyConcurrently() failed: %s", err.Error())
+		}
+
+		// The CNAME must be deleted before the A record is added, and
+		// isn't batched.
+		if !reflect.DeepEqual(b.batches, [][]string{{"1", "3"}, {"4"}}) {
+			t.Errorf("%d workers: deleted wrong batches %v", workers, b.batches)
+		}
+
+		if !reflect.DeepEqual(b.log, []string{"delete www.example.com", "create WWW.example.com"}) {
+			t.Errorf("%d workers: made wrong calls %v", workers, b.log)
+		}
+
+		if deleted != 4 {
+			t.Errorf("%d workers: reported %d deletes, expected 4", workers, deleted)
+		}
+	}
+}
+
+func TestCloudflareUpdateParams(t *testing.T) {
+	c := NewCloudflare(nil)
+
+	params, _ := c.updateParams(cloudflare.DNSRecord{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1"})
+	if params.Proxied == nil || *params.Proxied {
+		t.Errorf("updateParams() didn't unproxy the record: %+v", params)
+	}
+
+	if params.Comment == nil || *params.Comment != "" {
+		t.Errorf("updateParams() didn't clear the comment: %+v", params)
+	}
+
+	c.KeepComments = true
+
+	params, _ = c.updateParams(cloudflare.DNSRecord{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1", Proxied: cloudflare.BoolPtr(true), Comment: "web"})
+	if params.Proxied == nil || !*params.Proxied {
+		t.Errorf("updateParams() didn't proxy the record: %+v", params)
+	}
+
+	if params.Comment != nil {
+		t.Errorf("updateParams() changed the comment with KeepComments: %+v", params)
+	}
+}
+
+func TestCloudflareDSData(t *testing.T) {
+	c := NewCloudflare(nil)
+
+	ds := cloudflare.DNSRecord{ID: "1", Type: "DS", Name: "sub.example.com", Content: "2371 13 2 1F987CC6583E92DF0890718C42"}
+
+	params, err := c.updateParams(ds)
+	if err != nil {
+		t.Fatalf("updateParams() failed: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"key_tag":     uint64(2371),
+		"algorithm":   uint64(13),
+		"digest_type": uint64(2),
+		"digest":      "1F987CC6583E92DF0890718C42",
+	}
+
+	if !reflect.DeepEqual(params.Data, expected) {
+		t.Errorf("updateParams() returned data %+v, expected %+v", params.Data, expected)
+	}
+
+	params, _ = c.updateParams(cloudflare.DNSRecord{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.1"})
+	if params.Data != nil {
+		t.Errorf("updateParams() returned data for an A record: %+v", params.Data)
+	}
+
+	for _, content := range []string{"2371 13 2", "70000 13 2 1F98", "2371 x 2 1F98"} {
+		_, err = cloudflareData(cloudflare.DNSRecord{Type: "DS", Name: "sub.example.com", Content: content})
+		if err == nil {
+			t.Errorf("cloudflareData() accepted '%s'", content)
+		}
+	}
+}
+
+func TestFromCloudflareTXT(t *testing.T) {
+	cases := map[string]string{
+		`"v=spf1 -all"`:     "v=spf1 -all",
+		`""`:                "",
+		`"a" "b"`:           "ab",
+		`"a \"b\""`:         `a "b"`,
+		`"a\"`:              `"a\"`,
+		`"a\\"`:             `a\`,
+		`"\065\059 b"`:      "A; b",
+		`"`:                 `"`,
+		`say "hi"`:          `say "hi"`,
+		`"quoted" at start`: `"quoted" at start`,
+		`"a""b"`:            `"a""b"`,
+
+		// Long DKIM keys are split in strings of 255 bytes.
+		`"v=DKIM1; k=rsa; " "p=MIGfMA0GCSqGSIb3"`: "v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3",
+	}
+
+	for in, expected := range cases {
+		got := RecordCollection{{Type: "TXT", Content: in}}.FromCloudflare()[0].Content
+		if got != expected {
+			t.Errorf("FromCloudflare() returned %q for %q, expected %q", got, in, expected)
+		}
+	}
+}
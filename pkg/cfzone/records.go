@@ -0,0 +1,426 @@
+package cfzone
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+type (
+	// RecordCollection is a list of DNS records.
+	RecordCollection []cloudflare.DNSRecord
+
+	// FilterFunc is used for finding records in a RecordCollection. The
+	// function must return true if there is a hit, false otherwise.
+	FilterFunc func(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool
+)
+
+// Clone will make a copy of a RecordCollection.
+func (c RecordCollection) Clone() RecordCollection {
+	result := RecordCollection{}
+
+	result = append(result, c...)
+
+	return result
+}
+
+// Remove will remove the n'th element of c.
+func (c *RecordCollection) Remove(n int) {
+	*c = append((*c)[:n], (*c)[n+1:]...)
+}
+
+// Find will search for needle in a RecordCollection.
+func (c RecordCollection) Find(needle cloudflare.DNSRecord, match FilterFunc) (int, *cloudflare.DNSRecord) {
+	for i, r := range c {
+		if match(r, needle) {
+			return i, &r
+		}
+	}
+
+	return -1, nil
+}
+
+// Difference will find all the elements in c not present in remote [c \ remote].
+func (c RecordCollection) Difference(remote RecordCollection, match FilterFunc) RecordCollection {
+	result := RecordCollection{}
+	B := remote.Clone()
+
+	for _, r := range c {
+		n, _ := B.Find(r, match)
+
+		if n < 0 {
+			result = append(result, r)
+		} else {
+			B.Remove(n)
+		}
+	}
+
+	return result
+}
+
+// Intersect will find the intersection between c and remote [c ∩ remote] with
+// the caveat that the ID from c will be used in the result - while all other
+// properties will be copied from remote.
+// If multiple record from a collection matches, only one will be present in
+// the returned collection.
+func (c RecordCollection) Intersect(remote RecordCollection, match FilterFunc) RecordCollection {
+	// Clone the inputs - we do this to be able to remove from these
+	// collections when a match is found.
+	A := c.Clone()
+	B := remote.Clone()
+	intersect := RecordCollection{}
+
+	for i := 0; i < len(A); i++ {
+		found, hit := B.Find(A[i], match)
+		if found >= 0 {
+			// We do this trickery to keep the ID from the left part.
+			record := *hit
+			record.ID = A[i].ID
+
+			intersect = append(intersect, record)
+
+			// To make sure we're not double-spending we remove the found
+			// record from both inputs.
+			A.Remove(i)
+			B.Remove(found)
+
+			// Rewind the index to compensate for the item we just removed.
+			i--
+		}
+	}
+
+	return intersect
+}
+
+// Dedupe will return c without duplicates, and the duplicates removed.
+// Records differing only in TTL are duplicates too, the first one is kept.
+// Cloudflare refuses to create identical records, whatever their TTL.
+func (c RecordCollection) Dedupe() (RecordCollection, RecordCollection) {
+	result := RecordCollection{}
+	duplicates := RecordCollection{}
+	seen := map[string]bool{}
+
+	for _, r := range c {
+		key := sameExceptTTLKey(r)
+		if key == "" || !seen[key] {
+			seen[key] = true
+			result = append(result, r)
+		} else {
+			duplicates = append(duplicates, r)
+		}
+	}
+
+	return result, duplicates
+}
+
+// Sort will sort c in place by name, type and content. Remaining ties are
+// broken by priority, TTL and proxy status, making the order deterministic.
+func (c RecordCollection) Sort() {
+	sort.SliceStable(c, func(i, j int) bool {
+		a, b := c[i], c[j]
+
+		switch {
+		case !strings.EqualFold(a.Name, b.Name):
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+
+		case a.Type != b.Type:
+			return a.Type < b.Type
+
+		case a.Content != b.Content:
+			return a.Content < b.Content
+
+		case cloudflare.Uint16(a.Priority) != cloudflare.Uint16(b.Priority):
+			return cloudflare.Uint16(a.Priority) < cloudflare.Uint16(b.Priority)
+
+		case a.TTL != b.TTL:
+			return a.TTL < b.TTL
+		}
+
+		return !cloudflare.Bool(a.Proxied) && cloudflare.Bool(b.Proxied)
+	})
+}
+
+// Layout controls how FprintZone arranges records.
+type Layout int
+
+const (
+	// LayoutSorted outputs all records sorted by name.
+	LayoutSorted Layout = iota
+
+	// LayoutByType outputs a section for each record type, with the
+	// records of each section sorted by name.
+	LayoutByType
+)
+
+// typeOrder is the order of sections in LayoutByType. Other types follow in
+// alphabetical order.
+var typeOrder = []string{"A", "AAAA", "CNAME", "MX", "TXT"}
+
+// Fprint will output a textual representation of a RecordCollection resembling
+// the BIND zone file format. Records are sorted, c is left untouched.
+func (c RecordCollection) Fprint(w io.Writer) {
+	c.fprint(w, LayoutSorted, func(name string) string { return name + "." }, func(target string) string { return target })
+}
+
+// FprintZone will output c as a zone file for origin, starting with an
+// $ORIGIN line. Names are relative to origin, and targets are fully
+// qualified, so the output can be loaded by BIND once SOA and NS records are
+// added. Records are arranged according to layout, c is left untouched.
+func (c RecordCollection) FprintZone(w io.Writer, origin string, layout Layout) {
+	origin = strings.Trim(origin, ".")
+
+	fmt.Fprintf(w, "$ORIGIN %s.\n", origin)
+
+	c.fprintZone(w, origin, layout)
+}
+
+// FprintZoneFile will output c like FprintZone, preceded by a synthetic SOA
+// record with serial and apex NS records for nameServers, so the output can
+// be loaded by BIND as is. Apex NS records in c are left out, nameServers
+// replace them.
+func (c RecordCollection) FprintZoneFile(w io.Writer, origin string, nameServers []string, serial uint32, layout Layout) error {
+	origin = strings.Trim(origin, ".")
+
+	if len(nameServers) == 0 {
+		return fmt.Errorf("No name servers for '%s'", origin)
+	}
+
+	fmt.Fprintf(w, "$ORIGIN %s.\n", origin)
+	fmt.Fprintf(w, "@ 3600 IN SOA %s. hostmaster.%s. %d 10000 2400 604800 3600\n", strings.Trim(nameServers[0], "."), origin, serial)
+
+	for _, ns := range nameServers {
+		fmt.Fprintf(w, "@ 3600 IN NS %s.\n", strings.Trim(ns, "."))
+	}
+
+	records := make(RecordCollection, 0, len(c))
+	for _, r := range c {
+		if r.Type == "NS" && strings.EqualFold(strings.Trim(r.Name, "."), origin) {
+			continue
+		}
+
+		records = append(records, r)
+	}
+
+	records.fprintZone(w, origin, layout)
+
+	return nil
+}
+
+// fprintZone will output c with names relative to origin and fully qualified
+// targets.
+func (c RecordCollection) fprintZone(w io.Writer, origin string, layout Layout) {
+	suffix := "." + strings.ToLower(origin)
+
+	c.fprint(w, layout, func(name string) string {
+		switch {
+		case strings.EqualFold(name, origin):
+			return "@"
+
+		case strings.HasSuffix(strings.ToLower(name), suffix):
+			return name[:len(name)-len(suffix)]
+		}
+
+		return name + "."
+	}, func(target string) string {
+		if strings.HasSuffix(target, ".") {
+			return target
+		}
+
+		return target + "."
+	})
+}
+
+// fprint will output c with aligned columns arranged according to layout.
+// name returns the name to output for a record name, and target the output
+// for the target of records with one.
+func (c RecordCollection) fprint(w io.Writer, layout Layout, name func(string) string, target func(string) string) {
+	c = c.Clone()
+	c.Sort()
+
+	if layout == LayoutByType {
+		c.sortByType()
+	}
+
+	names := make([]string, len(c))
+	ttls := make([]string, len(c))
+	maxName := 0
+	maxTTL := 0
+
+	for i, r := range c {
+		names[i] = name(r.Name)
+		ttls[i] = strconv.Itoa(r.TTL)
+
+		if len(names[i]) > maxName {
+			maxName = len(names[i])
+		}
+
+		if len(ttls[i]) > maxTTL {
+			maxTTL = len(ttls[i])
+		}
+	}
+
+	for i, r := range c {
+		if layout == LayoutByType && (i == 0 || c[i-1].Type != r.Type) {
+			if i > 0 {
+				fmt.Fprintf(w, "\n")
+			}
+
+			fmt.Fprintf(w, "; %s records\n", r.Type)
+		}
+
+		comment := ""
+		if cloudflare.Bool(r.Proxied) {
+			comment = " ; PROXIED"
+		}
+
+		if r.Comment != "" {
+			comment += " ; " + r.Comment
+		}
+
+		content := r.Content
+		switch {
+		case HasTarget(r.Type):
+			// The target is the last field, SRV content is "weight port
+			// target".
+			fields := strings.Fields(CanonicalContent(r))
+			if len(fields) > 0 {
+				fields[len(fields)-1] = target(fields[len(fields)-1])
+			}
+
+			content = strings.Join(fields, " ")
+
+		case r.Type == "TXT":
+			content = quoteTXT(r.Content)
+		}
+
+		if HasPriority(r.Type) {
+			content = fmt.Sprintf("%d %s", cloudflare.Uint16(r.Priority), content)
+		}
+
+		fmt.Fprintf(w, "%-*s %-*s %-8s %s%s\n", maxName, names[i], maxTTL, ttls[i], "IN "+r.Type, content, comment)
+	}
+}
+
+// sortByType will stable sort c by type, in the order of typeOrder.
+func (c RecordCollection) sortByType() {
+	rank := func(t string) string {
+		for i, o := range typeOrder {
+			if t == o {
+				return fmt.Sprintf("%02d", i)
+			}
+		}
+
+		return "99" + t
+	}
+
+	sort.SliceStable(c, func(i, j int) bool {
+		return rank(c[i].Type) < rank(c[j].Type)
+	})
+}
+
+// FullMatch will do matching between two DNS records while ignoring CF specific
+// details.
+func FullMatch(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+	if a.Type != b.Type {
+		return false
+	}
+
+	if a.Name != b.Name {
+		return false
+	}
+
+	if cloudflare.Bool(a.Proxied) != cloudflare.Bool(b.Proxied) {
+		return false
+	}
+
+	if a.TTL != b.TTL {
+		return false
+	}
+
+	switch a.Type {
+	case "A", "AAAA", "CNAME", "TXT", "NS", "DS":
+		if CanonicalContent(a) == CanonicalContent(b) {
+			return true
+		}
+
+	case "MX":
+		if CanonicalContent(a) == CanonicalContent(b) && cloudflare.Uint16(a.Priority) == cloudflare.Uint16(b.Priority) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SameExceptTTL will return true if a and b would match using FullMatch if
+// they had the same TTL. Such records only need their TTL updated.
+func SameExceptTTL(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+	a.TTL = b.TTL
+
+	return FullMatch(a, b)
+}
+
+// Updatable will return true if it makes sense to update (instead of
+// add/delete) from a to b or b to a.
+func Updatable(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+	if a.Type != b.Type {
+		return false
+	}
+
+	if a.Name != b.Name {
+		return false
+	}
+
+	return true
+}
+
+// Ignored returns true if name matches any of patterns.
+func Ignored(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		match, _ := path.Match(pattern, name)
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithoutIgnored returns a new collection without the records matching any of
+// patterns.
+func (c RecordCollection) WithoutIgnored(patterns []string) RecordCollection {
+	result := RecordCollection{}
+
+	for _, r := range c {
+		if !Ignored(r.Name, patterns) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// WithAutoTTL returns a new collection where records with a TTL of ttl get
+// automatic TTL, like records with a TTL of 0. This is for zone files using
+// another value for automatic TTL, like zones migrated from other tools. A
+// ttl of 0 or 1 changes nothing, as 1 means proxied.
+func (c RecordCollection) WithAutoTTL(ttl int) RecordCollection {
+	result := c.Clone()
+
+	if ttl <= 1 {
+		return result
+	}
+
+	for i := range result {
+		if result[i].TTL == ttl {
+			result[i].TTL = 0
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,448 @@
+package cfzone
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestClone(t *testing.T) {
+	a := RecordCollection{}
+	b := a.Clone()
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Clone() failed to clone an empty RecordCollection")
+	}
+
+	a = RecordCollection{
+		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"},
+		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"},
+		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.10"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.11"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.13"},
+		cloudflare.DNSRecord{Type: "AAAA", Name: "a1", Content: "::1"},
+		cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: cloudflare.Uint16Ptr(10)},
+	}
+	b = a.Clone()
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Clone() failed to clone a RecordCollection")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	in := RecordCollection{
+		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
+		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2", TTL: 200},
+		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+	}
+
+	a := in.Clone()
+	a.Remove(1)
+	if !reflect.DeepEqual(a, RecordCollection{
+		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
+		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+	}) {
+		t.Errorf("Remove() did not return expected result")
+	}
+
+	a2 := in.Clone()
+	a2.Remove(1)
+	if !reflect.DeepEqual(a2, RecordCollection{
+		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
+		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+	}) {
+		t.Errorf("Remove() did not return expected result")
+	}
+}
+
+func TestFindEmpty(t *testing.T) {
+	c := RecordCollection{}
+
+	n, r := c.Find(cloudflare.DNSRecord{}, FullMatch)
+	if n >= 0 {
+		t.Errorf("Find() returned a non-negative value from an empty collection")
+	}
+
+	if r != nil {
+		t.Errorf("Find() returned a record from an empty collection")
+	}
+}
+
+func TestFullMatch(t *testing.T) {
+	cases := []struct {
+		a        cloudflare.DNSRecord
+		b        cloudflare.DNSRecord
+		expected bool
+	}{
+		{cloudflare.DNSRecord{Type: "A"}, cloudflare.DNSRecord{Type: "A"}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "ab"}, false},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 1}, false},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: cloudflare.BoolPtr(true)}, cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: cloudflare.BoolPtr(true)}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: cloudflare.BoolPtr(true)}, cloudflare.DNSRecord{Type: "A", Name: "a"}, false},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, false},
+		{cloudflare.DNSRecord{Type: "CNAME", Name: "a", Content: "Mail.Example.COM."}, cloudflare.DNSRecord{Type: "CNAME", Name: "a", Content: "mail.example.com"}, true},
+		{cloudflare.DNSRecord{Type: "MX", Name: "a", Content: "Mail.Example.COM."}, cloudflare.DNSRecord{Type: "MX", Name: "a", Content: "mail.example.com"}, true},
+		{cloudflare.DNSRecord{Type: "TXT", Name: "a", Content: "Hello"}, cloudflare.DNSRecord{Type: "TXT", Name: "a", Content: "hello"}, false},
+		{cloudflare.DNSRecord{Type: "NS", Name: "sub", Content: "NS1.example.net."}, cloudflare.DNSRecord{Type: "NS", Name: "sub", Content: "ns1.example.net"}, true},
+		{cloudflare.DNSRecord{Type: "DS", Name: "sub", Content: "2371 13 2 1F98"}, cloudflare.DNSRecord{Type: "DS", Name: "sub", Content: "2371 13 2 1F98"}, true},
+	}
+
+	for i, in := range cases {
+		result := FullMatch(in.a, in.b)
+
+		if result != in.expected {
+			t.Errorf("%d: match() Returned unexpected result for %v, %v: %v (expected %v)", i, in.a, in.b, result, in.expected)
+		}
+	}
+}
+
+func TestUpdatable(t *testing.T) {
+	cases := []struct {
+		a        cloudflare.DNSRecord
+		b        cloudflare.DNSRecord
+		expected bool
+	}{
+		{cloudflare.DNSRecord{Type: "A"}, cloudflare.DNSRecord{Type: "A"}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "ab"}, false},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 1}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: cloudflare.BoolPtr(true)}, cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: cloudflare.BoolPtr(true)}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: cloudflare.BoolPtr(true)}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
+		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, true},
+		{cloudflare.DNSRecord{Type: "CNAME", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, false},
+	}
+
+	for i, in := range cases {
+		result := Updatable(in.a, in.b)
+
+		if result != in.expected {
+			t.Errorf("%d: match() Returned unexpected result for %v, %v: %v (expected %v)", i, in.a, in.b, result, in.expected)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	c := RecordCollection{
+		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"},
+		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"},
+		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.10"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.11"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"},
+		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.13"},
+		cloudflare.DNSRecord{Type: "AAAA", Name: "a1", Content: "::1"},
+		cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: cloudflare.Uint16Ptr(10)},
+	}
+
+	cases := []struct {
+		needle cloudflare.DNSRecord
+		n      int
+		r      *cloudflare.DNSRecord
+	}{
+		{cloudflare.DNSRecord{Type: "A", Name: "a1"}, -1, nil},
+		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.2"}, -1, nil},
+		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &cloudflare.DNSRecord{}},
+		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &cloudflare.DNSRecord{}},
+		{cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"}, 5, &cloudflare.DNSRecord{}},
+		{cloudflare.DNSRecord{Type: "MX", Name: "a1", Content: "127.0.0.12"}, -1, nil},
+		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "127.0.0.12"}, -1, nil},
+		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "::1"}, -1, nil},
+		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: cloudflare.Uint16Ptr(10)}, 8, &cloudflare.DNSRecord{}},
+	}
+
+	for i, in := range cases {
+		n, r := c.Find(in.needle, FullMatch)
+		if n != in.n {
+			t.Errorf("%d: Find() Returned unexpected n: %d (expected %d)", i, n, in.n)
+		}
+
+		if (r == nil) != (in.r == nil) {
+			t.Errorf("%d: Find() returned unexpected pointer: %p (expected %p)", i, r, in.r)
+		}
+	}
+}
+
+func TestDifference(t *testing.T) {
+	empty := RecordCollection{}
+	a1 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.1"}
+	a2 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.2"}
+	aaaa1 := cloudflare.DNSRecord{Type: "AAAA", Name: "test1", Content: "::1"}
+	cases := []struct {
+		a        RecordCollection
+		b        RecordCollection
+		expected RecordCollection
+	}{
+		{empty, empty, empty},
+		{empty, RecordCollection{a1}, empty},
+		{RecordCollection{a1}, empty, RecordCollection{a1}},
+		{empty, RecordCollection{aaaa1}, empty},
+		{RecordCollection{aaaa1}, empty, RecordCollection{aaaa1}},
+		{RecordCollection{aaaa1}, RecordCollection{a1}, RecordCollection{aaaa1}},
+		{RecordCollection{a1, a2}, RecordCollection{a1}, RecordCollection{a2}},
+		{RecordCollection{a1, a2, a2}, RecordCollection{a1}, RecordCollection{a2, a2}},
+	}
+
+	for i, in := range cases {
+		result := in.a.Difference(in.b, FullMatch)
+		if !reflect.DeepEqual(in.expected, result) {
+			t.Errorf("%d: aOnly != in.aOnly, Got %+v, expcted %+v", i, result, in.expected)
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	empty := RecordCollection{}
+	a1 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.1"}
+	aaaa1 := cloudflare.DNSRecord{Type: "AAAA", Name: "test1", Content: "::1"}
+	cases := []struct {
+		a        RecordCollection
+		b        RecordCollection
+		expected RecordCollection
+	}{
+		{empty, empty, empty},
+		{empty, RecordCollection{a1}, empty},
+		{RecordCollection{a1}, empty, empty},
+		{RecordCollection{a1}, RecordCollection{a1}, RecordCollection{a1}},
+		{empty, RecordCollection{aaaa1}, empty},
+	}
+
+	for i, in := range cases {
+		result := in.a.Intersect(in.b, FullMatch)
+		if !reflect.DeepEqual(in.expected, result) {
+			t.Errorf("%d: aOnly != in.aOnly, Got %+v, expcted %+v", i, result, in.expected)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	c := RecordCollection{
+		cloudflare.DNSRecord{Name: "a1", TTL: 0, Type: "A", Content: "127.0.0.1"},
+		cloudflare.DNSRecord{Name: "a2", TTL: 1, Type: "A", Content: "127.0.0.2", Proxied: cloudflare.BoolPtr(true)},
+		cloudflare.DNSRecord{Name: "aaaa1", TTL: 0, Type: "AAAA", Content: "::1"},
+		cloudflare.DNSRecord{Name: "mx1", TTL: 0, Type: "MX", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10)},
+		cloudflare.DNSRecord{Name: "txt1", TTL: 0, Type: "TXT", Content: `with "quotes"`},
+	}
+	expected := `a1.    0 IN A     127.0.0.1
+a2.    1 IN A     127.0.0.2 ; PROXIED
+aaaa1. 0 IN AAAA  ::1
+mx1.   0 IN MX    10 mail.example.com
+txt1.  0 IN TXT   "with \"quotes\""
+`
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+
+	c.Fprint(w)
+	w.Flush()
+
+	if b.String() != expected {
+		t.Fatalf("Print() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestWithoutIgnored(t *testing.T) {
+	a1 := cloudflare.DNSRecord{Type: "A", Name: "a1.example.com", Content: "127.0.0.1"}
+	a2 := cloudflare.DNSRecord{Type: "A", Name: "a2.k8s.example.com", Content: "127.0.0.2"}
+	txt := cloudflare.DNSRecord{Type: "TXT", Name: "_acme-challenge.example.com", Content: "token"}
+	in := RecordCollection{a1, a2, txt}
+
+	cases := []struct {
+		patterns []string
+		expected RecordCollection
+	}{
+		{nil, RecordCollection{a1, a2, txt}},
+		{[]string{"*.k8s.example.com"}, RecordCollection{a1, txt}},
+		{[]string{"*.k8s.example.com", "_acme-challenge.*"}, RecordCollection{a1}},
+		{[]string{"*"}, RecordCollection{}},
+	}
+
+	for i, c := range cases {
+		result := in.WithoutIgnored(c.patterns)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: WithoutIgnored() returned wrong result, got %+v, expected %+v", i, result, c.expected)
+		}
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	a := cloudflare.DNSRecord{Type: "A", Name: "a.example.com", Content: "192.0.2.1"}
+	b := cloudflare.DNSRecord{Type: "A", Name: "b.example.com", Content: "192.0.2.2"}
+
+	c := a
+	c.TTL = 600
+
+	result, duplicates := RecordCollection{a, b, a, c}.Dedupe()
+	if !reflect.DeepEqual(result, RecordCollection{a, b}) || !reflect.DeepEqual(duplicates, RecordCollection{a, c}) {
+		t.Errorf("Dedupe() returned %+v and %+v", result, duplicates)
+	}
+}
+
+func TestSameExceptTTL(t *testing.T) {
+	a := cloudflare.DNSRecord{Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: 300}
+
+	ttl := a
+	ttl.TTL = 600
+
+	content := ttl
+	content.Content = "192.0.2.2"
+
+	proxied := ttl
+	proxied.Proxied = cloudflare.BoolPtr(true)
+
+	if !SameExceptTTL(a, a) || !SameExceptTTL(a, ttl) {
+		t.Errorf("SameExceptTTL() didn't match records differing only in TTL")
+	}
+
+	if SameExceptTTL(a, content) || SameExceptTTL(a, proxied) {
+		t.Errorf("SameExceptTTL() matched records with other differences")
+	}
+}
+
+func TestSort(t *testing.T) {
+	c := RecordCollection{
+		{Name: "www.example.com", Type: "A", Content: "192.0.2.2"},
+		{Name: "example.com", Type: "MX", Content: "mx.example.com", Priority: cloudflare.Uint16Ptr(20)},
+		{Name: "WWW.example.com", Type: "A", Content: "192.0.2.1"},
+		{Name: "example.com", Type: "MX", Content: "mx.example.com", Priority: cloudflare.Uint16Ptr(10)},
+		{Name: "example.com", Type: "A", Content: "192.0.2.1", TTL: 300, Proxied: cloudflare.BoolPtr(true)},
+		{Name: "example.com", Type: "A", Content: "192.0.2.1", TTL: 300},
+		{Name: "example.com", Type: "A", Content: "192.0.2.1", TTL: 1},
+	}
+
+	expected := RecordCollection{c[6], c[5], c[4], c[3], c[1], c[2], c[0]}
+
+	c.Sort()
+
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Sort() returned wrong order: %+v", c)
+	}
+}
+
+func TestFprintZone(t *testing.T) {
+	c := RecordCollection{
+		{Name: "www.example.com", TTL: 1, Type: "A", Content: "192.0.2.1", Proxied: cloudflare.BoolPtr(true)},
+		{Name: "example.com", TTL: 3600, Type: "MX", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10)},
+		{Name: "alias.example.com", TTL: 300, Type: "CNAME", Content: "example.net."},
+		{Name: "example.net", TTL: 300, Type: "TXT", Content: "outside"},
+	}
+
+	expected := `$ORIGIN example.com.
+alias        300  IN CNAME example.net.
+@            3600 IN MX    10 mail.example.com.
+example.net. 300  IN TXT   "outside"
+www          1    IN A     192.0.2.1 ; PROXIED
+`
+
+	var b bytes.Buffer
+	c.FprintZone(&b, "example.com.", LayoutSorted)
+
+	if b.String() != expected {
+		t.Fatalf("FprintZone() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestFprintZoneByType(t *testing.T) {
+	c := RecordCollection{
+		{Name: "www.example.com", TTL: 300, Type: "A", Content: "192.0.2.1"},
+		{Name: "example.com", TTL: 300, Type: "TXT", Content: "v=spf1 -all"},
+		{Name: "example.com", TTL: 300, Type: "MX", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10)},
+		{Name: "api.example.com", TTL: 300, Type: "A", Content: "192.0.2.2"},
+		{Name: "_sip._tcp.example.com", TTL: 300, Type: "SRV", Content: "10 5060 sip.example.com"},
+	}
+
+	expected := `$ORIGIN example.com.
+; A records
+api       300 IN A     192.0.2.2
+www       300 IN A     192.0.2.1
+
+; MX records
+@         300 IN MX    10 mail.example.com.
+
+; TXT records
+@         300 IN TXT   "v=spf1 -all"
+
+; SRV records
+_sip._tcp 300 IN SRV   0 10 5060 sip.example.com.
+`
+
+	var b bytes.Buffer
+	c.FprintZone(&b, "example.com", LayoutByType)
+
+	if b.String() != expected {
+		t.Fatalf("FprintZone() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestFprintZoneFile(t *testing.T) {
+	c := RecordCollection{
+		{Name: "www.example.com", TTL: 300, Type: "A", Content: "192.0.2.1"},
+		{Name: "example.com", TTL: 300, Type: "NS", Content: "old.example.net"},
+		{Name: "sub.example.com", TTL: 300, Type: "NS", Content: "ns.example.net"},
+	}
+
+	expected := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.net. hostmaster.example.com. 42 10000 2400 604800 3600
+@ 3600 IN NS ns1.example.net.
+@ 3600 IN NS ns2.example.net.
+sub 300 IN NS    ns.example.net.
+www 300 IN A     192.0.2.1
+`
+
+	var b bytes.Buffer
+	err := c.FprintZoneFile(&b, "example.com.", []string{"ns1.example.net", "ns2.example.net."}, 42, LayoutSorted)
+	if err != nil {
+		t.Fatalf("FprintZoneFile() failed: %s", err.Error())
+	}
+
+	if b.String() != expected {
+		t.Fatalf("FprintZoneFile() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+
+	err = c.FprintZoneFile(&b, "example.com", nil, 42, LayoutSorted)
+	if err == nil {
+		t.Errorf("FprintZoneFile() did not fail without name servers")
+	}
+}
+
+func TestWithAutoTTL(t *testing.T) {
+	c := RecordCollection{
+		{Type: "A", Name: "a.example.com", TTL: 300},
+		{Type: "A", Name: "b.example.com", TTL: 600},
+		{Type: "A", Name: "c.example.com", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+	}
+
+	ttls := func(c RecordCollection) []int {
+		result := []int{}
+		for _, r := range c {
+			result = append(result, r.TTL)
+		}
+
+		return result
+	}
+
+	if got := ttls(c.WithAutoTTL(300)); !reflect.DeepEqual(got, []int{0, 600, 1}) {
+		t.Errorf("WithAutoTTL(300) returned TTLs %v", got)
+	}
+
+	if got := ttls(c.WithAutoTTL(0)); !reflect.DeepEqual(got, []int{300, 600, 1}) {
+		t.Errorf("WithAutoTTL(0) returned TTLs %v", got)
+	}
+
+	if got := ttls(c.WithAutoTTL(1)); !reflect.DeepEqual(got, []int{300, 600, 1}) {
+		t.Errorf("WithAutoTTL(1) returned TTLs %v", got)
+	}
+
+	if c[0].TTL != 300 {
+		t.Errorf("WithAutoTTL() changed the original collection")
+	}
+}
@@ -0,0 +1,342 @@
+package cfzone
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anderskvist/cfzone/internal/awsv4"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// route53Namespace is the XML namespace of the Route53 API.
+const route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+
+// Route53 is a Provider using the AWS Route53 API. Route53 groups records
+// with the same name and type in record sets, each record is mapped to a
+// single value in a set.
+type Route53 struct {
+	// Endpoint is the Route53 API endpoint.
+	Endpoint string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// Client is used for all requests.
+	Client *http.Client
+
+	lock sync.Mutex
+	ids  map[string]string
+}
+
+type (
+	// route53Zone is a hosted zone.
+	route53Zone struct {
+		ID   string `xml:"Id"`
+		Name string `xml:"Name"`
+	}
+
+	// route53Value is a single value of a record set.
+	route53Value struct {
+		Value string `xml:"Value"`
+	}
+
+	// route53Set is a resource record set.
+	route53Set struct {
+		Name          string         `xml:"Name"`
+		Type          string         `xml:"Type"`
+		SetIdentifier string         `xml:"SetIdentifier,omitempty"`
+		TTL           int            `xml:"TTL,omitempty"`
+		Values        []route53Value `xml:"ResourceRecords>ResourceRecord"`
+		AliasTarget   *struct{}      `xml:"AliasTarget"`
+	}
+
+	// route53Changes is the body of a ChangeResourceRecordSets request.
+	route53Changes struct {
+		XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+		Xmlns   string          `xml:"xmlns,attr"`
+		Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+	}
+
+	// route53Change is a single change to a record set.
+	route53Change struct {
+		Action string     `xml:"Action"`
+		Set    route53Set `xml:"ResourceRecordSet"`
+	}
+)
+
+// NewRoute53 returns a Route53 provider using the standard AWS environment
+// variables for credentials.
+func NewRoute53() (*Route53, error) {
+	r := &Route53{
+		Endpoint:     "https://route53.amazonaws.com",
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Client:       http.DefaultClient,
+	}
+
+	if r.AccessKey == "" || r.SecretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	return r, nil
+}
+
+// do will send a signed request to the Route53 API, and decode the XML
+// response into v.
+func (r *Route53) do(method string, path string, query url.Values, body interface{}, v interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = xml.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	u := strings.TrimSuffix(r.Endpoint, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	if r.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", r.SessionToken)
+	}
+
+	awsv4.Sign(req, payload, r.AccessKey, r.SecretKey, "us-east-1", "route53", time.Now())
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(b, &e)
+
+		return fmt.Errorf("Route53 returned %d: %s", resp.StatusCode, e.Message)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return xml.Unmarshal(b, v)
+}
+
+// ZoneID returns the ID of the hosted zone zoneName. IDs are cached.
+func (r *Route53) ZoneID(zoneName string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	id, found := r.ids[zoneName]
+	if found {
+		return id, nil
+	}
+
+	var resp struct {
+		Zones []route53Zone `xml:"HostedZones>HostedZone"`
+	}
+
+	err := r.do("GET", "/2013-04-01/hostedzonesbyname", url.Values{"dnsname": {zoneName}, "maxitems": {"1"}}, nil, &resp)
+	if err != nil {
+		return "", fmt.Errorf("Can't get hosted zone for '%s': %s", zoneName, err.Error())
+	}
+
+	if len(resp.Zones) == 0 || strings.TrimSuffix(resp.Zones[0].Name, ".") != zoneName {
+		return "", fmt.Errorf("Can't get hosted zone for '%s': not found", zoneName)
+	}
+
+	id = strings.TrimPrefix(resp.Zones[0].ID, "/hostedzone/")
+
+	if r.ids == nil {
+		r.ids = map[string]string{}
+	}
+	r.ids[zoneName] = id
+
+	return id, nil
+}
+
+// sets will return record sets of the zone with id, starting at name and
+// type. If limit is 0, all sets are returned.
+func (r *Route53) sets(id string, name string, typ string, limit int) ([]route53Set, error) {
+	result := []route53Set{}
+	query := url.Values{}
+
+	if name != "" {
+		query.Set("name", name)
+		query.Set("type", typ)
+	}
+
+	if limit > 0 {
+		query.Set("maxitems", strconv.Itoa(limit))
+	}
+
+	for {
+		var resp struct {
+			Sets        []route53Set `xml:"ResourceRecordSets>ResourceRecordSet"`
+			IsTruncated bool         `xml:"IsTruncated"`
+			NextName    string       `xml:"NextRecordName"`
+			NextType    string       `xml:"NextRecordType"`
+		}
+
+		err := r.do("GET", "/2013-04-01/hostedzone/"+id+"/rrset", query, nil, &resp)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, resp.Sets...)
+
+		if !resp.IsTruncated || limit > 0 {
+			return result, nil
+		}
+
+		query.Set("name", resp.NextName)
+		query.Set("type", resp.NextType)
+	}
+}
+
+// fromRoute53Name returns a Route53 name as used by cfzone.
+func fromRoute53Name(name string) string {
+	return fromFQDN(strings.Replace(name, `\052`, "*", 1))
+}
+
+// List implements Provider. Alias records, records with routing policies and
+// the NS and SOA records at the apex are left out.
+func (r *Route53) List(zoneName string) (RecordCollection, error) {
+	id, err := r.ZoneID(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	sets, err := r.sets(id, "", "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get zone records for '%s': %s", zoneName, err.Error())
+	}
+
+	records := RecordCollection{}
+	for _, set := range sets {
+		apex := fromRoute53Name(set.Name) == Name(zoneName)
+		if set.AliasTarget != nil || set.SetIdentifier != "" || set.Type == "SOA" || (set.Type == "NS" && apex) {
+			continue
+		}
+
+		for _, v := range set.Values {
+			record := cloudflare.DNSRecord{
+				ID:   setRecordID(fromRoute53Name(set.Name), set.Type, v.Value),
+				Name: fromRoute53Name(set.Name),
+				Type: set.Type,
+				TTL:  set.TTL,
+			}
+			setRdata(&record, v.Value)
+
+			records = append(records, setRecord(record))
+		}
+	}
+
+	return records, nil
+}
+
+// change will fetch the record set with the name and type of rec, let
+// modify change the values, and write the set back.
+func (r *Route53) change(zoneName string, rec cloudflare.DNSRecord, modify func(values []string) []string) error {
+	id, err := r.ZoneID(zoneName)
+	if err != nil {
+		return err
+	}
+
+	sets, err := r.sets(id, fqdn(rec.Name), rec.Type, 1)
+	if err != nil {
+		return err
+	}
+
+	var old *route53Set
+	if len(sets) > 0 && fromRoute53Name(sets[0].Name) == strings.ToLower(rec.Name) && sets[0].Type == rec.Type {
+		old = &sets[0]
+	}
+
+	values := []string{}
+	if old != nil {
+		for _, v := range old.Values {
+			values = append(values, v.Value)
+		}
+	}
+
+	values = modify(values)
+	sort.Strings(values)
+
+	var change route53Change
+	if len(values) == 0 {
+		if old == nil {
+			return nil
+		}
+
+		change = route53Change{Action: "DELETE", Set: *old}
+	} else {
+		ttl := setRecord(rec).TTL
+
+		set := route53Set{Name: fqdn(rec.Name), Type: rec.Type, TTL: ttl}
+		for _, v := range values {
+			set.Values = append(set.Values, route53Value{v})
+		}
+
+		change = route53Change{Action: "UPSERT", Set: set}
+	}
+
+	return r.do("POST", "/2013-04-01/hostedzone/"+id+"/rrset", nil, route53Changes{
+		Xmlns:   route53Namespace,
+		Changes: []route53Change{change},
+	}, nil)
+}
+
+// Normalize implements Normalizer. Route53 has no automatic TTL, no proxy
+// and no comments.
+func (r *Route53) Normalize(rec cloudflare.DNSRecord) cloudflare.DNSRecord {
+	return setRecord(rec)
+}
+
+// Create implements Provider.
+func (r *Route53) Create(zoneName string, rec cloudflare.DNSRecord) error {
+	return r.change(zoneName, rec, addValue(rec))
+}
+
+// Update implements Provider. The value replaced is found from the ID of
+// rec.
+func (r *Route53) Update(zoneName string, rec cloudflare.DNSRecord) error {
+	return r.change(zoneName, rec, replaceValue(rec))
+}
+
+// Delete implements Provider.
+func (r *Route53) Delete(zoneName string, rec cloudflare.DNSRecord) error {
+	return r.change(zoneName, rec, removeValue(rec))
+}
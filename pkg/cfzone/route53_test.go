@@ -0,0 +1,207 @@
+package cfzone
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// fakeRoute53 is a minimal Route53 API serving a single hosted zone.
+type fakeRoute53 struct {
+	sets    []route53Set
+	changes []route53Change
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/2013-04-01/hostedzonesbyname":
+		w.Write([]byte(`<ListHostedZonesByNameResponse><HostedZones><HostedZone><Id>/hostedzone/Z1</Id><Name>example.com.</Name></HostedZone></HostedZones></ListHostedZonesByNameResponse>`))
+
+	case r.URL.Path == "/2013-04-01/hostedzone/Z1/rrset" && r.Method == "GET":
+		sets := f.sets
+		if name := r.URL.Query().Get("name"); name != "" {
+			sets = nil
+			for _, set := range f.sets {
+				if set.Name == name && set.Type == r.URL.Query().Get("type") {
+					sets = append(sets, set)
+				}
+			}
+		}
+
+		b, _ := xml.Marshal(struct {
+			XMLName xml.Name     `xml:"ListResourceRecordSetsResponse"`
+			Sets    []route53Set `xml:"ResourceRecordSets>ResourceRecordSet"`
+		}{Sets: sets})
+		w.Write(b)
+
+	case r.URL.Path == "/2013-04-01/hostedzone/Z1/rrset" && r.Method == "POST":
+		var c route53Changes
+		xml.NewDecoder(r.Body).Decode(&c)
+		f.changes = append(f.changes, c.Changes...)
+
+		for _, change := range c.Changes {
+			sets := []route53Set{}
+			for _, set := range f.sets {
+				if set.Name != change.Set.Name || set.Type != change.Set.Type {
+					sets = append(sets, set)
+				}
+			}
+
+			if change.Action == "UPSERT" {
+				sets = append(sets, change.Set)
+			}
+
+			f.sets = sets
+		}
+		w.Write([]byte(`<ChangeResourceRecordSetsResponse/>`))
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestRoute53(f *fakeRoute53) (*Route53, func()) {
+	s := httptest.NewServer(f)
+
+	return &Route53{Endpoint: s.URL, AccessKey: "key", SecretKey: "secret", Client: s.Client()}, s.Close
+}
+
+func TestRoute53List(t *testing.T) {
+	f := &fakeRoute53{sets: []route53Set{
+		{Name: "example.com.", Type: "NS", TTL: 172800, Values: []route53Value{{"ns-1.awsdns-00.com."}}},
+		{Name: "example.com.", Type: "MX", TTL: 300, Values: []route53Value{{"10 mx1.example.com."}, {"20 mx2.example.com."}}},
+		{Name: "\\052.example.com.", Type: "TXT", TTL: 60, Values: []route53Value{{`"hello"`}}},
+		{Name: "www.example.com.", Type: "CNAME", TTL: 300, Values: []route53Value{{"example.net."}}},
+		{Name: "alias.example.com.", Type: "A", AliasTarget: &struct{}{}},
+		{Name: "sub.example.com.", Type: "NS", TTL: 86400, Values: []route53Value{{"ns1.example.net."}}},
+	}}
+
+	r, done := newTestRoute53(f)
+	defer done()
+
+	records, err := r.List("example.com")
+	if err != nil {
+		t.Fatalf("List() failed: %s", err.Error())
+	}
+
+	expected := RecordCollection{
+		{ID: "example.com/MX/10 mx1.example.com.", Name: "example.com", Type: "MX", TTL: 300, Priority: cloudflare.Uint16Ptr(10), Content: "mx1.example.com", Proxied: cloudflare.BoolPtr(false)},
+		{ID: "example.com/MX/20 mx2.example.com.", Name: "example.com", Type: "MX", TTL: 300, Priority: cloudflare.Uint16Ptr(20), Content: "mx2.example.com", Proxied: cloudflare.BoolPtr(false)},
+		{ID: `*.example.com/TXT/"hello"`, Name: "*.example.com", Type: "TXT", TTL: 60, Content: "hello", Proxied: cloudflare.BoolPtr(false)},
+		{ID: "www.example.com/CNAME/example.net.", Name: "www.example.com", Type: "CNAME", TTL: 300, Content: "example.net", Proxied: cloudflare.BoolPtr(false)},
+		{ID: "sub.example.com/NS/ns1.example.net.", Name: "sub.example.com", Type: "NS", TTL: 86400, Content: "ns1.example.net", Proxied: cloudflare.BoolPtr(false)},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("List() returned wrong records: %+v", records)
+	}
+
+	_, err = r.List("example.org")
+	if err == nil {
+		t.Errorf("List() didn't fail for unknown zone")
+	}
+}
+
+func TestRoute53Changes(t *testing.T) {
+	cases := []struct {
+		name     string
+		apply    func(r *Route53) error
+		action   string
+		expected []string
+	}{
+		{
+			"create new set",
+			func(r *Route53) error {
+				return r.Create("example.com", cloudflare.DNSRecord{Name: "new.example.com", Type: "A", TTL: 1, Content: "192.0.2.1"})
+			},
+			"UPSERT",
+			[]string{"192.0.2.1"},
+		},
+		{
+			"create in existing set",
+			func(r *Route53) error {
+				return r.Create("example.com", cloudflare.DNSRecord{Name: "example.com", Type: "MX", TTL: 300, Priority: cloudflare.Uint16Ptr(30), Content: "mx3.example.com"})
+			},
+			"UPSERT",
+			[]string{"10 mx1.example.com.", "20 mx2.example.com.", "30 mx3.example.com."},
+		},
+		{
+			"update",
+			func(r *Route53) error {
+				return r.Update("example.com", cloudflare.DNSRecord{ID: "example.com/MX/20 mx2.example.com.", Name: "example.com", Type: "MX", TTL: 300, Priority: cloudflare.Uint16Ptr(5), Content: "mx2.example.com"})
+			},
+			"UPSERT",
+			[]string{"10 mx1.example.com.", "5 mx2.example.com."},
+		},
+		{
+			"delete from set",
+			func(r *Route53) error {
+				return r.Delete("example.com", cloudflare.DNSRecord{Name: "example.com", Type: "MX", Priority: cloudflare.Uint16Ptr(10), Content: "mx1.example.com"})
+			},
+			"UPSERT",
+			[]string{"20 mx2.example.com."},
+		},
+		{
+			"delete set",
+			func(r *Route53) error {
+				return r.Delete("example.com", cloudflare.DNSRecord{Name: "txt.example.com", Type: "TXT", Content: "hello"})
+			},
+			"DELETE",
+			[]string{`"hello"`},
+		},
+	}
+
+	for _, c := range cases {
+		f := &fakeRoute53{sets: []route53Set{
+			{Name: "example.com.", Type: "MX", TTL: 300, Values: []route53Value{{"10 mx1.example.com."}, {"20 mx2.example.com."}}},
+			{Name: "txt.example.com.", Type: "TXT", TTL: 300, Values: []route53Value{{`"hello"`}}},
+		}}
+
+		r, done := newTestRoute53(f)
+
+		err := c.apply(r)
+		done()
+		if err != nil {
+			t.Errorf("%s: failed: %s", c.name, err.Error())
+			continue
+		}
+
+		if len(f.changes) != 1 {
+			t.Errorf("%s: sent %d changes, expected 1", c.name, len(f.changes))
+			continue
+		}
+
+		values := []string{}
+		for _, v := range f.changes[0].Set.Values {
+			values = append(values, v.Value)
+		}
+
+		if f.changes[0].Action != c.action || !reflect.DeepEqual(values, c.expected) {
+			t.Errorf("%s: sent wrong change %s %v", c.name, f.changes[0].Action, values)
+		}
+
+		if c.action == "UPSERT" && f.changes[0].Set.TTL < 60 {
+			t.Errorf("%s: sent TTL %d", c.name, f.changes[0].Set.TTL)
+		}
+	}
+}
+
+func TestRoute53SyncTwice(t *testing.T) {
+	r, done := newTestRoute53(&fakeRoute53{})
+	defer done()
+
+	changes := syncTwice(t, r)
+	if len(changes.Adds)+len(changes.Deletes)+len(changes.Updates) != 0 {
+		t.Errorf("Second sync found changes: %+v", changes)
+	}
+}
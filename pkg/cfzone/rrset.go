@@ -0,0 +1,126 @@
+package cfzone
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// setDefaultTTL is used for records with automatic TTL by providers without
+// such a thing.
+const setDefaultTTL = 300
+
+// Providers like Route53 and Cloud DNS group records with the same name and
+// type in record sets. Each record is mapped to a single value in a set, and
+// the helpers below translate between the two.
+
+// setRecord returns r the way providers using record sets store it. They have
+// no automatic TTL, no proxy and no comments, so automatic TTLs become
+// setDefaultTTL, records are never proxied and comments are dropped. Records
+// must be the same on the way in and out, or they would differ on every sync.
+func setRecord(r cloudflare.DNSRecord) cloudflare.DNSRecord {
+	if r.TTL <= 1 {
+		r.TTL = setDefaultTTL
+	}
+
+	r.Proxied = cloudflare.BoolPtr(false)
+	r.Comment = ""
+
+	return r
+}
+
+// fqdn returns name with a trailing dot.
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// fromFQDN returns a fully qualified name as used by cfzone.
+func fromFQDN(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// rdata returns the record set value of r.
+func rdata(r cloudflare.DNSRecord) string {
+	switch r.Type {
+	case "CNAME", "NS":
+		return fqdn(CanonicalContent(r))
+
+	case "MX":
+		return fmt.Sprintf("%d %s", cloudflare.Uint16(r.Priority), fqdn(CanonicalContent(r)))
+
+	case "TXT":
+		return quoteTXT(r.Content)
+	}
+
+	return r.Content
+}
+
+// setRdata will set the content of r from a record set value.
+func setRdata(r *cloudflare.DNSRecord, value string) {
+	switch r.Type {
+	case "CNAME", "NS":
+		r.Content = Target(value)
+
+	case "MX":
+		parts := strings.SplitN(value, " ", 2)
+		if len(parts) == 2 {
+			priority, _ := strconv.ParseUint(parts[0], 10, 16)
+			r.Priority = cloudflare.Uint16Ptr(uint16(priority))
+			r.Content = Target(parts[1])
+		}
+
+	case "TXT":
+		r.Content = unquoteTXT(value)
+
+	default:
+		r.Content = value
+	}
+}
+
+// setRecordID returns the ID used for the record with value in a set.
+func setRecordID(name string, typ string, value string) string {
+	return name + "/" + typ + "/" + value
+}
+
+// without returns values without value.
+func without(values []string, value string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// addValue returns a function adding the value of r to a set.
+func addValue(r cloudflare.DNSRecord) func([]string) []string {
+	value := rdata(r)
+
+	return func(values []string) []string {
+		return append(without(values, value), value)
+	}
+}
+
+// replaceValue returns a function replacing the value identified by the ID of
+// r with the value of r.
+func replaceValue(r cloudflare.DNSRecord) func([]string) []string {
+	old := strings.TrimPrefix(r.ID, setRecordID(strings.ToLower(r.Name), r.Type, ""))
+	value := rdata(r)
+
+	return func(values []string) []string {
+		return append(without(without(values, old), value), value)
+	}
+}
+
+// removeValue returns a function removing the value of r from a set.
+func removeValue(r cloudflare.DNSRecord) func([]string) []string {
+	value := rdata(r)
+
+	return func(values []string) []string {
+		return without(values, value)
+	}
+}
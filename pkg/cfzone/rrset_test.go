@@ -0,0 +1,35 @@
+package cfzone
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// syncTwice will sync records with automatic TTLs and proxying to provider
+// twice, and return the changes found by the second sync.
+func syncTwice(t *testing.T, provider Provider) Changes {
+	wanted := RecordCollection{
+		{Name: "www.example.com", Type: "A", TTL: 1, Proxied: cloudflare.BoolPtr(true), Content: "192.0.2.1"},
+		{Name: "example.com", Type: "TXT", TTL: 0, Content: "hello"},
+		{Name: "example.com", Type: "MX", TTL: 3600, Priority: cloudflare.Uint16Ptr(10), Content: "mx1.example.com"},
+		{Name: "sub.example.com", Type: "NS", TTL: 86400, Content: "ns1.example.net"},
+	}
+
+	var changes Changes
+	for run := 0; run < 2; run++ {
+		existing, err := provider.List("example.com")
+		if err != nil {
+			t.Fatalf("List() failed: %s", err.Error())
+		}
+
+		changes = Diff(Normalize(provider, wanted).Canonical(), existing.Canonical())
+
+		err = changes.Apply(provider, "example.com", nil)
+		if err != nil {
+			t.Fatalf("Apply() failed: %s", err.Error())
+		}
+	}
+
+	return changes
+}
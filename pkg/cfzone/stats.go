@@ -0,0 +1,67 @@
+package cfzone
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Stats summarizes a RecordCollection.
+type Stats struct {
+	// Total is the number of records.
+	Total int `json:"total"`
+
+	// Types holds the number of records by type.
+	Types map[string]int `json:"types"`
+
+	// Proxied is the number of records proxied by Cloudflare.
+	Proxied int `json:"proxied"`
+
+	// TTLs holds the number of records by TTL.
+	TTLs map[int]int `json:"ttls"`
+}
+
+// Stats returns a summary of c.
+func (c RecordCollection) Stats() Stats {
+	s := Stats{
+		Total: len(c),
+		Types: map[string]int{},
+		TTLs:  map[int]int{},
+	}
+
+	for _, r := range c {
+		s.Types[r.Type]++
+		s.TTLs[r.TTL]++
+
+		if cloudflare.Bool(r.Proxied) {
+			s.Proxied++
+		}
+	}
+
+	return s
+}
+
+// String returns a one-line summary like
+// "3 records (A 2, MX 1), 1 proxied, TTLs (1: 1, 300: 2)".
+func (s Stats) String() string {
+	types := []string{}
+	for t, n := range s.Types {
+		types = append(types, fmt.Sprintf("%s %d", t, n))
+	}
+	sort.Strings(types)
+
+	ttls := []int{}
+	for ttl := range s.TTLs {
+		ttls = append(ttls, ttl)
+	}
+	sort.Ints(ttls)
+
+	counts := []string{}
+	for _, ttl := range ttls {
+		counts = append(counts, fmt.Sprintf("%d: %d", ttl, s.TTLs[ttl]))
+	}
+
+	return fmt.Sprintf("%d records (%s), %d proxied, TTLs (%s)", s.Total, strings.Join(types, ", "), s.Proxied, strings.Join(counts, ", "))
+}
@@ -0,0 +1,33 @@
+package cfzone
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestStats(t *testing.T) {
+	c := RecordCollection{
+		{Type: "A", Name: "www.example.com", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+		{Type: "A", Name: "api.example.com", TTL: 300},
+		{Type: "MX", Name: "example.com", TTL: 300, Priority: cloudflare.Uint16Ptr(10)},
+	}
+
+	s := c.Stats()
+
+	expected := Stats{
+		Total:   3,
+		Types:   map[string]int{"A": 2, "MX": 1},
+		Proxied: 1,
+		TTLs:    map[int]int{1: 1, 300: 2},
+	}
+
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("Stats() returned %+v", s)
+	}
+
+	if s.String() != "3 records (A 2, MX 1), 1 proxied, TTLs (1: 1, 300: 2)" {
+		t.Errorf("String() returned '%s'", s.String())
+	}
+}
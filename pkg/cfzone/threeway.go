@@ -0,0 +1,113 @@
+package cfzone
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Class tells where a change found by Diff comes from, when compared to the
+// records as of the last apply.
+type Class string
+
+const (
+	// LocalChange is a change made to the wanted records since the last
+	// apply.
+	LocalChange Class = "local"
+
+	// RemoteDrift is a change made to the existing records since the last
+	// apply, by someone else.
+	RemoteDrift Class = "drift"
+
+	// Conflict is a record changed both in the wanted and the existing
+	// records since the last apply.
+	Conflict Class = "conflict"
+)
+
+// stateKey identifies a record by all the fields cfzone manages.
+func stateKey(r cloudflare.DNSRecord) string {
+	return strings.Join([]string{
+		strings.ToLower(r.Name),
+		r.Type,
+		CanonicalContent(r),
+		strconv.Itoa(r.TTL),
+		strconv.Itoa(int(cloudflare.Uint16(r.Priority))),
+		strconv.FormatBool(cloudflare.Bool(r.Proxied)),
+	}, "\x00")
+}
+
+// Classified holds the class of each change in Changes, in the same order.
+type Classified struct {
+	Deletes []Class
+	Adds    []Class
+	Updates []Class
+}
+
+// Classify will do a three-way comparison of the changes found by Diff,
+// using base as the wanted records at the last apply and existing as the
+// records Diff was given. Without a base, all changes are local.
+func (c Changes) Classify(base RecordCollection, existing RecordCollection) Classified {
+	applied := map[string]bool{}
+	for _, r := range base {
+		applied[stateKey(r)] = true
+	}
+
+	byID := map[string]cloudflare.DNSRecord{}
+	for _, r := range existing {
+		byID[r.ID] = r
+	}
+
+	result := Classified{}
+
+	if len(base) == 0 {
+		for range c.Deletes {
+			result.Deletes = append(result.Deletes, LocalChange)
+		}
+		for range c.Adds {
+			result.Adds = append(result.Adds, LocalChange)
+		}
+		for range c.Updates {
+			result.Updates = append(result.Updates, LocalChange)
+		}
+
+		return result
+	}
+
+	// A record missing from the wanted records was removed locally if it
+	// was applied, otherwise it was added by someone else.
+	for _, r := range c.Deletes {
+		if applied[stateKey(r)] {
+			result.Deletes = append(result.Deletes, LocalChange)
+		} else {
+			result.Deletes = append(result.Deletes, RemoteDrift)
+		}
+	}
+
+	// A wanted record missing from the existing records was deleted by
+	// someone else if it was applied, otherwise it's new.
+	for _, r := range c.Adds {
+		if applied[stateKey(r)] {
+			result.Adds = append(result.Adds, RemoteDrift)
+		} else {
+			result.Adds = append(result.Adds, LocalChange)
+		}
+	}
+
+	for _, r := range c.Updates {
+		remote, found := byID[r.ID]
+
+		switch {
+		case applied[stateKey(r)]:
+			result.Updates = append(result.Updates, RemoteDrift)
+
+		case found && applied[stateKey(remote)]:
+			result.Updates = append(result.Updates, LocalChange)
+
+		default:
+			result.Updates = append(result.Updates, Conflict)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,64 @@
+package cfzone
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestClassify(t *testing.T) {
+	a := func(id string, name string, content string, ttl int) cloudflare.DNSRecord {
+		return cloudflare.DNSRecord{ID: id, Type: "A", Name: name + ".example.com", Content: content, TTL: ttl}
+	}
+
+	base := RecordCollection{
+		a("", "www", "192.0.2.1", 300),
+		a("", "api", "192.0.2.5", 300),
+		a("", "old", "192.0.2.3", 300),
+		a("", "gone", "192.0.2.7", 300),
+		a("", "edited", "192.0.2.8", 300),
+	}
+
+	existing := RecordCollection{
+		a("w", "www", "192.0.2.10", 300),
+		a("a", "api", "192.0.2.9", 300),
+		a("o", "old", "192.0.2.3", 300),
+		a("e", "extra", "192.0.2.11", 300),
+		a("d", "edited", "192.0.2.8", 300),
+	}
+
+	changes := Changes{
+		Deletes: RecordCollection{existing[2], existing[3]},
+		Adds:    RecordCollection{a("", "new", "192.0.2.4", 300), a("", "gone", "192.0.2.7", 300)},
+		Updates: RecordCollection{
+			a("w", "www", "192.0.2.1", 300),
+			a("a", "api", "192.0.2.6", 300),
+			a("d", "edited", "192.0.2.8", 600),
+		},
+	}
+
+	expected := Classified{
+		Deletes: []Class{LocalChange, RemoteDrift},
+		Adds:    []Class{LocalChange, RemoteDrift},
+		Updates: []Class{RemoteDrift, Conflict, LocalChange},
+	}
+
+	classified := changes.Classify(base, existing)
+	if !reflect.DeepEqual(classified, expected) {
+		t.Errorf("Classify() returned %+v, expected %+v", classified, expected)
+	}
+
+	// Without knowing what was applied, everything is assumed to come
+	// from the zone file.
+	expected = Classified{
+		Deletes: []Class{LocalChange, LocalChange},
+		Adds:    []Class{LocalChange, LocalChange},
+		Updates: []Class{LocalChange, LocalChange, LocalChange},
+	}
+
+	classified = changes.Classify(nil, existing)
+	if !reflect.DeepEqual(classified, expected) {
+		t.Errorf("Classify() returned %+v without a base, expected %+v", classified, expected)
+	}
+}
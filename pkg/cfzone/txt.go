@@ -0,0 +1,128 @@
+package cfzone
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// unescapeTXT returns the text of a TXT string as parsed by miekg/dns, with
+// escapes like \" and \DDD resolved. The result is the text as stored by
+// Cloudflare.
+func unescapeTXT(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		// \DDD is a byte in decimal.
+		if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+			n := int(s[i+1]-'0')*100 + int(s[i+2]-'0')*10 + int(s[i+3]-'0')
+			if n <= 255 {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+
+		b.WriteByte(s[i+1])
+		i++
+	}
+
+	return b.String()
+}
+
+// escapeTXT returns text escaped for a quoted string in a zone file. Quotes
+// and backslashes are escaped, as are control characters and bytes not part
+// of valid UTF-8. Other characters are kept as is, to keep exports readable.
+func escapeTXT(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&b, "\\%03d", text[i])
+
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&b, "\\%03d", r)
+
+		default:
+			b.WriteString(text[i : i+size])
+		}
+
+		i += size
+	}
+
+	return b.String()
+}
+
+// txtStringLength is the maximum length of a single string in a TXT record.
+const txtStringLength = 255
+
+// quoteTXT returns text as quoted strings for a zone file or a record set,
+// split in strings of at most 255 bytes like long DKIM keys must be.
+func quoteTXT(text string) string {
+	if text == "" {
+		return `""`
+	}
+
+	strs := []string{}
+	for len(text) > 0 {
+		n := txtStringLength
+		if n > len(text) {
+			n = len(text)
+		}
+
+		strs = append(strs, `"`+escapeTXT(text[:n])+`"`)
+		text = text[n:]
+	}
+
+	return strings.Join(strs, " ")
+}
+
+// unquoteTXT returns the text of one or more quoted strings, like `"a" "b"`,
+// joined the way DNS clients join them. Text without quotes is unescaped as
+// a single string.
+func unquoteTXT(s string) string {
+	if !strings.HasPrefix(strings.TrimSpace(s), `"`) {
+		return unescapeTXT(s)
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '"' {
+			continue
+		}
+
+		start := i + 1
+		for i = start; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' {
+				i++
+			}
+		}
+
+		end := i
+		if end > len(s) {
+			end = len(s)
+		}
+
+		b.WriteString(unescapeTXT(s[start:end]))
+	}
+
+	return b.String()
+}
+
+// isDigit returns true if c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
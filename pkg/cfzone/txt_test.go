@@ -0,0 +1,141 @@
+package cfzone
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestTXTEscaping(t *testing.T) {
+	cases := []struct {
+		zone    string
+		text    string
+		escaped string
+	}{
+		{`"v=spf1 -all"`, "v=spf1 -all", "v=spf1 -all"},
+		{`"a \"quoted\" string"`, `a "quoted" string`, `a \"quoted\" string`},
+		{`"back\\slash"`, `back\slash`, `back\\slash`},
+		{`"v=DMARC1; p=reject"`, "v=DMARC1; p=reject", "v=DMARC1; p=reject"},
+		{`"emoji 😀"`, "emoji 😀", "emoji 😀"},
+		{`"blåbærgrød"`, "blåbærgrød", "blåbærgrød"},
+		{`"\240\159\152\128"`, "😀", "😀"},
+		{`"byte \233"`, "byte \xe9", `byte \233`},
+		{`"tab\009here"`, "tab\there", `tab\009here`},
+		{`"\a\b"`, "ab", "ab"},
+		{`""`, "", ""},
+	}
+
+	for i, c := range cases {
+		zone := "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400\ntxt.example.com. 300 IN TXT " + c.zone + "\n"
+
+		_, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+		if err != nil {
+			t.Fatalf("%d: ParseZone() failed: %s", i, err.Error())
+		}
+
+		if records[0].Content != c.text {
+			t.Errorf("%d: ParseZone() returned content %q, expected %q", i, records[0].Content, c.text)
+		}
+
+		if escaped := escapeTXT(c.text); escaped != c.escaped {
+			t.Errorf("%d: escapeTXT() returned %q, expected %q", i, escaped, c.escaped)
+		}
+
+		// The zone file, the model and Cloudflare must agree after
+		// printing and parsing again.
+		var b bytes.Buffer
+		records.FprintZone(&b, "example.com", LayoutSorted)
+
+		_, reparsed, _, err := ParseZone(strings.NewReader(zone[:strings.Index(zone, "\n")+1]+b.String()), ParseOptions{})
+		if err != nil {
+			t.Fatalf("%d: ParseZone() failed on FprintZone() output %q: %s", i, b.String(), err.Error())
+		}
+
+		if reparsed[0].Content != c.text {
+			t.Errorf("%d: FprintZone() changed content to %q", i, reparsed[0].Content)
+		}
+
+		existing := RecordCollection{{ID: "1", Type: "TXT", Name: "txt.example.com", Content: c.text, TTL: 300}}
+		if changes := Diff(records, existing); changes.Unchanged != 1 {
+			t.Errorf("%d: Diff() didn't match the content at Cloudflare: %+v", i, changes)
+		}
+
+		r := cloudflare.DNSRecord{Type: "TXT"}
+		setRdata(&r, rdata(records[0]))
+		if r.Content != c.text {
+			t.Errorf("%d: Record set value changed content to %q", i, r.Content)
+		}
+	}
+}
+
+func TestUnescapeTXT(t *testing.T) {
+	cases := map[string]string{
+		`plain`:            "plain",
+		`\"`:               `"`,
+		`\\`:               `\`,
+		`\065BC`:           "ABC",
+		`\999`:             "999",
+		`\12`:              "12",
+		`trailing\`:        `trailing\`,
+		`\240\159\152\128`: "😀",
+	}
+
+	for in, expected := range cases {
+		if got := unescapeTXT(in); got != expected {
+			t.Errorf("unescapeTXT(%q) returned %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+// dkimKey is the TXT content of a DKIM record with a 2048-bit RSA key, too
+// long for a single string.
+const dkimKey = "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA" +
+	"UvImZaYMEtKJGF2VDuiBNgkWb2sRPReNbA/TkB/yOaGglfIPk5VlDPk4C47bIkprJIoekk6P0K4uGpSSozBfGIy2EJAPnjR/rohtxlB3lex0XEw/" +
+	"yy6yxz4Uk0yGfuBXunJJm/oSHoNrKsFXJu59awr2qxPDjpLK4NFQV7FZmH+UzHQR1xfxRXmyqhAPu7NPpZP+rtJySLdi46tYBfB2WiucHX4PN8RJ" +
+	"Ib0/ZWTq338UKnJmjEfiI9Fu3YxHtGr8W67iYfU7JhUtJjuoOwN81JYuQ0gBJWuIXpyQUfMgsNuD856nrb0NdObex/PfrsyPZGVmZBp7omYPMBH8" +
+	"NXApHFeZDRoAkSaJGfJdnQYS3zWdYCaiQPRYml15Hx3ZfP76d3p7TxUkIDAQAB"
+
+func TestTXTMultipleStrings(t *testing.T) {
+	zone := "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400\n" +
+		"key._domainkey.example.com. 300 IN TXT \"" + dkimKey[:200] + "\" \"" + dkimKey[200:] + "\"\n"
+
+	_, records, _, err := ParseZone(strings.NewReader(zone), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed: %s", err.Error())
+	}
+
+	if records[0].Content != dkimKey {
+		t.Fatalf("ParseZone() returned content %q, expected the whole key", records[0].Content)
+	}
+
+	// Record sets and zone files can't hold strings over 255 bytes.
+	value := rdata(records[0])
+	if value != `"`+dkimKey[:255]+`" "`+dkimKey[255:]+`"` {
+		t.Errorf("rdata() returned %q", value)
+	}
+
+	r := cloudflare.DNSRecord{Type: "TXT"}
+	setRdata(&r, value)
+	if r.Content != dkimKey {
+		t.Errorf("setRdata() returned content %q", r.Content)
+	}
+
+	setRdata(&r, `"a \"b\"" "c"`)
+	if r.Content != `a "b"c` {
+		t.Errorf("setRdata() returned content %q for two strings", r.Content)
+	}
+
+	var b bytes.Buffer
+	records.FprintZone(&b, "example.com", LayoutSorted)
+
+	_, reparsed, _, err := ParseZone(strings.NewReader("example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400\n"+b.String()), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseZone() failed on exported zone: %s", err.Error())
+	}
+
+	if reparsed[0].Content != dkimKey {
+		t.Errorf("Exported zone changed the key to %q", reparsed[0].Content)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// plan holds the changes needed to make a Cloudflare zone match a zone file.
+type plan struct {
+	ZoneName string `json:"zone"`
+	ZoneID   string `json:"zone_id"`
+
+	// Author is the minisign key ID of the author of a saved plan.
+	Author string `json:"author,omitempty"`
+
+	Deletes recordCollection `json:"deletes"`
+	Adds    recordCollection `json:"adds"`
+	Updates recordCollection `json:"updates"`
+
+	// Replaced holds the records at Cloudflare replaced by Updates, as
+	// they were when a saved plan was made.
+	Replaced recordCollection `json:"replaced,omitempty"`
+
+	// Managed is the number of records in the zone file, not counting
+	// ignored records.
+	Managed int `json:"managed"`
+
+	// Unchanged is the number of records already matching the zone file.
+	Unchanged int `json:"unchanged"`
+
+	// Untouched is the number of unknown records left alone because of
+	// -leaveunknown.
+	Untouched int `json:"untouched"`
+
+	// Kept is the number of changes made outside of cfzone left alone
+	// because of -drift or -conflicts.
+	Kept int `json:"kept"`
+
+	// Stats summarizes the managed records in the zone file.
+	Stats cfzone.Stats `json:"stats"`
+
+	// existing holds the records fetched from Cloudflare.
+	existing recordCollection
+}
+
+// zoneOptions will reset all flags to the values given on the command line,
+// and then apply the per-zone options for zoneName from the configuration.
+func zoneOptions(zoneName string) error {
+	// Start from the flags as given on the command line, we could be
+	// syncing multiple zones with different options.
+	err := applyFlags(parsedFlags, baseFlags, nil)
+	if err != nil {
+		return err
+	}
+
+	err = applyFlags(parsedFlags, cfg.Zones[zoneName].Flags, explicitFlags)
+	if err != nil {
+		return fmt.Errorf("Error in configuration for '%s': %s", zoneName, err.Error())
+	}
+
+	// Like the flags, confirm_deletes doesn't override the command line.
+	if n := cfg.Zones[zoneName].ConfirmDeletes; n != nil && !explicitFlags["confirmdeletes"] {
+		confirmDeletes = *n
+	}
+
+	err = validateFlags()
+	if err != nil {
+		return fmt.Errorf("Error in configuration for '%s': %s", zoneName, err.Error())
+	}
+
+	return nil
+}
+
+// prepareZone will apply the per-zone options for zoneName, and run
+// fileRecords through the transform command. Everything diffing zone files
+// against a provider must do this, or the changes would differ from a sync.
+func prepareZone(zoneName string, fileRecords recordCollection) (recordCollection, error) {
+	err := zoneOptions(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	warnExcludedDelegations(zoneName)
+
+	return transformRecords(zoneName, fileRecords)
+}
+
+// scope returns the predicates for records in zoneName managed according to
+// -types, -match and -excludesubtree. Types skipped by -skipunsupported are
+// never managed, or the records would be deleted.
+func scope(zoneName string) []cfzone.Predicate {
+	predicates := []cfzone.Predicate{}
+
+	if len(skippedTypes[zoneName]) > 0 {
+		types := []string{}
+		for t := range skippedTypes[zoneName] {
+			types = append(types, t)
+		}
+
+		predicates = append(predicates, cfzone.Not(cfzone.ByType(types...)))
+	}
+
+	if syncTypes != "" {
+		predicates = append(predicates, cfzone.ByType(splitList(syncTypes)...))
+	}
+
+	if syncMatch != "" {
+		predicates = append(predicates, cfzone.ByName(splitList(syncMatch)...))
+	}
+
+	if excludeSubtrees != "" {
+		predicates = append(predicates, cfzone.Not(cfzone.BySubtree(splitList(excludeSubtrees)...)))
+	}
+
+	return predicates
+}
+
+// splitList will split a comma separated list, ignoring spaces and empty
+// elements.
+func splitList(s string) []string {
+	list := []string{}
+
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			list = append(list, e)
+		}
+	}
+
+	return list
+}
+
+// zoneIDer is implemented by providers with IDs for zones, like Cloudflare.
+type zoneIDer interface {
+	ZoneID(zoneName string) (string, error)
+}
+
+// fetchZone will return the ID and all records of zoneName from provider. The
+// ID is empty if the provider doesn't use IDs for zones. Records are cached
+// between syncs unless -nocache is given, and only fetched again if the zone
+// has been modified or the cached records expired.
+func fetchZone(provider cfzone.Provider, zoneName string) (id string, records recordCollection, err error) {
+	id, records, _, err = fetchCached(provider, zoneName, false)
+
+	return id, records, err
+}
+
+// fetchCached is like fetchZone, but also tells if the records came from the
+// cache. With force the records are always listed, and the cache updated.
+func fetchCached(provider cfzone.Provider, zoneName string, force bool) (id string, records recordCollection, cached bool, err error) {
+	s := tracing.startSpan("fetch")
+	s.SetAttribute("cfzone.zone", zoneName)
+	defer func() { s.End(err) }()
+	defer timings.start("fetch")()
+
+	var modified time.Time
+
+	m, cacheable := provider.(zoneModifier)
+	if cacheable && !noCache {
+		modified, err = m.ZoneModified(zoneName)
+		if err != nil {
+			return "", nil, false, err
+		}
+
+		id, records, found := zoneCache.get(zoneName, modified)
+		if found && !force {
+			debugf(1, "Using cached records for '%s' modified %s", zoneName, modified.Format(time.RFC3339))
+			return id, records, true, nil
+		}
+	}
+
+	if z, ok := provider.(zoneIDer); ok {
+		id, err = z.ZoneID(zoneName)
+		if err != nil {
+			return "", nil, false, err
+		}
+	}
+
+	records, err = provider.List(zoneName)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	// Names in mixed case, or content written differently by Cloudflare,
+	// must not end up as changes.
+	records = records.Canonical()
+
+	if cacheable && !noCache {
+		zoneCache.put(zoneName, modified, id, records)
+	}
+
+	return id, records, false, nil
+}
+
+// newPlan will fetch the records of zoneName from provider and find the
+// changes needed to make the zone match fileRecords.
+func newPlan(provider cfzone.Provider, zoneName string, fileRecords recordCollection) (*plan, error) {
+	id, records, cached, err := fetchCached(provider, zoneName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := cfzone.ParseFields(ignoreFields)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := tracing.startSpan("diff")
+	diffed := timings.start("diff")
+
+	// Records matching the ignore patterns, or outside the scope given by
+	// -types and -match, are left out on both sides.
+	patterns := cfg.ignorePatterns(zoneName)
+	fileRecords = stampable(cfzone.Normalize(provider, fileRecords.WithAutoTTL(autoTTL).Canonical()).WithoutIgnored(patterns).Filter(scope(zoneName)...))
+	existingRecords := withoutStamps(records.WithoutIgnored(patterns).Filter(scope(zoneName)...))
+	changes := cfzone.DiffIgnoring(fileRecords, existingRecords, fields...)
+
+	// Cached records can be stale, so changes are always planned from
+	// records listed again.
+	if cached && len(changes.Adds)+len(changes.Deletes)+len(changes.Updates) > 0 {
+		diffed()
+
+		id, records, _, err = fetchCached(provider, zoneName, true)
+		if err != nil {
+			diff.End(err)
+			return nil, err
+		}
+
+		diffed = timings.start("diff")
+		existingRecords = withoutStamps(records.WithoutIgnored(patterns).Filter(scope(zoneName)...))
+		changes = cfzone.DiffIgnoring(fileRecords, existingRecords, fields...)
+	}
+
+	// Zones imported long ago can hold exact duplicates. The extra copies
+	// end up as deletes.
+	_, remoteDuplicates := existingRecords.Dedupe()
+	if len(remoteDuplicates) > 0 {
+		lines := []string{}
+		for _, r := range remoteDuplicates {
+			lines = append(lines, recordLine(r))
+		}
+
+		warnf("Found %d duplicate record(s) in %s:\n  %s", len(remoteDuplicates), zoneName, strings.Join(lines, "\n  "))
+	}
+
+	traceDecisions(existingRecords, changes.Adds, changes.Deletes, changes.Updates)
+
+	p := &plan{
+		ZoneName:  zoneName,
+		ZoneID:    id,
+		Deletes:   changes.Deletes,
+		Adds:      changes.Adds,
+		Updates:   changes.Updates,
+		Managed:   len(fileRecords),
+		Unchanged: changes.Unchanged,
+		Stats:     fileRecords.Stats(),
+		existing:  records,
+	}
+
+	if leaveUnknown {
+		// Extra copies of records in the zone file aren't unknown, and
+		// are still deleted.
+		deletes := recordCollection{}
+		for _, r := range p.Deletes {
+			if n, _ := remoteDuplicates.Find(r, sameID); n < 0 {
+				continue
+			}
+
+			if n, _ := fileRecords.Find(r, cfzone.FullMatch); n >= 0 {
+				deletes = append(deletes, r)
+			}
+		}
+
+		p.Untouched = len(p.Deletes) - len(deletes)
+		p.Deletes = deletes
+	}
+
+	if threeWay {
+		base, err := loadApplied(zoneName)
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.threeWayFilter(base, existingRecords)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = p.checkPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	p.sort()
+
+	diffed()
+	diff.End(nil)
+
+	err = p.auditPlanned()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// sort will sort the changes, making output and the order of changes the same
+// every time.
+func (p *plan) sort() {
+	p.Deletes.Sort()
+	p.Adds.Sort()
+	p.Updates.Sort()
+}
+
+// NumChanges returns the number of changes in the plan.
+func (p *plan) NumChanges() int {
+	return len(p.Deletes) + len(p.Adds) + len(p.Updates)
+}
+
+// Fprint will output a textual representation of the plan.
+func (p *plan) Fprint(w io.Writer) {
+	if len(p.Deletes) > 0 {
+		fmt.Fprintf(w, "Records to delete:\n")
+		p.Deletes.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(p.Adds) > 0 {
+		fmt.Fprintf(w, "Records to add:\n")
+		p.Adds.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(p.Updates) > 0 {
+		fmt.Fprintf(w, "Records to update:\n")
+		p.Updates.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+
+	fmt.Fprintf(w, "Summary:\n")
+	fmt.Fprintf(w, "Records to delete: %d\n", len(p.Deletes))
+	fmt.Fprintf(w, "Records to add: %d\n", len(p.Adds))
+	fmt.Fprintf(w, "Records to update: %d\n", len(p.Updates))
+	fmt.Fprintf(w, "Unchanged records: %d\n", p.Unchanged)
+
+	if p.Stats.Total > 0 {
+		fmt.Fprintf(w, "Zone file: %s\n", p.Stats)
+	}
+}
+
+// Apply will apply all changes in the plan using provider. Progress is
+// reported to w.
+func (p *plan) Apply(provider cfzone.Provider, w io.Writer) (err error) {
+	s := tracing.startSpan("apply")
+	s.SetAttribute("cfzone.zone", p.ZoneName)
+	s.SetAttribute("cfzone.changes", strconv.Itoa(p.NumChanges()))
+	defer func() { s.End(err) }()
+	defer timings.start("apply")()
+
+	// Whatever happens, the cached records are no longer current.
+	defer zoneCache.invalidate(p.ZoneName)
+
+	progress := newProgress(w, p.NumChanges())
+
+	changes := cfzone.Changes{
+		Deletes: p.Deletes,
+		Adds:    stamped(p.Adds),
+		Updates: stamped(p.Updates),
+	}
+
+	return changes.ApplyConcurrently(provider, p.ZoneName, concurrency, func(operation string, r cloudflare.DNSRecord, err error) error {
+		err = p.applied(operation, r, err)
+		if err == nil {
+			progress.Step()
+		}
+
+		return err
+	})
+}
+
+// applied will log the result of operation on r to the audit log, and return
+// err. If the audit log can't be written, that error is returned instead.
+func (p *plan) applied(operation string, r cloudflare.DNSRecord, err error) error {
+	result := "applied"
+	if err != nil {
+		result = "failed"
+	}
+
+	auditErr := p.audit(operation, r, result, err)
+	if err == nil {
+		return auditErr
+	}
+
+	return err
+}
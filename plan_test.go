@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestPlanFprint(t *testing.T) {
+	p := &plan{
+		Deletes:   recordCollection{cloudflare.DNSRecord{Name: "a1", Type: "A", Content: "127.0.0.1"}},
+		Adds:      recordCollection{cloudflare.DNSRecord{Name: "a2", Type: "A", Content: "127.0.0.2"}},
+		Updates:   recordCollection{},
+		Unchanged: 3,
+	}
+
+	if p.NumChanges() != 2 {
+		t.Errorf("NumChanges() returned %d, expected 2", p.NumChanges())
+	}
+
+	expected := `Records to delete:
+a1. 0 IN A     127.0.0.1
+
+Records to add:
+a2. 0 IN A     127.0.0.2
+
+Summary:
+Records to delete: 1
+Records to add: 1
+Records to update: 0
+Unchanged records: 3
+`
+
+	var b bytes.Buffer
+	p.Fprint(&b)
+
+	if b.String() != expected {
+		t.Errorf("Fprint() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+// fakeProvider is a cfzone.Provider keeping records in memory.
+type fakeProvider struct {
+	records recordCollection
+	calls   []string
+}
+
+func (f *fakeProvider) List(zoneName string) (recordCollection, error) {
+	return f.records.Clone(), nil
+}
+
+func (f *fakeProvider) Create(zoneName string, r cloudflare.DNSRecord) error {
+	f.calls = append(f.calls, "create "+recordLine(r))
+	return nil
+}
+
+func (f *fakeProvider) Update(zoneName string, r cloudflare.DNSRecord) error {
+	f.calls = append(f.calls, "update "+recordLine(r))
+	return nil
+}
+
+func (f *fakeProvider) Delete(zoneName string, r cloudflare.DNSRecord) error {
+	f.calls = append(f.calls, "delete "+recordLine(r))
+	return nil
+}
+
+func TestNewPlanApply(t *testing.T) {
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{ID: "2", Type: "A", Name: "old.example.com", Content: "192.0.2.2"},
+			{ID: "3", Type: "A", Name: "same.example.com", Content: "192.0.2.3"},
+		},
+	}
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.10"},
+		{Type: "A", Name: "new.example.com", Content: "192.0.2.4"},
+		{Type: "A", Name: "same.example.com", Content: "192.0.2.3"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if p.NumChanges() != 3 || p.Unchanged != 1 || p.ZoneID != "" {
+		t.Errorf("newPlan() returned wrong plan: %+v", p)
+	}
+
+	var b bytes.Buffer
+	err = p.Apply(f, &b)
+	if err != nil {
+		t.Fatalf("Apply() failed: %s", err.Error())
+	}
+
+	expected := []string{
+		"delete old.example.com. 0 IN A     192.0.2.2",
+		"create new.example.com. 0 IN A     192.0.2.4",
+		"update www.example.com. 0 IN A     192.0.2.10",
+	}
+	if !reflect.DeepEqual(f.calls, expected) {
+		t.Errorf("Apply() made wrong calls: %#v", f.calls)
+	}
+}
+
+func TestNewPlanScope(t *testing.T) {
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{ID: "2", Type: "TXT", Name: "www.example.com", Content: "old"},
+			{ID: "3", Type: "A", Name: "app.k8s.example.com", Content: "192.0.2.3"},
+		},
+	}
+
+	syncTypes = "a, aaaa"
+	syncMatch = "*.example.com,"
+	defer func() { syncTypes, syncMatch = "", "" }()
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+		{Type: "TXT", Name: "example.com", Content: "new"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	// The TXT records are out of scope, and left alone.
+	expected := recordCollection{{ID: "3", Type: "A", Name: "app.k8s.example.com", Content: "192.0.2.3"}}
+	if !reflect.DeepEqual(p.Deletes, expected) || len(p.Adds) != 0 || len(p.Updates) != 0 || p.Managed != 1 {
+		t.Errorf("newPlan() returned wrong plan: %+v", p)
+	}
+}
+
+func TestNewPlanExcludeSubtree(t *testing.T) {
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{ID: "2", Type: "A", Name: "app.k8s.example.com", Content: "192.0.2.2"},
+			{ID: "3", Type: "NS", Name: "k8s.example.com", Content: "ns1.example.net"},
+		},
+	}
+
+	excludeSubtrees = "k8s.example.com"
+	defer func() { excludeSubtrees = "" }()
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+		{Type: "A", Name: "web.k8s.example.com", Content: "192.0.2.3"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	// Everything in k8s.example.com is invisible on both sides.
+	if p.NumChanges() != 0 || p.Managed != 1 {
+		t.Errorf("newPlan() returned wrong plan: %+v", p)
+	}
+}
+
+func TestNewPlanIgnoreFields(t *testing.T) {
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+		},
+	}
+
+	ignoreFields = "ttl,proxied"
+	defer func() { ignoreFields = "" }()
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if p.NumChanges() != 0 || p.Unchanged != 1 {
+		t.Errorf("newPlan() didn't ignore TTL and proxied: %+v", p)
+	}
+}
+
+func TestNewPlanStampedComments(t *testing.T) {
+	stampComments = true
+	defer func() { stampComments = false }()
+
+	long := strings.Repeat("x", 90)
+
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300, Comment: "Web - managed by cfzone (run by bob@laptop at 2018-06-01T08:00:00Z)"},
+			{ID: "2", Type: "A", Name: "api.example.com", Content: "192.0.2.2", TTL: 300, Comment: stamped(recordCollection{{Comment: long}})[0].Comment},
+		},
+	}
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300, Comment: "Web"},
+		{Type: "A", Name: "api.example.com", Content: "192.0.2.2", TTL: 300, Comment: long},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if p.NumChanges() != 0 {
+		t.Errorf("newPlan() returned changes for stamped comments: %+v", p)
+	}
+}
+
+func TestNewPlanMixedCase(t *testing.T) {
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "WWW.Example.com", Content: "192.0.2.1"},
+		},
+	}
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if p.NumChanges() != 0 {
+		t.Errorf("newPlan() returned changes for a name in mixed case: %+v", p)
+	}
+}
+
+func TestNewPlanRemoteDuplicates(t *testing.T) {
+	f := &fakeProvider{
+		records: recordCollection{
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+			{ID: "3", Type: "A", Name: "unknown.example.com", Content: "192.0.2.3"},
+		},
+	}
+
+	leaveUnknown = true
+	defer func() { leaveUnknown = false }()
+
+	p, err := newPlan(f, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	// The extra copy is deleted, the unknown record is left alone.
+	expected := recordCollection{{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.1"}}
+	if !reflect.DeepEqual(p.Deletes, expected) || p.Untouched != 1 || p.Unchanged != 1 {
+		t.Errorf("newPlan() returned wrong plan: %+v", p)
+	}
+}
+
+func TestZoneOptionsConfirmDeletes(t *testing.T) {
+	three := 3
+	defer func() { cfg.Zones = nil }()
+	defer parseArguments([]string{"./test", "zone"})
+
+	cases := []struct {
+		args     []string
+		zoneName string
+		expected int
+	}{
+		{[]string{"./test", "zone"}, "example.com", 3},
+		{[]string{"./test", "zone"}, "example.org", 10},
+		{[]string{"./test", "-confirmdeletes", "5", "zone"}, "example.com", 5},
+	}
+
+	for _, c := range cases {
+		parseArguments(c.args)
+		cfg.Zones = map[string]zoneConfig{"example.com": {ConfirmDeletes: &three}}
+
+		err := zoneOptions(c.zoneName)
+		if err != nil {
+			t.Fatalf("zoneOptions() failed: %s", err.Error())
+		}
+
+		if confirmDeletes != c.expected {
+			t.Errorf("zoneOptions(%s) with %v set -confirmdeletes to %d, expected %d", c.zoneName, c.args, confirmDeletes, c.expected)
+		}
+	}
+}
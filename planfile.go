@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+var (
+	// savePlanPath is where -saveplan writes the plan instead of applying
+	// it.
+	savePlanPath = ""
+
+	// applyPlanPath is a plan saved with -saveplan to apply.
+	applyPlanPath = ""
+
+	// approvers is a file with the minisign public keys allowed to approve
+	// saved plans. Saved plans can't be applied without it.
+	approvers = ""
+
+	// planAuthor is the minisign key ID of the author of plans saved with
+	// -saveplan. The author can't approve their own plan.
+	planAuthor = ""
+
+	// approvals is the number of different approvers that must sign a
+	// saved plan before it can be applied.
+	approvals = 2
+)
+
+// savePlan will write p to path as JSON, with -author as the author.
+func savePlan(p *plan, path string) error {
+	if planAuthor == "" {
+		return errors.New("-saveplan needs -author")
+	}
+
+	p.Author = strings.ToUpper(planAuthor)
+
+	// The records replaced by updates are saved too, to tell if they
+	// changed before the plan is applied.
+	p.Replaced = recordCollection{}
+	for _, r := range p.Updates {
+		if n, e := p.existing.Find(r, sameID); n >= 0 {
+			p.Replaced = append(p.Replaced, *e)
+		}
+	}
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// loadPlan will read a plan saved to path, and verify its approvals.
+func loadPlan(path string) (*plan, error) {
+	if approvers == "" {
+		return nil, errors.New("-applyplan needs -approvers")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan{}
+
+	err = json.Unmarshal(content, p)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid plan '%s': %s", path, err.Error())
+	}
+
+	if p.ZoneName == "" {
+		return nil, fmt.Errorf("Invalid plan '%s': No zone", path)
+	}
+
+	if p.Author == "" {
+		return nil, fmt.Errorf("Invalid plan '%s': No author", path)
+	}
+
+	err = checkApprovals(path, content, p.Author)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// checkApprovals will make sure content is signed by at least -approvals
+// different keys from -approvers, not counting the key of author. Signatures
+// are read from all files named like <path>*.minisig, like plan.json.minisig
+// and plan.json.alice.minisig.
+func checkApprovals(path string, content []byte, author string) error {
+	keys, err := loadMinisignKeys(approvers)
+	if err != nil {
+		return err
+	}
+
+	paths, err := filepath.Glob(path + "*.minisig")
+	if err != nil {
+		return err
+	}
+
+	signers := map[string]bool{}
+	for _, sigPath := range paths {
+		sig, err := ioutil.ReadFile(sigPath)
+		if err != nil {
+			return err
+		}
+
+		signer, err := verifyMinisign(content, sig, keys)
+		if err != nil {
+			return fmt.Errorf("Bad approval '%s': %s", sigPath, err.Error())
+		}
+
+		if !strings.EqualFold(signer, author) {
+			signers[signer] = true
+		}
+	}
+
+	if len(signers) < approvals {
+		return fmt.Errorf("The plan is approved by %d of %d required approvers", len(signers), approvals)
+	}
+
+	return nil
+}
+
+// checkCurrent will make sure the records deleted and updated by p are still
+// at Cloudflare as they were when the plan was made, and that the records
+// added by p are not there already.
+func (p *plan) checkCurrent(existing recordCollection) error {
+	byID := map[string]int{}
+	for i, r := range existing {
+		byID[r.ID] = i
+	}
+
+	replaced := map[string]cloudflare.DNSRecord{}
+	for _, r := range p.Replaced {
+		replaced[r.ID] = r
+	}
+
+	for _, r := range append(append(recordCollection{}, p.Deletes...), p.Updates...) {
+		i, found := byID[r.ID]
+		if !found {
+			return fmt.Errorf("The plan is out of date, %s %s is gone", r.Name, r.Type)
+		}
+
+		e := existing[i]
+		if !strings.EqualFold(e.Name, r.Name) || e.Type != r.Type {
+			return fmt.Errorf("The plan is out of date, %s %s has changed", r.Name, r.Type)
+		}
+	}
+
+	for _, r := range p.Deletes {
+		if !sameState(existing[byID[r.ID]], r) {
+			return fmt.Errorf("The plan is out of date, %s %s has changed", r.Name, r.Type)
+		}
+	}
+
+	for _, r := range p.Updates {
+		old, found := replaced[r.ID]
+		if !found {
+			return fmt.Errorf("Invalid plan, the record replaced by %s %s is missing", r.Name, r.Type)
+		}
+
+		if !sameState(existing[byID[r.ID]], old) {
+			return fmt.Errorf("The plan is out of date, %s %s has changed", r.Name, r.Type)
+		}
+	}
+
+	for _, r := range p.Adds {
+		for _, e := range existing {
+			if strings.EqualFold(e.Name, r.Name) && e.Type == r.Type && e.Content == r.Content {
+				return fmt.Errorf("The plan is out of date, %s %s %s exists", r.Name, r.Type, r.Content)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameState returns true if a and b have the same content, TTL and proxied
+// status.
+func sameState(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+	return a.Content == b.Content && a.TTL == b.TTL && cloudflare.Bool(a.Proxied) == cloudflare.Bool(b.Proxied)
+}
+
+// applyPlan will apply the plan saved at path. Unless -yes is given, the
+// user will be asked for confirmation. The result is reported like the result
+// of a sync.
+func applyPlan(path string) (err error) {
+	p, err := loadPlan(path)
+	if err != nil {
+		return err
+	}
+
+	err = zoneOptions(p.ZoneName)
+	if err != nil {
+		return err
+	}
+
+	start := now()
+	defer func() {
+		reportResult(&result{ZoneName: p.ZoneName, Plan: p, Err: err, Duration: now().Sub(start)})
+	}()
+
+	unlockZone, err := lockZone(p.ZoneName)
+	if err != nil {
+		return err
+	}
+	defer unlockZone()
+
+	provider, err := newProvider()
+	if err != nil {
+		return fmt.Errorf("Error contacting Cloudflare: %s", err.Error())
+	}
+
+	_, existing, err := fetchZone(provider, p.ZoneName)
+	if err != nil {
+		applyErrors.Add(p.ZoneName, 1)
+		return err
+	}
+
+	err = p.checkCurrent(existing)
+	if err != nil {
+		applyErrors.Add(p.ZoneName, 1)
+		return err
+	}
+
+	// The policy could have changed since the plan was made.
+	err = p.checkPolicy()
+	if err != nil {
+		applyErrors.Add(p.ZoneName, 1)
+		return err
+	}
+
+	p.existing = existing
+
+	setZoneMetrics(p)
+
+	if p.NumChanges() == 0 {
+		return nil
+	}
+
+	answers := bufio.NewReader(stdin)
+
+	if !yes {
+		p.Fprint(stdout)
+
+		err = confirmChanges(p, answers)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = confirmDeletions(p, answers)
+	if err != nil {
+		return err
+	}
+
+	err = runPreHook(p)
+	if err != nil {
+		return err
+	}
+
+	err = p.Apply(provider, stdout)
+	runPostHook(p, err)
+	if err != nil {
+		applyErrors.Add(p.ZoneName, 1)
+		return err
+	}
+
+	zoneDrift.Set(p.ZoneName, 0)
+	zoneLastSync.Set(p.ZoneName, float64(now().Unix()))
+
+	if changelogPath != "" {
+		return writeChangelog(p)
+	}
+
+	return nil
+}
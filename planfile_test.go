@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestCheckApprovals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	_, alice, _ := ed25519.GenerateKey(rand.Reader)
+	_, bob, _ := ed25519.GenerateKey(rand.Reader)
+	_, mallory, _ := ed25519.GenerateKey(rand.Reader)
+
+	path := filepath.Join(dir, "plan.json")
+	content := []byte(`{"zone":"example.com"}`)
+	ioutil.WriteFile(path, content, 0644)
+
+	aliceSig, alicePublic := minisign(alice, []byte("alice..."), "ED", content)
+	bobSig, bobPublic := minisign(bob, []byte("bob....."), "ED", content)
+	mallorySig, _ := minisign(mallory, []byte("mallory."), "ED", content)
+
+	approvers = filepath.Join(dir, "approvers")
+	defer func() { approvers = "" }()
+	ioutil.WriteFile(approvers, []byte(alicePublic+"\n"+bobPublic+"\n"), 0644)
+
+	err = checkApprovals(path, content, "")
+	if err == nil || !strings.Contains(err.Error(), "0 of 2") {
+		t.Errorf("checkApprovals() didn't fail without approvals: %v", err)
+	}
+
+	ioutil.WriteFile(path+".minisig", []byte(aliceSig), 0644)
+
+	err = checkApprovals(path, content, "")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2") {
+		t.Errorf("checkApprovals() didn't fail with one approval: %v", err)
+	}
+
+	// The same approver twice doesn't count.
+	ioutil.WriteFile(path+".again.minisig", []byte(aliceSig), 0644)
+
+	err = checkApprovals(path, content, "")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2") {
+		t.Errorf("checkApprovals() counted the same approver twice: %v", err)
+	}
+
+	ioutil.WriteFile(path+".bob.minisig", []byte(bobSig), 0644)
+
+	err = checkApprovals(path, content, "")
+	if err != nil {
+		t.Errorf("checkApprovals() failed with two approvals: %s", err.Error())
+	}
+
+	// The author can't approve their own plan.
+	author := minisignKey{id: []byte("bob.....")}.String()
+
+	err = checkApprovals(path, content, strings.ToLower(author))
+	if err == nil || !strings.Contains(err.Error(), "1 of 2") {
+		t.Errorf("checkApprovals() counted the author as an approver: %v", err)
+	}
+
+	err = checkApprovals(path, []byte(`{"zone":"example.net"}`), "")
+	if err == nil {
+		t.Errorf("checkApprovals() accepted a changed plan")
+	}
+
+	ioutil.WriteFile(path+".mallory.minisig", []byte(mallorySig), 0644)
+
+	err = checkApprovals(path, content, "")
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("checkApprovals() accepted an unknown approver: %v", err)
+	}
+}
+
+func TestCheckCurrent(t *testing.T) {
+	existing := recordCollection{
+		{ID: "1", Type: "A", Name: "old.example.com", Content: "192.0.2.1", TTL: 300},
+		{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.2", TTL: 300},
+	}
+
+	p := &plan{
+		Deletes:  recordCollection{existing[0]},
+		Updates:  recordCollection{{ID: "2", Type: "A", Name: "www.example.com", Content: "192.0.2.3", TTL: 300}},
+		Replaced: recordCollection{existing[1]},
+		Adds:     recordCollection{{Type: "A", Name: "new.example.com", Content: "192.0.2.4", TTL: 300}},
+	}
+
+	err := p.checkCurrent(existing)
+	if err != nil {
+		t.Fatalf("checkCurrent() failed: %s", err.Error())
+	}
+
+	cases := []struct {
+		change func(c recordCollection) recordCollection
+		err    string
+	}{
+		{func(c recordCollection) recordCollection { return c[1:] }, "old.example.com A is gone"},
+		{func(c recordCollection) recordCollection { c[0].TTL = 600; return c }, "old.example.com A has changed"},
+		{func(c recordCollection) recordCollection { c[1].Content = "192.0.2.5"; return c }, "www.example.com A has changed"},
+		{func(c recordCollection) recordCollection { c[1].TTL = 1; return c }, "www.example.com A has changed"},
+		{func(c recordCollection) recordCollection { c[1].Proxied = cloudflare.BoolPtr(true); return c }, "www.example.com A has changed"},
+		{func(c recordCollection) recordCollection {
+			return append(c, cloudflare.DNSRecord{ID: "3", Type: "A", Name: "new.example.com", Content: "192.0.2.4", TTL: 1})
+		}, "new.example.com A 192.0.2.4 exists"},
+	}
+
+	for _, c := range cases {
+		err = p.checkCurrent(c.change(existing.Clone()))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("checkCurrent() returned [%v], expected [%s]", err, c.err)
+		}
+	}
+}
+
+func TestSaveApplyPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	seed := filepath.Join(dir, "seed.json")
+	ioutil.WriteFile(seed, []byte(`[
+		{"id": "1", "type": "A", "name": "old.example.com", "content": "192.0.2.1", "zone_name": "example.com"},
+		{"id": "2", "type": "A", "name": "www.example.com", "content": "192.0.2.2", "zone_name": "example.com"}
+	]`), 0600)
+
+	simulated, err = loadSimulation(seed)
+	if err != nil {
+		t.Fatalf("loadSimulation() failed: %s", err.Error())
+	}
+	defer func() { simulated = nil }()
+
+	p, err := newPlan(simulated, "example.com", recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.3"},
+		{Type: "A", Name: "new.example.com", Content: "192.0.2.4"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	path := filepath.Join(dir, "plan.json")
+
+	err = savePlan(p, path)
+	if err == nil || !strings.Contains(err.Error(), "-author") {
+		t.Errorf("savePlan() saved a plan without an author: %v", err)
+	}
+
+	_, alice, _ := ed25519.GenerateKey(rand.Reader)
+	_, bob, _ := ed25519.GenerateKey(rand.Reader)
+
+	planAuthor = minisignKey{id: []byte("alice...")}.String()
+	defer func() { planAuthor = "" }()
+
+	err = savePlan(p, path)
+	if err != nil {
+		t.Fatalf("savePlan() failed: %s", err.Error())
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	aliceSig, alicePublic := minisign(alice, []byte("alice..."), "ED", content)
+	bobSig, bobPublic := minisign(bob, []byte("bob....."), "ED", content)
+
+	keys := filepath.Join(dir, "approvers")
+	ioutil.WriteFile(keys, []byte(alicePublic+"\n"+bobPublic+"\n"), 0644)
+
+	// The plan is applied later, with only the plan file.
+	parseArguments([]string{"./test", "-yes", "-applyplan", path})
+	defer parseArguments([]string{"./test", "zone"})
+
+	err = applyPlan(path)
+	if err == nil || !strings.Contains(err.Error(), "-approvers") {
+		t.Fatalf("applyPlan() applied a plan without -approvers: %v", err)
+	}
+
+	parseArguments([]string{"./test", "-yes", "-approvers", keys, "-approvals", "1", "-applyplan", path})
+
+	// The author signing their own plan isn't an approval.
+	ioutil.WriteFile(path+".alice.minisig", []byte(aliceSig), 0644)
+
+	err = applyPlan(path)
+	if err == nil || !strings.Contains(err.Error(), "0 of 1") {
+		t.Fatalf("applyPlan() applied a plan approved by the author: %v", err)
+	}
+
+	ioutil.WriteFile(path+".bob.minisig", []byte(bobSig), 0644)
+
+	// Deleting more than -confirmdeletes records must be confirmed.
+	parseArguments([]string{"./test", "-confirmdeletes", "0", "-report", filepath.Join(dir, "report.html"), "-approvers", keys, "-approvals", "1", "-applyplan", path})
+	defer func() { reportZones = nil }()
+
+	stdin = strings.NewReader("example.net\n")
+	defer func() { stdin = os.Stdin }()
+
+	err = applyPlan(path)
+	if err != errAborted {
+		t.Fatalf("applyPlan() didn't ask to confirm deletes: %v", err)
+	}
+
+	stdin = strings.NewReader("example.com\n")
+
+	err = applyPlan(path)
+	if err != nil {
+		t.Fatalf("applyPlan() failed: %s", err.Error())
+	}
+
+	if len(reportZones) != 1 || len(reportZones[0].Changes) != 3 {
+		t.Errorf("applyPlan() didn't report the result: %+v", reportZones)
+	}
+
+	records, _ := simulated.List("example.com")
+	if len(records) != 2 {
+		t.Fatalf("applyPlan() left wrong records: %+v", records)
+	}
+
+	for _, r := range records {
+		if r.Name == "old.example.com" || r.Content == "192.0.2.2" {
+			t.Errorf("applyPlan() left %+v", r)
+		}
+	}
+
+	// The records changed by the plan are gone now.
+	err = applyPlan(path)
+	if err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("applyPlan() applied an outdated plan: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+	"gopkg.in/yaml.v2"
+)
+
+// policyPath is a file with rules all changes must follow. Empty means no
+// rules.
+var policyPath = ""
+
+type (
+	// policyFile is the content of a -policy file.
+	policyFile struct {
+		Rules []policyRule `yaml:"rules"`
+	}
+
+	// policyRule is a single rule. Changes matching Zones, Names, Types
+	// and Operations must follow the requirements of the rule. Empty
+	// lists match everything.
+	policyRule struct {
+		// Name explains the rule when it's broken.
+		Name string `yaml:"name"`
+
+		// Zones and Names are glob patterns, like "*.example.com".
+		Zones []string `yaml:"zones"`
+		Names []string `yaml:"names"`
+		Types []string `yaml:"types"`
+
+		// Operations are "add", "update" and "delete".
+		Operations []string `yaml:"operations"`
+
+		// Deny forbids all matching changes.
+		Deny bool `yaml:"deny"`
+
+		// MinTTL and MaxTTL limit the TTL of records added or updated.
+		// Automatic TTL counts as 300.
+		MinTTL int `yaml:"min_ttl"`
+		MaxTTL int `yaml:"max_ttl"`
+
+		// Proxied requires records added or updated to be proxied, or
+		// not proxied, by Cloudflare.
+		Proxied *bool `yaml:"proxied"`
+	}
+)
+
+// loadPolicy will read the rules in the file at path.
+func loadPolicy(path string) (policyFile, error) {
+	p := policyFile{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+
+	err = yaml.UnmarshalStrict(b, &p)
+	if err != nil {
+		return p, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			return p, fmt.Errorf("%s: Rule %d has no name", path, i+1)
+		}
+
+		if !rule.Deny && rule.MinTTL == 0 && rule.MaxTTL == 0 && rule.Proxied == nil {
+			return p, fmt.Errorf("%s: Rule '%s' requires nothing", path, rule.Name)
+		}
+
+		for _, operation := range rule.Operations {
+			if operation != "add" && operation != "update" && operation != "delete" {
+				return p, fmt.Errorf("%s: Unknown operation '%s' in rule '%s'", path, operation, rule.Name)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// matches returns true if the rule covers operation on r in zoneName.
+func (rule policyRule) matches(zoneName string, operation string, r cloudflare.DNSRecord) bool {
+	if len(rule.Zones) > 0 && !matchAny(rule.Zones, zoneName) {
+		return false
+	}
+
+	if len(rule.Names) > 0 && !cfzone.ByName(rule.Names...)(r) {
+		return false
+	}
+
+	if len(rule.Types) > 0 && !cfzone.ByType(rule.Types...)(r) {
+		return false
+	}
+
+	if len(rule.Operations) > 0 && !matchAny(rule.Operations, operation) {
+		return false
+	}
+
+	return true
+}
+
+// violation returns why operation on r breaks the rule, or an empty string
+// if it doesn't.
+func (rule policyRule) violation(operation string, r cloudflare.DNSRecord) string {
+	if rule.Deny {
+		return rule.Name
+	}
+
+	// Records are only removed, whatever they look like.
+	if operation == "delete" {
+		return ""
+	}
+
+	ttl := r.TTL
+	if ttl == 1 {
+		ttl = 300
+	}
+
+	if rule.MinTTL > 0 && ttl < rule.MinTTL {
+		return fmt.Sprintf("%s (TTL %d is below %d)", rule.Name, ttl, rule.MinTTL)
+	}
+
+	if rule.MaxTTL > 0 && ttl > rule.MaxTTL {
+		return fmt.Sprintf("%s (TTL %d is above %d)", rule.Name, ttl, rule.MaxTTL)
+	}
+
+	if rule.Proxied != nil && cloudflare.Bool(r.Proxied) != *rule.Proxied {
+		if *rule.Proxied {
+			return rule.Name + " (not proxied)"
+		}
+
+		return rule.Name + " (proxied)"
+	}
+
+	return ""
+}
+
+// matchAny returns true if s matches any of the glob patterns. Matching is
+// case insensitive.
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		match, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s))
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPolicy will return an error listing all changes in p breaking the
+// rules in the -policy file.
+func (p *plan) checkPolicy() error {
+	if policyPath == "" {
+		return nil
+	}
+
+	policy, err := loadPolicy(policyPath)
+	if err != nil {
+		return err
+	}
+
+	broken := []string{}
+
+	check := func(verb string, operation string, records recordCollection) {
+		for _, r := range records {
+			for _, rule := range policy.Rules {
+				if !rule.matches(p.ZoneName, operation, r) {
+					continue
+				}
+
+				reason := rule.violation(operation, r)
+				if reason != "" {
+					broken = append(broken, fmt.Sprintf("%s %s: %s", verb, recordLine(r), reason))
+				}
+			}
+		}
+	}
+
+	check("Delete", "delete", p.Deletes)
+	check("Add", "add", p.Adds)
+	check("Update", "update", p.Updates)
+
+	if len(broken) > 0 {
+		return fmt.Errorf("Changes to %s break the policy in '%s':\n  %s", p.ZoneName, policyPath, strings.Join(broken, "\n  "))
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const testPolicy = `rules:
+  - name: never delete MX records
+    types: [MX]
+    operations: [delete]
+    deny: true
+  - name: TTL must be at least 300 in production
+    zones: ["example.com"]
+    min_ttl: 300
+  - name: web must be proxied
+    names: ["*.web.example.com"]
+    types: [A, AAAA, CNAME]
+    proxied: true
+`
+
+func TestCheckPolicy(t *testing.T) {
+	policyPath = writeTempFile(t, testPolicy)
+	defer func() {
+		os.Remove(policyPath)
+		policyPath = ""
+	}()
+
+	cases := []struct {
+		zone    string
+		deletes recordCollection
+		adds    recordCollection
+		updates recordCollection
+		broken  []string
+	}{
+		// Compliant changes.
+		{"example.com", nil, recordCollection{
+			{Type: "A", Name: "a.web.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+			{Type: "TXT", Name: "example.com", Content: "hello", TTL: 3600},
+		}, nil, nil},
+		{"example.com", recordCollection{
+			{Type: "A", Name: "old.example.com", Content: "192.0.2.1", TTL: 60},
+		}, nil, nil, nil},
+		{"example.net", nil, recordCollection{
+			{Type: "A", Name: "www.example.net", Content: "192.0.2.1", TTL: 60},
+		}, nil, nil},
+
+		// Broken rules.
+		{"example.net", recordCollection{
+			{Type: "MX", Name: "example.net", Content: "mail.example.net", Priority: cloudflare.Uint16Ptr(10)},
+		}, nil, nil, []string{"never delete MX records"}},
+		{"example.com", nil, recordCollection{
+			{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 60},
+		}, nil, []string{"(TTL 60 is below 300)"}},
+		{"example.com", nil, nil, recordCollection{
+			{Type: "A", Name: "b.web.example.com", Content: "192.0.2.1", TTL: 300},
+		}, []string{"web must be proxied (not proxied)"}},
+	}
+
+	for i, c := range cases {
+		p := &plan{ZoneName: c.zone, Deletes: c.deletes, Adds: c.adds, Updates: c.updates}
+
+		err := p.checkPolicy()
+		if len(c.broken) == 0 {
+			if err != nil {
+				t.Errorf("%d checkPolicy() failed: %s", i, err.Error())
+			}
+
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%d checkPolicy() didn't fail", i)
+			continue
+		}
+
+		for _, reason := range c.broken {
+			if !strings.Contains(err.Error(), reason) {
+				t.Errorf("%d checkPolicy() returned '%s', expected '%s'", i, err.Error(), reason)
+			}
+		}
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	cases := []string{
+		"rules:\n  - deny: true\n",
+		"rules:\n  - name: nothing\n",
+		"rules:\n  - name: typo\n    deny: true\n    operations: [remove]\n",
+		"rules:\n  - name: unknown\n    mintll: 300\n",
+	}
+
+	for i, in := range cases {
+		path := writeTempFile(t, in)
+
+		_, err := loadPolicy(path)
+		if err == nil {
+			t.Errorf("%d loadPolicy() didn't fail for %s", i, in)
+		}
+
+		os.Remove(path)
+	}
+
+	_, err := loadPolicy("/nonexistent")
+	if err == nil {
+		t.Errorf("loadPolicy() didn't fail for a missing file")
+	}
+}
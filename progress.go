@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// now can be overridden for testing.
+	now = time.Now
+
+	// progressInterval is the minimum time between two progress lines.
+	progressInterval = 2 * time.Second
+)
+
+// progress keeps track of how many changes have been applied and will
+// periodically report it to the user, to make it obvious that we're still
+// working on large zones.
+type progress struct {
+	w     io.Writer
+	verb  string
+	total int
+	done  int
+	last  time.Time
+}
+
+// newProgress will instantiate a new progress reporter writing to w.
+func newProgress(w io.Writer, total int) *progress {
+	return &progress{
+		w:     w,
+		verb:  "applied",
+		total: total,
+		last:  now(),
+	}
+}
+
+// newCounter will instantiate a progress reporter for an unknown number of
+// steps, reporting "<n> <verb>".
+func newCounter(w io.Writer, verb string) *progress {
+	return &progress{
+		w:    w,
+		verb: verb,
+		last: now(),
+	}
+}
+
+// Step should be called every time a change has been applied. A line will be
+// written if progressInterval has passed since the last line, or if all
+// changes have been applied.
+func (p *progress) Step() {
+	p.done++
+
+	t := now()
+	if (p.total == 0 || p.done < p.total) && t.Sub(p.last) < progressInterval {
+		return
+	}
+
+	p.last = t
+
+	if p.total == 0 {
+		fmt.Fprintf(p.w, "%d %s\n", p.done, p.verb)
+		return
+	}
+
+	fmt.Fprintf(p.w, "%d/%d %s\n", p.done, p.total, p.verb)
+}
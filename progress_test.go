@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	clock := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	var b bytes.Buffer
+	p := newProgress(&b, 4)
+
+	// Nothing should be printed before progressInterval has passed.
+	p.Step()
+	if b.Len() != 0 {
+		t.Fatalf("Step() printed too early: [%s]", b.String())
+	}
+
+	clock = clock.Add(progressInterval)
+	p.Step()
+	if b.String() != "2/4 applied\n" {
+		t.Fatalf("Step() printed wrong progress, got [%s]", b.String())
+	}
+
+	b.Reset()
+	p.Step()
+	if b.Len() != 0 {
+		t.Fatalf("Step() printed too early: [%s]", b.String())
+	}
+
+	// The last step must always be reported.
+	p.Step()
+	if b.String() != "4/4 applied\n" {
+		t.Fatalf("Step() failed to report completion, got [%s]", b.String())
+	}
+}
+
+func TestCounter(t *testing.T) {
+	clock := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	var b bytes.Buffer
+	p := newCounter(&b, "records parsed")
+
+	p.Step()
+	p.Step()
+	if b.Len() != 0 {
+		t.Fatalf("Step() printed too early: [%s]", b.String())
+	}
+
+	clock = clock.Add(progressInterval)
+	p.Step()
+	if b.String() != "3 records parsed\n" {
+		t.Fatalf("Step() printed wrong progress, got [%s]", b.String())
+	}
+}
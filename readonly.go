@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// readOnly disables all API calls changing anything when set.
+var readOnly bool
+
+// readOnlyTransport is a http.RoundTripper refusing all requests except GET
+// and HEAD requests.
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, fmt.Errorf("refusing %s %s in read-only mode", req.Method, req.URL.Path)
+	}
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestReadOnlyTransport(t *testing.T) {
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer s.Close()
+
+	readOnly = true
+	defer func() {
+		readOnly = false
+		apiTransport = http.DefaultTransport
+	}()
+
+	err := setupAPITransport()
+	if err != nil {
+		t.Fatalf("setupAPITransport() failed: %s", err.Error())
+	}
+
+	client := &http.Client{Transport: apiTransport}
+
+	cases := []struct {
+		method  string
+		allowed bool
+	}{
+		{"GET", true},
+		{"HEAD", true},
+		{"POST", false},
+		{"PUT", false},
+		{"PATCH", false},
+		{"DELETE", false},
+	}
+
+	for _, c := range cases {
+		before := calls
+
+		req, _ := http.NewRequest(c.method, s.URL+"/zones/1/dns_records", nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if c.allowed && (err != nil || calls != before+1) {
+			t.Errorf("%s was refused in read-only mode: %v", c.method, err)
+		}
+
+		if !c.allowed && (err == nil || calls != before) {
+			t.Errorf("%s was allowed in read-only mode", c.method)
+		}
+	}
+}
+
+func TestSetupCommandFlagsReadOnly(t *testing.T) {
+	os.Setenv("CFZONE_READ_ONLY", "true")
+	defer func() {
+		os.Unsetenv("CFZONE_READ_ONLY")
+		parseArguments([]string{"./test", "zone"})
+		apiTransport = http.DefaultTransport
+	}()
+
+	cfg = config{}
+
+	flagset := flag.NewFlagSet("clone", flag.ContinueOnError)
+	flagset.BoolVar(&yes, "yes", false, "")
+	flagset.Parse([]string{"-yes"})
+
+	setupCommandFlags(flagset)
+
+	if !readOnly || !yes {
+		t.Errorf("setupCommandFlags() set readonly %v and yes %v", readOnly, yes)
+	}
+
+	if _, ok := apiTransport.(*readOnlyTransport); !ok {
+		t.Errorf("setupCommandFlags() didn't set up a read-only transport, got %T", apiTransport)
+	}
+}
@@ -1,263 +1,232 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 
+	"github.com/anderskvist/cfzone/pkg/cfzone"
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/miekg/dns"
 )
 
-type (
-	recordCollection []cloudflare.DNSRecord
+// maxSkippedLines is the maximum number of skipped records listed.
+const maxSkippedLines = 20
 
-	// FilterFunc is used for finding records in a recordCollection. The
-	// function must return true if there is a hit, false otherwise.
-	FilterFunc func(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool
-)
-
-// Clone will make a copy of a recordCollection.
-func (c recordCollection) Clone() recordCollection {
-	result := recordCollection{}
+// recordCollection is used throughout cfzone for lists of DNS records.
+type recordCollection = cfzone.RecordCollection
 
-	result = append(result, c...)
+// zoneSection is a zone read from a zone file, which can hold several zones.
+type zoneSection struct {
+	name    string
+	records recordCollection
 
-	return result
+	// skipped holds the types of the records skipped because of
+	// -skipunsupported.
+	skipped map[string]bool
 }
 
-// Remove will remove the n'th element of c.
-func (c *recordCollection) Remove(n int) {
-	*c = append((*c)[:n], (*c)[n+1:]...)
+// parseZone will parse a BIND style zone file and return the zone name and
+// a recordCollection. Duplicates, records outside the zone, apex CNAME
+// records and TTLs are handled according to the flags. The zone file is read
+// as a stream, and progress is reported for huge zones, but all records are
+// returned in memory.
+func parseZone(r io.Reader) (string, recordCollection, error) {
+	return parseZoneOrigin(r, "")
 }
 
-// Find will search for needle in a recordCollection.
-func (c recordCollection) Find(needle cloudflare.DNSRecord, match FilterFunc) (int, *cloudflare.DNSRecord) {
-	for i, r := range c {
-		if match(r, needle) {
-			return i, &r
-		}
+// parseZoneOrigin is like parseZone, but relative names are relative to
+// origin, and origin is the zone name if the zone file has no SOA record.
+func parseZoneOrigin(r io.Reader, origin string) (string, recordCollection, error) {
+	zones, err := parseZonesOrigin(r, origin)
+	if err != nil {
+		return "", recordCollection{}, err
 	}
 
-	return -1, nil
-}
+	if len(zones) > 1 {
+		return "", recordCollection{}, fmt.Errorf("Expected a single zone, found %d", len(zones))
+	}
 
-// Difference will find all the elements in c not present in remote [c \ remote].
-func (c recordCollection) Difference(remote recordCollection, match FilterFunc) recordCollection {
-	result := recordCollection{}
-	B := remote.Clone()
+	return zones[0].name, zones[0].records, nil
+}
 
-	for _, r := range c {
-		n, _ := B.Find(r, match)
+// parseZones is like parseZone, but for zone files holding several zones.
+// Every SOA record starts a new zone, usually following an $ORIGIN line.
+func parseZones(r io.Reader) ([]zoneSection, error) {
+	return parseZonesOrigin(r, "")
+}
 
-		if n < 0 {
-			result = append(result, r)
-		} else {
-			B.Remove(n)
-		}
+// parseZonesOrigin is like parseZones, with origin used like for
+// parseZoneOrigin.
+func parseZonesOrigin(r io.Reader, origin string) ([]zoneSection, error) {
+	zones := []zoneSection{{}}
+	// Progress is never interesting with -q, not even if there are changes.
+	counterOutput := stderr
+	if quiet {
+		counterOutput = ioutil.Discard
 	}
+	progress := newCounter(counterOutput, "records parsed")
 
-	return result
-}
+	// Only the first skipped records are kept for the warning, a huge zone
+	// could be full of them.
+	skipped := 0
+	lines := []string{}
 
-// Intersect will find the intersection between c and remote [c ∩ remote] with
-// the caveat that the ID from c will be used in the result - while all other
-// properties will be copied from remote.
-// If multiple record from a collection matches, only one will be present in
-// the returned collection.
-func (c recordCollection) Intersect(remote recordCollection, match FilterFunc) recordCollection {
-	// Clone the inputs - we do this to be able to remove from these
-	// collections when a match is found.
-	A := c.Clone()
-	B := remote.Clone()
-	intersect := recordCollection{}
-
-	for i := 0; i < len(A); i++ {
-		found, hit := B.Find(A[i], match)
-		if found >= 0 {
-			// We do this trickery to keep the ID from the left part.
-			record := *hit
-			record.ID = A[i].ID
-
-			intersect = append(intersect, record)
-
-			// To make sure we're not double-spending we remove the found
-			// record from both inputs.
-			A.Remove(i)
-			B.Remove(found)
-
-			// Rewind the index to compensate for the item we just removed.
-			i--
-		}
-	}
+	opts := cfzone.ParseOptions{
+		SkipUnsupported: skipUnsupported,
+		Origin:          origin,
+		DefaultTTL:      defaultTTL,
+		Skipped: func(rr dns.RR) {
+			skipped++
 
-	return intersect
-}
+			zone := &zones[len(zones)-1]
+			if zone.skipped == nil {
+				zone.skipped = map[string]bool{}
+			}
+			zone.skipped[dns.TypeToString[rr.Header().Rrtype]] = true
 
-// Fprint will output a textual representation of a recordCollection resembling
-// the BIND zone file format.
-func (c recordCollection) Fprint(w io.Writer) {
-	maxName := 0
-	for _, r := range c {
-		if len(r.Name) > maxName {
-			maxName = len(r.Name)
-		}
+			if len(lines) < maxSkippedLines {
+				lines = append(lines, strings.Join(strings.Fields(rr.String()), " "))
+			}
+		},
+		ZoneStart: func(zoneName string) {
+			// Records before the first SOA record belong to the
+			// first zone.
+			if zones[len(zones)-1].name != "" {
+				zones = append(zones, zoneSection{})
+			}
+
+			zones[len(zones)-1].name = zoneName
+		},
 	}
 
-	for _, r := range c {
-		name := r.Name + "." + strings.Repeat(" ", maxName-len(r.Name))
+	zoneName, _, err := cfzone.ParseZoneFunc(r, opts, func(record cloudflare.DNSRecord) error {
+		zones[len(zones)-1].records = append(zones[len(zones)-1].records, record)
+		progress.Step()
 
-		proxied := ""
-		if r.Proxied {
-			proxied = " ; PROXIED"
-		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		fmt.Fprintf(w, "%s %d %-8s %s%s\n", name, r.TTL, "IN "+r.Type, r.Content, proxied)
+	if zones[0].name == "" {
+		zones[0].name = zoneName
 	}
-}
 
-// parseZone will parse a BIND style zone file and return the zone name and
-// a recordCollection.
-func parseZone(r io.Reader) (string, recordCollection, error) {
-	var zoneName string
-	records := recordCollection{}
+	if skipped > len(lines) {
+		lines = append(lines, fmt.Sprintf("(%d more)", skipped-len(lines)))
+	}
 
-	for t := range dns.ParseZone(r, "", "") {
-		if t.Error != nil {
-			return "", recordCollection{}, t.Error
-		}
+	if skipped > 0 {
+		warnf("Skipped %d unsupported records:\n  %s", skipped, strings.Join(lines, "\n  "))
+	}
 
-		// Search for zonename while we're at it.
-		soa, found := t.RR.(*dns.SOA)
-		if found {
-			zoneName = strings.Trim(soa.Header().Name, ".")
+	seen := map[string]bool{}
+	for i, zone := range zones {
+		if seen[zone.name] {
+			return nil, fmt.Errorf("Zone %s found more than once", zone.name)
 		}
+		seen[zone.name] = true
 
-		r, err := newRecord(t)
+		zones[i].records, err = checkZone(zone.name, zone.records)
 		if err != nil {
-			return "", recordCollection{}, err
-		}
+			if len(zones) > 1 {
+				return nil, fmt.Errorf("%s: %s", zone.name, err.Error())
+			}
 
-		if r != nil {
-			records = append(records, *r)
+			return nil, err
 		}
 	}
 
-	if zoneName == "" {
-		return "", recordCollection{}, errors.New("Zone name not found")
-	}
-
-	return zoneName, records, nil
+	return zones, nil
 }
 
-// newRecord will instantiate a new cloudflare-compatible DNS record based on
-// a token from miekg/dns..
-// If the TTL has a value of 1 Proxied will be set to true in the resulting
-// DNSRecord mimicking Cloudflare internal TTL's.
-// A TTL of 0 will result in "automatic" TTL.
-func newRecord(in *dns.Token) (*cloudflare.DNSRecord, error) {
-	record := &cloudflare.DNSRecord{
-		Name: strings.Trim(in.Header().Name, "."),
-		TTL:  int(in.Header().Ttl),
+// checkZone will handle duplicates, records outside the zone, apex CNAME
+// records and TTLs in the records of zoneName.
+func checkZone(zoneName string, records recordCollection) (recordCollection, error) {
+	if records == nil {
+		records = recordCollection{}
 	}
 
-	if record.TTL == 1 {
-		record.Proxied = true
+	records, err := dedupe(records)
+	if err != nil {
+		return nil, err
 	}
 
-	switch in.RR.(type) {
-	case *dns.A:
-		a := in.RR.(*dns.A)
-		record.Content = a.A.String()
-		record.Type = "A"
-		return record, nil
-
-	case *dns.AAAA:
-		a := in.RR.(*dns.AAAA)
-		record.Content = a.AAAA.String()
-		record.Type = "AAAA"
-		return record, nil
-
-	case *dns.CNAME:
-		cname := in.RR.(*dns.CNAME)
-		record.Content = cname.Target
-		record.Type = "CNAME"
-
-		// CloudFlare does not use the "FQDN-dot". We remove it.
-		if strings.HasSuffix(record.Content, ".") {
-			record.Content = record.Content[:len(record.Content)-1]
-		}
-		return record, nil
-
-	case *dns.MX:
-		mx := in.RR.(*dns.MX)
-		record.Content = strings.Trim(mx.Mx, ".")
-		record.Priority = int(mx.Preference)
-		record.Type = "MX"
-		return record, nil
-
-	case *dns.TXT:
-		txt := in.RR.(*dns.TXT)
-		if len(txt.Txt) > 0 {
-			record.Content = txt.Txt[0]
-		}
-		record.Type = "TXT"
-		return record, nil
+	records, err = handleOutOfZone(records, zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err = handleApexCNAME(records, zoneName)
+	if err != nil {
+		return nil, err
+	}
 
-	case *dns.NS, *dns.SOA:
-		// We silently ignore NS and SOA because Cloudflare does not allow
-		// the user to change nameservers and SOA doesn't make sense.
-		return nil, nil
+	err = checkRecordTTLs(records)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("Record type %T is not supported", in.RR)
+	return records, nil
 }
 
-// FullMatch will do matching between two DNS records while ignoring CF specific
-// details.
-func FullMatch(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
-	if a.Type != b.Type {
-		return false
-	}
+// defaultTTL returns the TTL of records of type recordType without one in
+// zoneName, from the default_ttl and proxied options of the zone.
+func defaultTTL(zoneName string, recordType string) int {
+	z := cfg.Zones[zoneName]
 
-	if a.Name != b.Name {
-		return false
+	if z.Proxied && cfzone.Proxiable(recordType) {
+		return 1
 	}
 
-	if a.Proxied != b.Proxied {
-		return false
-	}
+	return z.DefaultTTL
+}
 
-	if a.TTL != b.TTL {
-		return false
-	}
+// checkRecordTTLs will check the TTLs of all records in c. Problems are
+// aggregated into a single warning or error.
+func checkRecordTTLs(c recordCollection) error {
+	errs := []string{}
+	warnings := []string{}
 
-	switch a.Type {
-	case "A", "AAAA", "CNAME", "TXT":
-		if a.Content == b.Content {
-			return true
-		}
+	for _, r := range c {
+		severity, message := ttlProblem(int64(r.TTL))
+
+		switch severity {
+		case severityError:
+			errs = append(errs, fmt.Sprintf("%s: %s", r.Name, message))
 
-	case "MX":
-		if a.Content == b.Content && a.Priority == b.Priority {
-			return true
+		case severityWarning:
+			warnings = append(warnings, fmt.Sprintf("%s: %s", r.Name, message))
 		}
 	}
 
-	return false
-}
+	if len(warnings) > 0 {
+		warnf("TTL warnings:\n  %s", strings.Join(warnings, "\n  "))
+	}
 
-// Updatable will return true if it makes sense to update (instead of
-// add/delete) from a to b or b to a.
-func Updatable(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
-	if a.Type != b.Type {
-		return false
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid TTLs:\n  %s", strings.Join(errs, "\n  "))
 	}
 
-	if a.Name != b.Name {
-		return false
+	return nil
+}
+
+// dedupe will remove exact duplicates from c with a warning, or return an
+// error if -duplicates is "fail". Cloudflare refuses to create identical
+// records, and we would fail halfway through applying.
+func dedupe(c recordCollection) (recordCollection, error) {
+	result, duplicated := c.Dedupe()
+
+	for _, r := range duplicated {
+		if duplicates == "fail" {
+			return nil, fmt.Errorf("Duplicate record: %s", recordLine(r))
+		}
+
+		warnf("Ignoring duplicate record: %s", recordLine(r))
 	}
 
-	return true
+	return result, nil
 }
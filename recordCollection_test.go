@@ -10,384 +10,199 @@ import (
 	cloudflare "github.com/cloudflare/cloudflare-go"
 )
 
-func TestClone(t *testing.T) {
-	a := recordCollection{}
-	b := a.Clone()
-	if !reflect.DeepEqual(a, b) {
-		t.Errorf("Clone() failed to clone an empty recordCollection")
-	}
+func zoneString(c recordCollection) string {
+	var b bytes.Buffer
 
-	a = recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"},
-		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.10"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.11"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.13"},
-		cloudflare.DNSRecord{Type: "AAAA", Name: "a1", Content: "::1"},
-		cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: 10},
-	}
-	b = a.Clone()
-	if !reflect.DeepEqual(a, b) {
-		t.Errorf("Clone() failed to clone a recordCollection")
-	}
+	w := bufio.NewWriter(&b)
+	c.Fprint(w)
+	w.Flush()
+
+	return b.String()
 }
 
-func TestRemove(t *testing.T) {
-	in := recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
-		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2", TTL: 200},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+func TestDedupe(t *testing.T) {
+	a := cloudflare.DNSRecord{Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: 300}
+	b := cloudflare.DNSRecord{Type: "A", Name: "example.com", Content: "192.0.2.2", TTL: 300}
+	c := cloudflare.DNSRecord{Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: 600}
+
+	buf, restore := captureStderr(0)
+	defer restore()
+
+	result, err := dedupe(recordCollection{a, b, a, c})
+	if err != nil {
+		t.Fatalf("dedupe() returned error: %s", err.Error())
 	}
 
-	a := in.Clone()
-	a.Remove(1)
-	if !reflect.DeepEqual(a, recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
-	}) {
-		t.Errorf("Remove() did not return expected result")
+	if !reflect.DeepEqual(result, recordCollection{a, b}) {
+		t.Errorf("dedupe() returned wrong records:\n%s", zoneString(result))
 	}
 
-	a2 := in.Clone()
-	a2.Remove(1)
-	if !reflect.DeepEqual(a2, recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
-	}) {
-		t.Errorf("Remove() did not return expected result")
+	if !strings.Contains(buf.String(), "Ignoring duplicate record: example.com. 300 IN A     192.0.2.1") {
+		t.Errorf("dedupe() did not warn about duplicate: %s", buf.String())
 	}
-}
 
-func TestFindEmpty(t *testing.T) {
-	c := recordCollection{}
+	duplicates = "fail"
+	defer func() { duplicates = "warn" }()
 
-	n, r := c.Find(cloudflare.DNSRecord{}, FullMatch)
-	if n >= 0 {
-		t.Errorf("Find() returned a non-negative value from an empty collection")
+	_, err = dedupe(recordCollection{a, b, a})
+	if err == nil {
+		t.Errorf("dedupe() did not fail on duplicate")
 	}
 
-	if r != nil {
-		t.Errorf("Find() returned a record from an empty collection")
+	_, err = dedupe(recordCollection{a, b, c})
+	if err == nil {
+		t.Errorf("dedupe() did not fail on duplicate with another TTL")
 	}
-}
 
-func TestFullMatch(t *testing.T) {
-	cases := []struct {
-		a        cloudflare.DNSRecord
-		b        cloudflare.DNSRecord
-		expected bool
-	}{
-		{cloudflare.DNSRecord{Type: "A"}, cloudflare.DNSRecord{Type: "A"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "ab"}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 1}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a"}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, false},
+	_, err = dedupe(recordCollection{a, b})
+	if err != nil {
+		t.Errorf("dedupe() failed without duplicates: %s", err.Error())
 	}
+}
 
-	for i, in := range cases {
-		result := FullMatch(in.a, in.b)
+func TestCheckTTLs(t *testing.T) {
+	buf, restore := captureStderr(0)
+	defer restore()
 
-		if result != in.expected {
-			t.Errorf("%d: match() Returned unexpected result for %v, %v: %v (expected %v)", i, in.a, in.b, result, in.expected)
-		}
+	err := checkRecordTTLs(recordCollection{{Name: "a", TTL: 0}, {Name: "b", TTL: 1}, {Name: "c", TTL: 60}, {Name: "d", TTL: 86400}})
+	if err != nil || buf.Len() != 0 {
+		t.Errorf("checkRecordTTLs() complained about sensible TTLs: %v %s", err, buf.String())
 	}
-}
 
-func TestUpdatable(t *testing.T) {
-	cases := []struct {
-		a        cloudflare.DNSRecord
-		b        cloudflare.DNSRecord
-		expected bool
-	}{
-		{cloudflare.DNSRecord{Type: "A"}, cloudflare.DNSRecord{Type: "A"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "ab"}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 1}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, true},
-		{cloudflare.DNSRecord{Type: "CNAME", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, false},
+	err = checkRecordTTLs(recordCollection{{Name: "a", TTL: 30}, {Name: "b", TTL: 10}})
+	if err != nil {
+		t.Errorf("checkRecordTTLs() returned error for low TTLs: %s", err.Error())
 	}
 
-	for i, in := range cases {
-		result := Updatable(in.a, in.b)
+	if buf.String() != "TTL warnings:\n  a: TTL 30 will be raised to 60 by Cloudflare\n  b: TTL 10 will be raised to 60 by Cloudflare\n" {
+		t.Errorf("checkRecordTTLs() wrote wrong warning [%s]", buf.String())
+	}
 
-		if result != in.expected {
-			t.Errorf("%d: match() Returned unexpected result for %v, %v: %v (expected %v)", i, in.a, in.b, result, in.expected)
-		}
+	err = checkRecordTTLs(recordCollection{{Name: "a", TTL: 3000000000}, {Name: "b", TTL: -1}})
+	if err == nil || err.Error() != "Invalid TTLs:\n  a: TTL 3000000000 is above the maximum of 2147483647\n  b: Negative TTL -1" {
+		t.Errorf("checkRecordTTLs() returned wrong error: %v", err)
 	}
 }
 
-func TestFind(t *testing.T) {
-	c := recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"},
-		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.10"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.11"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.13"},
-		cloudflare.DNSRecord{Type: "AAAA", Name: "a1", Content: "::1"},
-		cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: 10},
-	}
+func TestParseZoneSkipUnsupported(t *testing.T) {
+	defer func() {
+		skipUnsupported = false
+		skippedTypes = map[string]map[string]bool{}
+	}()
 
-	cases := []struct {
-		needle cloudflare.DNSRecord
-		n      int
-		r      *cloudflare.DNSRecord
-	}{
-		{cloudflare.DNSRecord{Type: "A", Name: "a1"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.2"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &cloudflare.DNSRecord{}},
-		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &cloudflare.DNSRecord{}},
-		{cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"}, 5, &cloudflare.DNSRecord{}},
-		{cloudflare.DNSRecord{Type: "MX", Name: "a1", Content: "127.0.0.12"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "127.0.0.12"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "::1"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: 10}, 8, &cloudflare.DNSRecord{}},
-	}
+	zone := `$ORIGIN example.com.
+@ 86400 IN SOA ns1.example.com. hostmaster.example.com. 2015071700 86400 7200 604800 86400
+test1 1800 IN A 127.0.0.1
+loc1 IN LOC 57 2 59.173 N 9 56 42.07 E 0m 10m 100m 10m
+_sip._tcp 3600 IN SRV 10 60 5060 sip.example.com.
+`
 
-	for i, in := range cases {
-		n, r := c.Find(in.needle, FullMatch)
-		if n != in.n {
-			t.Errorf("%d: Find() Returned unexpected n: %d (expected %d)", i, n, in.n)
-		}
+	buf, restore := captureStderr(0)
+	defer restore()
 
-		if (r == nil) != (in.r == nil) {
-			t.Errorf("%d: Find() returned unexpected pointer: %p (expected %p)", i, r, in.r)
-		}
+	skipUnsupported = true
+	zones, err := parseZones(strings.NewReader(zone + "$ORIGIN example.org.\n@ 86400 IN SOA ns1.example.org. hostmaster.example.org. 2015071700 86400 7200 604800 86400\n"))
+	if err == nil {
+		zones, err = expandZones("test", zones)
 	}
-}
-
-func TestDifference(t *testing.T) {
-	empty := recordCollection{}
-	a1 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.1"}
-	a2 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.2"}
-	aaaa1 := cloudflare.DNSRecord{Type: "AAAA", Name: "test1", Content: "::1"}
-	cases := []struct {
-		a        recordCollection
-		b        recordCollection
-		expected recordCollection
-	}{
-		{empty, empty, empty},
-		{empty, recordCollection{a1}, empty},
-		{recordCollection{a1}, empty, recordCollection{a1}},
-		{empty, recordCollection{aaaa1}, empty},
-		{recordCollection{aaaa1}, empty, recordCollection{aaaa1}},
-		{recordCollection{aaaa1}, recordCollection{a1}, recordCollection{aaaa1}},
-		{recordCollection{a1, a2}, recordCollection{a1}, recordCollection{a2}},
-		{recordCollection{a1, a2, a2}, recordCollection{a1}, recordCollection{a2, a2}},
+	if err != nil {
+		t.Fatalf("parseZone() failed with -skipunsupported: %s", err.Error())
 	}
+	records := zones[0].records
 
-	for i, in := range cases {
-		result := in.a.Difference(in.b, FullMatch)
-		if !reflect.DeepEqual(in.expected, result) {
-			t.Errorf("%d: aOnly != in.aOnly, Got %+v, expcted %+v", i, result, in.expected)
-		}
+	if len(records) != 1 || records[0].Name != "test1.example.com" {
+		t.Errorf("parseZone() returned wrong records:\n%s", zoneString(records))
 	}
-}
 
-func TestIntersect(t *testing.T) {
-	empty := recordCollection{}
-	a1 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.1"}
-	aaaa1 := cloudflare.DNSRecord{Type: "AAAA", Name: "test1", Content: "::1"}
-	cases := []struct {
-		a        recordCollection
-		b        recordCollection
-		expected recordCollection
-	}{
-		{empty, empty, empty},
-		{empty, recordCollection{a1}, empty},
-		{recordCollection{a1}, empty, empty},
-		{recordCollection{a1}, recordCollection{a1}, recordCollection{a1}},
-		{empty, recordCollection{aaaa1}, empty},
+	expected := "Skipped 2 unsupported records:\n  loc1.example.com. 1800 IN LOC 57 02 59.173 N 09 56 42.069 E 0m 10m 100m 10m\n  _sip._tcp.example.com. 3600 IN SRV 10 60 5060 sip.example.com.\n"
+	if buf.String() != expected {
+		t.Errorf("parseZone() wrote wrong warning [%s], expected [%s]", buf.String(), expected)
 	}
 
-	for i, in := range cases {
-		result := in.a.Intersect(in.b, FullMatch)
-		if !reflect.DeepEqual(in.expected, result) {
-			t.Errorf("%d: aOnly != in.aOnly, Got %+v, expcted %+v", i, result, in.expected)
-		}
+	existing := recordCollection{
+		{Type: "A", Name: "test1.example.com", Content: "127.0.0.1"},
+		{Type: "SRV", Name: "_sip._tcp.example.com", Content: "10 60 5060 sip.example.com"},
+		{Type: "LOC", Name: "loc1.example.com", Content: "57 2 59.173 N 9 56 42.07 E 0 10 100 10"},
+		{Type: "CAA", Name: "example.com", Content: "0 issue \"letsencrypt.org\""},
 	}
-}
 
-func TestFprint(t *testing.T) {
-	c := recordCollection{
-		cloudflare.DNSRecord{Name: "a1", TTL: 0, Type: "A", Content: "127.0.0.1"},
-		cloudflare.DNSRecord{Name: "a2", TTL: 1, Type: "A", Content: "127.0.0.2", Proxied: true},
-		cloudflare.DNSRecord{Name: "aaaa1", TTL: 0, Type: "AAAA", Content: "::1"},
+	kept := existing.Filter(scope("example.com")...)
+	if len(kept) != 2 || kept[0].Type != "A" || kept[1].Type != "CAA" {
+		t.Errorf("scope() didn't leave skipped types alone:\n%s", zoneString(kept))
 	}
-	expected := `a1.    0 IN A     127.0.0.1
-a2.    1 IN A     127.0.0.2 ; PROXIED
-aaaa1. 0 IN AAAA  ::1
-`
 
-	var b bytes.Buffer
-	w := bufio.NewWriter(&b)
+	// Types skipped in one zone must not leave them alone in the others.
+	kept = existing.Filter(scope("example.org")...)
+	if len(kept) != 4 {
+		t.Errorf("scope() left types skipped in another zone alone:\n%s", zoneString(kept))
+	}
 
-	c.Fprint(w)
-	w.Flush()
+	// Reading the zone again without the records must manage the types
+	// again.
+	zones, err = parseZones(strings.NewReader("$ORIGIN example.com.\n@ 86400 IN SOA ns1.example.com. hostmaster.example.com. 2015071700 86400 7200 604800 86400\n"))
+	if err == nil {
+		_, err = expandZones("test", zones)
+	}
+	if err != nil {
+		t.Fatalf("parseZones() failed: %s", err.Error())
+	}
 
-	if b.String() != expected {
-		t.Fatalf("Print() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	kept = existing.Filter(scope("example.com")...)
+	if len(kept) != 4 {
+		t.Errorf("scope() left types alone no longer skipped:\n%s", zoneString(kept))
 	}
 }
 
-func TestParseZone(t *testing.T) {
-	zone := `
-$ORIGIN example.com.
-$TTL 3600
-
-@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
-          2015071700 ; serial
-          86400 ; refresh
-          7200 ; retry
-          604800 ; expire
-          86400 ; minimum
-          )
-
-@     1800     IN NS    ns1.example.com.
-@     1800     IN NS    ns2.example.com.
-@     1800     IN NS    ns3.example.com.
-@     1800     IN MX    10 mail10.example.com.
-test1 1800 IN A 127.0.0.1
-test2 1800 IN CNAME test1
-test3 1800 IN AAAA ::1
-test4 1 IN A 127.0.0.4
-@     1800 IN TXT "v=spf1 include:spf.example.com -all"
+func TestParseZoneDefaultTTL(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.1
+mail IN MX 10 mx.example.net.
+api 600 IN A 192.0.2.2
 `
 
-	parsed := recordCollection{
-		cloudflare.DNSRecord{
-			Type:     "MX",
-			Priority: 10,
-			Name:     "example.com",
-			Content:  "mail10.example.com",
-			TTL:      1800,
-		},
-		cloudflare.DNSRecord{
-			Type:    "A",
-			Name:    "test1.example.com",
-			Content: "127.0.0.1",
-			TTL:     1800,
-		},
-		cloudflare.DNSRecord{
-			Type:    "CNAME",
-			Name:    "test2.example.com",
-			Content: "test1.example.com",
-			TTL:     1800,
-		},
-		cloudflare.DNSRecord{
-			Type:    "AAAA",
-			Name:    "test3.example.com",
-			Content: "::1",
-			TTL:     1800,
-		},
-		cloudflare.DNSRecord{
-			Type:    "A",
-			Name:    "test4.example.com",
-			Content: "127.0.0.4",
-			TTL:     1,
-			Proxied: true,
-		},
-		cloudflare.DNSRecord{
-			Type:    "TXT",
-			Name:    "example.com",
-			Content: "v=spf1 include:spf.example.com -all",
-			TTL:     1800,
-		},
+	_, _, err := parseZone(strings.NewReader(zone))
+	if err == nil || !strings.Contains(err.Error(), "www.example.com has no TTL") {
+		t.Errorf("parseZone() didn't fail for records without a TTL: %v", err)
 	}
 
-	cases := []struct {
-		zone         string
-		expectedName string
-		expected     recordCollection
-		err          bool
-	}{
-		{"", "", recordCollection{}, true},
-		{"broken zone", "", recordCollection{}, true},
-		{zone, "example.com", parsed, false},
-	}
-
-	for i, in := range cases {
-		r := strings.NewReader(in.zone)
-		zoneName, records, err := parseZone(r)
-		if in.err && err == nil {
-			t.Fatalf("%d: parseZone() failed to error on [%s]", i, in.zone)
-		}
+	defer func() { cfg.Zones = nil }()
 
-		if !in.err && err != nil {
-			t.Fatalf("%d: parseZone() returned error on [%s]: %s", i, in.zone, err.Error())
-		}
-
-		if zoneName != in.expectedName {
-			t.Errorf("%d: parseZone() rturned wrong zone name for [%s], got %s, expected %s", i, in.zone, zoneName, in.expectedName)
-		}
-
-		if !reflect.DeepEqual(in.expected, records) {
-			t.Errorf("%d: parseZone() returned wrong zone for [%s], got:\n%s, expected:\n%s", i, in.zone, zoneString(records), zoneString(in.expected))
-		}
+	type expectation struct {
+		ttl     int
+		proxied bool
 	}
-}
-
-func TestParseZoneFail(t *testing.T) {
-	cases := []string{`$ORIGIN example.com.
 
-@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
-          2015071700
-          86400
-          7200
-          604800
-          86400
-)
-test1 1800 IN A 127.0.0.1
-loc1 IN LOC 57 2 59.173 N 9 56 42.07 E 0m 10m 100m 10m
-`, `@    86400    IN SOA ns1.example.com. hostmaster.example.com. (
-	  2015071700
-	  86400
-	  7200
-	  604800
-	  86400
-)
-test2 1800 IN A 127.0.0.2
-`,
+	cases := []struct {
+		zone     zoneConfig
+		expected []expectation
+	}{
+		{zoneConfig{DefaultTTL: 1}, []expectation{{1, true}, {1, false}, {600, false}}},
+		{zoneConfig{DefaultTTL: 300}, []expectation{{300, false}, {300, false}, {600, false}}},
+		{zoneConfig{DefaultTTL: 300, Proxied: true}, []expectation{{1, true}, {300, false}, {600, false}}},
 	}
 
-	for i, in := range cases {
-		r := strings.NewReader(in)
-		zoneName, records, err := parseZone(r)
-
-		if zoneName != "" {
-			t.Errorf("%d parseZone() returned a zonename for a broken zone: %s", i, zoneName)
-		}
+	for i, c := range cases {
+		cfg.Zones = map[string]zoneConfig{"example.com": c.zone}
 
-		if len(records) > 0 {
-			t.Errorf("%d: parseZone() returned record for a broken zone", i)
+		_, records, err := parseZone(strings.NewReader(zone))
+		if err != nil {
+			t.Fatalf("%d parseZone() failed: %s", i, err.Error())
 		}
 
-		if err == nil {
-			t.Errorf("%d: parseZone() failed to err on broken zone", i)
+		for j, e := range c.expected {
+			r := records[j]
+			if r.TTL != e.ttl || (r.Proxied != nil && *r.Proxied) != e.proxied {
+				t.Errorf("%d parseZone() returned %s with TTL %d, expected %d (proxied %v)", i, r.Name, r.TTL, e.ttl, e.proxied)
+			}
 		}
-
-		parseZone(r)
 	}
-}
-
-func zoneString(c recordCollection) string {
-	var b bytes.Buffer
 
-	w := bufio.NewWriter(&b)
-	c.Fprint(w)
-	w.Flush()
+	// MX records can't be proxied, and need a default_ttl.
+	cfg.Zones = map[string]zoneConfig{"example.com": {Proxied: true}}
 
-	return b.String()
+	_, _, err = parseZone(strings.NewReader(zone))
+	if err == nil || !strings.Contains(err.Error(), "mail.example.com has no TTL") {
+		t.Errorf("parseZone() didn't fail for MX record without a TTL: %v", err)
+	}
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+var (
+	// recordAPIPath is a file to record all API requests and responses to.
+	recordAPIPath = ""
+
+	// replayAPIPath is a file with recorded API responses to replay instead
+	// of contacting the API.
+	replayAPIPath = ""
+
+	// apiTransport is used for all API requests. It's replaced when
+	// recording or replaying.
+	apiTransport = http.DefaultTransport
+)
+
+// exchange is a recorded API request and its response. Headers are left out
+// to keep credentials out of fixtures.
+type exchange struct {
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Body     string `json:"body,omitempty"`
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+// readBody will read and replace the body of req, returning the content.
+func readBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	return string(b), nil
+}
+
+// recordingTransport is a http.RoundTripper recording all exchanges to a
+// file. The file is rewritten after each exchange, to keep what was recorded
+// if cfzone crashes.
+type recordingTransport struct {
+	next http.RoundTripper
+	path string
+
+	lock      sync.Mutex
+	exchanges []exchange
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.exchanges = append(t.exchanges, exchange{
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Body:     redact(body),
+		Status:   resp.StatusCode,
+		Response: redact(string(b)),
+	})
+
+	out, _ := json.MarshalIndent(t.exchanges, "", "  ")
+
+	err = ioutil.WriteFile(t.path, out, 0600)
+	if err != nil {
+		errorf("Error recording API response: %s", err.Error())
+	}
+
+	return resp, nil
+}
+
+// replayingTransport is a http.RoundTripper answering requests with recorded
+// responses. Each recorded exchange is used once, in the order recorded.
+type replayingTransport struct {
+	lock      sync.Mutex
+	exchanges []exchange
+	used      []bool
+}
+
+// loadReplay returns a replayingTransport for the recorded exchanges in the
+// file at path.
+func loadReplay(path string) (*replayingTransport, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &replayingTransport{}
+
+	err = json.Unmarshal(b, &t.exchanges)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+	}
+
+	t.used = make([]bool, len(t.exchanges))
+
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i, e := range t.exchanges {
+		if t.used[i] || e.Method != req.Method || e.URL != req.URL.String() || e.Body != redact(body) {
+			continue
+		}
+
+		t.used[i] = true
+
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+			StatusCode: e.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(e.Response))),
+			Request:    req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL.String())
+}
+
+// setupAPITransport will set apiTransport according to -recordapi,
+// -replayapi, -readonly and the TLS options.
+func setupAPITransport() error {
+	err := setupBaseTransport()
+	if err == nil && readOnly {
+		apiTransport = &readOnlyTransport{next: apiTransport}
+	}
+
+	return err
+}
+
+// setupBaseTransport will set apiTransport according to -recordapi,
+// -replayapi and the TLS options.
+func setupBaseTransport() error {
+	if replayAPIPath != "" {
+		t, err := loadReplay(replayAPIPath)
+		if err != nil {
+			return err
+		}
+
+		apiTransport = t
+
+		return nil
+	}
+
+	base, err := baseTransport()
+	if err != nil {
+		return err
+	}
+
+	apiTransport = base
+
+	if recordAPIPath != "" {
+		apiTransport = &recordingTransport{next: base, path: recordAPIPath}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(b)))
+	}))
+	defer s.Close()
+
+	path := filepath.Join(dir, "fixture.json")
+
+	do := func(client *http.Client, method string, body string) (string, error) {
+		req, _ := http.NewRequest(method, s.URL+"/zones?name=example.com", strings.NewReader(body))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Got status %d, expected %d", resp.StatusCode, http.StatusCreated)
+		}
+
+		b, _ := ioutil.ReadAll(resp.Body)
+
+		return string(b), nil
+	}
+
+	recorder := &http.Client{Transport: &recordingTransport{next: http.DefaultTransport, path: path}}
+
+	first, _ := do(recorder, "POST", "one")
+	second, _ := do(recorder, "POST", "two")
+
+	if calls != 2 {
+		t.Fatalf("Recording made %d calls, expected 2", calls)
+	}
+
+	replayer, err := loadReplay(path)
+	if err != nil {
+		t.Fatalf("loadReplay() failed: %s", err.Error())
+	}
+
+	client := &http.Client{Transport: replayer}
+
+	// Replies are matched by request, not only by order.
+	got, err := do(client, "POST", "two")
+	if err != nil || got != second {
+		t.Errorf("Replay returned [%s], expected [%s]", got, second)
+	}
+
+	got, err = do(client, "POST", "one")
+	if err != nil || got != first {
+		t.Errorf("Replay returned [%s], expected [%s]", got, first)
+	}
+
+	if calls != 2 {
+		t.Errorf("Replaying made calls to the API")
+	}
+
+	_, err = do(client, "POST", "one")
+	if err == nil {
+		t.Errorf("Replay didn't fail when out of responses")
+	}
+}
+
+func TestRecordRedacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	realKey := apiKey
+	apiKey = "secret-key"
+	defer func() { apiKey = realKey }()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("key is secret-key"))
+	}))
+	defer s.Close()
+
+	path := filepath.Join(dir, "fixture.json")
+	client := &http.Client{Transport: &recordingTransport{next: http.DefaultTransport, path: path}}
+
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	b, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(b), "secret-key") {
+		t.Errorf("Recording contains secret: %s", b)
+	}
+}
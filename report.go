@@ -0,0 +1,148 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+var (
+	// reportPath is the path of a HTML report of all synced zones. Empty
+	// disables the report.
+	reportPath = ""
+
+	// reportZones holds the latest result for each zone in the order they
+	// were first synced.
+	reportZones []reportZone
+)
+
+// reportZone is a zone in the HTML report.
+type reportZone struct {
+	Name    string
+	Status  string
+	Error   string
+	Changes []reportChange
+}
+
+// reportChange is a single change in the HTML report.
+type reportChange struct {
+	Action string
+	Record cloudflare.DNSRecord
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"priority": cloudflare.Uint16,
+	"proxied":  cloudflare.Bool,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cfzone report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #eee; }
+.delete { background: #fdd; }
+.add { background: #dfd; }
+.update { background: #ffd; }
+.failed { color: #c00; }
+</style>
+</head>
+<body>
+<h1>cfzone report</h1>
+<p>Generated {{.Generated}} by {{.Actor}}.</p>
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+{{range .Zones}}
+<h2>{{.Name}}: <span class="{{.Status}}">{{.Status}}</span></h2>
+{{if .Error}}<p class="failed">{{.Error}}</p>{{end}}
+{{if .Changes}}
+<table>
+<tr><th>Change</th><th>Name</th><th>TTL</th><th>Type</th><th>Content</th><th>Proxied</th></tr>
+{{range .Changes}}<tr class="{{.Action}}"><td>{{.Action}}</td><td>{{.Record.Name}}</td><td>{{.Record.TTL}}</td><td>{{.Record.Type}}</td><td>{{if eq .Record.Type "MX"}}{{priority .Record.Priority}} {{end}}{{.Record.Content}}</td><td>{{if proxied .Record.Proxied}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No changes.</p>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// addReport will record r in the report, replacing any earlier result for
+// the same zone.
+func addReport(r *result) {
+	z := reportZone{Name: r.ZoneName}
+
+	switch {
+	case r.Err == errAborted:
+		z.Status = "pending"
+
+	case r.Err != nil:
+		z.Status = "failed"
+		z.Error = redact(r.Err.Error())
+
+	case r.Plan == nil || r.Plan.NumChanges() == 0:
+		z.Status = "unchanged"
+
+	default:
+		z.Status = "applied"
+	}
+
+	z.Changes = reportChanges(r.Plan)
+
+	for i := range reportZones {
+		if reportZones[i].Name == z.Name {
+			reportZones[i] = z
+			return
+		}
+	}
+
+	reportZones = append(reportZones, z)
+}
+
+// reportChanges returns the changes in p for the report. p may be nil.
+func reportChanges(p *plan) []reportChange {
+	var changes []reportChange
+
+	if p == nil {
+		return changes
+	}
+
+	for _, c := range []struct {
+		action  string
+		records recordCollection
+	}{
+		{"delete", p.Deletes},
+		{"add", p.Adds},
+		{"update", p.Updates},
+	} {
+		for _, record := range c.records {
+			changes = append(changes, reportChange{c.action, record})
+		}
+	}
+
+	return changes
+}
+
+// writeReport will write the HTML report to reportPath.
+func writeReport() error {
+	f, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return reportTemplate.Execute(f, struct {
+		Generated string
+		Actor     string
+		Summary   string
+		Zones     []reportZone
+	}{
+		Generated: now().UTC().Format(time.RFC1123),
+		Actor:     currentActor(),
+		Zones:     reportZones,
+	})
+}
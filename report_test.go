@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestAddReport(t *testing.T) {
+	defer func() { reportZones = nil }()
+
+	p := &plan{
+		Deletes: recordCollection{{Type: "A", Name: "old.example.com"}},
+		Adds:    recordCollection{{Type: "A", Name: "new.example.com"}},
+	}
+
+	cases := []struct {
+		r      *result
+		status string
+	}{
+		{&result{ZoneName: "a.example", Plan: p}, "applied"},
+		{&result{ZoneName: "b.example", Plan: p, Err: errAborted}, "pending"},
+		{&result{ZoneName: "c.example", Err: errors.New("broken")}, "failed"},
+		{&result{ZoneName: "d.example", Plan: &plan{}}, "unchanged"},
+	}
+
+	for i, c := range cases {
+		addReport(c.r)
+
+		z := reportZones[len(reportZones)-1]
+		if z.Name != c.r.ZoneName || z.Status != c.status {
+			t.Errorf("%d: addReport() added %+v, expected status %s", i, z, c.status)
+		}
+	}
+
+	if len(reportZones[0].Changes) != 2 || reportZones[0].Changes[0].Action != "delete" || reportZones[0].Changes[1].Action != "add" {
+		t.Errorf("addReport() recorded wrong changes: %+v", reportZones[0].Changes)
+	}
+
+	// A new result for a zone should replace the old one.
+	addReport(&result{ZoneName: "a.example", Plan: &plan{}})
+	if len(reportZones) != 4 || reportZones[0].Status != "unchanged" {
+		t.Errorf("addReport() did not replace earlier result: %+v", reportZones)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	reportPath = filepath.Join(dir, "report.html")
+	defer func() {
+		reportPath = ""
+		reportZones = nil
+	}()
+
+	p := &plan{
+		Adds: recordCollection{{Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10), TTL: 300}},
+	}
+	addReport(&result{ZoneName: "example.com", Plan: p})
+	addReport(&result{ZoneName: "example.org", Err: errors.New("<broken>")})
+
+	err = writeReport()
+	if err != nil {
+		t.Fatalf("writeReport() returned error: %s", err.Error())
+	}
+
+	b, _ := ioutil.ReadFile(reportPath)
+	html := string(b)
+
+	for _, expected := range []string{
+		"<h2>example.com: <span class=\"applied\">applied</span></h2>",
+		"<td>add</td><td>example.com</td><td>300</td><td>MX</td><td>10 mail.example.com</td><td>no</td>",
+		"<h2>example.org: <span class=\"failed\">failed</span></h2>",
+		"&lt;broken&gt;",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("writeReport() output does not contain '%s': %s", expected, html)
+		}
+	}
+}
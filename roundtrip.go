@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// roundtrip will parse the zone file in b, render the records using
+// FprintZone and parse the result again. Anything lost or changed on the way
+// is returned as problems.
+func roundtrip(b []byte) (int, []problem) {
+	problems := []problem{}
+
+	zoneName, records, err := parseZone(bytes.NewReader(b))
+	if err != nil {
+		return 0, append(problems, problem{0, severityError, err.Error()})
+	}
+
+	var rendered bytes.Buffer
+	fmt.Fprintf(&rendered, "%s. 3600 IN SOA ns.%s. hostmaster.%s. 1 3600 600 86400 300\n", zoneName, zoneName, zoneName)
+	records.FprintZone(&rendered, zoneName, cfzone.LayoutSorted)
+
+	_, reparsed, err := parseZone(&rendered)
+	if err != nil {
+		return 0, append(problems, problem{0, severityError, fmt.Sprintf("Rendered zone can't be parsed: %s", err.Error())})
+	}
+
+	for _, r := range records.Difference(reparsed, cfzone.FullMatch) {
+		problems = append(problems, problem{0, severityError, fmt.Sprintf("Lost: %s", recordLine(r))})
+	}
+
+	for _, r := range reparsed.Difference(records, cfzone.FullMatch) {
+		problems = append(problems, problem{0, severityError, fmt.Sprintf("Changed: %s", recordLine(r))})
+	}
+
+	return len(records), problems
+}
+
+// runRoundtrip implements "cfzone roundtrip <file>...". cfzone will exit with
+// 1 if any records didn't survive the round trip.
+func runRoundtrip(args []string) {
+	if len(args) < 1 {
+		errorf("Usage: cfzone roundtrip <zone file>...")
+		exit(1)
+	}
+
+	failed := false
+
+	for _, path := range args {
+		f, err := openZone(path)
+		if err != nil {
+			errorf("Error opening '%s': %s", path, err.Error())
+			failed = true
+			continue
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			errorf("Error reading '%s': %s", path, err.Error())
+			failed = true
+			continue
+		}
+
+		n, problems := roundtrip(b)
+		for _, p := range problems {
+			if p.line == 0 {
+				fmt.Fprintf(stdout, "%s: %s\n", path, p.String())
+			} else {
+				fmt.Fprintf(stdout, "%s:%s\n", path, p.String())
+			}
+		}
+
+		if len(problems) > 0 {
+			failed = true
+			continue
+		}
+
+		fmt.Fprintf(stdout, "%s: %d records survived the round trip\n", path, n)
+	}
+
+	if failed {
+		exit(1)
+	}
+}
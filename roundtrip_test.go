@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundtrip(t *testing.T) {
+	cases := []struct {
+		zone     string
+		records  int
+		expected []string
+	}{
+		{validZone, 2, []string{}},
+		{
+			validZone[:len(validZone)-len("txt  IN TXT   \"with ; semicolon\" \"and ( paren\"\n")] +
+				"@ 1 IN A 192.0.2.1\n@ IN MX 10 mail.example.com.\nspf IN TXT \"v=spf1 -all\"\nquote IN TXT \"a \\\"quoted\\\" \\\\ string\"\nalias IN CNAME www\n",
+			6,
+			[]string{},
+		},
+		{"bad zone\n", 0, []string{"error: dns: bad owner name: \"bad\" at line: 1:4"}},
+	}
+
+	for i, in := range cases {
+		n, problems := roundtrip([]byte(in.zone))
+
+		got := []string{}
+		for _, p := range problems {
+			got = append(got, p.String())
+		}
+
+		if n != in.records || !reflect.DeepEqual(got, in.expected) {
+			t.Errorf("%d: roundtrip() returned %d %#v, expected %d %#v", i, n, got, in.records, in.expected)
+		}
+	}
+}
+
+func TestRunRoundtripUsage(t *testing.T) {
+	defer expectExit(t, 1)
+
+	runRoundtrip([]string{})
+}
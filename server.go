@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// serveListen is the address to serve the HTTP API on. Empty disables server
+// mode.
+var serveListen = ""
+
+// maxZoneBody is the maximum size of zone files accepted by the HTTP API.
+const maxZoneBody = 16 << 20
+
+// server exposes diff, plan, apply and export over HTTP. All requests must be
+// authenticated using "Authorization: Bearer <token>".
+type server struct {
+	token string
+
+	// newProvider is used to get the DNS provider.
+	newProvider func() (cfzone.Provider, error)
+
+	// lock serializes all requests. Options are global, and we don't want
+	// concurrent applies to the same zone.
+	lock sync.Mutex
+}
+
+// apiError is returned as JSON on errors.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// applyResult is returned as JSON from apply.
+type applyResult struct {
+	Plan    *plan  `json:"plan"`
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// newServer will instantiate a new HTTP API server.
+func newServer(token string) *server {
+	return &server{
+		token:       token,
+		newProvider: newProvider,
+	}
+}
+
+// Handler returns the http.Handler for the API.
+func (s *server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/diff", s.post(s.diff))
+	mux.HandleFunc("/v1/plan", s.post(s.plan))
+	mux.HandleFunc("/v1/apply", s.post(s.apply))
+	mux.HandleFunc("/v1/export", s.export)
+
+	return s.authenticate(mux)
+}
+
+// authenticate wraps next, and rejects all requests without a valid token.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+
+		if token == header || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, apiError{"unauthorized"})
+			return
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// post returns a handler accepting a zone file as POST body, and calling
+// handle with a plan for the zone.
+func (s *server) post(handle func(http.ResponseWriter, *http.Request, cfzone.Provider, *plan)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxZoneBody))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+
+		zones, err := parseZones(strings.NewReader(string(body)))
+		if err == nil && len(zones) > 1 {
+			err = fmt.Errorf("Expected a single zone, found %d", len(zones))
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+
+		// Layers and delegations are added like for a sync. Targets of
+		// the zone are left for syncs, we only plan the zone posted.
+		zones, err = expandZones("request", zones)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{err.Error()})
+			return
+		}
+
+		zoneName := zones[0].name
+
+		fileRecords, err := prepareZone(zoneName, zones[0].records)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{err.Error()})
+			return
+		}
+
+		unlockZone, err := lockZone(zoneName)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, apiError{err.Error()})
+			return
+		}
+		defer unlockZone()
+
+		provider, err := s.newProvider()
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, apiError{redact(err.Error())})
+			return
+		}
+
+		p, err := newPlan(provider, zoneName, fileRecords)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, apiError{redact(err.Error())})
+			return
+		}
+
+		handle(w, r, provider, p)
+	}
+}
+
+// diff responds with a human readable plan.
+func (s *server) diff(w http.ResponseWriter, r *http.Request, provider cfzone.Provider, p *plan) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	p.Fprint(w)
+}
+
+// plan responds with the plan as JSON.
+func (s *server) plan(w http.ResponseWriter, r *http.Request, provider cfzone.Provider, p *plan) {
+	writeJSON(w, http.StatusOK, p)
+}
+
+// apply will apply the plan and respond with the result as JSON. Deleting
+// more than -confirmdeletes records must be confirmed by giving the zone name
+// in the "confirm" query parameter, unless -yes is given.
+func (s *server) apply(w http.ResponseWriter, r *http.Request, provider cfzone.Provider, p *plan) {
+	if len(p.Deletes) > confirmDeletes && !yes && r.URL.Query().Get("confirm") != p.ZoneName {
+		writeJSON(w, http.StatusConflict, applyResult{Plan: p, Error: fmt.Sprintf("%d record(s) will be deleted from %s, add confirm=%s to continue", len(p.Deletes), p.ZoneName, p.ZoneName)})
+		return
+	}
+
+	if p.NumChanges() > 0 {
+		err := runPreHook(p)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, applyResult{Plan: p, Error: redact(err.Error())})
+			return
+		}
+	}
+
+	err := p.Apply(provider, ioutil.Discard)
+	if p.NumChanges() > 0 {
+		runPostHook(p, err)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, applyResult{Plan: p, Error: redact(err.Error())})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, applyResult{Plan: p, Applied: true})
+}
+
+// export responds with the records of the zone given by the "zone" query
+// parameter.
+func (s *server) export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+		return
+	}
+
+	zoneName := r.URL.Query().Get("zone")
+	if zoneName == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{"missing zone"})
+		return
+	}
+
+	layout := cfzone.LayoutSorted
+	switch r.URL.Query().Get("layout") {
+	case "", "sorted":
+
+	case "type":
+		layout = cfzone.LayoutByType
+
+	default:
+		writeJSON(w, http.StatusBadRequest, apiError{"unknown layout"})
+		return
+	}
+
+	provider, err := s.newProvider()
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, apiError{redact(err.Error())})
+		return
+	}
+
+	_, records, err := fetchZone(provider, zoneName)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, apiError{redact(err.Error())})
+		return
+	}
+
+	nameServers, err := zoneNameServers(provider, zoneName, records)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, apiError{redact(err.Error())})
+		return
+	}
+
+	var b bytes.Buffer
+	err = records.FprintZoneFile(&b, zoneName, nameServers, uint32(now().Unix()), layout)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, apiError{redact(err.Error())})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// nameServerer is implemented by providers able to tell the name servers of a
+// zone, like Cloudflare.
+type nameServerer interface {
+	NameServers(zoneName string) ([]string, error)
+}
+
+// zoneNameServers returns the name servers of zoneName. Providers unable to
+// tell them are assumed to list them as apex NS records.
+func zoneNameServers(provider cfzone.Provider, zoneName string, records cfzone.RecordCollection) ([]string, error) {
+	if n, ok := provider.(nameServerer); ok {
+		return n.NameServers(zoneName)
+	}
+
+	nameServers := []string{}
+	for _, r := range records {
+		if r.Type == "NS" && cfzone.Name(r.Name) == cfzone.Name(zoneName) {
+			nameServers = append(nameServers, r.Content)
+		}
+	}
+
+	return nameServers, nil
+}
+
+// writeJSON will respond with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// runServer will serve the HTTP API on addr. It only returns on error.
+func runServer(addr string) error {
+	token, err := readSecret(cfg.Server.TokenEnv, cfg.Server.TokenFile)
+	if err != nil {
+		return err
+	}
+
+	if token == "" {
+		return fmt.Errorf("no server token configured")
+	}
+
+	return http.ListenAndServe(addr, redactPanics(newServer(token).Handler()))
+}
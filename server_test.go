@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+func TestServerAuthentication(t *testing.T) {
+	s := newServer("token")
+
+	cases := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"token", http.StatusUnauthorized},
+		{"Bearer token", http.StatusMethodNotAllowed},
+	}
+
+	for i, c := range cases {
+		req := httptest.NewRequest("GET", "/v1/plan", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		w := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%d: ServeHTTP() returned %d for '%s', expected %d", i, w.Code, c.header, c.status)
+		}
+	}
+}
+
+func TestServerRequests(t *testing.T) {
+	s := newServer("token")
+	s.newProvider = func() (cfzone.Provider, error) {
+		return nil, errors.New("no network in tests")
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/v1/diff", "", http.StatusMethodNotAllowed},
+		{"POST", "/v1/diff", "broken zone", http.StatusBadRequest},
+		{"POST", "/v1/plan", "", http.StatusBadRequest},
+		{"POST", "/v1/apply", "", http.StatusBadRequest},
+		{"POST", "/v1/export?zone=example.com", "", http.StatusMethodNotAllowed},
+		{"GET", "/v1/export", "", http.StatusBadRequest},
+		{"GET", "/v1/export?zone=example.com", "", http.StatusBadGateway},
+		{"GET", "/v1/export?zone=example.com&layout=type", "", http.StatusBadGateway},
+		{"GET", "/v1/export?zone=example.com&layout=nonexisting", "", http.StatusBadRequest},
+		{"GET", "/v1/nonexisting", "", http.StatusNotFound},
+	}
+
+	for i, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, bytes.NewBufferString(c.body))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%d: %s %s returned %d, expected %d: %s", i, c.method, c.path, w.Code, c.status, w.Body.String())
+		}
+	}
+}
+
+func TestServerRedactsErrors(t *testing.T) {
+	apiKey = "0123456789abcdef"
+	defer func() { apiKey = "" }()
+
+	s := newServer("token")
+	s.newProvider = func() (cfzone.Provider, error) {
+		return nil, errors.New("bad key 0123456789abcdef")
+	}
+
+	req := httptest.NewRequest("GET", "/v1/export?zone=example.com", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(w, req)
+
+	if strings.Contains(w.Body.String(), apiKey) {
+		t.Errorf("Secret found in response: %s", w.Body.String())
+	}
+}
+
+func TestServerExport(t *testing.T) {
+	now = func() time.Time { return time.Unix(42, 0) }
+	defer func() { now = time.Now }()
+
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{
+		{Type: "NS", Name: "example.com", Content: "ns1.example.net", TTL: 3600},
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300},
+	})
+
+	s := newServer("token")
+	s.newProvider = func() (cfzone.Provider, error) {
+		return m, nil
+	}
+
+	req := httptest.NewRequest("GET", "/v1/export?zone=example.com", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(w, req)
+
+	expected := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.net. hostmaster.example.com. 42 10000 2400 604800 3600
+@ 3600 IN NS ns1.example.net.
+www 300 IN A     192.0.2.1
+`
+
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Errorf("Export returned %d [%s], expected [%s]", w.Code, w.Body.String(), expected)
+	}
+}
+
+func TestServerApplyConfirmDeletes(t *testing.T) {
+	parseArguments([]string{"./test", "-confirmdeletes", "1", "zone"})
+	defer parseArguments([]string{"./test", "zone"})
+
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{
+		{Type: "A", Name: "a.example.com", Content: "192.0.2.1", TTL: 300},
+		{Type: "A", Name: "b.example.com", Content: "192.0.2.2", TTL: 300},
+		{Type: "A", Name: "c.example.com", Content: "192.0.2.3", TTL: 300},
+	})
+
+	s := newServer("token")
+	s.newProvider = func() (cfzone.Provider, error) {
+		return m, nil
+	}
+
+	zone := "$ORIGIN example.com.\n@ 300 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300\na 300 IN A 192.0.2.1\n"
+
+	apply := func(path string) int {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(zone))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	if code := apply("/v1/apply"); code != http.StatusConflict {
+		t.Errorf("Deleting 2 records returned %d, expected %d", code, http.StatusConflict)
+	}
+
+	if code := apply("/v1/apply?confirm=example.com"); code != http.StatusOK {
+		t.Errorf("Confirmed deletions returned %d, expected %d", code, http.StatusOK)
+	}
+
+	records, _ := m.List("example.com")
+	if len(records) != 1 {
+		t.Errorf("Confirmed deletions left %d records, expected 1", len(records))
+	}
+}
+
+func TestServerInvalidZoneOptions(t *testing.T) {
+	cfg = config{Zones: map[string]zoneConfig{"example.com": {Flags: map[string]string{"duplicates": "nonexisting"}}}}
+	defer func() { cfg = config{} }()
+
+	s := newServer("token")
+
+	zone := "$ORIGIN example.com.\n@ 300 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300\n"
+	req := httptest.NewRequest("POST", "/v1/plan", bytes.NewBufferString(zone))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "nonexisting") {
+		t.Errorf("Invalid zone options returned %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServerDelegations(t *testing.T) {
+	cfg = config{Zones: map[string]zoneConfig{"example.com": {Delegations: []delegationConfig{{Name: "sub.example.com", NameServers: []string{"ns1.example.net"}}}}}}
+	defer func() { cfg = config{} }()
+
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{
+		{Type: "A", Name: "a.example.com", Content: "192.0.2.1", TTL: 300},
+		{Type: "NS", Name: "sub.example.com", Content: "ns1.example.net", TTL: 86400},
+	})
+
+	s := newServer("token")
+	s.newProvider = func() (cfzone.Provider, error) {
+		return m, nil
+	}
+
+	zone := "$ORIGIN example.com.\n@ 300 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300\na 300 IN A 192.0.2.1\n"
+	req := httptest.NewRequest("POST", "/v1/plan", bytes.NewBufferString(zone))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(w, req)
+
+	var p plan
+	json.Unmarshal(w.Body.Bytes(), &p)
+
+	if w.Code != http.StatusOK || p.NumChanges() != 0 {
+		t.Errorf("Plan with a delegation returned %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRunServerNoToken(t *testing.T) {
+	cfg = config{}
+
+	err := runServer("127.0.0.1:0")
+	if err == nil {
+		t.Errorf("runServer() started without a token")
+	}
+}
+
+func TestServeRefusesSignatures(t *testing.T) {
+	defer func() { serveListen, minisignKeys, gpgKeyring = "", "", "" }()
+
+	serveListen = ":8081"
+
+	err := validateFlags()
+	if err != nil {
+		t.Fatalf("validateFlags() refused -serve: %s", err.Error())
+	}
+
+	minisignKeys = "keys.pub"
+
+	err = validateFlags()
+	if err == nil {
+		t.Errorf("validateFlags() accepted -serve with -minisignkeys")
+	}
+
+	minisignKeys, gpgKeyring = "", "keyring.gpg"
+
+	err = validateFlags()
+	if err == nil {
+		t.Errorf("validateFlags() accepted -serve with -gpgkeyring")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	// minisignKeys is a file with the minisign public keys allowed to sign
+	// zone files. Zone files must have a signature in <path>.minisig.
+	minisignKeys = ""
+
+	// gpgKeyring is a keyring with the GPG keys allowed to sign zone files.
+	// Zone files must have a detached signature in <path>.sig, verified
+	// using gpgv.
+	gpgKeyring = ""
+)
+
+// minisignKey is a minisign public key.
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// String returns the key ID the way minisign shows it, as a little endian
+// number in hex.
+func (k minisignKey) String() string {
+	id := ""
+	for i := len(k.id) - 1; i >= 0; i-- {
+		id += fmt.Sprintf("%02X", k.id[i])
+	}
+
+	return id
+}
+
+// loadMinisignKeys will read the public keys in the file at path. Lines
+// starting with "untrusted comment:" and empty lines are skipped.
+func loadMinisignKeys(path string) ([]minisignKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []minisignKey{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(raw) != 42 || string(raw[:2]) != "Ed" {
+			return nil, fmt.Errorf("Invalid minisign public key '%s' in '%s'", line, path)
+		}
+
+		keys = append(keys, minisignKey{id: raw[2:10], key: ed25519.PublicKey(raw[10:])})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No minisign public keys in '%s'", path)
+	}
+
+	return keys, nil
+}
+
+// verifyMinisign will verify the minisign signature sig of content using
+// one of keys, and return the ID of the key used. Both legacy and prehashed
+// signatures are supported.
+func verifyMinisign(content []byte, sig []byte, keys []minisignKey) (string, error) {
+	lines := []string{}
+	for _, line := range strings.Split(string(sig), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("Malformed minisign signature")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 74 {
+		return "", errors.New("Malformed minisign signature")
+	}
+
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return "", errors.New("Malformed minisign signature")
+	}
+
+	message := content
+	switch string(raw[:2]) {
+	case "Ed":
+
+	case "ED":
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+
+	default:
+		return "", fmt.Errorf("Unsupported minisign signature algorithm '%s'", raw[:2])
+	}
+
+	id, signature := raw[2:10], raw[10:]
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+
+	for _, k := range keys {
+		if !bytes.Equal(k.id, id) {
+			continue
+		}
+
+		if !ed25519.Verify(k.key, message, signature) {
+			return "", errors.New("Invalid minisign signature")
+		}
+
+		// The trusted comment is signed too.
+		if !ed25519.Verify(k.key, append(append([]byte{}, signature...), trusted...), global) {
+			return "", errors.New("Invalid minisign signature of the trusted comment")
+		}
+
+		return k.String(), nil
+	}
+
+	return "", errors.New("Signed by a key not allowed")
+}
+
+// verifyGPG will verify the detached GPG signature sig of content using
+// gpgv and the keys in gpgKeyring.
+func verifyGPG(content []byte, sig []byte) error {
+	f, err := ioutil.TempFile("", "cfzone-sig")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(sig)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("gpgv", "--keyring", gpgKeyring, f.Name(), "-")
+	cmd.Stdin = bytes.NewReader(content)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Invalid GPG signature: %s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// readSignature returns the signature at path, which can be a git source
+// like zone files.
+func readSignature(path string) ([]byte, error) {
+	f, err := openZone(path)
+	if err != nil {
+		return nil, fmt.Errorf("Missing signature '%s': %s", path, err.Error())
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
+
+// openVerifiedZone is like openZone, but will refuse zone files without a
+// valid signature when -minisignkeys or -gpgkeyring is used.
+func openVerifiedZone(path string) (io.ReadCloser, error) {
+	if minisignKeys == "" && gpgKeyring == "" {
+		return openZone(path)
+	}
+
+	f, err := openZone(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyFileSignature(path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// readVerified is like ioutil.ReadFile, but will refuse files without a
+// valid signature when -minisignkeys or -gpgkeyring is used. Files other
+// than zone files able to change records, like -values, are read using it.
+func readVerified(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyFileSignature(path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// verifyFileSignature will verify the signature of content read from path, if
+// -minisignkeys or -gpgkeyring is used.
+func verifyFileSignature(path string, content []byte) error {
+	if minisignKeys != "" {
+		keys, err := loadMinisignKeys(minisignKeys)
+		if err != nil {
+			return err
+		}
+
+		sig, err := readSignature(path + ".minisig")
+		if err != nil {
+			return err
+		}
+
+		_, err = verifyMinisign(content, sig, keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	if gpgKeyring != "" {
+		sig, err := readSignature(path + ".sig")
+		if err != nil {
+			return err
+		}
+
+		err = verifyGPG(content, sig)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign returns a minisign signature of content and the public key line
+// for key. alg is "Ed" for legacy or "ED" for prehashed signatures.
+func minisign(key ed25519.PrivateKey, id []byte, alg string, content []byte) (string, string) {
+	message := content
+	if alg == "ED" {
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	}
+
+	signature := ed25519.Sign(key, message)
+	trusted := "timestamp:1700000000\tfile:example.com.zone"
+	global := ed25519.Sign(key, append(append([]byte{}, signature...), trusted...))
+
+	raw := append(append([]byte(alg), id...), signature...)
+	sig := fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), trusted, base64.StdEncoding.EncodeToString(global))
+
+	public := append(append([]byte("Ed"), id...), key.Public().(ed25519.PublicKey)...)
+
+	return sig, base64.StdEncoding.EncodeToString(public)
+}
+
+func TestReadZoneSigned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+
+	path := filepath.Join(dir, "example.com.zone")
+	content := []byte(validZone)
+	ioutil.WriteFile(path, content, 0644)
+
+	sig, public := minisign(key, []byte("12345678"), "ED", content)
+	_, otherPublic := minisign(other, []byte("87654321"), "ED", content)
+
+	minisignKeys = filepath.Join(dir, "keys")
+	defer func() { minisignKeys = "" }()
+	ioutil.WriteFile(minisignKeys, []byte("untrusted comment: minisign public key\n"+otherPublic+"\n"+public+"\n"), 0644)
+
+	_, err = readZones(path)
+	if err == nil || !strings.Contains(err.Error(), "Missing signature") {
+		t.Errorf("readZones() didn't fail without a signature: %v", err)
+	}
+
+	ioutil.WriteFile(path+".minisig", []byte(sig), 0644)
+
+	zones, err := readZones(path)
+	if err != nil || len(zones) != 1 || zones[0].name != "example.com" || len(zones[0].records) == 0 {
+		t.Fatalf("readZones() failed on a signed zone: %v", err)
+	}
+
+	legacy, _ := minisign(key, []byte("12345678"), "Ed", content)
+	ioutil.WriteFile(path+".minisig", []byte(legacy), 0644)
+
+	_, err = readZones(path)
+	if err != nil {
+		t.Errorf("readZones() failed on a legacy signature: %s", err.Error())
+	}
+
+	// A changed zone file.
+	ioutil.WriteFile(path, append(content, "evil IN A 192.0.2.66\n"...), 0644)
+
+	_, err = readZones(path)
+	if err == nil || !strings.Contains(err.Error(), "Invalid minisign signature") {
+		t.Errorf("readZones() accepted a changed zone file: %v", err)
+	}
+
+	// A key not allowed.
+	ioutil.WriteFile(path, content, 0644)
+	stranger, _ := minisign(key, []byte("00000000"), "ED", content)
+	ioutil.WriteFile(path+".minisig", []byte(stranger), 0644)
+
+	_, err = readZones(path)
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("readZones() accepted a signature from an unknown key: %v", err)
+	}
+}
+
+func TestSignedInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	_, public := minisign(key, []byte("12345678"), "ED", nil)
+
+	minisignKeys = filepath.Join(dir, "keys")
+	defer func() { minisignKeys = "" }()
+	ioutil.WriteFile(minisignKeys, []byte(public+"\n"), 0644)
+
+	values := filepath.Join(dir, "values.yaml")
+	config := filepath.Join(dir, "config.yaml")
+	layer := filepath.Join(dir, "override.zone")
+	program := filepath.Join(dir, "transform.sh")
+
+	inputs := map[string]string{
+		values:  "ip: 192.0.2.1\n",
+		config:  "zones:\n  example.com:\n    delegations:\n      - {name: sub.example.com, nameservers: [ns1.example.net]}\n",
+		layer:   "www 300 IN A 192.0.2.1\n",
+		program: "#!/bin/sh\ncat\n",
+	}
+
+	load := map[string]func() error{
+		values: func() error {
+			_, err := loadValues(values)
+			return err
+		},
+		config: func() error {
+			_, err := loadConfig(config, true)
+			return err
+		},
+		layer: func() error {
+			_, err := readLayer(layer, "example.com")
+			return err
+		},
+		program: func() error {
+			return verifyCommand(program + " --flag")
+		},
+	}
+
+	for path, content := range inputs {
+		ioutil.WriteFile(path, []byte(content), 0755)
+
+		err = load[path]()
+		if err == nil || !strings.Contains(err.Error(), "Missing signature") {
+			t.Errorf("%s was accepted without a signature: %v", filepath.Base(path), err)
+		}
+
+		sig, _ := minisign(key, []byte("12345678"), "ED", []byte(content))
+		ioutil.WriteFile(path+".minisig", []byte(sig), 0644)
+
+		err = load[path]()
+		if err != nil {
+			t.Errorf("%s wasn't accepted with a signature: %s", filepath.Base(path), err.Error())
+		}
+	}
+}
+
+func TestLoadMinisignKeysErrors(t *testing.T) {
+	p := writeTempFile(t, "untrusted comment: nothing here\n")
+	defer os.Remove(p)
+
+	_, err := loadMinisignKeys(p)
+	if err == nil {
+		t.Errorf("loadMinisignKeys() accepted a file without keys")
+	}
+
+	ioutil.WriteFile(p, []byte("not base64!\n"), 0644)
+
+	_, err = loadMinisignKeys(p)
+	if err == nil {
+		t.Errorf("loadMinisignKeys() accepted an invalid key")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+var (
+	// simulatePath is a JSON or zone file to seed the simulated provider
+	// from. Empty disables simulation.
+	simulatePath = ""
+
+	// simulated is the in-memory provider used instead of Cloudflare when
+	// simulating.
+	simulated *cfzone.Memory
+)
+
+// loadSimulation will return an in-memory provider seeded from the file at
+// path. The file can be a zone file, or a JSON dump of records as returned
+// by the Cloudflare API, either as a list or a full API response.
+func loadSimulation(path string) (*cfzone.Memory, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := cfzone.NewMemory()
+
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		var records []cloudflare.DNSRecord
+
+		if trimmed[0] == '{' {
+			var response struct {
+				Result []cloudflare.DNSRecord `json:"result"`
+			}
+
+			err = json.Unmarshal(trimmed, &response)
+			records = response.Result
+		} else {
+			err = json.Unmarshal(trimmed, &records)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+		}
+
+		for _, r := range recordCollection(records).FromCloudflare() {
+			if r.ZoneName == "" {
+				return nil, fmt.Errorf("Error reading '%s': record '%s' has no zone_name", path, r.Name)
+			}
+
+			m.Seed(r.ZoneName, recordCollection{r})
+		}
+
+		return m, nil
+	}
+
+	zoneName, records, _, err := cfzone.ParseZone(bytes.NewReader(b), cfzone.ParseOptions{SkipUnsupported: true})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+	}
+
+	m.Seed(zoneName, records)
+
+	return m, nil
+}
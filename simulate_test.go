@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSimulation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		content string
+		zone    string
+		records int
+		fails   bool
+	}{
+		{`[{"id": "1", "type": "A", "name": "www.example.com", "content": "192.0.2.1", "zone_name": "example.com"}]`, "example.com", 1, false},
+		{`{"result": [{"type": "A", "name": "a.example.com", "content": "192.0.2.1", "zone_name": "example.com"}, {"type": "A", "name": "b.example.com", "content": "192.0.2.2", "zone_name": "example.com"}]}`, "example.com", 2, false},
+		{`[{"type": "A", "name": "www.example.com", "content": "192.0.2.1"}]`, "", 0, true},
+		{`[{"type": `, "", 0, true},
+		{"$ORIGIN example.org.\n@ 3600 IN SOA ns1 hostmaster 1 2 3 4 5\nwww 3600 IN A 192.0.2.1\n", "example.org", 1, false},
+		{"$ORIGIN example.org.\nwww IN A\n", "", 0, true},
+	}
+
+	for i, c := range cases {
+		path := filepath.Join(dir, "seed")
+		ioutil.WriteFile(path, []byte(c.content), 0600)
+
+		m, err := loadSimulation(path)
+		if c.fails {
+			if err == nil {
+				t.Errorf("%d: loadSimulation() didn't fail", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d: loadSimulation() failed: %s", i, err.Error())
+			continue
+		}
+
+		records, err := m.List(c.zone)
+		if err != nil || len(records) != c.records {
+			t.Errorf("%d: loadSimulation() seeded %d records, expected %d", i, len(records), c.records)
+		}
+	}
+
+	_, err = loadSimulation(filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Errorf("loadSimulation() didn't fail for missing file")
+	}
+}
+
+func TestSimulatedProvider(t *testing.T) {
+	simulated = nil
+	defer func() { simulated = nil }()
+
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "seed.json")
+	ioutil.WriteFile(path, []byte(`[{"type": "A", "name": "old.example.com", "content": "192.0.2.1", "zone_name": "example.com"}]`), 0600)
+
+	simulated, err = loadSimulation(path)
+	if err != nil {
+		t.Fatalf("loadSimulation() failed: %s", err.Error())
+	}
+
+	provider, err := newProvider()
+	if err != nil || provider != simulated {
+		t.Fatalf("newProvider() didn't return the simulated provider")
+	}
+
+	p, err := newPlan(provider, "example.com", recordCollection{
+		{Type: "A", Name: "new.example.com", Content: "192.0.2.2"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	err = p.Apply(provider, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Apply() failed: %s", err.Error())
+	}
+
+	records, _ := simulated.List("example.com")
+	if len(records) != 1 || records[0].Name != "new.example.com" {
+		t.Errorf("Apply() left wrong records: %+v", records)
+	}
+}
+
+func TestSimulatedSideEffects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "seed.json")
+	ioutil.WriteFile(path, []byte(`[{"type": "A", "name": "old.example.com", "content": "192.0.2.1", "zone_name": "example.com"}]`), 0600)
+
+	simulated, err = loadSimulation(path)
+	if err != nil {
+		t.Fatalf("loadSimulation() failed: %s", err.Error())
+	}
+	defer func() { simulated = nil }()
+
+	auditPath = filepath.Join(dir, "audit.log")
+	changelogPath = filepath.Join(dir, "changelog")
+	preHook = filepath.Join(dir, "missing-hook")
+	defer func() {
+		auditPath = ""
+		changelogPath = ""
+		preHook = ""
+	}()
+
+	p, err := newPlan(simulated, "example.com", recordCollection{
+		{Type: "A", Name: "new.example.com", Content: "192.0.2.2"},
+	})
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	err = runPreHook(p)
+	if err != nil {
+		t.Errorf("runPreHook() ran a hook for simulated changes: %s", err.Error())
+	}
+
+	err = p.Apply(simulated, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Apply() failed: %s", err.Error())
+	}
+
+	err = writeChangelog(p)
+	if err != nil {
+		t.Fatalf("writeChangelog() failed: %s", err.Error())
+	}
+
+	for _, written := range []string{auditPath, changelogPath} {
+		if _, err := os.Stat(written); err == nil {
+			t.Errorf("Simulated changes were written to %s", filepath.Base(written))
+		}
+	}
+
+	zoneLastSync.Set("simulated.example.com", 1)
+
+	var b strings.Builder
+	writeMetrics(&b)
+	if strings.Contains(b.String(), "simulated.example.com") {
+		t.Errorf("Simulated syncs showed up in the metrics")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// slackNotifier posts results to a Slack incoming webhook.
+type slackNotifier struct {
+	url string
+}
+
+// slackMessage is the payload accepted by Slack incoming webhooks.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Notify implements notifier.
+func (s *slackNotifier) Notify(r *result) error {
+	if s.url == "" {
+		return fmt.Errorf("no Slack webhook URL configured")
+	}
+
+	// The summary line is shown in notifications, the details are kept in
+	// a code block.
+	lines := strings.SplitN(strings.TrimSpace(r.Summary()), "\n", 2)
+
+	text := lines[0]
+	if r.Err != nil {
+		text = ":x: " + text
+	} else {
+		text = ":white_check_mark: " + text
+	}
+
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		text += "\n```\n" + strings.TrimSpace(lines[1]) + "\n```"
+	}
+
+	return postJSON(s.url, nil, slackMessage{Text: text})
+}
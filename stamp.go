@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// stampComments will append who ran cfzone and when to the comments of
+// records created or updated.
+var stampComments bool
+
+// maxCommentLength is the longest comment accepted by Cloudflare on all
+// plans.
+const maxCommentLength = 100
+
+// stampPattern matches a stamp added by stamped, with the separator if it
+// follows a comment.
+var stampPattern = regexp.MustCompile(`(^| - )managed by cfzone \(run by .* at [^ ]*\)$`)
+
+// stripStamp returns comment without any stamp added by stamped.
+func stripStamp(comment string) string {
+	return stampPattern.ReplaceAllString(comment, "")
+}
+
+// stamped returns records with the operator stamped into their comments if
+// -stampcomments is set. An earlier stamp is replaced, and the comment is
+// shortened to fit in maxCommentLength with the stamp.
+func stamped(records recordCollection) recordCollection {
+	if !stampComments || len(records) == 0 {
+		return records
+	}
+
+	stamp := currentStamp()
+
+	result := make(recordCollection, len(records))
+	for i, r := range records {
+		comment := stampableComment(r.Comment, stamp)
+		if comment == "" {
+			r.Comment = truncate(stamp, maxCommentLength)
+		} else {
+			r.Comment = comment + " - " + stamp
+		}
+
+		result[i] = r
+	}
+
+	return result
+}
+
+// currentStamp returns the stamp added to comments by stamped.
+func currentStamp() string {
+	return fmt.Sprintf("managed by cfzone (run by %s at %s)", currentActor(), now().UTC().Format(time.RFC3339))
+}
+
+// stampableComment returns comment without any stamp, shortened to leave
+// room for stamp.
+func stampableComment(comment string, stamp string) string {
+	return strings.TrimSpace(truncate(stripStamp(comment), maxCommentLength-len(" - "+stamp)))
+}
+
+// stampable returns records with their comments shortened the way stamped
+// would if -stampcomments is set, so comments too long to stamp don't differ
+// on every sync.
+func stampable(records recordCollection) recordCollection {
+	if !stampComments {
+		return records
+	}
+
+	stamp := currentStamp()
+
+	result := make(recordCollection, len(records))
+	for i, r := range records {
+		r.Comment = stampableComment(r.Comment, stamp)
+		result[i] = r
+	}
+
+	return result
+}
+
+// withoutStamps returns records with stamps added by stamped removed from
+// their comments, so stamps alone never make records differ.
+func withoutStamps(records recordCollection) recordCollection {
+	result := make(recordCollection, len(records))
+	for i, r := range records {
+		r.Comment = stripStamp(r.Comment)
+		result[i] = r
+	}
+
+	return result
+}
+
+// truncate returns s shortened to at most n bytes, without splitting UTF-8
+// characters.
+func truncate(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStamped(t *testing.T) {
+	records := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+		{Type: "A", Name: "mail.example.com", Content: "192.0.2.2", Comment: "Mail server"},
+	}
+
+	if got := stamped(records); got[0].Comment != "" {
+		t.Errorf("stamped() changed comments without -stampcomments")
+	}
+
+	stampComments = true
+	actor = "alice@bastion"
+	now = func() time.Time { return time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() {
+		stampComments = false
+		actor = ""
+		now = time.Now
+	}()
+
+	got := stamped(records)
+
+	expected := []string{
+		"managed by cfzone (run by alice@bastion at 2019-01-01T12:00:00Z)",
+		"Mail server - managed by cfzone (run by alice@bastion at 2019-01-01T12:00:00Z)",
+	}
+
+	for i, r := range got {
+		if r.Comment != expected[i] {
+			t.Errorf("stamped() set comment '%s', expected '%s'", r.Comment, expected[i])
+		}
+	}
+
+	if records[1].Comment != "Mail server" {
+		t.Errorf("stamped() modified its argument")
+	}
+}
+
+func TestStampedReplacesStamp(t *testing.T) {
+	stampComments = true
+	actor = "alice@bastion"
+	now = func() time.Time { return time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() {
+		stampComments = false
+		actor = ""
+		now = time.Now
+	}()
+
+	stamp := "managed by cfzone (run by alice@bastion at 2019-01-01T12:00:00Z)"
+
+	cases := []struct {
+		comment  string
+		expected string
+	}{
+		{"managed by cfzone (run by bob@laptop at 2018-06-01T08:00:00Z)", stamp},
+		{"Mail server - managed by cfzone (run by bob@laptop at 2018-06-01T08:00:00Z)", "Mail server - " + stamp},
+		{strings.Repeat("x", 90), strings.Repeat("x", 100-len(" - "+stamp)) + " - " + stamp},
+		{strings.Repeat("æ", 40), strings.Repeat("æ", 16) + " - " + stamp},
+	}
+
+	for _, c := range cases {
+		got := stamped(recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1", Comment: c.comment}})
+		if got[0].Comment != c.expected {
+			t.Errorf("stamped() set comment '%s', expected '%s'", got[0].Comment, c.expected)
+		}
+
+		if len(got[0].Comment) > maxCommentLength {
+			t.Errorf("stamped() set a comment of %d bytes", len(got[0].Comment))
+		}
+
+		// Stamping again only replaces the stamp.
+		again := stamped(got)
+		if again[0].Comment != got[0].Comment {
+			t.Errorf("stamped() changed '%s' to '%s'", got[0].Comment, again[0].Comment)
+		}
+	}
+}
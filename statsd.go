@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// statsdAddr is the host:port of a StatsD server receiving metrics after each
+// sync. Empty disables StatsD.
+var statsdAddr = ""
+
+// statsdLines returns the StatsD metrics for r. Tags are added in the
+// DogStatsD format.
+func statsdLines(r *result) []string {
+	tags := "|#zone:" + r.ZoneName
+
+	lines := []string{
+		fmt.Sprintf("cfzone.sync.duration:%d|ms%s", r.Duration.Nanoseconds()/1e6, tags),
+	}
+
+	if r.Err != nil {
+		lines = append(lines, "cfzone.sync.failures:1|c"+tags)
+	} else if r.Plan != nil {
+		lines = append(lines, fmt.Sprintf("cfzone.changes.applied:%d|c%s", r.Plan.NumChanges(), tags))
+		lines = append(lines, fmt.Sprintf("cfzone.records.managed:%d|g%s", r.Plan.Managed, tags))
+		lines = append(lines, fmt.Sprintf("cfzone.records.proxied:%d|g%s", r.Plan.Stats.Proxied, tags))
+
+		types := []string{}
+		for t := range r.Plan.Stats.Types {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		for _, t := range types {
+			lines = append(lines, fmt.Sprintf("cfzone.records.type:%d|g%s,type:%s", r.Plan.Stats.Types[t], tags, t))
+		}
+	}
+
+	return lines
+}
+
+// sendStatsd will send the metrics for r to statsdAddr. Errors are logged.
+func sendStatsd(r *result) {
+	if statsdAddr == "" || r.Err == errAborted {
+		return
+	}
+
+	conn, err := net.Dial("udp", statsdAddr)
+	if err != nil {
+		errorf("Error sending StatsD metrics: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	var b bytes.Buffer
+	for _, line := range statsdLines(r) {
+		b.WriteString(line + "\n")
+	}
+
+	_, err = conn.Write(b.Bytes())
+	if err != nil {
+		errorf("Error sending StatsD metrics: %s", err.Error())
+	}
+}
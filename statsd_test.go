@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+func TestStatsdLines(t *testing.T) {
+	p := &plan{Managed: 10, Adds: recordCollection{{Type: "A"}, {Type: "A"}}, Stats: cfzone.Stats{Types: map[string]int{"MX": 2, "A": 8}, Proxied: 3}}
+
+	cases := []struct {
+		r        *result
+		expected []string
+	}{
+		{
+			&result{ZoneName: "example.com", Plan: p, Duration: 1500 * time.Millisecond},
+			[]string{
+				"cfzone.sync.duration:1500|ms|#zone:example.com",
+				"cfzone.changes.applied:2|c|#zone:example.com",
+				"cfzone.records.managed:10|g|#zone:example.com",
+				"cfzone.records.proxied:3|g|#zone:example.com",
+				"cfzone.records.type:8|g|#zone:example.com,type:A",
+				"cfzone.records.type:2|g|#zone:example.com,type:MX",
+			},
+		},
+		{
+			&result{ZoneName: "example.com", Err: errors.New("broken"), Duration: time.Second},
+			[]string{
+				"cfzone.sync.duration:1000|ms|#zone:example.com",
+				"cfzone.sync.failures:1|c|#zone:example.com",
+			},
+		},
+	}
+
+	for i, c := range cases {
+		result := statsdLines(c.r)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: statsdLines() returned %+v, expected %+v", i, result, c.expected)
+		}
+	}
+}
+
+func TestSendStatsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer conn.Close()
+
+	statsdAddr = conn.LocalAddr().String()
+	defer func() { statsdAddr = "" }()
+
+	sendStatsd(&result{ZoneName: "example.com", Err: errors.New("broken")})
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %s", err.Error())
+	}
+
+	expected := "cfzone.sync.duration:0|ms|#zone:example.com\ncfzone.sync.failures:1|c|#zone:example.com\n"
+	if string(buf[:n]) != expected {
+		t.Errorf("sendStatsd() sent [%s], expected [%s]", string(buf[:n]), expected)
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errAborted is returned by syncZone if the user declined to apply changes.
+var errAborted = errors.New("aborted by user")
+
+// readZones will read and parse the zone file at path, which can hold
+// several zones, and expand them using expandZones.
+func readZones(path string) ([]zoneSection, error) {
+	f, err := openRenderedZone(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("Error opening '%s': %s", path, err.Error())
+	}
+	defer f.Close()
+
+	zones, err := parseZones(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+	}
+
+	return expandZones(path, zones)
+}
+
+// expandZones will merge layers into each of the zones read from path, and
+// add the targets of each zone after the zones. Delegation records are added
+// last. Everything syncing zones must do this, or the records added would be
+// deleted.
+func expandZones(path string, zones []zoneSection) ([]zoneSection, error) {
+	var err error
+
+	targets := []zoneSection{}
+
+	for i, zone := range zones {
+		layers := []recordCollection{}
+		for _, layerPath := range cfg.Zones[zone.name].Layers {
+			layer, err := readLayer(layerPath, zone.name)
+			if err != nil {
+				return nil, err
+			}
+
+			layers = append(layers, layer.records)
+			zones[i].skipped = mergeSkipped(zones[i].skipped, layer.skipped)
+		}
+
+		if len(layers) > 0 {
+			zones[i].records, err = zone.records.Merge(layers...)
+			if err != nil {
+				return nil, fmt.Errorf("Error merging layers into '%s': %s", path, err.Error())
+			}
+		}
+
+		t, err := targetZones(zones[i])
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, t...)
+	}
+
+	seen := map[string]bool{}
+	for _, zone := range zones {
+		seen[zone.name] = true
+	}
+
+	for _, target := range targets {
+		if seen[target.name] {
+			return nil, fmt.Errorf("Zone %s found more than once", target.name)
+		}
+		seen[target.name] = true
+	}
+
+	zones = append(zones, targets...)
+
+	for i, zone := range zones {
+		zones[i].records, err = withDelegations(zone)
+		if err != nil {
+			return nil, err
+		}
+
+		skippedTypes[zone.name] = zone.skipped
+	}
+
+	return zones, nil
+}
+
+// mergeSkipped returns the types skipped in both a and b.
+func mergeSkipped(a map[string]bool, b map[string]bool) map[string]bool {
+	if len(b) == 0 {
+		return a
+	}
+
+	merged := map[string]bool{}
+	for t := range a {
+		merged[t] = true
+	}
+	for t := range b {
+		merged[t] = true
+	}
+
+	return merged
+}
+
+// readLayer will read and parse a zone file at path to be merged into
+// zoneName.
+func readLayer(path string, zoneName string) (zoneSection, error) {
+	f, err := openRenderedZone(path, zoneName)
+	if err != nil {
+		return zoneSection{}, fmt.Errorf("Error opening '%s': %s", path, err.Error())
+	}
+	defer f.Close()
+
+	zones, err := parseZonesOrigin(f, zoneName)
+	if err == nil && len(zones) > 1 {
+		err = fmt.Errorf("Expected a single zone, found %d", len(zones))
+	}
+	if err != nil {
+		return zoneSection{}, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+	}
+
+	if zones[0].name != zoneName {
+		return zoneSection{}, fmt.Errorf("Layer '%s' is for '%s', not '%s'", path, zones[0].name, zoneName)
+	}
+
+	return zones[0], nil
+}
+
+// syncZone will synchronize the zone file at path to Cloudflare. Unless -yes
+// is given, the user will be asked for confirmation. path can also be a git
+// source.
+func syncZone(path string) (err error) {
+	s := tracing.startSpan("sync")
+	s.SetAttribute("cfzone.path", path)
+	defer func() {
+		s.End(err)
+
+		flushErr := tracing.flush()
+		if flushErr != nil {
+			errorf("%s", flushErr.Error())
+		}
+	}()
+
+	changed := false
+	if quiet {
+		var bufferedStdout, bufferedStderr bytes.Buffer
+		realStdout, realStderr := stdout, stderr
+
+		// Everything is buffered until we know if it should be written,
+		// warnings from parsing and planning included.
+		stdout, stderr = &bufferedStdout, &bufferedStderr
+		defer func() {
+			stdout, stderr = realStdout, realStderr
+
+			if err != nil || changed {
+				bufferedStderr.WriteTo(realStderr)
+				bufferedStdout.WriteTo(realStdout)
+			}
+		}()
+	}
+
+	// Zones matching -zones can change without the template changing.
+	if skipUnchanged && zonePatterns == "" {
+		hash, same := unchanged(path)
+		if same {
+			debugf(1, "'%s' is unchanged since the last sync, skipping", path)
+			return nil
+		}
+
+		defer func() {
+			// A partial sync doesn't count.
+			if err != nil || interactive || simulated != nil || savePlanPath != "" || hash == "" {
+				return
+			}
+
+			markErr := markSynced(path, hash)
+			if markErr != nil {
+				warnf("Can't remember '%s' as synced: %s", path, markErr.Error())
+			}
+		}()
+	}
+
+	timings.reset()
+
+	parse := tracing.startSpan("parse")
+	parsed := timings.start("parse")
+	var zones []zoneSection
+	if zonePatterns != "" {
+		zones, err = templateZones(path)
+	} else {
+		zones, err = readZones(path)
+	}
+	parsed()
+	parse.End(err)
+	if err != nil {
+		return err
+	}
+
+	if savePlanPath != "" && len(zones) > 1 {
+		return fmt.Errorf("-saveplan can only be used with one zone, '%s' holds %d", path, len(zones))
+	}
+
+	names := []string{}
+	for _, zone := range zones {
+		names = append(names, zone.name)
+	}
+
+	s.SetAttribute("cfzone.zone", strings.Join(names, ","))
+
+	if len(zones) == 1 {
+		var numChanges int
+		numChanges, err = syncRecords(zones[0].name, zones[0].records)
+		changed = numChanges > 0
+
+		return err
+	}
+
+	// A failing zone must not keep the other zones in the file from being
+	// synced, the errors are returned together.
+	errs := []string{}
+	aborted := false
+	for _, zone := range zones {
+		numChanges, zoneErr := syncRecords(zone.name, zone.records)
+		changed = changed || numChanges > 0
+
+		switch {
+		case zoneErr == errAborted:
+			aborted = true
+
+		case zoneErr != nil:
+			errs = append(errs, fmt.Sprintf("%s: %s", zone.name, zoneErr.Error()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Syncing %d of %d zones in '%s' failed:\n  %s", len(errs), len(zones), path, strings.Join(errs, "\n  "))
+	}
+
+	if aborted {
+		return errAborted
+	}
+
+	return nil
+}
+
+// syncRecords will synchronize fileRecords to the Cloudflare zone zoneName,
+// and return the number of changes found. Unless -yes is given, the user will
+// be asked for confirmation.
+func syncRecords(zoneName string, fileRecords recordCollection) (numChanges int, err error) {
+	if showTimings {
+		defer timings.Fprint(stderr, zoneName)
+	}
+
+	fileRecords, err = prepareZone(zoneName, fileRecords)
+	if err != nil {
+		return numChanges, err
+	}
+
+	checkTargets(fileRecords)
+
+	unlockZone, err := lockZone(zoneName)
+	if err != nil {
+		return numChanges, err
+	}
+	defer unlockZone()
+
+	var p *plan
+	start := now()
+	defer func() {
+		reportResult(&result{ZoneName: zoneName, Plan: p, Err: err, Duration: now().Sub(start)})
+	}()
+
+	provider, err := newProvider()
+	if err != nil {
+		return numChanges, fmt.Errorf("Error contacting Cloudflare: %s", err.Error())
+	}
+
+	p, err = newPlan(provider, zoneName, fileRecords)
+	if err != nil {
+		applyErrors.Add(zoneName, 1)
+		return numChanges, err
+	}
+
+	setZoneMetrics(p)
+
+	if p.Untouched > 0 {
+		fmt.Fprintf(stdout, "%d unknown records left untouched\n", p.Untouched)
+	}
+
+	if p.Kept > 0 {
+		fmt.Fprintf(stdout, "%d changes made outside of cfzone left alone\n", p.Kept)
+	}
+
+	numChanges = p.NumChanges()
+
+	if savePlanPath != "" {
+		p.Fprint(stdout)
+
+		err = savePlan(p, savePlanPath)
+		if err != nil {
+			return numChanges, fmt.Errorf("Can't save plan: %s", err.Error())
+		}
+
+		fmt.Fprintf(stdout, "Plan saved to '%s'\n", savePlanPath)
+
+		return numChanges, nil
+	}
+
+	// All answers from the user is read through the same buffered reader,
+	// to avoid losing input between prompts.
+	answers := bufio.NewReader(stdin)
+
+	if numChanges > 0 && interactive && !yes {
+		d := newDialog(answers, stdout)
+		p.Deletes = d.Pick("Delete", p.Deletes)
+		p.Adds = d.Pick("Add", p.Adds)
+		p.Updates = d.Pick("Update", p.Updates)
+
+		numChanges = p.NumChanges()
+	} else if numChanges > 0 && !yes {
+		p.Fprint(stdout)
+
+		err = confirmChanges(p, answers)
+		if err != nil {
+			return numChanges, err
+		}
+	}
+
+	err = confirmDeletions(p, answers)
+	if err != nil {
+		return numChanges, err
+	}
+
+	if numChanges > 0 {
+		err = runPreHook(p)
+		if err != nil {
+			return numChanges, err
+		}
+	}
+
+	err = p.Apply(provider, stdout)
+	if numChanges > 0 {
+		runPostHook(p, err)
+	}
+	if err != nil {
+		applyErrors.Add(zoneName, 1)
+		return numChanges, err
+	}
+
+	// A partial sync would make changes not picked look applied.
+	if threeWay && !interactive && simulated == nil {
+		saveErr := saveApplied(zoneName, fileRecords)
+		if saveErr != nil {
+			warnf("Can't remember records applied to %s: %s", zoneName, saveErr.Error())
+		}
+	}
+
+	zoneDrift.Set(zoneName, 0)
+	zoneLastSync.Set(zoneName, float64(now().Unix()))
+
+	if changelogPath != "" && numChanges > 0 {
+		return numChanges, writeChangelog(p)
+	}
+
+	return numChanges, nil
+}
+
+// confirmChanges will ask the user to confirm the changes in p. If a lot of
+// records are deleted, confirmDeletions is used instead.
+func confirmChanges(p *plan, answers *bufio.Reader) error {
+	if len(p.Deletes) > confirmDeletes {
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "%d change(s). Continue (y/N)? ", p.NumChanges())
+
+	if !yesNo(answers) {
+		fmt.Fprintf(stdout, "Aborting...\n")
+		return errAborted
+	}
+
+	return nil
+}
+
+// confirmDeletions will make the user type the zone name to confirm deleting
+// more than -confirmdeletes records, unless -yes is given.
+func confirmDeletions(p *plan, answers *bufio.Reader) error {
+	if len(p.Deletes) <= confirmDeletes || yes {
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "%d record(s) will be deleted from %s. Type the zone name to continue: ", len(p.Deletes), p.ZoneName)
+
+	if !confirmZone(answers, p.ZoneName) {
+		fmt.Fprintf(stdout, "Aborting...\n")
+		return errAborted
+	}
+
+	return nil
+}
+
+// setZoneMetrics will update the metrics of the zone planned by p.
+func setZoneMetrics(p *plan) {
+	zoneRecords.Set(p.ZoneName, float64(p.Managed))
+	zoneProxied.Set(p.ZoneName, float64(p.Stats.Proxied))
+	for t, n := range p.Stats.Types {
+		zoneTypes.Set(labelValues(p.ZoneName, t), float64(n))
+	}
+	zoneDrift.Set(p.ZoneName, float64(p.NumChanges()))
+}
+
+// reportResult will pass the result of a sync or an applied plan to
+// notifiers, alerts, statsd and the report.
+func reportResult(r *result) {
+	// Simulated changes must not look like real ones to others.
+	if simulated == nil {
+		if savePlanPath == "" {
+			notify(r)
+		}
+		alerts.observe(r)
+		sendStatsd(r)
+	}
+
+	if reportPath != "" {
+		addReport(r)
+
+		err := writeReport()
+		if err != nil {
+			errorf("Error writing report: %s", err.Error())
+		}
+	}
+}
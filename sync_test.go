@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+func TestReadZoneLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0644)
+
+		return path
+	}
+
+	base := write("base.zone", `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www 300 IN A 192.0.2.1
+`)
+	prod := write("prod.zone", "www 300 IN A 192.0.2.1\napi 300 IN A 192.0.2.2\n")
+	conflict := write("conflict.zone", "www 600 IN A 192.0.2.1\n")
+
+	cfg.Zones = map[string]zoneConfig{"example.com": {Layers: []string{prod}}}
+	defer func() { cfg.Zones = nil }()
+
+	zones, err := readZones(base)
+	if err != nil {
+		t.Fatalf("readZones() failed: %s", err.Error())
+	}
+
+	if len(zones) != 1 || len(zones[0].records) != 2 || zones[0].records[1].Name != "api.example.com" {
+		t.Errorf("readZones() returned wrong zones: %+v", zones)
+	}
+
+	cfg.Zones = map[string]zoneConfig{"example.com": {Layers: []string{prod, conflict}}}
+
+	_, err = readZones(base)
+	if err == nil || !strings.Contains(err.Error(), "Conflicting records") {
+		t.Errorf("readZones() didn't fail on conflicting layers: %v", err)
+	}
+}
+
+func TestReadZonesCombined(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "all.zone")
+	ioutil.WriteFile(path, []byte(`$TTL 300
+$ORIGIN example.com.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.1
+
+$ORIGIN example.net.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.2
+mail IN A 192.0.2.3
+`), 0644)
+
+	zones, err := readZones(path)
+	if err != nil {
+		t.Fatalf("readZones() failed: %s", err.Error())
+	}
+
+	if len(zones) != 2 {
+		t.Fatalf("readZones() returned %d zones, expected 2", len(zones))
+	}
+
+	if zones[0].name != "example.com" || len(zones[0].records) != 1 || zones[0].records[0].Name != "www.example.com" {
+		t.Errorf("readZones() returned wrong first zone: %+v", zones[0])
+	}
+
+	if zones[1].name != "example.net" || len(zones[1].records) != 2 {
+		t.Errorf("readZones() returned wrong second zone: %+v", zones[1])
+	}
+
+	// Single zones are still expected elsewhere.
+	f, _ := os.Open(path)
+	defer f.Close()
+
+	_, _, err = parseZone(f)
+	if err == nil {
+		t.Errorf("parseZone() didn't fail for a file holding two zones")
+	}
+
+	ioutil.WriteFile(path, []byte(`$ORIGIN example.com.
+@ 300 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+$ORIGIN example.com.
+@ 300 IN SOA ns1.example.com. hostmaster.example.com. 2 86400 7200 604800 86400
+`), 0644)
+
+	_, err = readZones(path)
+	if err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("readZones() didn't fail for a zone found twice: %v", err)
+	}
+}
+
+func TestSyncZoneContinues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// example.com is missing, and fails first.
+	m := cfzone.NewMemory()
+	m.Seed("example.net", recordCollection{{Type: "A", Name: "old.example.net", Content: "192.0.2.9"}})
+	m.Seed("example.org", recordCollection{{Type: "A", Name: "old.example.org", Content: "192.0.2.9"}})
+
+	simulated = m
+	defer func() { simulated = nil }()
+
+	parseArguments([]string{"./test", "-yes", "zone"})
+	defer parseArguments([]string{"./test", "zone"})
+
+	path := filepath.Join(dir, "all.zone")
+	ioutil.WriteFile(path, []byte(`$TTL 300
+$ORIGIN example.com.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.1
+
+$ORIGIN example.net.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.2
+
+$ORIGIN example.org.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.3
+`), 0644)
+
+	stdout = ioutil.Discard
+	defer func() { stdout = os.Stdout }()
+
+	err = syncZone(path)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 zones") || !strings.Contains(err.Error(), "example.com: ") {
+		t.Errorf("syncZone() returned wrong error: %v", err)
+	}
+
+	for _, zoneName := range []string{"example.net", "example.org"} {
+		records, _ := m.List(zoneName)
+		if len(records) != 1 || records[0].Name != "www."+zoneName {
+			t.Errorf("syncZone() didn't sync %s after a failing zone: %+v", zoneName, records)
+		}
+	}
+}
+
+func TestSyncZoneQuiet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	m := cfzone.NewMemory()
+	m.Seed("example.com", recordCollection{{Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 300}})
+
+	simulated = m
+	defer func() { simulated = nil }()
+
+	parseArguments([]string{"./test", "-q", "-yes", "zone"})
+	defer parseArguments([]string{"./test", "zone"})
+
+	// Every record parsed would report progress.
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 0
+
+	var out, errOut bytes.Buffer
+	stdout, stderr = &out, &errOut
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	zone := `$TTL 300
+$ORIGIN example.com.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www IN A 192.0.2.1
+www IN A 192.0.2.1
+`
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte(zone), 0644)
+
+	err = syncZone(path)
+	if err != nil {
+		t.Fatalf("syncZone() failed: %s", err.Error())
+	}
+
+	if out.Len() > 0 || errOut.Len() > 0 {
+		t.Errorf("syncZone() with -q wrote output without changes:\n%s%s", out.String(), errOut.String())
+	}
+
+	ioutil.WriteFile(path, []byte(zone+"mail IN A 192.0.2.2\n"), 0644)
+
+	err = syncZone(path)
+	if err != nil {
+		t.Fatalf("syncZone() failed: %s", err.Error())
+	}
+
+	if !strings.Contains(errOut.String(), "Ignoring duplicate record") || strings.Contains(errOut.String(), "records parsed") {
+		t.Errorf("syncZone() with -q wrote wrong warnings with changes:\n%s", errOut.String())
+	}
+
+	if !strings.Contains(out.String(), "1/1 applied") {
+		t.Errorf("syncZone() with -q didn't write the changes:\n%s", out.String())
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+)
+
+// openSyslog will connect to the syslog daemon given by target.
+func openSyslog(target string) (syslogger, error) {
+	network, addr := "", ""
+
+	if target != "local" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+
+		if (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+			return nil, fmt.Errorf("syslog target must be 'local', 'udp://host:port' or 'tcp://host:port'")
+		}
+
+		network, addr = u.Scheme, u.Host
+	}
+
+	return syslog.Dial(network, addr, syslog.LOG_DAEMON|syslog.LOG_INFO, "cfzone")
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import (
+	"errors"
+)
+
+// openSyslog is not supported on this platform.
+func openSyslog(target string) (syslogger, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,48 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer conn.Close()
+
+	w, err := openSyslog("udp://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("openSyslog() returned error: %s", err.Error())
+	}
+
+	w.Err("hello")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read syslog message: %s", err.Error())
+	}
+
+	// LOG_DAEMON|LOG_ERR is priority 27.
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<27>") || !strings.Contains(msg, "cfzone") || !strings.HasSuffix(strings.TrimSpace(msg), "hello") {
+		t.Errorf("openSyslog() sent wrong message '%s'", msg)
+	}
+}
+
+func TestOpenSyslogInvalid(t *testing.T) {
+	for _, target := range []string{"udp://", "http://example.com:514", "example.com:514"} {
+		_, err := openSyslog(target)
+		if err == nil {
+			t.Errorf("openSyslog() accepted '%s'", target)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// targetConfig is an extra zone receiving the records of a zone file.
+type targetConfig struct {
+	// Zone is the name of the target zone.
+	Zone string `yaml:"zone"`
+
+	// Overrides is a list of zone files with records replacing all
+	// records with the same name and type in the target zone.
+	Overrides []string `yaml:"overrides"`
+}
+
+// overrideRecords returns records with all records sharing name and type with
+// a record in overrides replaced by the records in overrides.
+func overrideRecords(records, overrides recordCollection) recordCollection {
+	key := func(name, typ string) string {
+		return strings.ToLower(strings.TrimSuffix(name, ".")) + " " + typ
+	}
+
+	replaced := map[string]bool{}
+	for _, r := range overrides {
+		replaced[key(r.Name, r.Type)] = true
+	}
+
+	result := recordCollection{}
+	for _, r := range records {
+		if !replaced[key(r.Name, r.Type)] {
+			result = append(result, r)
+		}
+	}
+
+	return append(result, overrides...)
+}
+
+// targetZones returns the zones receiving the records of zone according to
+// the targets in the configuration. Names are moved to each target zone, and
+// overrides are applied.
+func targetZones(zone zoneSection) ([]zoneSection, error) {
+	targets := []zoneSection{}
+
+	for _, target := range cfg.Zones[zone.name].Targets {
+		if target.Zone == "" {
+			return nil, fmt.Errorf("Target of %s has no zone", zone.name)
+		}
+
+		records := renameRecords(zone.records, zone.name, target.Zone)
+		skipped := zone.skipped
+
+		for _, path := range target.Overrides {
+			overrides, err := readLayer(path, target.Zone)
+			if err != nil {
+				return nil, err
+			}
+
+			records = overrideRecords(records, overrides.records)
+			skipped = mergeSkipped(skipped, overrides.skipped)
+		}
+
+		targets = append(targets, zoneSection{name: target.Zone, records: records, skipped: skipped})
+	}
+
+	return targets, nil
+}
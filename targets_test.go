@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOverrideRecords(t *testing.T) {
+	records := recordCollection{
+		{Type: "A", Name: "www.example.org", Content: "192.0.2.1"},
+		{Type: "A", Name: "www.example.org", Content: "192.0.2.2"},
+		{Type: "AAAA", Name: "www.example.org", Content: "2001:db8::1"},
+		{Type: "MX", Name: "example.org", Content: "mail.example.org"},
+	}
+
+	overrides := recordCollection{
+		{Type: "A", Name: "WWW.example.org.", Content: "198.51.100.1"},
+	}
+
+	expected := recordCollection{
+		{Type: "AAAA", Name: "www.example.org", Content: "2001:db8::1"},
+		{Type: "MX", Name: "example.org", Content: "mail.example.org"},
+		{Type: "A", Name: "WWW.example.org.", Content: "198.51.100.1"},
+	}
+
+	result := overrideRecords(records, overrides)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("overrideRecords() returned %+v, expected %+v", result, expected)
+	}
+}
+
+func TestReadZonesTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func() { cfg = config{} }()
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte(`$TTL 300
+$ORIGIN example.com.
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+@ IN A 192.0.2.1
+www IN CNAME example.com.
+`), 0644)
+
+	override := filepath.Join(dir, "example.org.zone")
+	ioutil.WriteFile(override, []byte("@ 300 IN A 198.51.100.1\n"), 0644)
+
+	cfg = config{Zones: map[string]zoneConfig{
+		"example.com": {Targets: []targetConfig{
+			{Zone: "example.net"},
+			{Zone: "example.org", Overrides: []string{override}},
+		}},
+	}}
+
+	zones, err := readZones(path)
+	if err != nil {
+		t.Fatalf("readZones() failed: %s", err.Error())
+	}
+
+	if len(zones) != 3 {
+		t.Fatalf("readZones() returned %d zones, expected 3", len(zones))
+	}
+
+	for i, expected := range []struct {
+		name    string
+		apex    string
+		www     string
+		records int
+	}{
+		{"example.com", "192.0.2.1", "example.com", 2},
+		{"example.net", "192.0.2.1", "example.net", 2},
+		{"example.org", "198.51.100.1", "example.org", 2},
+	} {
+		zone := zones[i]
+		if zone.name != expected.name || len(zone.records) != expected.records {
+			t.Errorf("%d: readZones() returned wrong zone %+v", i, zone)
+			continue
+		}
+
+		for _, r := range zone.records {
+			if r.Type == "A" && (r.Name != expected.name || r.Content != expected.apex) {
+				t.Errorf("%d: wrong apex record %+v", i, r)
+			}
+
+			if r.Type == "CNAME" && (r.Name != "www."+expected.name || r.Content != expected.www) {
+				t.Errorf("%d: wrong www record %+v", i, r)
+			}
+		}
+	}
+
+	cfg.Zones["example.com"] = zoneConfig{Targets: []targetConfig{{Zone: "example.com"}}}
+
+	_, err = readZones(path)
+	if err == nil {
+		t.Errorf("readZones() didn't fail for a target already in the zone file")
+	}
+}
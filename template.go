@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// renderTemplates will render zone files as Go templates before
+	// parsing them.
+	renderTemplates bool
+
+	// valuesPath is a YAML file with the values used when rendering zone
+	// files. It implies -template.
+	valuesPath = ""
+)
+
+// templateEnvPrefix is the prefix of the environment variables available to
+// templates. Other variables could hold secrets, like API tokens.
+const templateEnvPrefix = "CFZONE_VAR_"
+
+// templateEnv returns the environment variable name for templates.
+func templateEnv(name string) (string, error) {
+	if !strings.HasPrefix(name, templateEnvPrefix) {
+		return "", fmt.Errorf("%s is not available to templates, only variables starting with %s are", name, templateEnvPrefix)
+	}
+
+	return os.Getenv(name), nil
+}
+
+// loadValues will read the values in the file at path.
+func loadValues(path string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if path == "" {
+		return values, nil
+	}
+
+	b, err := readVerified(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(b, &values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return values, nil
+}
+
+// renderZone will render content as a template using the values from
+// -values. Zone is the name of the zone zoneName, unless it's empty because
+// the zone file names the zone itself. The env function returns environment
+// variables starting with CFZONE_VAR_. Missing values are errors.
+func renderZone(name string, content []byte, zoneName string) ([]byte, error) {
+	values, err := loadValues(valuesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := values["Zone"]; found {
+		return nil, fmt.Errorf("%s: Zone is reserved for the zone name", valuesPath)
+	}
+
+	if zoneName != "" {
+		values["Zone"] = zoneName
+	}
+
+	t, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": templateEnv}).
+		Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	err = t.Execute(&b, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// openRenderedZone is like openVerifiedZone, but will render the zone file
+// as a template for zoneName if -template or -values is used. zoneName is
+// empty for zone files naming the zone. Signatures are verified before
+// rendering.
+func openRenderedZone(path string, zoneName string) (io.ReadCloser, error) {
+	f, err := openVerifiedZone(path)
+	if err != nil || (!renderTemplates && valuesPath == "") {
+		return f, err
+	}
+
+	content, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := renderZone(filepath.Base(path), content, zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(rendered)), nil
+}
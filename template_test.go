@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderedZone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "brand.zone")
+	ioutil.WriteFile(path, []byte(`$ORIGIN {{ .brand }}.example.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+www 300 IN A {{ .region.ip }}
+{{ range .mx }}@ 300 IN MX 10 {{ . }}.
+{{ end }}@ 300 IN TXT "{{ env "CFZONE_VAR_TEST_VERIFICATION" }}"
+`), 0644)
+
+	valuesPath = filepath.Join(dir, "eu.yaml")
+	ioutil.WriteFile(valuesPath, []byte("brand: acme\nregion:\n  ip: 192.0.2.10\nmx: [mx1.example.net, mx2.example.net]\n"), 0644)
+	defer func() { valuesPath = "" }()
+
+	os.Setenv("CFZONE_VAR_TEST_VERIFICATION", "verified")
+	defer os.Unsetenv("CFZONE_VAR_TEST_VERIFICATION")
+
+	zones, err := readZones(path)
+	if err != nil {
+		t.Fatalf("readZones() failed: %s", err.Error())
+	}
+
+	if len(zones) != 1 || zones[0].name != "acme.example" {
+		t.Fatalf("readZones() returned wrong zones: %+v", zones)
+	}
+
+	got := zoneString(zones[0].records)
+	for _, expected := range []string{"www.acme.example", "192.0.2.10", "mx2.example.net", "verified"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("Rendered zone is missing '%s':\n%s", expected, got)
+		}
+	}
+
+	// Missing values must not render as empty.
+	ioutil.WriteFile(valuesPath, []byte("brand: acme\n"), 0644)
+
+	_, err = readZones(path)
+	if err == nil {
+		t.Errorf("readZones() didn't fail for missing values")
+	}
+
+	// Other environment variables could hold secrets.
+	ioutil.WriteFile(valuesPath, []byte("brand: acme\nregion:\n  ip: 192.0.2.10\nmx: []\n"), 0644)
+	ioutil.WriteFile(path, []byte(`$ORIGIN {{ .brand }}.example.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 86400 7200 604800 86400
+@ 300 IN TXT "{{ env "CF_API_TOKEN" }}"
+`), 0644)
+
+	_, err = readZones(path)
+	if err == nil || !strings.Contains(err.Error(), "CFZONE_VAR_") {
+		t.Errorf("readZones() didn't fail for env outside CFZONE_VAR_: %v", err)
+	}
+}
+
+func TestRenderZoneName(t *testing.T) {
+	rendered, err := renderZone("layer.zone", []byte(`www 300 IN CNAME {{ .Zone }}.cdn.example.net.`), "example.com")
+	if err != nil {
+		t.Fatalf("renderZone() failed: %s", err.Error())
+	}
+
+	if string(rendered) != "www 300 IN CNAME example.com.cdn.example.net." {
+		t.Errorf("renderZone() rendered [%s]", rendered)
+	}
+
+	// The zone name isn't known before rendering zone files naming the
+	// zone.
+	_, err = renderZone("example.zone", []byte(`{{ .Zone }}`), "")
+	if err == nil {
+		t.Errorf("renderZone() didn't fail for an unknown zone name")
+	}
+}
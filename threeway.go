@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// threeWay will compare changes to the records applied by the last
+// successful sync, and let -drift and -conflicts decide what to do about
+// changes made outside of cfzone.
+var threeWay bool
+
+// driftPolicy decides what to do about records changed outside of cfzone
+// since the last sync. "apply" will assert the zone file, "keep" will leave
+// the records alone and "fail" will refuse to sync.
+var driftPolicy = "apply"
+
+// conflictPolicy decides what to do about records changed both in the zone
+// file and outside of cfzone since the last sync. Values are the same as for
+// driftPolicy.
+var conflictPolicy = "fail"
+
+// appliedPath returns the path of the file holding the records applied to
+// zoneName by the last successful sync.
+func appliedPath(zoneName string) (string, error) {
+	return statePath("applied", strings.ToLower(zoneName)+".json")
+}
+
+// loadApplied returns the records applied to zoneName by the last successful
+// sync. No records are returned if zoneName was never synced with -threeway.
+func loadApplied(zoneName string) (recordCollection, error) {
+	path, err := appliedPath(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records recordCollection
+
+	err = json.Unmarshal(content, &records)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read '%s': %s", path, err.Error())
+	}
+
+	return records, nil
+}
+
+// saveApplied will remember records as applied to zoneName.
+func saveApplied(zoneName string, records recordCollection) error {
+	path, err := appliedPath(zoneName)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// policyFor returns the policy for changes of class.
+func policyFor(class cfzone.Class) string {
+	switch class {
+	case cfzone.RemoteDrift:
+		return driftPolicy
+	case cfzone.Conflict:
+		return conflictPolicy
+	}
+
+	return "apply"
+}
+
+// threeWayFilter will classify the changes in p using base as the records
+// applied by the last sync, and remove changes the policies say to keep.
+func (p *plan) threeWayFilter(base recordCollection, existing recordCollection) error {
+	changes := cfzone.Changes{Deletes: p.Deletes, Adds: p.Adds, Updates: p.Updates}
+	classes := changes.Classify(base, existing)
+
+	refused := []string{}
+
+	filter := func(verb string, records recordCollection, classes []cfzone.Class) recordCollection {
+		kept := recordCollection{}
+
+		for i, r := range records {
+			class := classes[i]
+			if class != cfzone.LocalChange {
+				debugf(2, "%s %s is %s", verb, recordLine(r), class)
+			}
+
+			switch policyFor(class) {
+			case "keep":
+				p.Kept++
+				continue
+			case "fail":
+				refused = append(refused, fmt.Sprintf("%s (%s) %s", verb, class, recordLine(r)))
+			}
+
+			kept = append(kept, r)
+		}
+
+		return kept
+	}
+
+	p.Deletes = filter("Delete", p.Deletes, classes.Deletes)
+	p.Adds = filter("Add", p.Adds, classes.Adds)
+	p.Updates = filter("Update", p.Updates, classes.Updates)
+
+	if len(refused) > 0 {
+		return fmt.Errorf("Records in %s were changed outside of cfzone since the last sync:\n  %s", p.ZoneName, strings.Join(refused, "\n  "))
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewPlanThreeWay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	stateDir = filepath.Join(dir, "state")
+	threeWay = true
+	defer func() {
+		stateDir = ""
+		threeWay = false
+		driftPolicy = "apply"
+		conflictPolicy = "fail"
+	}()
+
+	applied := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+		{Type: "A", Name: "old.example.com", Content: "192.0.2.3"},
+	}
+
+	err = saveApplied("example.com", applied)
+	if err != nil {
+		t.Fatalf("saveApplied() failed: %s", err.Error())
+	}
+
+	loaded, err := loadApplied("EXAMPLE.com")
+	if err != nil || !reflect.DeepEqual(loaded, applied) {
+		t.Fatalf("loadApplied() returned %+v, %v", loaded, err)
+	}
+
+	f := &fakeProvider{
+		records: recordCollection{
+			// Changed and added outside of cfzone.
+			{ID: "1", Type: "A", Name: "www.example.com", Content: "192.0.2.10"},
+			{ID: "2", Type: "A", Name: "manual.example.com", Content: "192.0.2.2"},
+
+			// Removed from the zone file.
+			{ID: "3", Type: "A", Name: "old.example.com", Content: "192.0.2.3"},
+		},
+	}
+
+	fileRecords := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "192.0.2.1"},
+	}
+
+	p, err := newPlan(f, "example.com", fileRecords)
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	if len(p.Deletes) != 2 || len(p.Updates) != 1 || p.Kept != 0 {
+		t.Errorf("newPlan() didn't assert the zone file with -drift apply: %+v", p)
+	}
+
+	driftPolicy = "keep"
+
+	p, err = newPlan(f, "example.com", fileRecords)
+	if err != nil {
+		t.Fatalf("newPlan() failed: %s", err.Error())
+	}
+
+	expected := recordCollection{{ID: "3", Type: "A", Name: "old.example.com", Content: "192.0.2.3"}}
+	if !reflect.DeepEqual(p.Deletes, expected) || len(p.Updates) != 0 || p.Kept != 2 {
+		t.Errorf("newPlan() didn't keep drift with -drift keep: %+v", p)
+	}
+
+	driftPolicy = "fail"
+
+	_, err = newPlan(f, "example.com", fileRecords)
+	if err == nil || !strings.Contains(err.Error(), "manual.example.com") {
+		t.Errorf("newPlan() didn't fail on drift with -drift fail: %v", err)
+	}
+
+	// Changed in both places.
+	driftPolicy = "apply"
+	fileRecords[0].Content = "192.0.2.5"
+
+	_, err = newPlan(f, "example.com", fileRecords)
+	if err == nil || !strings.Contains(err.Error(), "(conflict)") {
+		t.Errorf("newPlan() didn't fail on a conflict: %v", err)
+	}
+}
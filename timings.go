@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// showTimings will print how long each phase of a sync took, and how many
+// API calls were made.
+var showTimings bool
+
+// timingPhases are the phases of a sync, in order.
+var timingPhases = []string{"parse", "fetch", "diff", "apply"}
+
+// timingReport collects durations and API calls for a single sync.
+type timingReport struct {
+	lock   sync.Mutex
+	phases map[string]time.Duration
+	calls  map[string]int
+}
+
+// timings is the report for the current sync.
+var timings = newTimingReport()
+
+// newTimingReport returns an empty report.
+func newTimingReport() *timingReport {
+	return &timingReport{
+		phases: map[string]time.Duration{},
+		calls:  map[string]int{},
+	}
+}
+
+// start will start timing phase. The returned function must be called when
+// the phase is done.
+func (t *timingReport) start(phase string) func() {
+	start := now()
+
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		t.phases[phase] += now().Sub(start)
+	}
+}
+
+// call will count an API call using method.
+func (t *timingReport) call(method string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.calls[method]++
+}
+
+// reset will clear the report.
+func (t *timingReport) reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.phases = map[string]time.Duration{}
+	t.calls = map[string]int{}
+}
+
+// Fprint will output the report for zoneName to w.
+func (t *timingReport) Fprint(w io.Writer, zoneName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	fmt.Fprintf(w, "Timings for %s:\n", zoneName)
+
+	for _, phase := range timingPhases {
+		fmt.Fprintf(w, "  %-6s %s\n", phase, t.phases[phase].Round(time.Millisecond))
+	}
+
+	total := 0
+	methods := []string{}
+	for method, n := range t.calls {
+		total += n
+		methods = append(methods, fmt.Sprintf("%s %d", method, n))
+	}
+
+	sort.Strings(methods)
+
+	if total == 0 {
+		fmt.Fprintf(w, "  API calls: 0\n")
+		return
+	}
+
+	fmt.Fprintf(w, "  API calls: %d (%s)\n", total, strings.Join(methods, ", "))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	clock := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	r := newTimingReport()
+
+	done := r.start("parse")
+	clock = clock.Add(1500 * time.Millisecond)
+	done()
+
+	done = r.start("fetch")
+	clock = clock.Add(200 * time.Millisecond)
+	done()
+
+	r.call("GET")
+	r.call("GET")
+	r.call("POST")
+
+	var b bytes.Buffer
+	r.Fprint(&b, "example.com")
+
+	expected := `Timings for example.com:
+  parse  1.5s
+  fetch  200ms
+  diff   0s
+  apply  0s
+  API calls: 3 (GET 2, POST 1)
+`
+
+	if b.String() != expected {
+		t.Errorf("Fprint() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+
+	r.reset()
+	b.Reset()
+	r.Fprint(&b, "example.com")
+
+	if !bytes.Contains(b.Bytes(), []byte("  parse  0s\n")) || !bytes.Contains(b.Bytes(), []byte("API calls: 0\n")) {
+		t.Errorf("reset() didn't clear the report: [%s]", b.String())
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+var (
+	// caBundle is a file with PEM encoded CA certificates trusted for API
+	// connections instead of the system roots, like for an inspecting
+	// proxy.
+	caBundle = ""
+
+	// clientCert and clientKey are PEM files with a client certificate and
+	// its key presented to the API endpoint.
+	clientCert = ""
+	clientKey  = ""
+)
+
+// baseTransport returns the transport used for API connections, configured
+// according to -cabundle, -clientcert and -clientkey.
+func baseTransport() (http.RoundTripper, error) {
+	if caBundle == "" && clientCert == "" && clientKey == "" {
+		return http.DefaultTransport, nil
+	}
+
+	config := &tls.Config{}
+
+	if caBundle != "" {
+		pem, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in '%s'", caBundle)
+		}
+	}
+
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, errors.New("-clientcert and -clientkey must be used together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = config
+
+	return t, nil
+}
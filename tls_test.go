@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM will write a PEM block of typ to path.
+func writePEM(t *testing.T, path string, typ string, der []byte) {
+	err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write '%s': %s", path, err.Error())
+	}
+}
+
+func TestBaseTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// A self-signed client certificate.
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cfzone"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %s", err.Error())
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	clientCert = filepath.Join(dir, "client.pem")
+	clientKey = filepath.Join(dir, "client.key")
+	caBundle = filepath.Join(dir, "ca.pem")
+	defer func() { caBundle, clientCert, clientKey = "", "", "" }()
+
+	writePEM(t, clientCert, "CERTIFICATE", der)
+	writePEM(t, clientKey, "EC PRIVATE KEY", keyDER)
+
+	cert, _ := x509.ParseCertificate(der)
+	clients := x509.NewCertPool()
+	clients.AddCert(cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clients}
+	server.StartTLS()
+	defer server.Close()
+
+	writePEM(t, caBundle, "CERTIFICATE", server.Certificate().Raw)
+
+	transport, err := baseTransport()
+	if err != nil {
+		t.Fatalf("baseTransport() failed: %s", err.Error())
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	// Without the client certificate, the server must refuse us.
+	clientCert, clientKey = "", ""
+
+	transport, err = baseTransport()
+	if err != nil {
+		t.Fatalf("baseTransport() failed: %s", err.Error())
+	}
+
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	if err == nil {
+		t.Errorf("Request without a client certificate succeeded")
+	}
+}
+
+func TestBaseTransportErrors(t *testing.T) {
+	defer func() { caBundle, clientCert, clientKey = "", "", "" }()
+
+	transport, err := baseTransport()
+	if err != nil || transport != http.DefaultTransport {
+		t.Errorf("baseTransport() didn't return the default transport")
+	}
+
+	p := writeTempFile(t, "not a certificate\n")
+	defer os.Remove(p)
+
+	caBundle = p
+	if _, err := baseTransport(); err == nil {
+		t.Errorf("baseTransport() accepted a CA bundle without certificates")
+	}
+
+	caBundle, clientCert = "", p
+	if _, err := baseTransport(); err == nil {
+		t.Errorf("baseTransport() accepted -clientcert without -clientkey")
+	}
+}
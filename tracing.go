@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// span is a single OpenTelemetry span. A nil *span is valid, and does
+// nothing. This is what's returned when tracing is disabled.
+type span struct {
+	traceID  string
+	spanID   string
+	parentID string
+	parent   *span
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    map[string]string
+	err      error
+}
+
+// OpenTelemetry span kinds.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+// tracer collects spans and exports them using OTLP over HTTP.
+type tracer struct {
+	lock sync.Mutex
+
+	// endpoint is the OTLP traces endpoint. Empty disables tracing.
+	endpoint string
+	header   http.Header
+	service  string
+
+	// remote is the parent given by TRACEPARENT, if any.
+	remote *span
+
+	// active is the span new spans will be children of.
+	active *span
+
+	finished []*span
+}
+
+// tracing is the tracer of this process.
+var tracing = &tracer{}
+
+// initTracing will configure tracing from the standard OpenTelemetry
+// environment variables. Tracing is enabled if an OTLP endpoint is given.
+func initTracing() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		endpoint = strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/") + "/v1/traces"
+	}
+
+	header := http.Header{}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			registerSecret(strings.TrimSpace(kv[1]))
+			header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "cfzone"
+	}
+
+	tracing = &tracer{
+		endpoint: endpoint,
+		header:   header,
+		service:  service,
+		remote:   parseTraceParent(os.Getenv("TRACEPARENT")),
+	}
+}
+
+// parseTraceParent will parse a W3C traceparent, allowing cfzone to be part
+// of a trace started by a deploy pipeline.
+func parseTraceParent(s string) *span {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil
+	}
+
+	return &span{traceID: parts[1], spanID: parts[2]}
+}
+
+// randomID returns n random bytes as hex.
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// newSpan will start a span as a child of the active span, without making it
+// active itself.
+func (t *tracer) newSpan(name string, kind int) *span {
+	if t.endpoint == "" {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s := &span{
+		traceID: randomID(16),
+		spanID:  randomID(8),
+		name:    name,
+		kind:    kind,
+		start:   now(),
+		attrs:   map[string]string{},
+	}
+
+	parent := t.active
+	if parent == nil {
+		parent = t.remote
+	}
+
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	}
+
+	s.parent = t.active
+
+	return s
+}
+
+// startSpan will start a span, and make it active until it ends.
+func (t *tracer) startSpan(name string) *span {
+	s := t.newSpan(name, spanKindInternal)
+	if s == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+	t.active = s
+	t.lock.Unlock()
+
+	return s
+}
+
+// SetAttribute will set an attribute on the span.
+func (s *span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.attrs[key] = value
+}
+
+// End will end the span with the result err.
+func (s *span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	tracing.lock.Lock()
+	defer tracing.lock.Unlock()
+
+	s.end = now()
+	s.err = err
+
+	if tracing.active == s {
+		tracing.active = s.parent
+	}
+
+	tracing.finished = append(tracing.finished, s)
+}
+
+// otlpAttribute is a key/value attribute in OTLP JSON.
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+// otlpAttributes will convert attrs to OTLP JSON.
+func otlpAttributes(attrs map[string]string) []otlpAttribute {
+	result := []otlpAttribute{}
+	for k, v := range attrs {
+		result = append(result, otlpAttribute{k, map[string]string{"stringValue": v}})
+	}
+
+	return result
+}
+
+// flush will export all finished spans.
+func (t *tracer) flush() error {
+	t.lock.Lock()
+	finished := t.finished
+	t.finished = nil
+	t.lock.Unlock()
+
+	if t.endpoint == "" || len(finished) == 0 {
+		return nil
+	}
+
+	spans := []map[string]interface{}{}
+	for _, s := range finished {
+		o := map[string]interface{}{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+			"status":            map[string]interface{}{"code": 1},
+		}
+
+		if s.parentID != "" {
+			o["parentSpanId"] = s.parentID
+		}
+
+		if s.err != nil {
+			o["status"] = map[string]interface{}{"code": 2, "message": redact(s.err.Error())}
+		}
+
+		spans = append(spans, o)
+	}
+
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]string{
+						"service.name":    t.service,
+						"service.version": version,
+					}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "cfzone"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+
+	err := postJSON(t.endpoint, t.header, payload)
+	if err != nil {
+		return fmt.Errorf("Error exporting traces: %s", err.Error())
+	}
+
+	return nil
+}
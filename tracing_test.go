@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string          `json:"traceId"`
+				SpanID       string          `json:"spanId"`
+				ParentSpanID string          `json:"parentSpanId"`
+				Name         string          `json:"name"`
+				Kind         int             `json:"kind"`
+				Attributes   []otlpAttribute `json:"attributes"`
+				Status       struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestParseTraceParent(t *testing.T) {
+	s := parseTraceParent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if s == nil || s.traceID != "0af7651916cd43dd8448eb211c80319c" || s.spanID != "b7ad6b7169203331" {
+		t.Errorf("parseTraceParent() returned %+v", s)
+	}
+
+	for _, broken := range []string{"", "00-short-b7ad6b7169203331-01", "garbage"} {
+		if parseTraceParent(broken) != nil {
+			t.Errorf("parseTraceParent() accepted '%s'", broken)
+		}
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	tracing = &tracer{}
+
+	s := tracing.startSpan("sync")
+	if s != nil {
+		t.Errorf("startSpan() returned span with tracing disabled")
+	}
+
+	// All of this should be safe on a nil span.
+	s.SetAttribute("key", "value")
+	s.End(nil)
+
+	if tracing.flush() != nil {
+		t.Errorf("flush() returned error with tracing disabled")
+	}
+}
+
+func TestTracing(t *testing.T) {
+	var received otlpRequest
+	var header string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Api-Key")
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer ts.Close()
+
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", ts.URL)
+	os.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Api-Key=secret")
+	os.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	defer func() {
+		os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		os.Unsetenv("OTEL_EXPORTER_OTLP_HEADERS")
+		os.Unsetenv("TRACEPARENT")
+		tracing = &tracer{}
+	}()
+
+	initTracing()
+
+	if tracing.endpoint != ts.URL+"/v1/traces" {
+		t.Fatalf("initTracing() used wrong endpoint '%s'", tracing.endpoint)
+	}
+
+	root := tracing.startSpan("sync")
+	child := tracing.startSpan("fetch")
+	call := tracing.newSpan("GET /zones", spanKindClient)
+	call.End(nil)
+	child.End(errors.New("broken"))
+
+	sibling := tracing.startSpan("diff")
+	sibling.End(nil)
+	root.End(nil)
+
+	err := tracing.flush()
+	if err != nil {
+		t.Fatalf("flush() returned error: %s", err.Error())
+	}
+
+	if header != "secret" {
+		t.Errorf("flush() did not send configured headers")
+	}
+
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 4 {
+		t.Fatalf("flush() exported %d spans, expected 4", len(spans))
+	}
+
+	parents := map[string]string{}
+	ids := map[string]string{}
+	for _, s := range spans {
+		if s.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("Span %s has wrong trace ID %s", s.Name, s.TraceID)
+		}
+
+		parents[s.Name] = s.ParentSpanID
+		ids[s.Name] = s.SpanID
+	}
+
+	expected := map[string]string{
+		"sync":       "b7ad6b7169203331",
+		"fetch":      ids["sync"],
+		"GET /zones": ids["fetch"],
+		"diff":       ids["sync"],
+	}
+
+	for name, parent := range expected {
+		if parents[name] != parent {
+			t.Errorf("Span %s has parent %s, expected %s", name, parents[name], parent)
+		}
+	}
+
+	if spans[1].Name != "fetch" || spans[1].Status.Code != 2 || spans[1].Status.Message != "broken" {
+		t.Errorf("Failed span exported with wrong status: %+v", spans[1])
+	}
+
+	if tracing.active != nil {
+		t.Errorf("Active span not reset after ending all spans")
+	}
+}
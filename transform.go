@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// transformCommand is an external command run between parsing and diffing.
+// It receives the records as JSON on stdin and must write the records to
+// sync as JSON on stdout. Empty disables transforms.
+var transformCommand = ""
+
+// transformAllowEmpty lets the transform command return no records. It's an
+// error otherwise, as a broken transform printing null or [] would delete
+// every record in the zone.
+var transformAllowEmpty = false
+
+// transformInput is written to the stdin of the transform command.
+type transformInput struct {
+	Zone    string           `json:"zone"`
+	Records recordCollection `json:"records"`
+}
+
+// transformRecords will run records for zoneName through the transform
+// command, if any, and return the records it outputs.
+func transformRecords(zoneName string, records recordCollection) (recordCollection, error) {
+	if transformCommand == "" {
+		return records, nil
+	}
+
+	err := verifyCommand(transformCommand)
+	if err != nil {
+		return nil, fmt.Errorf("Transform '%s': %s", transformCommand, err.Error())
+	}
+
+	out, err := runCommand(transformCommand, zoneName, transformInput{Zone: zoneName, Records: records})
+	if err != nil {
+		return nil, fmt.Errorf("Transform %s", err.Error())
+	}
+
+	var result recordCollection
+	err = json.Unmarshal(out, &result)
+	if err != nil {
+		return nil, fmt.Errorf("Transform '%s' returned invalid records: %s", transformCommand, err.Error())
+	}
+
+	if len(result) == 0 && len(records) > 0 && !transformAllowEmpty {
+		return nil, fmt.Errorf("Transform '%s' returned no records, use -transformallowempty if that's intended", transformCommand)
+	}
+
+	for _, r := range result {
+		if r.Name == "" || r.Type == "" {
+			return nil, fmt.Errorf("Transform '%s' returned a record without name or type: %+v", transformCommand, r)
+		}
+
+		if !inZone(r.Name, zoneName) {
+			return nil, fmt.Errorf("Transform '%s' returned '%s' outside %s", transformCommand, r.Name, zoneName)
+		}
+	}
+
+	debugf(1, "Transform '%s' turned %d records into %d", transformCommand, len(records), len(result))
+
+	return result, nil
+}
+
+// verifyCommand will verify the signature of the program run by command, if
+// -minisignkeys or -gpgkeyring is used. The records output by a transform
+// are only as trusted as the program.
+func verifyCommand(command string) error {
+	if minisignKeys == "" && gpgKeyring == "" {
+		return nil
+	}
+
+	path, err := exec.LookPath(strings.Fields(command)[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = readVerified(path)
+
+	return err
+}
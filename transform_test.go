@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestTransformHelper is run as a transform command by the tests below. It
+// rewrites 10.0.0.1 to 192.0.2.1 or outputs the content of
+// CFZONE_TEST_TRANSFORM verbatim.
+func TestTransformHelper(t *testing.T) {
+	mode := os.Getenv("CFZONE_TEST_TRANSFORM")
+	if mode == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	if mode != "rewrite" {
+		os.Stdout.WriteString(mode)
+		return
+	}
+
+	var in transformInput
+	json.NewDecoder(os.Stdin).Decode(&in)
+
+	for i := range in.Records {
+		if in.Zone == os.Getenv("CFZONE_ZONE") && in.Records[i].Content == "10.0.0.1" {
+			in.Records[i].Content = "192.0.2.1"
+		}
+	}
+
+	json.NewEncoder(os.Stdout).Encode(in.Records)
+}
+
+func TestTransformRecords(t *testing.T) {
+	records := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "10.0.0.1"},
+		{Type: "A", Name: "mail.example.com", Content: "10.0.0.2"},
+	}
+
+	transformCommand = ""
+	got, err := transformRecords("example.com", records)
+	if err != nil || len(got) != 2 {
+		t.Errorf("transformRecords() changed records without a transform")
+	}
+
+	// Other tests replace os.Args.
+	self, err := os.Executable()
+	if err != nil {
+		t.Skipf("Can't find test binary: %s", err.Error())
+	}
+
+	transformCommand = self + " -test.run=TestTransformHelper"
+	defer func() { transformCommand = "" }()
+	defer os.Unsetenv("CFZONE_TEST_TRANSFORM")
+
+	cases := []struct {
+		mode     string
+		err      string
+		expected string
+	}{
+		{"rewrite", "", "192.0.2.1"},
+		{"not json", "returned invalid records", ""},
+		{`[{"type": "A", "content": "192.0.2.1"}]`, "without name or type", ""},
+		{`[{"type": "A", "name": "www.example.org", "content": "192.0.2.1"}]`, "outside example.com", ""},
+		{"null", "returned no records", ""},
+		{"[]", "returned no records", ""},
+	}
+
+	for _, c := range cases {
+		os.Setenv("CFZONE_TEST_TRANSFORM", c.mode)
+
+		got, err := transformRecords("example.com", records)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: transformRecords() returned wrong error: %v", c.mode, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: transformRecords() failed: %s", c.mode, err.Error())
+			continue
+		}
+
+		if len(got) != 2 || got[0].Content != c.expected || got[1].Content != "10.0.0.2" {
+			t.Errorf("%s: transformRecords() returned wrong records: %+v", c.mode, got)
+		}
+	}
+
+	transformAllowEmpty = true
+	defer func() { transformAllowEmpty = false }()
+
+	got, err = transformRecords("example.com", records)
+	if err != nil || len(got) != 0 {
+		t.Errorf("transformRecords() with -transformallowempty returned %+v, %v", got, err)
+	}
+
+	transformCommand = "/nonexistent/transform"
+	_, err = transformRecords("example.com", records)
+	if err == nil {
+		t.Errorf("transformRecords() didn't fail for missing command")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// skipUnchanged will skip syncing zone files not changed since the last
+// successful sync.
+var skipUnchanged bool
+
+// stateDir is where state from earlier syncs is kept. Empty means a
+// directory in the user cache directory.
+var stateDir = ""
+
+// statePath returns the path of name in the state directory.
+func statePath(name ...string) (string, error) {
+	base := stateDir
+	if base == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+
+		base = filepath.Join(cache, "cfzone")
+	}
+
+	return filepath.Join(append([]string{base}, name...)...), nil
+}
+
+// zoneHash returns a hash of everything deciding the records synced from the
+// zone file at path: The zone file (templates as rendered), the
+// configuration with its layers, targets, overrides, delegations and zone
+// options, the -values file, the transform program and the effective flags.
+func zoneHash(path string) (string, error) {
+	open := openZone
+	if renderTemplates || valuesPath != "" {
+		open = func(path string) (io.ReadCloser, error) {
+			return openRenderedZone(path, "")
+		}
+	}
+
+	h := sha256.New()
+
+	err := hashFile(h, "zone", path, open)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(h, "config %d\n", len(b))
+	h.Write(b)
+
+	// The configuration only names layers and overrides, their content
+	// must be hashed too.
+	files := []string{}
+	for _, z := range cfg.Zones {
+		files = append(files, z.Layers...)
+		for _, target := range z.Targets {
+			files = append(files, target.Overrides...)
+		}
+	}
+
+	if valuesPath != "" {
+		files = append(files, valuesPath)
+	}
+
+	if transformCommand != "" {
+		program, err := exec.LookPath(strings.Fields(transformCommand)[0])
+		if err != nil {
+			return "", err
+		}
+
+		files = append(files, program)
+	}
+
+	sort.Strings(files)
+
+	for _, f := range files {
+		err = hashFile(h, "file", f, openZone)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	names := []string{}
+	for name := range baseFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(h, "flag %s=%q\n", name, baseFlags[name])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile will write the content of the file at path, opened using open, to
+// h. The content is preceded by kind, path and length, so content moved
+// between inputs changes the hash.
+func hashFile(h io.Writer, kind string, path string, open func(string) (io.ReadCloser, error)) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(h, "%s %s %d\n", kind, path, len(content))
+	_, err = h.Write(content)
+
+	return err
+}
+
+// syncedPath returns the path of the file holding the hash of the zone file
+// at path as of the last successful sync.
+func syncedPath(path string) (string, error) {
+	sum := sha256.Sum256([]byte(path))
+
+	return statePath("synced", hex.EncodeToString(sum[:8]))
+}
+
+// unchanged returns the hash of the zone file at path, and true if it's the
+// same as when it was last synced successfully. Errors are logged and
+// treated as changes, to make sure we sync.
+func unchanged(path string) (string, bool) {
+	hash, err := zoneHash(path)
+	if err != nil {
+		debugf(1, "Can't hash '%s': %s", path, err.Error())
+		return "", false
+	}
+
+	synced, err := syncedPath(path)
+	if err != nil {
+		debugf(1, "Can't find state for '%s': %s", path, err.Error())
+		return hash, false
+	}
+
+	last, err := ioutil.ReadFile(synced)
+	if err != nil {
+		return hash, false
+	}
+
+	return hash, string(bytes.TrimSpace(last)) == hash
+}
+
+// markSynced will remember hash as the content of the zone file at path as
+// of the last successful sync.
+func markSynced(path string, hash string) error {
+	synced, err := syncedPath(path)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(synced), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(synced, []byte(hash+"\n"), 0600)
+}
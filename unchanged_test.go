@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	stateDir = filepath.Join(dir, "state")
+	defer func() { stateDir = "" }()
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte("broken"), 0644)
+
+	hash, same := unchanged(path)
+	if same || hash == "" {
+		t.Fatalf("unchanged() returned %s, %v for a zone never synced", hash, same)
+	}
+
+	err = markSynced(path, hash)
+	if err != nil {
+		t.Fatalf("markSynced() failed: %s", err.Error())
+	}
+
+	_, same = unchanged(path)
+	if !same {
+		t.Fatalf("unchanged() didn't recognize a synced zone")
+	}
+
+	// The zone is broken, syncing it would fail.
+	skipUnchanged = true
+	defer func() { skipUnchanged = false }()
+
+	err = syncZone(path)
+	if err != nil {
+		t.Fatalf("syncZone() didn't skip an unchanged zone: %s", err.Error())
+	}
+
+	ioutil.WriteFile(path, []byte("still broken"), 0644)
+
+	_, same = unchanged(path)
+	if same {
+		t.Fatalf("unchanged() didn't notice a change")
+	}
+
+	err = syncZone(path)
+	if err == nil {
+		t.Fatalf("syncZone() skipped a changed zone")
+	}
+}
+
+func TestZoneHashInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		cfg = config{}
+		baseFlags = nil
+		valuesPath = ""
+	}()
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte(validZone), 0644)
+
+	layer := filepath.Join(dir, "layer.zone")
+	ioutil.WriteFile(layer, []byte("www 300 IN A 192.0.2.1\n"), 0644)
+
+	override := filepath.Join(dir, "override.zone")
+	ioutil.WriteFile(override, []byte("www 300 IN A 192.0.2.2\n"), 0644)
+
+	values := filepath.Join(dir, "values.yaml")
+	ioutil.WriteFile(values, []byte("ip: 192.0.2.3\n"), 0644)
+
+	z := cfg.Zones["example.com"]
+	z.Layers = []string{layer}
+	z.Targets = []targetConfig{{Zone: "example.net", Overrides: []string{override}}}
+	cfg.Zones = map[string]zoneConfig{"example.com": z}
+	valuesPath = values
+	baseFlags = map[string]string{"leaveunknown": "false"}
+
+	last, err := zoneHash(path)
+	if err != nil {
+		t.Fatalf("zoneHash() failed: %s", err.Error())
+	}
+
+	changes := map[string]func(){
+		"layer":         func() { ioutil.WriteFile(layer, []byte("www 300 IN A 192.0.2.9\n"), 0644) },
+		"override":      func() { ioutil.WriteFile(override, []byte("www 300 IN A 192.0.2.9\n"), 0644) },
+		"values":        func() { ioutil.WriteFile(values, []byte("ip: 192.0.2.9\n"), 0644) },
+		"flags":         func() { baseFlags["leaveunknown"] = "true" },
+		"configuration": func() { cfg.Zones["example.com"] = zoneConfig{DefaultTTL: 600, Layers: z.Layers, Targets: z.Targets} },
+	}
+
+	for _, input := range []string{"layer", "override", "values", "flags", "configuration"} {
+		changes[input]()
+
+		hash, err := zoneHash(path)
+		if err != nil {
+			t.Fatalf("zoneHash() failed after changing the %s: %s", input, err.Error())
+		}
+
+		if hash == last {
+			t.Errorf("zoneHash() didn't change with the %s", input)
+		}
+
+		last = hash
+	}
+}
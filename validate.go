@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/miekg/dns"
+)
+
+type (
+	// zoneEntry is a single resource record from a zone file.
+	zoneEntry struct {
+		rr dns.RR
+
+		// line is the line number the record starts on, or 0 if unknown.
+		line int
+	}
+
+	// problem is a problem found while validating a zone file.
+	problem struct {
+		line     int
+		severity string
+		message  string
+	}
+
+	// zoneCheck is a single validation of a zone file.
+	zoneCheck func(zoneName string, entries []zoneEntry) []problem
+)
+
+// Problem severities.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// zoneChecks holds all checks run by validateZone.
+var zoneChecks = []zoneCheck{
+	checkSupported,
+	checkNames,
+	checkCNAME,
+	checkApex,
+	checkDuplicates,
+	checkTTLs,
+	checkContent,
+	checkOutOfZone,
+	checkProxied,
+}
+
+// maxTTL is the largest TTL allowed by RFC 2181.
+const maxTTL = 1<<31 - 1
+
+// minTTL is the smallest TTL accepted by Cloudflare. Lower TTLs are clamped,
+// except 0 for automatic and 1 for proxied.
+const minTTL = 60
+
+// String implements fmt.Stringer. The line number is left out if unknown.
+func (p problem) String() string {
+	if p.line == 0 {
+		return fmt.Sprintf("%s: %s", p.severity, p.message)
+	}
+
+	return fmt.Sprintf("%d: %s: %s", p.line, p.severity, p.message)
+}
+
+// recordLines returns the line number of every record in the zone file in
+// order of appearance. Directives, comments, blank lines and continuation
+// lines inside parentheses are skipped.
+func recordLines(b []byte) []int {
+	lines := []int{}
+	depth := 0
+
+	for i, line := range strings.Split(string(b), "\n") {
+		start := depth == 0
+		content := false
+		quoted := false
+
+	scan:
+		for j := 0; j < len(line); j++ {
+			switch c := line[j]; {
+			case c == '\\' && quoted:
+				j++
+
+			case c == '"':
+				quoted = !quoted
+				content = true
+
+			case c == ';' && !quoted:
+				break scan
+
+			case c == '(' && !quoted:
+				depth++
+				content = true
+
+			case c == ')' && !quoted:
+				depth--
+				content = true
+
+			case c != ' ' && c != '\t' && c != '\r':
+				content = true
+			}
+		}
+
+		if start && content && !strings.HasPrefix(line, "$") {
+			lines = append(lines, i+1)
+		}
+	}
+
+	return lines
+}
+
+// parseZoneEntries will parse a zone file, and return all records with the
+// line they start on.
+func parseZoneEntries(r io.Reader) ([]zoneEntry, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []zoneEntry{}
+	for t := range dns.ParseZone(bytes.NewReader(b), "", "") {
+		if t.Error != nil {
+			return nil, t.Error
+		}
+
+		entries = append(entries, zoneEntry{rr: t.RR})
+	}
+
+	// If the file contains $INCLUDE or $GENERATE the lines will not match
+	// up, and we leave them unknown.
+	lines := recordLines(b)
+	if len(lines) == len(entries) {
+		for i := range entries {
+			entries[i].line = lines[i]
+		}
+	}
+
+	return entries, nil
+}
+
+// validateZone will run all checks on the zone file read from r, and return
+// the zone name and all problems found sorted by line.
+func validateZone(r io.Reader) (string, []problem) {
+	entries, err := parseZoneEntries(r)
+	if err != nil {
+		return "", []problem{{0, severityError, err.Error()}}
+	}
+
+	zoneName := ""
+	for _, e := range entries {
+		if soa, found := e.rr.(*dns.SOA); found {
+			zoneName = strings.ToLower(strings.Trim(soa.Header().Name, "."))
+		}
+	}
+
+	if zoneName == "" {
+		return "", []problem{{0, severityError, "Zone name not found, the zone file has no SOA record"}}
+	}
+
+	problems := []problem{}
+	for _, check := range zoneChecks {
+		problems = append(problems, check(zoneName, entries)...)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].line < problems[j].line
+	})
+
+	return zoneName, problems
+}
+
+// entryName returns the lower case name of the record without the trailing
+// dot.
+func entryName(e zoneEntry) string {
+	return strings.ToLower(strings.Trim(e.rr.Header().Name, "."))
+}
+
+// entryType returns the type of the record as a string.
+func entryType(e zoneEntry) string {
+	return dns.TypeToString[e.rr.Header().Rrtype]
+}
+
+// checkSupported will report records not supported by cfzone.
+func checkSupported(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for _, e := range entries {
+		_, err := cfzone.NewRecord(e.rr)
+		if err != nil {
+			problems = append(problems, problem{e.line, severityError, err.Error()})
+		}
+	}
+
+	return problems
+}
+
+// checkNames will report names with illegal characters.
+func checkNames(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for _, e := range entries {
+		name := entryName(e)
+
+		err := cfzone.WildcardError(name)
+		if err != nil {
+			problems = append(problems, problem{e.line, severityError, err.Error()})
+			continue
+		}
+
+		for i, label := range strings.Split(name, ".") {
+			// A wildcard is allowed as the first label.
+			if i == 0 && label == "*" {
+				continue
+			}
+
+			if !cfzone.ValidLabel(label) {
+				problems = append(problems, problem{e.line, severityError, fmt.Sprintf("Illegal name '%s'", name)})
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkCNAME will report CNAME records coexisting with other records at the
+// same name.
+func checkCNAME(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for i, e := range entries {
+		if entryType(e) != "CNAME" {
+			continue
+		}
+
+		for j, other := range entries {
+			if i == j || entryName(other) != entryName(e) {
+				continue
+			}
+
+			// Two CNAMEs are only reported once.
+			if entryType(other) == "CNAME" && j < i {
+				continue
+			}
+
+			// SOA and NS at the apex are left to Cloudflare, and don't
+			// conflict with a flattened CNAME.
+			if entryName(e) == zoneName && (entryType(other) == "SOA" || entryType(other) == "NS") {
+				continue
+			}
+
+			problems = append(problems, problem{e.line, severityError, fmt.Sprintf("CNAME at '%s' coexists with %s record on line %d", entryName(e), entryType(other), other.line)})
+		}
+	}
+
+	return problems
+}
+
+// checkApex will report missing NS records and CNAME records at the zone
+// apex.
+func checkApex(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+	ns := false
+
+	for _, e := range entries {
+		if entryName(e) != zoneName {
+			continue
+		}
+
+		switch entryType(e) {
+		case "NS":
+			ns = true
+
+		case "CNAME":
+			problems = append(problems, problem{e.line, severityWarning, "CNAME at the apex will be flattened by Cloudflare"})
+		}
+	}
+
+	if !ns {
+		problems = append(problems, problem{0, severityWarning, fmt.Sprintf("No NS records at the apex of '%s'", zoneName)})
+	}
+
+	return problems
+}
+
+// checkDuplicates will report records identical to an earlier record, whatever
+// their TTLs.
+func checkDuplicates(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for i, e := range entries {
+		for _, earlier := range entries[:i] {
+			if dns.IsDuplicate(e.rr, earlier.rr) {
+				problems = append(problems, problem{e.line, severityWarning, fmt.Sprintf("Duplicate of record on line %d", earlier.line)})
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// ttlProblem returns the severity and description of a problem with ttl, or
+// empty strings if ttl is fine.
+func ttlProblem(ttl int64) (string, string) {
+	switch {
+	case ttl < 0:
+		return severityError, fmt.Sprintf("Negative TTL %d", ttl)
+
+	case ttl > maxTTL:
+		return severityError, fmt.Sprintf("TTL %d is above the maximum of %d", ttl, maxTTL)
+
+	case ttl > 1 && ttl < minTTL:
+		return severityWarning, fmt.Sprintf("TTL %d will be raised to %d by Cloudflare", ttl, minTTL)
+	}
+
+	return "", ""
+}
+
+// checkTTLs will report insensible TTLs.
+func checkTTLs(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for _, e := range entries {
+		severity, message := ttlProblem(int64(e.rr.Header().Ttl))
+		if severity != "" {
+			problems = append(problems, problem{e.line, severity, message})
+		}
+	}
+
+	return problems
+}
+
+// checkContent will report records with content invalid for their type.
+func checkContent(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for _, e := range entries {
+		err := cfzone.ContentError(e.rr)
+		if err != nil {
+			problems = append(problems, problem{e.line, severityError, err.Error()})
+		}
+	}
+
+	return problems
+}
+
+// checkOutOfZone will report records outside the zone.
+func checkOutOfZone(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for _, e := range entries {
+		if !inZone(entryName(e), zoneName) {
+			problems = append(problems, problem{e.line, severityWarning, fmt.Sprintf("'%s' is outside the zone", entryName(e))})
+		}
+	}
+
+	return problems
+}
+
+// checkProxied will report records with a TTL of 1 that can't be proxied.
+// They are synced with automatic TTL.
+func checkProxied(zoneName string, entries []zoneEntry) []problem {
+	problems := []problem{}
+
+	for _, e := range entries {
+		if e.rr.Header().Ttl == 1 && !cfzone.Proxiable(entryType(e)) && entryType(e) != "SOA" && entryType(e) != "NS" {
+			problems = append(problems, problem{e.line, severityWarning, fmt.Sprintf("%s records can't be proxied, TTL 1 means automatic TTL", entryType(e))})
+		}
+	}
+
+	return problems
+}
+
+// runValidate implements "cfzone validate <file>...". All problems are
+// reported, and cfzone will exit with 1 if any errors were found.
+func runValidate(args []string) {
+	if len(args) < 1 {
+		errorf("Usage: cfzone validate <zone file>...")
+		exit(1)
+	}
+
+	failed := false
+
+	for _, path := range args {
+		f, err := openZone(path)
+		if err != nil {
+			errorf("Error opening '%s': %s", path, err.Error())
+			failed = true
+			continue
+		}
+
+		_, problems := validateZone(f)
+		f.Close()
+
+		for _, p := range problems {
+			if p.line == 0 {
+				fmt.Fprintf(stdout, "%s: %s\n", path, p.String())
+			} else {
+				fmt.Fprintf(stdout, "%s:%s\n", path, p.String())
+			}
+
+			if p.severity == severityError {
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		exit(1)
+	}
+}
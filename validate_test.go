@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const validZone = `$ORIGIN example.com.
+$TTL 300
+@ IN SOA ns1.example.com. hostmaster.example.com. (
+	2019010101 ; serial
+	3600       ; refresh
+	600        ; retry
+	86400      ; expire
+	300 )      ; minimum
+@    IN NS    ns1.example.com.
+; A comment
+www  IN A     192.0.2.1
+txt  IN TXT   "with ; semicolon" "and ( paren"
+`
+
+func TestRecordLines(t *testing.T) {
+	expected := []int{3, 9, 11, 12}
+
+	result := recordLines([]byte(validZone))
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("recordLines() returned %v, expected %v", result, expected)
+	}
+}
+
+func TestValidateZone(t *testing.T) {
+	cases := []struct {
+		zone     string
+		expected []string
+	}{
+		{validZone, []string{}},
+		{
+			validZone + "www IN CNAME example.com.\n",
+			[]string{"13: error: CNAME at 'www.example.com' coexists with A record on line 11"},
+		},
+		{
+			validZone + "ftp IN CNAME www\nftp IN CNAME txt\n",
+			[]string{"13: error: CNAME at 'ftp.example.com' coexists with CNAME record on line 14"},
+		},
+		{
+			validZone + "_under_score IN A 192.0.2.1\n-bad IN A 192.0.2.1\n*.wild IN A 192.0.2.1\n",
+			[]string{"14: error: Illegal name '-bad.example.com'"},
+		},
+		{
+			validZone + "_dmarc IN TXT \"v=DMARC1; p=reject\"\n_sip._tcp IN TXT \"sip\"\n_acme-challenge.www IN CNAME _acme.example.net.\n",
+			[]string{},
+		},
+		{
+			validZone + "srv IN SRV 0 0 80 www\n",
+			[]string{"13: error: Record type *dns.SRV is not supported"},
+		},
+		{
+			strings.Replace(validZone, "@    IN NS    ns1.example.com.\n", "", 1),
+			[]string{"warning: No NS records at the apex of 'example.com'"},
+		},
+		{
+			validZone + "www IN A 192.0.2.1\nwww 600 IN A 192.0.2.1\n",
+			[]string{"13: warning: Duplicate of record on line 11", "14: warning: Duplicate of record on line 11"},
+		},
+		{
+			validZone + "@ IN CNAME lb.example.net.\n",
+			[]string{"13: warning: CNAME at the apex will be flattened by Cloudflare"},
+		},
+		{
+			validZone + "www.example.net. IN A 192.0.2.1\n",
+			[]string{"13: warning: 'www.example.net' is outside the zone"},
+		},
+		{
+			validZone + "www.* IN A 192.0.2.1\n* 1 IN TXT \"hello\"\n",
+			[]string{"13: error: Wildcard in 'www.*.example.com' must be the leftmost label", "14: warning: TXT records can't be proxied, TTL 1 means automatic TTL"},
+		},
+		{
+			validZone + "v6 IN A ::1\nv4 IN AAAA 192.0.2.1\n",
+			[]string{"13: error: A record content '::1' is not an IPv4 address", "14: error: AAAA record content '192.0.2.1' is not an IPv6 address"},
+		},
+		{
+			validZone + "low 30 IN A 192.0.2.1\nhigh 3000000000 IN A 192.0.2.1\n",
+			[]string{"13: warning: TTL 30 will be raised to 60 by Cloudflare", "14: error: TTL 3000000000 is above the maximum of 2147483647"},
+		},
+		{
+			"$ORIGIN example.com.\nwww IN A 192.0.2.1\n",
+			[]string{"error: Zone name not found, the zone file has no SOA record"},
+		},
+	}
+
+	for i, c := range cases {
+		zoneName, problems := validateZone(bytes.NewBufferString(c.zone))
+
+		result := []string{}
+		for _, p := range problems {
+			result = append(result, p.String())
+		}
+
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: validateZone() returned %q, expected %q", i, result, c.expected)
+		}
+
+		if len(c.expected) == 0 && zoneName != "example.com" {
+			t.Errorf("%d: validateZone() returned zone name '%s'", i, zoneName)
+		}
+	}
+}
+
+func TestValidateZoneParseError(t *testing.T) {
+	_, problems := validateZone(bytes.NewBufferString(validZone + "www IN A 192.0.2.300\n"))
+
+	if len(problems) != 1 || problems[0].severity != severityError || !strings.Contains(problems[0].message, "line: 13") {
+		t.Errorf("validateZone() returned wrong problems for broken zone: %+v", problems)
+	}
+}
+
+func TestRunValidateUsage(t *testing.T) {
+	defer expectExit(t, 1)
+
+	runValidate([]string{})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultTimeout is the maximum duration of each request to Vault.
+const vaultTimeout = 30 * time.Second
+
+// kubernetesTokenPath is where Kubernetes mounts the service account token.
+const kubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// vaultConfig references an API token stored in HashiCorp Vault.
+type vaultConfig struct {
+	// Address of the Vault server. Defaults to VAULT_ADDR.
+	Address string `yaml:"address"`
+
+	// Namespace is the Vault Enterprise namespace, if any. Defaults to
+	// VAULT_NAMESPACE.
+	Namespace string `yaml:"namespace"`
+
+	// Path of the secret, like 'secret/data/cloudflare' for a KV version
+	// 2 secrets engine mounted at 'secret'.
+	Path string `yaml:"path"`
+
+	// Field of the secret holding the token. Defaults to 'token'.
+	Field string `yaml:"field"`
+
+	// Auth is the authentication method, 'token' to use VAULT_TOKEN,
+	// 'approle' or 'kubernetes'. Defaults to 'token'.
+	Auth string `yaml:"auth"`
+
+	// Mount is where the authentication method is mounted. Defaults to
+	// the name of the method.
+	Mount string `yaml:"mount"`
+
+	// RoleID, SecretIDEnv and SecretIDFile are used for AppRole
+	// authentication.
+	RoleID       string `yaml:"role_id"`
+	SecretIDEnv  string `yaml:"secret_id_env"`
+	SecretIDFile string `yaml:"secret_id_file"`
+
+	// Role and JWTFile are used for Kubernetes authentication. JWTFile
+	// defaults to the service account token of the pod.
+	Role    string `yaml:"role"`
+	JWTFile string `yaml:"jwt_file"`
+}
+
+// vaultClient is a minimal client for the Vault HTTP API.
+type vaultClient struct {
+	config vaultConfig
+	client *http.Client
+	token  string
+}
+
+// token will log in to Vault and read the API token from the secret.
+func (v vaultConfig) token() (string, error) {
+	if v.Address == "" {
+		v.Address = os.Getenv("VAULT_ADDR")
+	}
+
+	if v.Address == "" {
+		return "", errors.New("No Vault address, please set VAULT_ADDR")
+	}
+
+	if v.Namespace == "" {
+		v.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	if v.Path == "" {
+		return "", errors.New("No Vault secret path")
+	}
+
+	if v.Field == "" {
+		v.Field = "token"
+	}
+
+	transport, err := baseTransport()
+	if err != nil {
+		return "", err
+	}
+
+	c := &vaultClient{
+		config: v,
+		client: &http.Client{Transport: transport, Timeout: vaultTimeout},
+	}
+
+	err = c.login()
+	if err != nil {
+		return "", err
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	err = c.do("GET", strings.Trim(v.Path, "/"), nil, &secret)
+	if err != nil {
+		return "", err
+	}
+
+	// KV version 2 nests the secret in another data object.
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	token, _ := data[v.Field].(string)
+	if token == "" {
+		return "", fmt.Errorf("No '%s' field in the Vault secret at '%s'", v.Field, v.Path)
+	}
+
+	return token, nil
+}
+
+// login will authenticate to Vault using the configured method.
+func (c *vaultClient) login() error {
+	var body map[string]string
+
+	switch c.config.Auth {
+	case "", "token":
+		c.token = os.Getenv("VAULT_TOKEN")
+		registerSecret(c.token)
+		if c.token == "" {
+			return errors.New("No Vault token, please set VAULT_TOKEN")
+		}
+
+		return nil
+
+	case "approle":
+		secretID, err := readSecret(c.config.SecretIDEnv, c.config.SecretIDFile)
+		if err != nil {
+			return err
+		}
+
+		body = map[string]string{"role_id": c.config.RoleID, "secret_id": secretID}
+
+	case "kubernetes":
+		path := c.config.JWTFile
+		if path == "" {
+			path = kubernetesTokenPath
+		}
+
+		jwt, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		registerSecret(strings.TrimSpace(string(jwt)))
+
+		body = map[string]string{"role": c.config.Role, "jwt": strings.TrimSpace(string(jwt))}
+
+	default:
+		return fmt.Errorf("Unknown Vault authentication method '%s'", c.config.Auth)
+	}
+
+	mount := c.config.Mount
+	if mount == "" {
+		mount = c.config.Auth
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+
+	err := c.do("POST", "auth/"+strings.Trim(mount, "/")+"/login", body, &resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.Auth.ClientToken == "" {
+		return errors.New("Vault login returned no token")
+	}
+
+	c.token = resp.Auth.ClientToken
+	registerSecret(c.token)
+
+	return nil
+}
+
+// do will make a request to the Vault API at path and decode the response
+// into result.
+func (c *vaultClient) do(method string, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.config.Address, "/")+"/v1/"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+
+		json.Unmarshal(b, &failure)
+
+		return fmt.Errorf("Vault returned %s for '%s': %s", resp.Status, path, strings.Join(failure.Errors, ", "))
+	}
+
+	return json.Unmarshal(b, result)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVaultToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	jwtPath := filepath.Join(dir, "jwt")
+	ioutil.WriteFile(jwtPath, []byte("service-account-jwt\n"), 0600)
+
+	secretIDPath := filepath.Join(dir, "secret-id")
+	ioutil.WriteFile(secretIDPath, []byte("the-secret-id\n"), 0600)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			if body["role_id"] == "cfzone" && body["secret_id"] == "the-secret-id" {
+				w.Write([]byte(`{"auth":{"client_token":"approle-token"}}`))
+				return
+			}
+
+		case "/v1/auth/k8s/login":
+			if body["role"] == "cfzone" && body["jwt"] == "service-account-jwt" {
+				w.Write([]byte(`{"auth":{"client_token":"kubernetes-token"}}`))
+				return
+			}
+
+		case "/v1/secret/data/cloudflare":
+			switch r.Header.Get("X-Vault-Token") {
+			case "approle-token", "kubernetes-token", "static-token":
+				w.Write([]byte(`{"data":{"data":{"token":"cf-token"}}}`))
+				return
+			}
+
+		case "/v1/kv/cloudflare":
+			if r.Header.Get("X-Vault-Token") == "static-token" && r.Header.Get("X-Vault-Namespace") == "dns" {
+				w.Write([]byte(`{"data":{"api_token":"cf-kv1-token"}}`))
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+	}))
+	defer s.Close()
+
+	os.Setenv("VAULT_TOKEN", "static-token")
+	defer os.Unsetenv("VAULT_TOKEN")
+
+	cases := []struct {
+		config   vaultConfig
+		expected string
+		err      string
+	}{
+		{vaultConfig{Path: "secret/data/cloudflare"}, "cf-token", ""},
+		{vaultConfig{Path: "kv/cloudflare", Field: "api_token", Namespace: "dns"}, "cf-kv1-token", ""},
+		{vaultConfig{Path: "secret/data/cloudflare", Auth: "approle", RoleID: "cfzone", SecretIDFile: secretIDPath}, "cf-token", ""},
+		{vaultConfig{Path: "secret/data/cloudflare", Auth: "approle", RoleID: "other", SecretIDFile: secretIDPath}, "", "permission denied"},
+		{vaultConfig{Path: "secret/data/cloudflare", Auth: "kubernetes", Mount: "k8s", Role: "cfzone", JWTFile: jwtPath}, "cf-token", ""},
+		{vaultConfig{Path: "secret/data/cloudflare", Field: "missing"}, "", "No 'missing' field"},
+		{vaultConfig{Path: "secret/data/cloudflare", Auth: "ldap"}, "", "Unknown Vault authentication method"},
+		{vaultConfig{}, "", "No Vault secret path"},
+	}
+
+	for i, c := range cases {
+		c.config.Address = s.URL
+
+		token, err := c.config.token()
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%d token() returned error %v, expected '%s'", i, err, c.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d token() failed: %s", i, err.Error())
+			continue
+		}
+
+		if token != c.expected {
+			t.Errorf("%d token() returned '%s', expected '%s'", i, token, c.expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+var (
+	// verifyTargets will make cfzone resolve CNAME and MX targets before
+	// syncing, and warn about targets that don't exist.
+	verifyTargets = false
+
+	// lookupHost is used to resolve targets. Can be overridden for testing.
+	lookupHost = net.LookupHost
+)
+
+// serviceName returns true if a label in name starts with an underscore, like
+// in selector._domainkey.example.net. Such names usually only hold TXT or SRV
+// records, and can't be resolved as hosts.
+func serviceName(name string) bool {
+	for _, label := range strings.Split(name, ".") {
+		if strings.HasPrefix(label, "_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// danglingTargets will resolve all CNAME and MX targets in records, and
+// return a description of every record pointing to a name that doesn't
+// exist. Targets defined by records in the zone itself are not resolved, as
+// they may not be published yet.
+func danglingTargets(records recordCollection) []string {
+	local := map[string]bool{}
+	for _, r := range records {
+		local[strings.ToLower(r.Name)] = true
+	}
+
+	missing := map[string]bool{}
+	resolved := map[string]bool{}
+	result := []string{}
+
+	for _, r := range records {
+		if r.Type != "CNAME" && r.Type != "MX" {
+			continue
+		}
+
+		target := cfzone.CanonicalContent(r)
+		if target == "" || target == "." || local[target] || serviceName(target) {
+			continue
+		}
+
+		if !resolved[target] {
+			resolved[target] = true
+
+			_, err := lookupHost(target)
+			if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+				missing[target] = true
+			} else if err != nil {
+				debugf(1, "Unable to verify '%s': %s", target, err.Error())
+			}
+		}
+
+		if missing[target] {
+			result = append(result, recordLine(r))
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// checkTargets will warn about dangling CNAME and MX targets if
+// -verifytargets is given.
+func checkTargets(records recordCollection) {
+	if !verifyTargets {
+		return
+	}
+
+	dangling := danglingTargets(records)
+	if len(dangling) > 0 {
+		warnf("Records pointing to names that don't exist:\n  %s", strings.Join(dangling, "\n  "))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestDanglingTargets(t *testing.T) {
+	defer func(orig func(string) ([]string, error)) { lookupHost = orig }(lookupHost)
+
+	lookups := []string{}
+	lookupHost = func(host string) ([]string, error) {
+		lookups = append(lookups, host)
+
+		switch host {
+		case "gone.example.net":
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+
+		case "timeout.example.net":
+			return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+		}
+
+		return []string{"192.0.2.1"}, nil
+	}
+
+	records := recordCollection{
+		{Name: "example.com", Type: "MX", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10)},
+		{Name: "mail.example.com", Type: "A", Content: "192.0.2.2"},
+		{Name: "www.example.com", Type: "CNAME", Content: "gone.example.net"},
+		{Name: "web.example.com", Type: "CNAME", Content: "Gone.example.net."},
+		{Name: "ftp.example.com", Type: "CNAME", Content: "timeout.example.net"},
+		{Name: "blog.example.com", Type: "CNAME", Content: "blogs.example.net"},
+		{Name: "s1._domainkey.example.com", Type: "CNAME", Content: "s1._domainkey.mail.example.net"},
+		{Name: "example.com", Type: "MX", Content: ".", Priority: cloudflare.Uint16Ptr(0)},
+	}
+
+	dangling := danglingTargets(records)
+	expected := []string{
+		"web.example.com. 0 IN CNAME gone.example.net",
+		"www.example.com. 0 IN CNAME gone.example.net",
+	}
+
+	if !reflect.DeepEqual(dangling, expected) {
+		t.Errorf("danglingTargets() returned %#v, expected %#v", dangling, expected)
+	}
+
+	if !reflect.DeepEqual(lookups, []string{"gone.example.net", "timeout.example.net", "blogs.example.net"}) {
+		t.Errorf("danglingTargets() resolved wrong names: %v", lookups)
+	}
+}
+
+func TestCheckTargets(t *testing.T) {
+	defer func(orig func(string) ([]string, error)) { lookupHost = orig }(lookupHost)
+	defer func() { verifyTargets = false }()
+
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	records := recordCollection{cloudflare.DNSRecord{Name: "www.example.com", Type: "CNAME", Content: "gone.example.net"}}
+
+	buf, restore := captureStderr(0)
+	defer restore()
+
+	verifyTargets = false
+	checkTargets(records)
+	if buf.Len() != 0 {
+		t.Errorf("checkTargets() warned without -verifytargets: %s", buf.String())
+	}
+
+	verifyTargets = true
+	checkTargets(records)
+	if !strings.Contains(buf.String(), "www.example.com. 0 IN CNAME gone.example.net") {
+		t.Errorf("checkTargets() didn't warn about dangling target: %s", buf.String())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	version = "1.2.3"
+	commit = "abcdef"
+	date = "2017-01-02T03:04:05Z"
+	defer func() {
+		version = "dev"
+		commit = "unknown"
+		date = "unknown"
+	}()
+
+	var b bytes.Buffer
+	printVersion(&b)
+
+	expected := []string{
+		"cfzone 1.2.3\n",
+		"commit: abcdef\n",
+		"built: 2017-01-02T03:04:05Z\n",
+		"cloudflare-go: ",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(b.String(), e) {
+			t.Errorf("printVersion() output did not contain '%s': [%s]", e, b.String())
+		}
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	realStdout := stdout
+	defer func() { stdout = realStdout }()
+
+	var b bytes.Buffer
+	stdout = &b
+
+	func() {
+		defer expectExit(t, 0)
+
+		parseArguments([]string{"./test", "-version"})
+	}()
+
+	if !strings.HasPrefix(b.String(), "cfzone dev\n") {
+		t.Errorf("-version printed wrong output: [%s]", b.String())
+	}
+}
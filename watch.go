@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	// watch will make cfzone keep running and sync every time the zone file
+	// changes.
+	watch = false
+
+	// watchDebounce is how long we wait for the zone file to settle before
+	// syncing. Editors often write files in multiple steps.
+	watchDebounce = time.Second
+)
+
+// configMapData is the symlink Kubernetes swaps atomically when a mounted
+// ConfigMap or Secret is updated. The zone files themselves are symlinks
+// through it, and will not see any events.
+const configMapData = "..data"
+
+// watchZones will call sync for each of paths once, and then every time one
+// of the paths change until stop is closed or an error occurs while watching.
+// Errors from sync are logged, but will not stop the watch. sync is expected
+// to parse the zone file before touching Cloudflare, and thereby acting as a
+// validation gate for half-written or broken zone files.
+func watchZones(paths []string, sync func(string) error, stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	watched := map[string]bool{}
+	for _, path := range paths {
+		if isGitSource(path) {
+			return fmt.Errorf("Can't watch git source '%s', use -interval or -webhook instead", path)
+		}
+
+		watched[filepath.Clean(path)] = true
+
+		// We watch the directory instead of the file itself. Many
+		// editors replace the file on save, and we would lose track of
+		// it.
+		err = watcher.Add(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		syncLogged(path, sync)
+	}
+
+	var settled <-chan time.Time
+	changed := map[string]bool{}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			path := filepath.Clean(event.Name)
+			if filepath.Base(path) == configMapData {
+				// Every zone file in the volume could have changed.
+				for _, p := range paths {
+					if filepath.Dir(filepath.Clean(p)) == filepath.Dir(path) {
+						debugf(1, "%s changed (ConfigMap update)", p)
+						changed[filepath.Clean(p)] = true
+					}
+				}
+			} else if watched[path] {
+				debugf(1, "%s changed (%s)", path, event.Op.String())
+				changed[path] = true
+			} else {
+				continue
+			}
+
+			// Restart the timer on every change, we only sync when the
+			// files have been left alone for watchDebounce.
+			settled = time.After(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			return err
+
+		case <-settled:
+			settled = nil
+
+			// We sync in the order given on the command line.
+			for _, path := range paths {
+				if changed[filepath.Clean(path)] {
+					syncLogged(path, sync)
+				}
+			}
+
+			changed = map[string]bool{}
+
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// syncLogged will call sync, log any error and record the result for the
+// health checks.
+func syncLogged(path string, sync func(string) error) {
+	err := sync(path)
+	if err == errAborted {
+		err = nil
+	}
+
+	status.synced(path, err)
+	if err != nil {
+		errorf("%s", err.Error())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchZone(t *testing.T) {
+	watchDebounce = 50 * time.Millisecond
+	defer func() { watchDebounce = time.Second }()
+
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte("first"), 0644)
+
+	var lock sync.Mutex
+	synced := []string{}
+	record := func(p string) error {
+		b, _ := ioutil.ReadFile(p)
+
+		lock.Lock()
+		synced = append(synced, string(b))
+		lock.Unlock()
+
+		return nil
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- watchZones([]string{path}, record, stop)
+	}()
+
+	// Wait for the initial sync.
+	time.Sleep(100 * time.Millisecond)
+
+	// Changes to other files should be ignored.
+	ioutil.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0644)
+
+	// Multiple writes in quick succession should result in one sync.
+	ioutil.WriteFile(path, []byte("second"), 0644)
+	ioutil.WriteFile(path, []byte("third"), 0644)
+	time.Sleep(300 * time.Millisecond)
+
+	close(stop)
+	err = <-done
+	if err != nil {
+		t.Fatalf("watchZones() returned error: %s", err.Error())
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(synced) != 2 || synced[0] != "first" || synced[1] != "third" {
+		t.Errorf("watchZones() synced wrong content: %+v", synced)
+	}
+}
+
+func TestWatchConfigMap(t *testing.T) {
+	watchDebounce = 50 * time.Millisecond
+	defer func() { watchDebounce = time.Second }()
+
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// Mimic the layout of a ConfigMap volume as written by the kubelet.
+	os.Mkdir(filepath.Join(dir, "..v1"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "..v1", "example.com.zone"), []byte("first"), 0644)
+	os.Symlink("..v1", filepath.Join(dir, "..data"))
+	os.Symlink(filepath.Join("..data", "example.com.zone"), filepath.Join(dir, "example.com.zone"))
+
+	var lock sync.Mutex
+	synced := []string{}
+	record := func(p string) error {
+		b, _ := ioutil.ReadFile(p)
+
+		lock.Lock()
+		synced = append(synced, string(b))
+		lock.Unlock()
+
+		return nil
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- watchZones([]string{filepath.Join(dir, "example.com.zone")}, record, stop)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The kubelet writes a new directory, and atomically swaps ..data.
+	os.Mkdir(filepath.Join(dir, "..v2"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "..v2", "example.com.zone"), []byte("second"), 0644)
+	os.Symlink("..v2", filepath.Join(dir, "..data_tmp"))
+	os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data"))
+	time.Sleep(300 * time.Millisecond)
+
+	close(stop)
+	err = <-done
+	if err != nil {
+		t.Fatalf("watchZones() returned error: %s", err.Error())
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(synced) != 2 || synced[0] != "first" || synced[1] != "second" {
+		t.Errorf("watchZones() synced wrong content: %+v", synced)
+	}
+}
+
+func TestWatchRequiresYes(t *testing.T) {
+	defer expectExit(t, 1)
+
+	parseArguments([]string{"./test", "-watch", "zone"})
+}
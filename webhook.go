@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// webhookListen is the address to listen on for webhooks. Empty disables
+// webhook mode.
+var webhookListen = ""
+
+// maxWebhookBody is the maximum size of webhook payloads we will read.
+const maxWebhookBody = 1 << 20
+
+// webhook is a http.Handler receiving webhooks from a git forge. Each
+// webhook with a valid signature will trigger a sync.
+type webhook struct {
+	secret []byte
+
+	// trigger has a buffer of one. A webhook received while syncing will
+	// result in exactly one more sync, no matter how many webhooks are
+	// received.
+	trigger chan struct{}
+}
+
+// newWebhook will instantiate a new webhook handler verifying signatures
+// using secret.
+func newWebhook(secret string) *webhook {
+	return &webhook{
+		secret:  []byte(secret),
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Can't read body", http.StatusBadRequest)
+		return
+	}
+
+	if !verifySignature(h.secret, body, r.Header) {
+		debugf(1, "Webhook from %s rejected, bad signature", r.RemoteAddr)
+		http.Error(w, "Bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	debugf(1, "Webhook from %s accepted", r.RemoteAddr)
+
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+		// A sync is already pending.
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifySignature will verify the HMAC-SHA256 signature of body. Both the
+// GitHub style "X-Hub-Signature-256: sha256=<hex>" and the Gitea style
+// "X-Gitea-Signature: <hex>" headers are supported.
+func verifySignature(secret []byte, body []byte, header http.Header) bool {
+	signature := strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if signature == "" {
+		signature = header.Get("X-Gitea-Signature")
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// runWebhook will listen for webhooks on addr, and run the configured pull
+// command followed by sync for each path when a valid webhook is received.
+// It only returns on error.
+func runWebhook(addr string, paths []string, sync func(string) error) error {
+	secret, err := readSecret(cfg.Webhook.SecretEnv, cfg.Webhook.SecretFile)
+	if err != nil {
+		return err
+	}
+
+	if secret == "" {
+		return errors.New("no webhook secret configured")
+	}
+
+	h := newWebhook(secret)
+
+	go func() {
+		defer redactPanic()
+
+		for range h.trigger {
+			if cfg.Webhook.Pull != "" {
+				err := pull(cfg.Webhook.Pull)
+				if err != nil {
+					errorf("Pull failed, not syncing: %s", err.Error())
+					continue
+				}
+			}
+
+			for _, path := range paths {
+				syncLogged(path, sync)
+			}
+		}
+	}()
+
+	return http.ListenAndServe(addr, redactPanics(h))
+}
+
+// pull will run command using the shell.
+func pull(command string) error {
+	out, err := exec.Command("sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return errors.New(strings.TrimSpace(string(out)) + ": " + err.Error())
+	}
+
+	debugf(1, "%s: %s", command, strings.TrimSpace(string(out)))
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func sign(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+	good := sign("secret", body)
+	bad := sign("wrong", body)
+
+	cases := []struct {
+		header   string
+		value    string
+		expected bool
+	}{
+		{"X-Hub-Signature-256", "sha256=" + good, true},
+		{"X-Hub-Signature-256", good, true},
+		{"X-Gitea-Signature", good, true},
+		{"X-Hub-Signature-256", "sha256=" + bad, false},
+		{"X-Gitea-Signature", bad, false},
+		{"X-Hub-Signature-256", "sha256=", false},
+		{"X-Hub-Signature-256", "sha256=nothex", false},
+		{"X-Other-Signature", good, false},
+	}
+
+	for i, c := range cases {
+		header := http.Header{}
+		header.Set(c.header, c.value)
+
+		result := verifySignature([]byte("secret"), []byte(body), header)
+		if result != c.expected {
+			t.Errorf("%d: verifySignature() returned %v for %s: %s, expected %v", i, result, c.header, c.value, c.expected)
+		}
+	}
+}
+
+func TestWebhookServeHTTP(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+
+	cases := []struct {
+		method    string
+		signature string
+		status    int
+		triggered bool
+	}{
+		{"GET", sign("secret", body), http.StatusMethodNotAllowed, false},
+		{"POST", "", http.StatusUnauthorized, false},
+		{"POST", sign("wrong", body), http.StatusUnauthorized, false},
+		{"POST", sign("secret", body), http.StatusAccepted, true},
+	}
+
+	for i, c := range cases {
+		h := newWebhook("secret")
+
+		req := httptest.NewRequest(c.method, "/", bytes.NewBufferString(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+c.signature)
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%d: ServeHTTP() returned status %d, expected %d", i, w.Code, c.status)
+		}
+
+		if (len(h.trigger) == 1) != c.triggered {
+			t.Errorf("%d: ServeHTTP() trigger state wrong, expected %v", i, c.triggered)
+		}
+	}
+}
+
+func TestWebhookCoalesce(t *testing.T) {
+	body := `{}`
+	h := newWebhook("secret")
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		req.Header.Set("X-Gitea-Signature", sign("secret", body))
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("%d: ServeHTTP() returned status %d", i, w.Code)
+		}
+	}
+
+	if len(h.trigger) != 1 {
+		t.Errorf("ServeHTTP() did not coalesce triggers, got %d pending", len(h.trigger))
+	}
+}
+
+func TestRunWebhookNoSecret(t *testing.T) {
+	cfg = config{}
+
+	err := runWebhook("127.0.0.1:0", []string{"zone"}, nil)
+	if err == nil {
+		t.Errorf("runWebhook() started without a secret")
+	}
+}
+
+func TestPull(t *testing.T) {
+	err := pull("true")
+	if err != nil {
+		t.Errorf("pull() returned error for successful command: %s", err.Error())
+	}
+
+	err = pull("echo failed; false")
+	if err == nil {
+		t.Errorf("pull() did not return error for failing command")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const wildcardZone = `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+* 1 IN A 192.0.2.1
+* 1 IN MX 10 mail.example.com.
+*.dev 300 IN CNAME dev.example.net.
+`
+
+func TestParseZoneWildcards(t *testing.T) {
+	_, records, err := parseZone(strings.NewReader(wildcardZone))
+	if err != nil {
+		t.Fatalf("parseZone() failed: %s", err.Error())
+	}
+
+	expected := recordCollection{
+		{Type: "A", Name: "*.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+		{Type: "MX", Name: "*.example.com", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10), TTL: 1},
+		{Type: "CNAME", Name: "*.dev.example.com", Content: "dev.example.net", TTL: 300},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("parseZone() returned wrong wildcard records:\n%s, expected:\n%s", zoneString(records), zoneString(expected))
+	}
+
+	_, _, err = parseZone(strings.NewReader(wildcardZone + "www.* 300 IN A 192.0.2.2\n"))
+	if err == nil || err.Error() != "Wildcard in 'www.*.example.com.' must be the leftmost label" {
+		t.Errorf("parseZone() returned wrong error for misplaced wildcard: %v", err)
+	}
+}
+
+func TestWildcardMatching(t *testing.T) {
+	_, records, err := parseZone(strings.NewReader(wildcardZone))
+	if err != nil {
+		t.Fatalf("parseZone() failed: %s", err.Error())
+	}
+
+	// This is what Cloudflare returns for a proxied wildcard.
+	remote := recordCollection{
+		cloudflare.DNSRecord{ID: "1", Type: "A", Name: "*.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+		cloudflare.DNSRecord{ID: "2", Type: "MX", Name: "*.example.com", Content: "mail.example.com", Priority: cloudflare.Uint16Ptr(10), TTL: 1},
+		cloudflare.DNSRecord{ID: "3", Type: "A", Name: "www.example.com", Content: "192.0.2.1", TTL: 1, Proxied: cloudflare.BoolPtr(true)},
+	}
+
+	if diff := records.Difference(remote, cfzone.FullMatch); len(diff) != 1 || diff[0].Name != "*.dev.example.com" {
+		t.Errorf("Difference() didn't match wildcards:\n%s", zoneString(diff))
+	}
+
+	if diff := remote.Difference(records, cfzone.FullMatch); len(diff) != 1 || diff[0].ID != "3" {
+		t.Errorf("Difference() matched a wildcard to a specific name:\n%s", zoneString(diff))
+	}
+
+	// Ignore patterns are globs, the wildcard itself is matched by escaping
+	// the star.
+	if ignored := remote.WithoutIgnored([]string{"www.example.com"}); len(ignored) != 2 {
+		t.Errorf("WithoutIgnored() ignored a wildcard for a specific pattern: %v", ignored)
+	}
+
+	if ignored := remote.WithoutIgnored([]string{"\\*.example.com"}); len(ignored) != 1 || ignored[0].ID != "3" {
+		t.Errorf("WithoutIgnored() didn't ignore escaped wildcard: %v", ignored)
+	}
+}
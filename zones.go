@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+)
+
+// zonePatterns selects the zones to sync by glob patterns, like
+// "*.example-customers.com". Zone files are used as templates for all of
+// them.
+var zonePatterns = ""
+
+// matchingZones returns the names of the zones available from provider
+// matching -zones.
+func matchingZones(provider cfzone.Provider) ([]string, error) {
+	lister, ok := provider.(cfzone.ZoneLister)
+	if !ok {
+		return nil, fmt.Errorf("-zones isn't supported by the %s provider", providerName)
+	}
+
+	names, err := lister.ZoneNames()
+	if err != nil {
+		return nil, fmt.Errorf("Can't list zones: %s", err.Error())
+	}
+
+	patterns := splitList(zonePatterns)
+
+	matching := []string{}
+	for _, name := range names {
+		if matchAny(patterns, name) {
+			matching = append(matching, name)
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("No zones match '%s'", zonePatterns)
+	}
+
+	return matching, nil
+}
+
+// templateZones will read the template at path, and return its records for
+// every zone matching -zones. Names in the template are relative to each
+// zone, and it can't have an SOA record. The template is rendered for each
+// zone.
+func templateZones(path string) ([]zoneSection, error) {
+	provider, err := newProvider()
+	if err != nil {
+		return nil, fmt.Errorf("Error contacting Cloudflare: %s", err.Error())
+	}
+
+	names, err := matchingZones(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []zoneSection{}
+	for _, name := range names {
+		f, err := openRenderedZone(path, name)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening '%s' for %s: %s", path, name, err.Error())
+		}
+
+		content, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+		}
+
+		sections, err := parseZonesOrigin(bytes.NewReader(content), name)
+		if err == nil && len(sections) > 1 {
+			err = fmt.Errorf("Expected a single zone, found %d", len(sections))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading '%s' for %s: %s", path, name, err.Error())
+		}
+
+		zone := sections[0]
+		if zone.name != name {
+			return nil, fmt.Errorf("Template '%s' is for %s, templates for -zones can't have an SOA record", path, zone.name)
+		}
+
+		zone.records, err = withDelegations(zone)
+		if err != nil {
+			return nil, err
+		}
+
+		skippedTypes[name] = zone.skipped
+
+		zones = append(zones, zone)
+	}
+
+	return zones, nil
+}
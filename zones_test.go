@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anderskvist/cfzone/pkg/cfzone"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestTemplateZones(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	seed := filepath.Join(dir, "seed.json")
+	ioutil.WriteFile(seed, []byte(`[
+		{"type": "A", "name": "a.example-customers.com", "content": "192.0.2.1", "zone_name": "a.example-customers.com"},
+		{"type": "A", "name": "b.example-customers.com", "content": "192.0.2.1", "zone_name": "b.example-customers.com"},
+		{"type": "A", "name": "example.com", "content": "192.0.2.1", "zone_name": "example.com"}
+	]`), 0600)
+
+	simulated, err = loadSimulation(seed)
+	if err != nil {
+		t.Fatalf("loadSimulation() failed: %s", err.Error())
+	}
+	defer func() { simulated = nil }()
+
+	template := filepath.Join(dir, "standard.zone")
+	ioutil.WriteFile(template, []byte("@ 300 IN MX 10 mx.example.net.\nwww 300 IN CNAME @\n"), 0644)
+
+	zonePatterns = "*.example-customers.com"
+	defer func() { zonePatterns = "" }()
+
+	zones, err := templateZones(template)
+	if err != nil {
+		t.Fatalf("templateZones() failed: %s", err.Error())
+	}
+
+	if len(zones) != 2 || zones[0].name != "a.example-customers.com" || zones[1].name != "b.example-customers.com" {
+		t.Fatalf("templateZones() returned wrong zones: %+v", zones)
+	}
+
+	for _, zone := range zones {
+		if len(zone.records) != 2 || zone.records[1].Name != "www."+zone.name || zone.records[1].Content != zone.name {
+			t.Errorf("templateZones() returned wrong records for %s: %+v", zone.name, zone.records)
+		}
+	}
+
+	// Delegations are added to templated zones too.
+	cfg.Zones = map[string]zoneConfig{"a.example-customers.com": {Delegations: []delegationConfig{{Name: "sub.a.example-customers.com", NameServers: []string{"ns1.example.net"}}}}}
+	defer func() { cfg = config{} }()
+
+	zones, err = templateZones(template)
+	if err != nil {
+		t.Fatalf("templateZones() failed: %s", err.Error())
+	}
+
+	if n, _ := zones[0].records.Find(cloudflare.DNSRecord{Type: "NS", Name: "sub.a.example-customers.com", Content: "ns1.example.net", TTL: defaultDelegationTTL}, cfzone.FullMatch); n < 0 {
+		t.Errorf("templateZones() didn't add delegations: %+v", zones[0].records)
+	}
+
+	if len(zones[1].records) != 2 {
+		t.Errorf("templateZones() added delegations to the wrong zone: %+v", zones[1].records)
+	}
+
+	zonePatterns = "*.example.org"
+
+	_, err = templateZones(template)
+	if err == nil || !strings.Contains(err.Error(), "No zones match") {
+		t.Errorf("templateZones() didn't fail without matching zones: %v", err)
+	}
+
+	zonePatterns = "example.com"
+	ioutil.WriteFile(template, []byte("$ORIGIN example.net.\n@ 300 IN SOA ns1.example.net. hostmaster.example.net. 1 86400 7200 604800 86400\n"), 0644)
+
+	_, err = templateZones(template)
+	if err == nil || !strings.Contains(err.Error(), "SOA") {
+		t.Errorf("templateZones() didn't fail for a template with an SOA record: %v", err)
+	}
+}